@@ -1365,6 +1365,13 @@ func findOverrideValue(overrides []string, name string, errorMsg string) (newVal
 	return "", false
 }
 
+// ApexSizeBudgetFor returns the size budget of the APEX set in PRODUCT_APEX_SIZE_BUDGETS, which
+// takes precedence over the size_budget property of the APEX.
+func (c *deviceConfig) ApexSizeBudgetFor(name string) (budget string, overridden bool) {
+	return findOverrideValue(c.config.productVariables.ApexSizeBudgets, name,
+		"invalid rule %q in PRODUCT_APEX_SIZE_BUDGETS should be <apex_name>:<size>")
+}
+
 func (c *deviceConfig) ApexGlobalMinSdkVersionOverride() string {
 	return String(c.config.productVariables.ApexGlobalMinSdkVersionOverride)
 }
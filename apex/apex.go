@@ -52,6 +52,8 @@ func registerApexBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("override_apex", overrideApexFactory)
 	ctx.RegisterModuleType("apex_set", apexSetFactory)
 
+	ctx.RegisterSingletonType("apex_size_report", apexSizeReportSingletonFactory)
+
 	ctx.PreArchMutators(registerPreArchMutators)
 	ctx.PreDepsMutators(RegisterPreDepsMutators)
 	ctx.PostDepsMutators(RegisterPostDepsMutators)
@@ -344,6 +346,12 @@ type overridableProperties struct {
 	// conditions, e.g., target device needs to support APEX compression, are also fulfilled.
 	// Default: false.
 	Compressible *bool
+
+	// Maximum size of the APEX file, either in bytes or with a K, M or G suffix, e.g. "24M". When
+	// the APEX is larger than this, the build fails with a breakdown of the largest modules in the
+	// APEX. Only image APEXes are checked. An entry for this APEX in PRODUCT_APEX_SIZE_BUDGETS
+	// takes precedence over this property.
+	Size_budget *string
 }
 
 type apexBundle struct {
@@ -446,6 +454,14 @@ type apexBundle struct {
 	// Optional list of lint report zip files for apexes that contain java or app modules
 	lintReports android.Paths
 
+	// Dependency info collected by buildApexDependencyInfo. Used to show why modules are included
+	// in the APEX in the size report.
+	depInfos android.DepNameToDepInfoMap
+
+	// JSON report attributing the size of the APEX to the modules in it. Merged into the
+	// tree-wide report by the apex_size_report singleton.
+	sizeReport android.Path
+
 	prebuiltFileToDelete string
 
 	isCompressed bool
@@ -2245,14 +2261,15 @@ func (a *apexBundle) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	////////////////////////////////////////////////////////////////////////////////////////////
-	// 4) generate the build rules to create the APEX. This is done in builder.go.
+	// 4) generate the build rules to create the APEX. This is done in builder.go. The dependency
+	// info is collected first, as the size report of the APEX uses it.
+	a.buildApexDependencyInfo(ctx)
 	a.buildManifest(ctx, provideNativeLibs, requireNativeLibs)
 	if a.properties.ApexType == flattenedApex {
 		a.buildFlattenedApex(ctx)
 	} else {
 		a.buildUnflattenedApex(ctx)
 	}
-	a.buildApexDependencyInfo(ctx)
 	a.buildLintReports(ctx)
 
 	// Append meta-files to the filesInfo list so that they are reflected in Android.mk as well.
@@ -125,3 +125,50 @@ func (s *apexDepsInfoSingleton) MakeVars(ctx android.MakeVarsContext) {
 	// Export check result to Make. The path is added to droidcore.
 	ctx.Strict("APEX_ALLOWED_DEPS_CHECK", s.allowedApexDepsInfoCheckResult.String())
 }
+
+// apexSizeReportSingleton merges the size reports of all APEXes installed on the device into a
+// tree-wide report that lists the APEXes by size, along with their size budgets and their largest
+// modules.
+type apexSizeReportSingleton struct {
+	report     android.Path
+	textReport android.Path
+}
+
+func apexSizeReportSingletonFactory() android.Singleton {
+	return &apexSizeReportSingleton{}
+}
+
+func (s *apexSizeReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if a, ok := module.(*apexBundle); ok && a.sizeReport != nil {
+			reports = append(reports, a.sizeReport)
+		}
+	})
+
+	if len(reports) == 0 {
+		return
+	}
+
+	report := android.PathForOutput(ctx, "apex", "size", "apex_size_report.json")
+	textReport := android.PathForOutput(ctx, "apex", "size", "apex_size_report.txt")
+	rspFile := android.PathForOutput(ctx, "apex", "size", "apex_size_report.rsp")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("apex_size").
+		Text("merge").
+		FlagWithOutput("-o ", report).
+		FlagWithOutput("-text ", textReport).
+		FlagWithRspFileInputList("@", rspFile, android.SortedUniquePaths(reports))
+	rule.Build("apex_size_report", "merge APEX size reports")
+
+	ctx.Phony("apex-size-report", report, textReport)
+	s.report = report
+	s.textReport = textReport
+}
+
+func (s *apexSizeReportSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.report != nil {
+		ctx.DistForGoal("apex-size-report", s.report, s.textReport)
+	}
+}
@@ -113,6 +113,12 @@ func withManifestPackageNameOverrides(specs []string) android.FixturePreparer {
 	})
 }
 
+func withApexSizeBudgets(specs []string) android.FixturePreparer {
+	return android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+		variables.ApexSizeBudgets = specs
+	})
+}
+
 func withApexGlobalMinSdkVersionOverride(minSdkOverride *string) android.FixturePreparer {
 	return android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
 		variables.ApexGlobalMinSdkVersionOverride = minSdkOverride
@@ -8410,6 +8416,131 @@ func TestAllowedFiles(t *testing.T) {
 	}
 }
 
+func TestApexSizeBudget(t *testing.T) {
+	bp := `
+		apex {
+			name: "myapex",
+			key: "myapex.key",
+			native_shared_libs: ["mylib"],
+			size_budget: "24M",
+			updatable: false,
+		}
+
+		apex_key {
+			name: "myapex.key",
+			public_key: "testkey.avbpubkey",
+			private_key: "testkey.pem",
+		}
+
+		cc_library {
+			name: "mylib",
+			srcs: ["mylib.cpp"],
+			shared_libs: ["mylib2"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["//apex_available:platform", "myapex"],
+		}
+
+		cc_library {
+			name: "mylib2",
+			srcs: ["mylib.cpp"],
+			system_shared_libs: [],
+			stl: "none",
+			apex_available: ["//apex_available:platform", "myapex"],
+		}
+	`
+
+	t.Run("property", func(t *testing.T) {
+		ctx := testApex(t, bp, withUnbundledBuild)
+		module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+
+		contents := android.ContentFromFileRuleForTests(t, module.Output("size/contents.json"))
+		ensureContains(t, contents, `"budget":25165824`)
+		ensureContains(t, contents, `"module":"mylib","path":"lib64/mylib.so"`)
+		ensureContains(t, contents, `"module":"mylib2","path":"lib64/mylib2.so"`)
+		ensureContains(t, contents, `"required_by":{"mylib2":["mylib"]}`)
+
+		check := module.Output("size_budget.check")
+		android.AssertPathsRelativeToTopEquals(t, "size budget check inputs",
+			[]string{"out/soong/.intermediates/myapex/android_common_myapex_image/size/report.json"},
+			check.Implicits)
+
+		signed := module.Rule("signapk")
+		android.AssertPathsRelativeToTopEquals(t, "signed apex validations",
+			[]string{"out/soong/.intermediates/myapex/android_common_myapex_image/size_budget.check"},
+			signed.Validations)
+
+		merge := ctx.SingletonForTests("apex_size_report").Output("apex/size/apex_size_report.json")
+		android.AssertStringListContains(t, "apex size report inputs", merge.Inputs.Strings(),
+			"out/soong/.intermediates/myapex/android_common_myapex_image/size/report.json")
+	})
+
+	t.Run("product override", func(t *testing.T) {
+		ctx := testApex(t, bp, withApexSizeBudgets([]string{"myapex:512K"}))
+		module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+
+		contents := android.ContentFromFileRuleForTests(t, module.Output("size/contents.json"))
+		ensureContains(t, contents, `"budget":524288`)
+		module.Output("size_budget.check")
+	})
+
+	t.Run("product override removes budget", func(t *testing.T) {
+		ctx := testApex(t, bp, withApexSizeBudgets([]string{"my%:0"}))
+		module := ctx.ModuleForTests("myapex", "android_common_myapex_image")
+
+		contents := android.ContentFromFileRuleForTests(t, module.Output("size/contents.json"))
+		ensureNotContains(t, contents, `"budget"`)
+		if check := module.MaybeOutput("size_budget.check"); check.Rule != nil {
+			t.Errorf("expected no size budget check, got %q", check.RuleParams.Command)
+		}
+		if signed := module.Rule("signapk"); len(signed.Validations) > 0 {
+			t.Errorf("expected no validations of the signed apex, got %q", signed.Validations.Strings())
+		}
+	})
+
+	t.Run("invalid size", func(t *testing.T) {
+		testApexError(t, `size_budget: "24X" is not a valid size`,
+			strings.Replace(bp, `size_budget: "24M"`, `size_budget: "24X"`, 1))
+	})
+
+	t.Run("invalid product override", func(t *testing.T) {
+		testApexError(t, `invalid size budget in PRODUCT_APEX_SIZE_BUDGETS: "-1" is not a valid size`,
+			bp, withApexSizeBudgets([]string{"myapex:-1"}))
+	})
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		in       string
+		expected int64
+		err      bool
+	}{
+		{in: "0", expected: 0},
+		{in: "1000", expected: 1000},
+		{in: "4K", expected: 4096},
+		{in: "24M", expected: 24 << 20},
+		{in: "2G", expected: 2 << 30},
+		{in: "", err: true},
+		{in: "M", err: true},
+		{in: "1.5M", err: true},
+		{in: "10m", err: true},
+		{in: "-1", err: true},
+		{in: "9223372036854775807G", err: true},
+	}
+	for _, tc := range testCases {
+		size, err := parseSize(tc.in)
+		if tc.err {
+			if err == nil {
+				t.Errorf("parseSize(%q): expected an error, got %d", tc.in, size)
+			}
+		} else if err != nil {
+			t.Errorf("parseSize(%q): unexpected error %s", tc.in, err)
+		} else if size != tc.expected {
+			t.Errorf("parseSize(%q): expected %d, got %d", tc.in, tc.expected, size)
+		}
+	}
+}
+
 func TestNonPreferredPrebuiltDependency(t *testing.T) {
 	testApex(t, `
 		apex {
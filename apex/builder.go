@@ -17,6 +17,7 @@ package apex
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"path/filepath"
 	"runtime"
 	"sort"
@@ -774,6 +775,17 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 	// Step 4: Sign the APEX using signapk
 	signedOutputFile := android.PathForModuleOut(ctx, a.Name()+suffix)
 
+	// The size budget check depends on the final APEX, so it is added as a validation of the
+	// signed APEX to run whenever the APEX is built. The rules for it are created in
+	// buildSizeReport once the final APEX is known.
+	sizeBudget := a.sizeBudget(ctx)
+	var sizeBudgetCheck android.WritablePath
+	var validations android.Paths
+	if apexType == imageApex && sizeBudget > 0 {
+		sizeBudgetCheck = android.PathForModuleOut(ctx, "size_budget.check")
+		validations = append(validations, sizeBudgetCheck)
+	}
+
 	pem, key := a.getCertificateAndPrivateKey(ctx)
 	rule := java.Signapk
 	args := map[string]string{
@@ -792,6 +804,7 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		Output:      signedOutputFile,
 		Input:       unsignedOutputFile,
 		Implicits:   implicits,
+		Validations: validations,
 		Args:        args,
 	})
 	if suffix == imageApexSuffix {
@@ -835,6 +848,10 @@ func (a *apexBundle) buildUnflattenedApex(ctx android.ModuleContext) {
 		a.outputFile = signedCompressedOutputFile
 	}
 
+	if apexType == imageApex {
+		a.buildSizeReport(ctx, sizeBudget, sizeBudgetCheck)
+	}
+
 	installSuffix := suffix
 	if a.isCompressed {
 		installSuffix = imageCapexSuffix
@@ -999,6 +1016,7 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 		return !externalDep
 	})
 
+	a.depInfos = depInfos
 	a.ApexBundleDepsInfo.BuildDepsInfoLists(ctx, a.MinSdkVersion(ctx).Raw, depInfos)
 
 	ctx.Build(pctx, android.BuildParams{
@@ -1011,6 +1029,131 @@ func (a *apexBundle) buildApexDependencyInfo(ctx android.ModuleContext) {
 	})
 }
 
+// sizeBudget returns the size budget of the APEX in bytes, or 0 if it has none. An entry for the
+// APEX in PRODUCT_APEX_SIZE_BUDGETS takes precedence over the size_budget property, so that a
+// product can also remove the budget of an APEX with a size of 0.
+func (a *apexBundle) sizeBudget(ctx android.ModuleContext) int64 {
+	if budget, overridden := ctx.DeviceConfig().ApexSizeBudgetFor(a.Name()); overridden {
+		size, err := parseSize(budget)
+		if err != nil {
+			ctx.ModuleErrorf("invalid size budget in PRODUCT_APEX_SIZE_BUDGETS: %s", err)
+		}
+		return size
+	}
+	if a.overridableProperties.Size_budget != nil {
+		size, err := parseSize(*a.overridableProperties.Size_budget)
+		if err != nil {
+			ctx.PropertyErrorf("size_budget", "%s", err)
+		}
+		return size
+	}
+	return 0
+}
+
+// parseSize parses a size in bytes, optionally with a K, M or G suffix for kibibytes, mebibytes
+// or gibibytes.
+func parseSize(s string) (int64, error) {
+	shift := 0
+	num := s
+	switch {
+	case strings.HasSuffix(s, "K"):
+		shift = 10
+	case strings.HasSuffix(s, "M"):
+		shift = 20
+	case strings.HasSuffix(s, "G"):
+		shift = 30
+	}
+	if shift > 0 {
+		num = s[:len(s)-1]
+	}
+	size, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || size < 0 || size > math.MaxInt64>>shift {
+		return 0, fmt.Errorf("%q is not a valid size, expected a number of bytes optionally followed by K, M or G", s)
+	}
+	return size << shift, nil
+}
+
+// buildSizeReport creates build rules for a report that attributes the size of the APEX to the
+// modules whose files are included in it, along with the modules that require them according to
+// the dependency info of the APEX. When sizeBudgetCheck is not nil, it also creates a rule for it
+// that fails with a breakdown of the largest modules when the APEX is larger than sizeBudget.
+func (a *apexBundle) buildSizeReport(ctx android.ModuleContext, sizeBudget int64, sizeBudgetCheck android.WritablePath) {
+	type contentsFile struct {
+		Module string `json:"module"`
+		Path   string `json:"path"`
+		File   string `json:"file"`
+	}
+	contents := struct {
+		Apex       string              `json:"apex"`
+		Budget     int64               `json:"budget,omitempty"`
+		Files      []contentsFile      `json:"files"`
+		RequiredBy map[string][]string `json:"required_by,omitempty"`
+	}{
+		Apex:       a.Name(),
+		Budget:     sizeBudget,
+		Files:      []contentsFile{},
+		RequiredBy: make(map[string][]string),
+	}
+
+	var builtFiles android.Paths
+	for _, fi := range a.filesInfo {
+		// Files that are symlinks to the system partition don't take space in the APEX.
+		if a.linkToSystemLib && fi.transitiveDep && fi.availableToPlatform() {
+			continue
+		}
+		moduleName := fi.androidMkModuleName
+		if fi.module != nil {
+			moduleName = fi.module.Name()
+		}
+		contents.Files = append(contents.Files, contentsFile{
+			Module: moduleName,
+			Path:   fi.path(),
+			File:   fi.builtFile.String(),
+		})
+		builtFiles = append(builtFiles, fi.builtFile)
+
+		// Direct dependencies are required by the APEX itself, which is left out.
+		if info, ok := a.depInfos[moduleName]; ok {
+			if _, requiredBy := android.RemoveFromList(ctx.ModuleName(), info.From); len(requiredBy) > 0 {
+				contents.RequiredBy[moduleName] = android.SortedUniqueStrings(requiredBy)
+			}
+		}
+	}
+
+	j, err := json.Marshal(contents)
+	if err != nil {
+		panic(fmt.Errorf("error while marshalling to %q: %#v", a.Name(), err))
+	}
+	contentsJson := android.PathForModuleOut(ctx, "size", "contents.json")
+	android.WriteFileRule(ctx, contentsJson, string(j))
+
+	report := android.PathForModuleOut(ctx, "size", "report.json")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		BuiltTool("apex_size").
+		Text("report").
+		FlagWithInput("-apex ", a.outputFile).
+		FlagWithInput("-contents ", contentsJson).
+		FlagWithOutput("-o ", report).
+		Implicits(builtFiles)
+	rule.Build("apex_size_report", "APEX size report "+a.Name())
+
+	if sizeBudgetCheck != nil {
+		checkRule := android.NewRuleBuilder(pctx, ctx)
+		checkRule.Command().
+			BuiltTool("apex_size").
+			Text("check").
+			FlagWithOutput("-o ", sizeBudgetCheck).
+			Input(report)
+		checkRule.Build("apex_size_budget", "Check the size budget of "+a.Name())
+	}
+
+	// Only the APEXes that are installed on the device are included in the tree-wide report.
+	if a.primaryApexType && !a.properties.IsCoverageVariant && !ctx.Host() {
+		a.sizeReport = report
+	}
+}
+
 func (a *apexBundle) buildLintReports(ctx android.ModuleContext) {
 	depSetsBuilder := java.NewLintDepSetBuilder()
 	for _, fi := range a.filesInfo {
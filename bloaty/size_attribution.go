@@ -0,0 +1,145 @@
+// Copyright 2022 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bloaty
+
+import (
+	"android/soong/android"
+
+	"github.com/google/blueprint"
+)
+
+const sizeAttributionFilename = "binary_size_attribution.json"
+
+var (
+	sizeAttributionKey blueprint.ProviderKey
+
+	// bloatyCompileUnits measures the sizes of the compile units and symbols of a binary.
+	bloatyCompileUnits = pctx.AndroidStaticRule("bloatyCompileUnits",
+		blueprint.RuleParams{
+			Command:     "${bloaty} -d compileunits,symbols -n 0 --csv ${in} > ${out}",
+			CommandDeps: []string{"${bloaty}"},
+		})
+
+	// sizeAttribution combines the linker map and the bloaty measurements of a binary into a
+	// size attribution report.
+	sizeAttribution = pctx.AndroidStaticRule("sizeAttribution",
+		blueprint.RuleParams{
+			Command: "${sizeAttributionCmd} report -module ${module} -binary ${binary} " +
+				"-map ${linkerMap} -bloaty ${in} -o ${out}",
+			CommandDeps: []string{"${sizeAttributionCmd}"},
+		}, "module", "binary", "linkerMap")
+
+	// sizeAttributionMerger merges the size attribution reports of all binaries.
+	sizeAttributionMerger = pctx.AndroidStaticRule("sizeAttributionMerger",
+		blueprint.RuleParams{
+			Command:        "${sizeAttributionCmd} merge -o ${out} @${out}.rsp",
+			CommandDeps:    []string{"${sizeAttributionCmd}"},
+			Rspfile:        "${out}.rsp",
+			RspfileContent: "${in}",
+		})
+)
+
+func init() {
+	pctx.HostBinToolVariable("sizeAttributionCmd", "size_attribution")
+	android.RegisterSingletonType("size_attribution", sizeAttributionSingletonFactory)
+	sizeAttributionKey = blueprint.NewProvider(sizeAttributionInfo{})
+}
+
+// SizeAttributionEnabled returns true if the linker should write linker maps that are used to
+// attribute the size of binaries to the code linked into them.
+func SizeAttributionEnabled(config android.Config) bool {
+	return config.IsEnvTrue("BINARY_SIZE_ATTRIBUTION")
+}
+
+// sizeAttributionInfo contains the binary linked by a module and the linker map written while
+// linking it.
+type sizeAttributionInfo struct {
+	binary    android.Path
+	linkerMap android.ModuleOutPath
+}
+
+// LinkerMapForSizeAttribution returns the path of the linker map that should be written while
+// linking binary, and registers the binary to be measured by the size attribution singleton. It
+// must only be called once per module and only when SizeAttributionEnabled returns true. The
+// binary must not be stripped, as the compile units are read from its debug info.
+func LinkerMapForSizeAttribution(ctx android.ModuleContext, binary android.Path) android.ModuleOutPath {
+	linkerMap := android.PathForModuleOut(ctx, "size_attribution", binary.Base()+".map")
+	ctx.SetProvider(sizeAttributionKey, sizeAttributionInfo{
+		binary:    binary,
+		linkerMap: linkerMap,
+	})
+	return linkerMap
+}
+
+type sizeAttributionSingleton struct {
+	report android.Path
+}
+
+func sizeAttributionSingletonFactory() android.Singleton {
+	return &sizeAttributionSingleton{}
+}
+
+func (s *sizeAttributionSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var reports android.Paths
+	ctx.VisitAllModules(func(m android.Module) {
+		if !ctx.ModuleHasProvider(m, sizeAttributionKey) {
+			return
+		}
+		info := ctx.ModuleProvider(m, sizeAttributionKey).(sizeAttributionInfo)
+
+		compileUnits := info.linkerMap.InSameDir(ctx, info.binary.Base()+".compileunits.csv")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        bloatyCompileUnits,
+			Description: "bloaty compileunits " + info.binary.Base(),
+			Input:       info.binary,
+			Output:      compileUnits,
+		})
+
+		report := info.linkerMap.InSameDir(ctx, info.binary.Base()+".size.json")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        sizeAttribution,
+			Description: "size attribution " + info.binary.Base(),
+			Input:       compileUnits,
+			Implicit:    info.linkerMap,
+			Output:      report,
+			Args: map[string]string{
+				"module":    ctx.ModuleName(m),
+				"binary":    info.binary.String(),
+				"linkerMap": info.linkerMap.String(),
+			},
+		})
+		reports = append(reports, report)
+	})
+
+	if len(reports) == 0 {
+		return
+	}
+
+	report := android.PathForOutput(ctx, sizeAttributionFilename)
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        sizeAttributionMerger,
+		Description: "merge size attribution reports",
+		Inputs:      android.SortedUniquePaths(reports),
+		Output:      report,
+	})
+	ctx.Phony("size_attribution", report)
+	s.report = report
+}
+
+func (s *sizeAttributionSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if s.report != nil {
+		ctx.DistForGoalWithFilename("size_attribution", s.report, sizeAttributionFilename)
+	}
+}
@@ -22,4 +22,5 @@ import (
 var PrepareForTestWithBloatyDefaultModules = android.GroupFixturePreparers(
 	android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
 		ctx.RegisterSingletonType("file_metrics", fileSizesSingleton)
+		ctx.RegisterSingletonType("size_attribution", sizeAttributionSingletonFactory)
 	}))
@@ -0,0 +1,127 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package removedeps removes dependencies from the list properties of modules in Android.bp files,
+// for the tools that find the unused dependencies of modules.
+package removedeps
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/google/blueprint/parser"
+)
+
+// Dependency is a dependency of a module that is listed in one of its list properties.
+type Dependency struct {
+	Name string
+	// Property is the name of the list property that lists the dependency.
+	Property string
+}
+
+// RemoveDeps removes dependencies of the modules defined in a blueprint file, and returns the
+// reformatted file. deps maps module names to the dependencies to remove from them. Only
+// dependencies listed as literal strings in the top level properties are removed; the ones listed
+// in arch or target specific properties, in defaults modules, or through variables, are left for a
+// manual fix.
+func RemoveDeps(filename string, input []byte, deps map[string][]Dependency) ([]byte, error) {
+	tree, errs := parser.Parse(filename, bytes.NewReader(input), parser.NewScope(nil))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, errs)
+	}
+
+	for _, def := range tree.Defs {
+		mod, ok := def.(*parser.Module)
+		if !ok {
+			continue
+		}
+		nameProp, ok := mod.GetProperty("name")
+		if !ok {
+			continue
+		}
+		name, ok := nameProp.Value.(*parser.String)
+		if !ok {
+			continue
+		}
+
+		for _, dep := range deps[name.Value] {
+			RemoveFromListProperty(mod, dep.Property, dep.Name)
+		}
+	}
+
+	return parser.Print(tree)
+}
+
+// RemoveFromListProperty removes a string from a list property of a module, and removes the
+// property if the list becomes empty.
+func RemoveFromListProperty(mod *parser.Module, property string, value string) {
+	prop, ok := mod.GetProperty(property)
+	if !ok {
+		return
+	}
+	list, ok := prop.Value.(*parser.List)
+	if !ok {
+		return
+	}
+
+	newValues := []parser.Expression{}
+	for _, v := range list.Values {
+		if s, ok := v.(*parser.String); ok && s.Value == value {
+			continue
+		}
+		newValues = append(newValues, v)
+	}
+
+	if len(newValues) == 0 {
+		newProperties := make([]*parser.Property, 0, len(mod.Properties))
+		for _, p := range mod.Properties {
+			if p != prop {
+				newProperties = append(newProperties, p)
+			}
+		}
+		mod.Properties = newProperties
+	} else {
+		list.Values = newValues
+	}
+}
+
+// FixBlueprints removes dependencies of modules from the blueprint files that define them, and
+// rewrites the files that changed. deps maps blueprint files to the names of the modules defined
+// in them, and those to the dependencies to remove.
+func FixBlueprints(deps map[string]map[string][]Dependency) error {
+	var files []string
+	for file := range deps {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+
+	for _, file := range files {
+		input, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		output, err := RemoveDeps(file, input, deps[file])
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(input, output) {
+			if err := ioutil.WriteFile(file, output, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package removedeps
+
+import (
+	"testing"
+)
+
+func TestRemoveDeps(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		deps  map[string][]Dependency
+		want  string
+	}{
+		{
+			name: "cc",
+			input: `cc_binary {
+    name: "foo",
+    srcs: ["foo.cpp"],
+    shared_libs: [
+        "libused",
+        "libunused",
+    ],
+    static_libs: ["libstatic_unused"],
+    header_libs: ["libheader"],
+    arch: {
+        arm: {
+            shared_libs: ["libunused"],
+        },
+    },
+}
+
+cc_binary {
+    name: "bar",
+    shared_libs: ["libunused"],
+}
+`,
+			deps: map[string][]Dependency{
+				"foo": {
+					{Name: "libunused", Property: "shared_libs"},
+					{Name: "libstatic_unused", Property: "static_libs"},
+				},
+			},
+			want: `cc_binary {
+    name: "foo",
+    srcs: ["foo.cpp"],
+    shared_libs: [
+        "libused",
+    ],
+    header_libs: ["libheader"],
+    arch: {
+        arm: {
+            shared_libs: ["libunused"],
+        },
+    },
+}
+
+cc_binary {
+    name: "bar",
+    shared_libs: ["libunused"],
+}
+`,
+		},
+		{
+			name: "java",
+			input: `java_library {
+    name: "foo",
+    srcs: ["Foo.java"],
+    libs: [
+        "used",
+        "unused",
+    ],
+    static_libs: ["static_unused"],
+    target: {
+        host: {
+            libs: ["unused"],
+        },
+    },
+}
+
+java_library {
+    name: "bar",
+    libs: ["unused"],
+}
+`,
+			deps: map[string][]Dependency{
+				"foo": {
+					{Name: "unused", Property: "libs"},
+					{Name: "static_unused", Property: "static_libs"},
+				},
+			},
+			want: `java_library {
+    name: "foo",
+    srcs: ["Foo.java"],
+    libs: [
+        "used",
+    ],
+    target: {
+        host: {
+            libs: ["unused"],
+        },
+    },
+}
+
+java_library {
+    name: "bar",
+    libs: ["unused"],
+}
+`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := RemoveDeps("Android.bp", []byte(tc.input), tc.deps)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("incorrect output\nwant:\n%s\n got:\n%s", tc.want, string(got))
+			}
+		})
+	}
+}
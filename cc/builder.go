@@ -28,6 +28,7 @@ import (
 	"github.com/google/blueprint/pathtools"
 
 	"android/soong/android"
+	"android/soong/bloaty"
 	"android/soong/cc/config"
 	"android/soong/remoteexec"
 )
@@ -804,6 +805,21 @@ func transformObjToStaticLib(ctx android.ModuleContext,
 	}
 }
 
+// Request a linker map for outputFile when binary size attribution is enabled, so that its size can
+// be attributed to the object files and static libraries linked into it. Returns the linker map to
+// be added to the implicit outputs of the link rule.
+func linkerMapForSizeAttribution(ctx android.ModuleContext, flags *builderFlags,
+	outputFile android.WritablePath) android.WritablePaths {
+
+	if !bloaty.SizeAttributionEnabled(ctx.Config()) || ctx.Darwin() || ctx.Windows() {
+		return nil
+	}
+
+	linkerMap := bloaty.LinkerMapForSizeAttribution(ctx, outputFile)
+	flags.localLdFlags += " -Wl,-Map=" + linkerMap.String()
+	return android.WritablePaths{linkerMap}
+}
+
 // Generate a rule for compiling multiple .o files, plus static libraries, whole static libraries,
 // and shared libraries, to a shared library (.so) or dynamic executable
 func transformObjToDynamicBinary(ctx android.ModuleContext,
@@ -882,15 +898,24 @@ func transformObjToDynamicBinary(ctx android.ModuleContext,
 }
 
 // Generate a rule to combine .dump sAbi dump files from multiple source files
-// into a single .ldump sAbi dump file
+// into a single .ldump sAbi dump file. soFile may be nil for libraries that are
+// not linked into a shared object.
 func transformDumpToLinkedDump(ctx android.ModuleContext, sAbiDumps android.Paths, soFile android.Path,
 	baseName, exportedHeaderFlags string, symbolFile android.OptionalPath,
 	excludedSymbolVersions, excludedSymbolTags []string) android.OptionalPath {
 
 	outputFile := android.PathForModuleOut(ctx, baseName+".lsdump")
 
-	implicits := android.Paths{soFile}
-	symbolFilterStr := "-so " + soFile.String()
+	var implicits android.Paths
+	var symbolFilterStr string
+	if soFile != nil {
+		implicits = append(implicits, soFile)
+		symbolFilterStr = "-so " + soFile.String()
+	} else {
+		// Static and header-only libraries have no ELF symbols to filter the dump with, so keep
+		// everything that is declared in the exported headers.
+		symbolFilterStr = "-sources-only"
+	}
 
 	if symbolFile.Valid() {
 		implicits = append(implicits, symbolFile.Path())
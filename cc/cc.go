@@ -853,6 +853,9 @@ type Module struct {
 	kytheFiles android.Paths
 	// Object .o file output paths for this compilation module
 	objFiles android.Paths
+	// Library dependencies listed in the Android.bp file, only collected for the unused
+	// dependency report
+	linkDeps []linkDep
 	// Tidy .tidy file output paths for this compilation module
 	tidyFiles android.Paths
 
@@ -2907,6 +2910,10 @@ func (c *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			depPaths.GeneratedDeps = append(depPaths.GeneratedDeps, depExporterInfo.Deps...)
 			depPaths.Flags = append(depPaths.Flags, depExporterInfo.Flags...)
 
+			if unusedDepsEnabled(ctx.Config()) {
+				c.recordLinkDep(depName, libDepTag, linkFile, depExporterInfo)
+			}
+
 			if libDepTag.reexportFlags {
 				reexportExporter(depExporterInfo)
 				// Add these re-exported flags to help header-abi-dumper to infer the abi exported by a library.
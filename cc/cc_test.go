@@ -4196,3 +4196,211 @@ func TestIncludeDirectoryOrdering(t *testing.T) {
 	}
 
 }
+
+func TestUnusedDepsLinkDeps(t *testing.T) {
+	t.Parallel()
+	bp := `
+		cc_binary {
+			name: "foo",
+			srcs: ["foo.cpp"],
+			shared_libs: ["libshared"],
+			static_libs: ["libstatic"],
+			whole_static_libs: ["libwhole"],
+			header_libs: ["libheader"],
+		}
+
+		cc_library_shared {
+			name: "libshared",
+		}
+
+		cc_library_static {
+			name: "libstatic",
+			export_include_dirs: ["static_include"],
+		}
+
+		cc_library_static {
+			name: "libwhole",
+		}
+
+		cc_library_headers {
+			name: "libheader",
+			export_include_dirs: ["header_include"],
+		}
+	`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_CC_UNUSED_DEPS": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a").Module().(*Module)
+
+	var got []string
+	for _, dep := range foo.linkDeps {
+		got = append(got, dep.kind+":"+dep.name)
+	}
+	// whole_static_libs and the implicit system shared libraries are not reported.
+	android.AssertArrayString(t, "link deps", []string{
+		"header_libs:libheader",
+		"shared_libs:libshared",
+		"static_libs:libstatic",
+	}, android.SortedUniqueStrings(got))
+
+	for _, dep := range foo.linkDeps {
+		switch dep.kind {
+		case "shared_libs":
+			android.AssertPathRelativeToTopEquals(t, "shared library",
+				"out/soong/.intermediates/libshared/android_arm64_armv8-a_shared/libshared.so", dep.library.Path())
+		case "static_libs":
+			android.AssertPathsRelativeToTopEquals(t, "static library include dirs",
+				[]string{"static_include"}, dep.exporter.IncludeDirs)
+		}
+	}
+}
+
+func TestHostNativeCoverageReport(t *testing.T) {
+	t.Parallel()
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_HOST_NATIVE_COVERAGE":      "true",
+			"SOONG_HOST_NATIVE_COVERAGE_DATA": "coverage",
+		}),
+		android.FixtureModifyProductVariables(func(variables android.FixtureProductVariables) {
+			variables.ClangCoverage = BoolPtr(true)
+			variables.Native_coverage = BoolPtr(true)
+			variables.NativeCoveragePaths = []string{"foo"}
+		}),
+		android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+			ctx.RegisterSingletonType("native_coverage_report", nativeCoverageReportSingletonFactory)
+		}),
+		android.FixtureAddTextFile("foo/Android.bp", `
+			cc_test_host {
+				name: "foo_test",
+				srcs: ["foo_test.cpp"],
+				gtest: false,
+				compile_multilib: "both",
+			}
+
+			cc_test_host {
+				name: "bar_test",
+				srcs: ["bar_test.cpp"],
+				gtest: false,
+			}
+		`),
+		android.FixtureAddFile("coverage/foo_test/1-2.profraw", nil),
+		android.FixtureAddFile("coverage/foo_test/3-4.profraw", nil),
+	).RunTest(t)
+
+	variant := "linux_glibc_x86_64_cov"
+	fooTest := result.ModuleForTests("foo_test", variant)
+	cFlags := fooTest.Rule("cc").Args["cFlags"]
+	android.AssertStringDoesContain(t, "cflags", cFlags, "-fprofile-instr-generate -fcoverage-mapping")
+	android.AssertStringDoesNotContain(t, "cflags", cFlags, "/data/misc/trace")
+	android.AssertStringDoesContain(t, "ldflags", fooTest.Rule("ld").Args["ldFlags"],
+		"${config.ClangAsanLibDir}/libclang_rt.profile-x86_64.a")
+
+	binary := fooTest.Module().(*Module).CoverageReportTestBinary()
+	android.AssertBoolEquals(t, "foo_test has a coverage report", true, binary.Valid())
+
+	singleton := result.SingletonForTests("native_coverage_report")
+	profile := singleton.Output("native_coverage/modules/foo_test/" + variant + "/coverage.profdata")
+	android.AssertPathsRelativeToTopEquals(t, "profiles",
+		[]string{"coverage/foo_test/1-2.profraw", "coverage/foo_test/3-4.profraw"}, profile.Inputs)
+
+	// bar_test has no profiles, it is left out of the reports.
+	android.AssertBoolEquals(t, "bar_test has a coverage report", false,
+		singleton.MaybeOutput("native_coverage/modules/bar_test/"+variant+"/coverage.profdata").Rule != nil)
+
+	// Both variants of foo_test have a report from the same profiles.
+	variant32 := "linux_glibc_x86_cov"
+	binary32 := result.ModuleForTests("foo_test", variant32).Module().(*Module).CoverageReportTestBinary()
+	android.AssertBoolEquals(t, "32-bit foo_test has a coverage report", true, binary32.Valid())
+	profile32 := singleton.Output("native_coverage/modules/foo_test/" + variant32 + "/coverage.profdata")
+	android.AssertPathsRelativeToTopEquals(t, "32-bit profiles",
+		[]string{"coverage/foo_test/1-2.profraw", "coverage/foo_test/3-4.profraw"}, profile32.Inputs)
+
+	// The profiles of foo_test are merged only once into the directory profile.
+	dirProfile := singleton.Output("native_coverage/dirs/foo/coverage.profdata")
+	android.AssertPathsRelativeToTopEquals(t, "directory profiles",
+		[]string{"coverage/foo_test/1-2.profraw", "coverage/foo_test/3-4.profraw"}, dirProfile.Inputs)
+
+	report := singleton.Output("native_coverage/dirs/foo/coverage.html.zip")
+	android.AssertStringDoesContain(t, "report objects", report.Args["objects"], binary.Path().String())
+	android.AssertStringDoesContain(t, "report objects", report.Args["objects"], binary32.Path().String())
+	android.AssertPathRelativeToTopEquals(t, "lcov report",
+		"out/soong/native_coverage/dirs/foo/coverage.lcov", report.ImplicitOutput)
+}
+
+func TestCcBinarySizeAttribution(t *testing.T) {
+	bp := `
+cc_binary {
+	name: "foo",
+	srcs: ["foo.cc"],
+}`
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeEnv(map[string]string{
+			"BINARY_SIZE_ATTRIBUTION": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_arm64_armv8-a")
+	linkerMap := foo.Output("size_attribution/foo.map")
+	link := foo.Rule("ld")
+	android.AssertStringListContains(t, "implicit outputs", link.ImplicitOutputs.Strings(),
+		linkerMap.Output.String())
+	android.AssertStringDoesContain(t, "ldFlags", link.Args["ldFlags"],
+		"-Wl,-Map="+linkerMap.Output.String())
+}
+
+func TestCcIncludeGraph(t *testing.T) {
+	t.Parallel()
+	bp := `
+		genrule {
+			name: "genrule_foo",
+			cmd: "generate-foo",
+			out: ["generated_headers/foo/generated_header.h"],
+		}
+
+		cc_library {
+			name: "libfoo",
+			srcs: ["foo.cpp"],
+			export_include_dirs: ["include"],
+			export_system_include_dirs: ["system_include"],
+			generated_headers: ["genrule_foo"],
+			export_generated_headers: ["genrule_foo"],
+		}
+	`
+	registerIncludeGraph := android.FixtureRegisterWithContext(func(ctx android.RegistrationContext) {
+		ctx.RegisterSingletonType("cc_include_graph", ccIncludeGraphSingletonFactory)
+	})
+
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		registerIncludeGraph,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_COLLECT_CC_INCLUDE_GRAPH": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	singleton := result.SingletonForTests("cc_include_graph").Singleton().(*ccIncludeGraphSingleton)
+	libfoo, ok := singleton.modules.Modules["libfoo"]
+	android.AssertBoolEquals(t, "libfoo is in the include graph", true, ok)
+	android.AssertStringEquals(t, "libfoo path", "", libfoo.Path)
+	android.AssertDeepEquals(t, "libfoo exported include dirs",
+		[]string{"include", "system_include"}, libfoo.Export_include_dirs)
+	android.AssertIntEquals(t, "libfoo exported generated headers", 1, len(libfoo.Export_generated_headers))
+	android.AssertStringDoesContain(t, "libfoo exported generated header",
+		libfoo.Export_generated_headers[0], "genrule_foo/gen/generated_headers/foo/generated_header.h")
+
+	result = android.GroupFixturePreparers(
+		prepareForCcTest,
+		registerIncludeGraph,
+	).RunTestWithBp(t, bp)
+
+	singleton = result.SingletonForTests("cc_include_graph").Singleton().(*ccIncludeGraphSingleton)
+	android.AssertBoolEquals(t, "include graph written without SOONG_COLLECT_CC_INCLUDE_GRAPH", false,
+		singleton.outputPath != nil)
+}
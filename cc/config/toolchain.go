@@ -229,6 +229,10 @@ func BuiltinsRuntimeLibrary(t Toolchain) string {
 	return LibclangRuntimeLibrary(t, "builtins")
 }
 
+func ProfileRuntimeLibrary(t Toolchain) string {
+	return LibclangRuntimeLibrary(t, "profile")
+}
+
 func AddressSanitizerRuntimeLibrary(t Toolchain) string {
 	return LibclangRuntimeLibrary(t, "asan")
 }
@@ -253,6 +257,10 @@ func ThreadSanitizerRuntimeLibrary(t Toolchain) string {
 	return LibclangRuntimeLibrary(t, "tsan")
 }
 
+func MemorySanitizerRuntimeLibrary(t Toolchain) string {
+	return LibclangRuntimeLibrary(t, "msan")
+}
+
 func ScudoRuntimeLibrary(t Toolchain) string {
 	return LibclangRuntimeLibrary(t, "scudo")
 }
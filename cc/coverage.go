@@ -20,10 +20,14 @@ import (
 	"github.com/google/blueprint"
 
 	"android/soong/android"
+	"android/soong/cc/config"
 )
 
 const profileInstrFlag = "-fprofile-instr-generate=/data/misc/trace/clang-%p-%m.profraw"
 
+// Host tests write their profiles to the path in LLVM_PROFILE_FILE, see coverage_report.go.
+const hostProfileInstrFlag = "-fprofile-instr-generate"
+
 type CoverageProperties struct {
 	Native_coverage *bool
 
@@ -66,7 +70,9 @@ func getClangProfileLibraryName(ctx ModuleContextIntf) string {
 }
 
 func (cov *coverage) deps(ctx DepsContext, deps Deps) Deps {
-	if cov.Properties.NeedCoverageVariant {
+	// The profile libraries redirect the profiles to the device, host modules link the profile
+	// runtime directly.
+	if cov.Properties.NeedCoverageVariant && ctx.Device() {
 		ctx.AddVariationDependencies([]blueprint.Variation{
 			{Mutator: "link", Variation: "static"},
 		}, CoverageDepTag, getGcovProfileLibraryName(ctx))
@@ -81,6 +87,20 @@ func EnableContinuousCoverage(ctx android.BaseModuleContext) bool {
 	return ctx.DeviceConfig().ClangCoverageContinuousMode()
 }
 
+// HostCoverageEnabled returns true when host modules are built with clang coverage along with
+// device modules, for the native coverage report of the host tests.
+func HostCoverageEnabled(ctx android.BaseModuleContext) bool {
+	return hostCoverageReportEnabled(ctx.Config()) &&
+		ctx.DeviceConfig().ClangCoverageEnabled() && !ctx.DeviceConfig().GcovCoverageEnabled()
+}
+
+// HostProfileRuntimeLibrary returns the clang profile runtime of a host toolchain, in
+// ${config.ClangAsanLibDir}. It must be linked explicitly as -nodefaultlibs prevents the driver from
+// linking it.
+func HostProfileRuntimeLibrary(t config.Toolchain) string {
+	return config.ProfileRuntimeLibrary(t) + "-" + t.LibclangRuntimeLibraryArch() + ".a"
+}
+
 func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags, PathDeps) {
 	clangCoverage := ctx.DeviceConfig().ClangCoverageEnabled()
 	gcovCoverage := ctx.DeviceConfig().GcovCoverageEnabled()
@@ -100,7 +120,11 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 			// flags that the module may use.
 			flags.Local.CFlags = append(flags.Local.CFlags, "-Wno-frame-larger-than=", "-O0")
 		} else if clangCoverage {
-			flags.Local.CommonFlags = append(flags.Local.CommonFlags, profileInstrFlag,
+			instrFlag := profileInstrFlag
+			if ctx.Host() {
+				instrFlag = hostProfileInstrFlag
+			}
+			flags.Local.CommonFlags = append(flags.Local.CommonFlags, instrFlag,
 				"-fcoverage-mapping", "-Wno-pass-failed", "-D__ANDROID_CLANG_COVERAGE__")
 			// Override -Wframe-larger-than.  We can expect frame size increase after
 			// coverage instrumentation.
@@ -157,6 +181,9 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 			deps.WholeStaticLibs = append(deps.WholeStaticLibs, coverage.OutputFile().Path())
 
 			flags.Local.LdFlags = append(flags.Local.LdFlags, "-Wl,--wrap,getenv")
+		} else if clangCoverage && ctx.Host() {
+			flags.Local.LdFlags = append(flags.Local.LdFlags, hostProfileInstrFlag,
+				"${config.ClangAsanLibDir}/"+HostProfileRuntimeLibrary(ctx.toolchain()))
 		} else if clangCoverage {
 			flags.Local.LdFlags = append(flags.Local.LdFlags, profileInstrFlag)
 			if EnableContinuousCoverage(ctx) {
@@ -174,8 +201,9 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 
 func (cov *coverage) begin(ctx BaseModuleContext) {
 	if ctx.Host() {
-		// TODO(dwillemsen): because of -nodefaultlibs, we must depend on libclang_rt.profile-*.a
-		// Just turn off for now.
+		if HostCoverageEnabled(ctx) {
+			cov.Properties = SetCoverageProperties(ctx, cov.Properties, ctx.nativeCoverage(), false, "")
+		}
 	} else {
 		cov.Properties = SetCoverageProperties(ctx, cov.Properties, ctx.nativeCoverage(), ctx.useSdk(), ctx.sdkVersion())
 	}
@@ -0,0 +1,220 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This singleton writes the native coverage reports of the host tests built with clang coverage,
+// whether they are written in C++ or Rust. SOONG_HOST_NATIVE_COVERAGE builds the host tests in
+// NATIVE_COVERAGE_PATHS with clang coverage, and SOONG_HOST_NATIVE_COVERAGE_DATA is a directory in
+// the source tree that contains the profiles (.profraw) written by runs of those tests, in a
+// subdirectory named after each test module, e.g. with LLVM_PROFILE_FILE=<dir>/<module>/%p-%m.profraw.
+// The profiles of each test module are merged with llvm-profdata, and llvm-cov writes lcov and HTML
+// reports for each variant of each test module and for each directory that contains test modules,
+// from the profiles of the tests in it. The reports are generated in
+// $OUT/soong/native_coverage/{modules/<module>/<variant>,dirs/<dir>} by the native-coverage-report
+// goal:
+//
+//	SOONG_HOST_NATIVE_COVERAGE=true CLANG_COVERAGE=true NATIVE_COVERAGE_PATHS=foo \
+//	    SOONG_HOST_NATIVE_COVERAGE_DATA=coverage/foo m native-coverage-report
+
+func init() {
+	android.RegisterSingletonType("native_coverage_report", nativeCoverageReportSingletonFactory)
+	pctx.SourcePathVariable("llvmProfdataCmd", "${config.ClangBin}/llvm-profdata")
+	pctx.SourcePathVariable("llvmCovCmd", "${config.ClangBin}/llvm-cov")
+}
+
+var (
+	coverageMergeProfiles = pctx.AndroidStaticRule("coverageMergeProfiles",
+		blueprint.RuleParams{
+			Command:     "$llvmProfdataCmd merge -sparse -o $out $in",
+			CommandDeps: []string{"$llvmProfdataCmd"},
+		})
+
+	// coverageReport writes the lcov report and the zipped HTML report of test binaries from their
+	// merged profile.
+	coverageReport = pctx.AndroidStaticRule("coverageReport",
+		blueprint.RuleParams{
+			Command: "$llvmCovCmd export -format=lcov -instr-profile=$in $objects > $lcov && " +
+				"rm -rf $htmlDir && $llvmCovCmd show -format=html -instr-profile=$in -output-dir=$htmlDir $objects && " +
+				"${SoongZipCmd} -o $out -C $htmlDir -D $htmlDir",
+			CommandDeps: []string{"$llvmCovCmd", "${SoongZipCmd}"},
+		},
+		"objects", "lcov", "htmlDir")
+)
+
+func hostCoverageReportEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_HOST_NATIVE_COVERAGE")
+}
+
+func hostCoverageReportDataDir(config android.Config) string {
+	return config.Getenv("SOONG_HOST_NATIVE_COVERAGE_DATA")
+}
+
+// CoverageReportTest is implemented by the test modules of all languages that can be part of the
+// native coverage report.
+type CoverageReportTest interface {
+	android.Module
+
+	// CoverageReportTestBinary returns the unstripped test binary if the module is a host test
+	// built with clang coverage.
+	CoverageReportTestBinary() android.OptionalPath
+}
+
+var _ CoverageReportTest = (*Module)(nil)
+
+// CoverageReportTestBinary returns the test binary of host tests built with coverage for the native
+// coverage report.
+func (c *Module) CoverageReportTestBinary() android.OptionalPath {
+	if !c.Host() || !c.testBinary() || c.coverage == nil || !c.coverage.Properties.CoverageEnabled ||
+		c.UnstrippedOutputFile() == nil {
+		return android.OptionalPath{}
+	}
+	return android.OptionalPathForPath(c.UnstrippedOutputFile())
+}
+
+func nativeCoverageReportSingletonFactory() android.Singleton {
+	return &nativeCoverageReportSingleton{}
+}
+
+type nativeCoverageReportSingleton struct{}
+
+// buildCoverageReport writes the lcov and HTML reports of binaries in dir, from their merged
+// profile.
+func buildCoverageReport(ctx android.SingletonContext, dir android.OutputPath, desc string,
+	profile android.Path, binaries android.Paths) android.Paths {
+
+	objects := []string{binaries[0].String()}
+	for _, binary := range binaries[1:] {
+		objects = append(objects, "-object", binary.String())
+	}
+
+	lcov := dir.Join(ctx, "coverage.lcov")
+	html := dir.Join(ctx, "coverage.html.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           coverageReport,
+		Description:    "coverage report " + desc,
+		Output:         html,
+		ImplicitOutput: lcov,
+		Input:          profile,
+		Implicits:      binaries,
+		Args: map[string]string{
+			"objects": strings.Join(objects, " "),
+			"lcov":    lcov.String(),
+			"htmlDir": dir.Join(ctx, "html").String(),
+		},
+	})
+	return android.Paths{lcov, html}
+}
+
+func (n *nativeCoverageReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	dataDir := hostCoverageReportDataDir(ctx.Config())
+	if !hostCoverageReportEnabled(ctx.Config()) || dataDir == "" {
+		return
+	}
+
+	profraws, err := ctx.GlobWithDeps(filepath.Join(dataDir, "**/*.profraw"), nil)
+	if err != nil {
+		ctx.Errorf("native coverage report: %s", err)
+		return
+	}
+	if len(profraws) == 0 {
+		ctx.Errorf("native coverage report: no profiles (.profraw) found in SOONG_HOST_NATIVE_COVERAGE_DATA=%s", dataDir)
+		return
+	}
+
+	// The profiles of each test module are in a subdirectory named after it.
+	moduleProfraws := make(map[string][]string)
+	for _, profraw := range profraws {
+		rel, err := filepath.Rel(dataDir, profraw)
+		if err != nil {
+			ctx.Errorf("native coverage report: %s", err)
+			return
+		}
+		if i := strings.IndexRune(rel, filepath.Separator); i > 0 {
+			name := rel[:i]
+			moduleProfraws[name] = append(moduleProfraws[name], profraw)
+		}
+	}
+
+	var reports android.Paths
+	dirProfraws := make(map[string]android.Paths)
+	dirBinaries := make(map[string]android.Paths)
+	dirModules := make(map[string]map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		test, ok := module.(CoverageReportTest)
+		if !ok || !module.Enabled() {
+			return
+		}
+		binary := test.CoverageReportTestBinary()
+		if !binary.Valid() {
+			return
+		}
+
+		name := ctx.ModuleName(module)
+		if len(moduleProfraws[name]) == 0 {
+			return
+		}
+		profraws := android.PathsForSource(ctx, moduleProfraws[name])
+		dir := android.PathForOutput(ctx, "native_coverage", "modules", name, ctx.ModuleSubDir(module))
+		profile := dir.Join(ctx, "coverage.profdata")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        coverageMergeProfiles,
+			Description: "coverage merge " + name,
+			Output:      profile,
+			Inputs:      profraws,
+		})
+		reports = append(reports, buildCoverageReport(ctx, dir, name, profile, android.Paths{binary.Path()})...)
+
+		// The profiles are shared by all the variants of a module, only merge them once into the
+		// directory profile so that they are not counted once per variant.
+		moduleDir := ctx.ModuleDir(module)
+		if dirModules[moduleDir] == nil {
+			dirModules[moduleDir] = make(map[string]bool)
+		}
+		if !dirModules[moduleDir][name] {
+			dirModules[moduleDir][name] = true
+			dirProfraws[moduleDir] = append(dirProfraws[moduleDir], profraws...)
+		}
+		dirBinaries[moduleDir] = append(dirBinaries[moduleDir], binary.Path())
+	})
+
+	var dirs []string
+	for dir := range dirProfraws {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, moduleDir := range dirs {
+		dir := android.PathForOutput(ctx, "native_coverage", "dirs", moduleDir)
+		profile := dir.Join(ctx, "coverage.profdata")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        coverageMergeProfiles,
+			Description: "coverage merge " + moduleDir,
+			Output:      profile,
+			Inputs:      dirProfraws[moduleDir],
+		})
+		reports = append(reports, buildCoverageReport(ctx, dir, moduleDir, profile, dirBinaries[moduleDir])...)
+	}
+
+	ctx.Phony("native-coverage-report", reports...)
+}
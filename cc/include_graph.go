@@ -0,0 +1,120 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+
+	"android/soong/android"
+)
+
+// This singleton collects the headers exported by cc modules into a json file. It is used by the
+// include_graph tool to map the headers listed in the ninja deps log back to the modules that
+// export them, and the object files listed there back to the modules that compiled them.
+// The info file is generated in $OUT/soong/module_bp_cc_include_graph.json when
+// SOONG_COLLECT_CC_INCLUDE_GRAPH is set:
+//
+//	SOONG_COLLECT_CC_INCLUDE_GRAPH=true m nothing
+
+func init() {
+	android.RegisterSingletonType("cc_include_graph", ccIncludeGraphSingletonFactory)
+}
+
+func ccIncludeGraphSingletonFactory() android.Singleton {
+	return &ccIncludeGraphSingleton{}
+}
+
+type ccIncludeGraphSingleton struct {
+	outputPath android.Path
+	modules    ccIncludeGraphModules
+}
+
+const ccIncludeGraphJsonFileName = "module_bp_cc_include_graph.json"
+
+type ccIncludeGraphModule struct {
+	// Directory of the Android.bp file that defines the module.
+	Path string `json:"path"`
+	// Include directories exported by any variant of the module, with -I or -isystem.
+	Export_include_dirs []string `json:"export_include_dirs,omitempty"`
+	// Generated headers exported by any variant of the module.
+	Export_generated_headers []string `json:"export_generated_headers,omitempty"`
+}
+
+type ccIncludeGraphModules struct {
+	Modules map[string]ccIncludeGraphModule `json:"modules"`
+}
+
+func ccIncludeGraphEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_COLLECT_CC_INCLUDE_GRAPH")
+}
+
+func (c *ccIncludeGraphSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ccIncludeGraphEnabled(ctx.Config()) {
+		return
+	}
+
+	modules := ccIncludeGraphModules{Modules: map[string]ccIncludeGraphModule{}}
+
+	ctx.VisitAllModules(func(module android.Module) {
+		ccModule, ok := module.(*Module)
+		if !ok || !ccModule.Enabled() {
+			return
+		}
+
+		name := ctx.ModuleName(module)
+		info := modules.Modules[name]
+		info.Path = ctx.ModuleDir(module)
+
+		if ctx.ModuleHasProvider(module, FlagExporterInfoProvider) {
+			exported := ctx.ModuleProvider(module, FlagExporterInfoProvider).(FlagExporterInfo)
+			info.Export_include_dirs = append(info.Export_include_dirs, exported.IncludeDirs.Strings()...)
+			info.Export_include_dirs = append(info.Export_include_dirs, exported.SystemIncludeDirs.Strings()...)
+			info.Export_generated_headers = append(info.Export_generated_headers,
+				exported.GeneratedHeaders.Strings()...)
+		}
+		info.Export_include_dirs = android.FirstUniqueStrings(info.Export_include_dirs)
+		info.Export_generated_headers = android.FirstUniqueStrings(info.Export_generated_headers)
+
+		modules.Modules[name] = info
+	})
+
+	buf, err := json.MarshalIndent(modules, "", "\t")
+	if err != nil {
+		ctx.Errorf("JSON marshal of cc include graph failed: %s", err)
+		return
+	}
+
+	outputPath := android.PathForOutput(ctx, ccIncludeGraphJsonFileName)
+	if err := android.WriteFileToOutputDir(outputPath, buf, 0666); err != nil {
+		ctx.Errorf("Writing cc include graph to %s failed: %s", outputPath.String(), err)
+		return
+	}
+	c.outputPath = outputPath
+	c.modules = modules
+
+	// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Touch,
+		Output: outputPath,
+	})
+}
+
+func (c *ccIncludeGraphSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if c.outputPath == nil {
+		return
+	}
+
+	ctx.DistForGoal("include_graph", c.outputPath)
+}
@@ -94,7 +94,9 @@ type LibraryProperties struct {
 
 	// Properties for ABI compatibility checker
 	Header_abi_checker struct {
-		// Enable ABI checks (even if this is not an LLNDK/VNDK lib)
+		// Enable ABI checks (even if this is not an LLNDK/VNDK lib). Static libraries and
+		// header-only libraries that don't have a shared variant are checked against the
+		// exported headers only.
 		Enabled *bool
 
 		// Path to a symbol file that specifies the symbols to be included in the generated
@@ -113,6 +115,11 @@ type LibraryProperties struct {
 
 		// Extra flags passed to header-abi-diff
 		Diff_flags []string
+
+		// Headers of a header-only library that are left out of its ABI dump, for example
+		// headers that can't be compiled on their own. The paths are globs relative to the
+		// exported include directories, e.g. "internal/**/*".
+		Exclude_headers []string
 	}
 
 	// Inject boringssl hash into the shared library.  This is only intended for use by external/boringssl.
@@ -1052,18 +1059,6 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 		return objs
 	}
 
-	if !library.buildShared() && !library.buildStatic() {
-		if len(library.baseCompiler.Properties.Srcs) > 0 {
-			ctx.PropertyErrorf("srcs", "cc_library_headers must not have any srcs")
-		}
-		if len(library.StaticProperties.Static.Srcs) > 0 {
-			ctx.PropertyErrorf("static.srcs", "cc_library_headers must not have any srcs")
-		}
-		if len(library.SharedProperties.Shared.Srcs) > 0 {
-			ctx.PropertyErrorf("shared.srcs", "cc_library_headers must not have any srcs")
-		}
-		return Objects{}
-	}
 	if library.sabi.shouldCreateSourceAbiDump() {
 		exportIncludeDirs := library.flagExporter.exportedIncludes(ctx)
 		var SourceAbiFlags []string
@@ -1080,6 +1075,21 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 			flags.SAbiDump = true
 		}
 	}
+	if !library.buildShared() && !library.buildStatic() {
+		if len(library.baseCompiler.Properties.Srcs) > 0 {
+			ctx.PropertyErrorf("srcs", "cc_library_headers must not have any srcs")
+		}
+		if len(library.StaticProperties.Static.Srcs) > 0 {
+			ctx.PropertyErrorf("static.srcs", "cc_library_headers must not have any srcs")
+		}
+		if len(library.SharedProperties.Shared.Srcs) > 0 {
+			ctx.PropertyErrorf("shared.srcs", "cc_library_headers must not have any srcs")
+		}
+		if library.sabi.shouldCreateSourceAbiDump() {
+			return library.compileHeaderAbiDumpSource(ctx, flags, deps)
+		}
+		return Objects{}
+	}
 	objs := library.baseCompiler.compile(ctx, flags, deps)
 	library.reuseObjects = objs
 	buildFlags := flagsToBuilderFlags(flags)
@@ -1101,6 +1111,77 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 	return objs
 }
 
+// headerAbiDumpExtensions are the extensions of the headers included in the ABI dump of a
+// header-only library.
+var headerAbiDumpExtensions = []string{".h", ".hh", ".hpp", ".hxx", ".h++"}
+
+// extensionlessAbiDumpHeaders are the names of the C++ standard library headers, which are the
+// only headers without an extension included in the ABI dump of a header-only library.
+var extensionlessAbiDumpHeaders = []string{
+	"algorithm", "any", "array", "atomic", "barrier", "bit", "bitset", "cassert", "cctype",
+	"cerrno", "cfenv", "cfloat", "charconv", "chrono", "cinttypes", "climits", "clocale", "cmath",
+	"codecvt", "compare", "complex", "concepts", "condition_variable", "coroutine", "csetjmp",
+	"csignal", "cstdarg", "cstddef", "cstdint", "cstdio", "cstdlib", "cstring", "ctime", "cuchar",
+	"cwchar", "cwctype", "deque", "exception", "execution", "filesystem", "format", "forward_list",
+	"fstream", "functional", "future", "initializer_list", "iomanip", "ios", "iosfwd", "iostream",
+	"istream", "iterator", "latch", "limits", "list", "locale", "map", "memory", "memory_resource",
+	"mutex", "new", "numbers", "numeric", "optional", "ostream", "queue", "random", "ranges",
+	"ratio", "regex", "scoped_allocator", "semaphore", "set", "shared_mutex", "source_location",
+	"span", "sstream", "stack", "stdexcept", "stop_token", "streambuf", "string", "string_view",
+	"syncstream", "system_error", "thread", "tuple", "type_traits", "typeindex", "typeinfo",
+	"unordered_map", "unordered_set", "utility", "valarray", "variant", "vector", "version",
+}
+
+// isAbiDumpHeader returns true if the file is a header that should be included in the ABI dump of
+// a header-only library: a file with one of headerAbiDumpExtensions, or a C++ standard library
+// header without an extension like <vector>. Other headers can be left out with
+// header_abi_checker.exclude_headers.
+func isAbiDumpHeader(path string) bool {
+	base := filepath.Base(path)
+	if ext := filepath.Ext(base); ext != "" {
+		return android.InList(ext, headerAbiDumpExtensions)
+	}
+	return android.InList(base, extensionlessAbiDumpHeaders)
+}
+
+// compileHeaderAbiDumpSource generates a source file that includes every header in the exported
+// include directories of a header-only library, except for the ones listed in
+// header_abi_checker.exclude_headers, and compiles it to create the source ABI dump of the
+// library. Only the ABI dump is returned, the object file is not used.
+func (library *libraryDecorator) compileHeaderAbiDumpSource(ctx ModuleContext, flags Flags, deps PathDeps) Objects {
+	var includes []string
+	for _, dir := range library.flagExporter.exportedIncludes(ctx) {
+		var excludes []string
+		for _, exclude := range library.Properties.Header_abi_checker.Exclude_headers {
+			excludes = append(excludes, filepath.Join(dir.String(), exclude))
+		}
+		for _, header := range ctx.GlobFiles(filepath.Join(dir.String(), "**/*"), excludes) {
+			if !isAbiDumpHeader(header.String()) {
+				continue
+			}
+			relHeader, err := filepath.Rel(dir.String(), header.String())
+			if err != nil {
+				ctx.ModuleErrorf("filepath.Rel(%q, %q) failed: %s", dir.String(), header.String(), err)
+				continue
+			}
+			includes = append(includes, "#include \""+relHeader+"\"")
+		}
+	}
+	if len(includes) == 0 {
+		return Objects{}
+	}
+
+	src := android.PathForModuleGen(ctx, "header_abi_dump", ctx.ModuleName()+".cpp")
+	android.WriteFileRule(ctx, src, strings.Join(includes, "\n"))
+
+	flags.SAbiDump = true
+	pathDeps := append(android.Paths(nil), deps.GeneratedDeps...)
+	pathDeps = append(pathDeps, ndkPathDeps(ctx)...)
+	objs := compileObjs(ctx, flagsToBuilderFlags(flags), "", android.Paths{src}, nil, nil,
+		pathDeps, flags.CFlagsDeps)
+	return Objects{sAbiDumpFiles: objs.sAbiDumpFiles}
+}
+
 type libraryInterface interface {
 	versionedInterface
 
@@ -1377,6 +1458,16 @@ func (library *libraryDecorator) linkStatic(ctx ModuleContext,
 
 	library.coverageOutputFile = transformCoverageFilesToZip(ctx, library.objects, ctx.ModuleName())
 
+	// Static and header-only libraries that are ABI checked on their own link their dumps without
+	// a shared object. Other static libraries only contribute their dumps to shared libraries.
+	if library.headerAbiCheckerEnabled() && !library.buildShared() {
+		abiDumpFileName := fileName
+		if library.header() {
+			abiDumpFileName = ctx.ModuleName()
+		}
+		library.linkSAbiDumpFiles(ctx, library.objects, abiDumpFileName, nil)
+	}
+
 	ctx.CheckbuildFile(outputFile)
 
 	if library.static() {
@@ -1519,6 +1610,12 @@ func (library *libraryDecorator) linkShared(ctx ModuleContext,
 	linkerDeps = append(linkerDeps, deps.EarlySharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.SharedLibsDeps...)
 	linkerDeps = append(linkerDeps, deps.LateSharedLibsDeps...)
+
+	if !library.buildStubs() {
+		implicitOutputs = append(implicitOutputs,
+			linkerMapForSizeAttribution(ctx, &builderFlags, outputFile)...)
+	}
+
 	transformObjToDynamicBinary(ctx, objs.objFiles, sharedLibs,
 		deps.StaticLibs, deps.LateStaticLibs, deps.WholeStaticLibs,
 		linkerDeps, deps.CrtBegin, deps.CrtEnd, false, builderFlags, outputFile, implicitOutputs, objs.tidyDepFiles)
@@ -453,3 +453,61 @@ func TestWholeStaticLibPrebuilts(t *testing.T) {
 	android.AssertStringDoesContain(t, "missing flag for baz.o",
 		libtransitiveWithSrcs.Args["arObjs"], bazObj.Output.String())
 }
+
+func TestHeaderAbiCheckerStaticAndHeaderLibraries(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForCcTest,
+		android.FixtureMergeMockFs(android.MockFS{
+			"include/foo.h":            nil,
+			"include/sub/bar.h":        nil,
+			"include/baz.hpp":          nil,
+			"include/vector":           nil,
+			"include/OWNERS":           nil,
+			"include/readme":           nil,
+			"include/gen_headers":      nil,
+			"include/index.html":       nil,
+			"include/internal/qux.h":   nil,
+			"include/internal/sub/q.h": nil,
+		}),
+	).RunTestWithBp(t, `
+		cc_library_static {
+			name: "libfoo_static",
+			srcs: ["foo.c"],
+			export_include_dirs: ["include"],
+			header_abi_checker: {
+				enabled: true,
+			},
+		}
+
+		cc_library_headers {
+			name: "libfoo_headers",
+			export_include_dirs: ["include"],
+			header_abi_checker: {
+				enabled: true,
+				exclude_headers: ["internal/**/*"],
+			},
+		}
+
+		cc_library_static {
+			name: "libbar_static",
+			srcs: ["bar.c"],
+		}
+	`)
+
+	staticLib := result.ModuleForTests("libfoo_static", "android_arm64_armv8-a_static")
+	staticLink := staticLib.Output("libfoo_static.a.lsdump")
+	android.AssertStringEquals(t, "static library symbol filter", "-sources-only", staticLink.Args["symbolFilter"])
+
+	headerLib := result.ModuleForTests("libfoo_headers", "android_arm64_armv8-a")
+	headerSrc := headerLib.Output("header_abi_dump/libfoo_headers.cpp")
+	android.AssertStringEquals(t, "header library ABI dump source",
+		"#include \"baz.hpp\"\n#include \"foo.h\"\n#include \"sub/bar.h\"\n#include \"vector\"",
+		android.ContentFromFileRuleForTests(t, headerSrc))
+	headerLink := headerLib.Output("libfoo_headers.lsdump")
+	android.AssertStringEquals(t, "header library symbol filter", "-sources-only", headerLink.Args["symbolFilter"])
+
+	unchecked := result.ModuleForTests("libbar_static", "android_arm64_armv8-a_static")
+	if unchecked.MaybeOutput("libbar_static.a.lsdump").Rule != nil {
+		t.Errorf("expected no ABI dump for libbar_static")
+	}
+}
@@ -287,6 +287,10 @@ func (linker *baseLinker) linkerInit(ctx BaseModuleContext) {
 	}
 }
 
+func (linker *baseLinker) baseLinkerProperties() *BaseLinkerProperties {
+	return &linker.Properties
+}
+
 func (linker *baseLinker) linkerProps() []interface{} {
 	return []interface{}{&linker.Properties, &linker.dynamicProperties}
 }
@@ -28,7 +28,7 @@ var (
 
 type SAbiProperties struct {
 	// Whether ABI dump should be created for this module.
-	// Set by `sabiDepsMutator` if this module is a shared library that needs ABI check, or a static
+	// Set by `sabiDepsMutator` if this module is a library that needs ABI check, or a static
 	// library that is depended on by an ABI checked library.
 	ShouldCreateSourceAbiDump bool `blueprint:"mutated"`
 
@@ -113,12 +113,14 @@ func shouldCreateSourceAbiDumpForLibrary(ctx android.BaseModuleContext) bool {
 		return false
 	}
 
-	// Create ABI dump for static libraries only if they are dependencies of ABI checked libraries.
-	if m.library.static() {
-		return m.sabi.shouldCreateSourceAbiDump()
+	// Static and header-only libraries are ABI checked on their own only if they enable
+	// header_abi_checker and don't have a shared variant, which would be checked instead.
+	if !m.library.shared() && (!m.library.headerAbiCheckerEnabled() || m.library.buildShared()) {
+		// Create ABI dump for static libraries only if they are dependencies of ABI checked libraries.
+		return m.library.static() && m.sabi.shouldCreateSourceAbiDump()
 	}
 
-	// Module is shared library type.
+	// Module is shared library type, or an ABI checked static or header-only library.
 
 	// Don't check uninstallable modules.
 	if m.IsHideFromMake() {
@@ -174,7 +176,7 @@ func sabiDepsMutator(mctx android.TopDownMutatorContext) {
 	if mctx.Config().IsEnvTrue("SKIP_ABI_CHECKS") {
 		return
 	}
-	// Only create ABI dump for native shared libraries and their static library dependencies.
+	// Only create ABI dump for native libraries and their static library dependencies.
 	if m, ok := mctx.Module().(*Module); ok && m.sabi != nil {
 		if shouldCreateSourceAbiDumpForLibrary(mctx) {
 			// Mark this module so that .sdump / .lsdump for this library can be generated.
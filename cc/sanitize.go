@@ -38,6 +38,11 @@ var (
 	}
 	asanLdflags = []string{"-Wl,-u,__asan_preinit"}
 
+	msanCflags = []string{
+		"-fno-omit-frame-pointer",
+		"-fsanitize-memory-track-origins",
+	}
+
 	hwasanCflags = []string{
 		"-fno-omit-frame-pointer",
 		"-Wno-frame-larger-than=",
@@ -85,6 +90,7 @@ const (
 	Asan SanitizerType = iota + 1
 	Hwasan
 	tsan
+	msan
 	intOverflow
 	scs
 	Fuzzer
@@ -96,6 +102,7 @@ var Sanitizers = []SanitizerType{
 	Asan,
 	Hwasan,
 	tsan,
+	msan,
 	intOverflow,
 	scs,
 	Fuzzer,
@@ -112,6 +119,8 @@ func (t SanitizerType) variationName() string {
 		return "hwasan"
 	case tsan:
 		return "tsan"
+	case msan:
+		return "msan"
 	case intOverflow:
 		return "intOverflow"
 	case cfi:
@@ -138,6 +147,8 @@ func (t SanitizerType) name() string {
 		return "memtag_heap"
 	case tsan:
 		return "thread"
+	case msan:
+		return "memory"
 	case intOverflow:
 		return "integer_overflow"
 	case cfi:
@@ -153,7 +164,7 @@ func (t SanitizerType) name() string {
 
 func (t SanitizerType) registerMutators(ctx android.RegisterMutatorsContext) {
 	switch t {
-	case Asan, Hwasan, Fuzzer, scs, tsan, cfi:
+	case Asan, Hwasan, Fuzzer, scs, tsan, msan, cfi:
 		ctx.TopDown(t.variationName()+"_deps", sanitizerDepsMutator(t))
 		ctx.BottomUp(t.variationName(), sanitizerMutator(t))
 	case Memtag_heap, intOverflow:
@@ -171,6 +182,8 @@ func (*Module) SanitizerSupported(t SanitizerType) bool {
 		return true
 	case tsan:
 		return true
+	case msan:
+		return true
 	case intOverflow:
 		return true
 	case cfi:
@@ -188,7 +201,7 @@ func (*Module) SanitizerSupported(t SanitizerType) bool {
 
 // incompatibleWithCfi returns true if a sanitizer is incompatible with CFI.
 func (t SanitizerType) incompatibleWithCfi() bool {
-	return t == Asan || t == Fuzzer || t == Hwasan
+	return t == Asan || t == Fuzzer || t == Hwasan || t == msan
 }
 
 type SanitizeUserProps struct {
@@ -205,6 +218,16 @@ type SanitizeUserProps struct {
 	// Use of thread sanitizer disables cfi and scudo sanitizers.
 	// Hwaddress sanitizer takes precedence over this sanitizer.
 	Thread *bool `android:"arch_variant"`
+	// MSan (Memory sanitizer), only available on 64-bit linux glibc hosts. Incompatible with
+	// static binaries, and with address, hwaddress and thread sanitizers.
+	// Always runs in a diagnostic mode.
+	// Use of memory sanitizer disables cfi and scudo sanitizers.
+	// MSan reports false positives in code that isn't instrumented, so modules that use libc++
+	// link the instrumented variant of libc++_static instead, and static libraries are
+	// instrumented along with the modules that link them. Shared libraries are only instrumented
+	// if they enable MSan themselves, as SANITIZE_HOST=memory does for every host module, and are
+	// then instrumented for all of their users.
+	Memory *bool `android:"arch_variant"`
 	// HWASan (Hardware Address sanitizer).
 	// Use of hwasan sanitizer disables cfi, address, thread, and scudo sanitizers.
 	Hwaddress *bool `android:"arch_variant"`
@@ -361,6 +384,12 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 			s.Thread = proptools.BoolPtr(true)
 		}
 
+		// Global memory sanitizer builds skip modules that use an incompatible sanitizer.
+		if found, globalSanitizers = removeFromList("memory", globalSanitizers); found && s.Memory == nil &&
+			!Bool(s.Address) && !Bool(s.Thread) {
+			s.Memory = proptools.BoolPtr(true)
+		}
+
 		if found, globalSanitizers = removeFromList("fuzzer", globalSanitizers); found && s.Fuzzer == nil {
 			s.Fuzzer = proptools.BoolPtr(true)
 		}
@@ -519,6 +548,7 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		s.Address = nil
 		s.Fuzzer = nil
 		s.Thread = nil
+		s.Memory = nil
 	}
 
 	if Bool(s.All_undefined) {
@@ -532,17 +562,33 @@ func (sanitize *sanitize) begin(ctx BaseModuleContext) {
 		// TODO(ccross): error for compile_multilib = "32"?
 	}
 
-	if ctx.Os() != android.Windows && (Bool(s.All_undefined) || Bool(s.Undefined) || Bool(s.Address) || Bool(s.Thread) ||
+	// MSan is only supported on 64-bit linux glibc hosts, where the whole process including the
+	// STL can be instrumented.
+	if !ctx.Host() || ctx.Os() != android.Linux || !ctx.toolchain().Is64Bit() {
+		s.Memory = nil
+	}
+
+	if Bool(s.Memory) && (Bool(s.Address) || Bool(s.Hwaddress) || Bool(s.Thread)) {
+		ctx.ModuleErrorf("memory sanitizer cannot be used with address, hwaddress or thread sanitizers")
+	}
+
+	if ctx.Os() != android.Windows && (Bool(s.All_undefined) || Bool(s.Undefined) || Bool(s.Address) || Bool(s.Thread) || Bool(s.Memory) ||
 		Bool(s.Fuzzer) || Bool(s.Safestack) || Bool(s.Cfi) || Bool(s.Integer_overflow) || len(s.Misc_undefined) > 0 ||
 		Bool(s.Scudo) || Bool(s.Hwaddress) || Bool(s.Scs) || Bool(s.Memtag_heap)) {
 		sanitize.Properties.SanitizerEnabled = true
 	}
 
-	// Disable Scudo if ASan or TSan is enabled, or if it's disabled globally.
-	if Bool(s.Address) || Bool(s.Thread) || Bool(s.Hwaddress) || ctx.Config().DisableScudo() {
+	// Disable Scudo if ASan, TSan or MSan is enabled, or if it's disabled globally.
+	if Bool(s.Address) || Bool(s.Thread) || Bool(s.Memory) || Bool(s.Hwaddress) || ctx.Config().DisableScudo() {
 		s.Scudo = nil
 	}
 
+	// Also disable CFI if MSan is enabled.
+	if Bool(s.Memory) {
+		s.Cfi = nil
+		s.Diag.Cfi = nil
+	}
+
 	if Bool(s.Hwaddress) {
 		s.Address = nil
 		s.Thread = nil
@@ -622,6 +668,10 @@ func (sanitize *sanitize) flags(ctx ModuleContext, flags Flags) Flags {
 		}
 	}
 
+	if Bool(sanitize.Properties.Sanitize.Memory) {
+		flags.Local.CFlags = append(flags.Local.CFlags, msanCflags...)
+	}
+
 	if Bool(sanitize.Properties.Sanitize.Hwaddress) {
 		flags.Local.CFlags = append(flags.Local.CFlags, hwasanCflags...)
 
@@ -790,6 +840,8 @@ func (sanitize *sanitize) getSanitizerBoolPtr(t SanitizerType) *bool {
 		return sanitize.Properties.Sanitize.Hwaddress
 	case tsan:
 		return sanitize.Properties.Sanitize.Thread
+	case msan:
+		return sanitize.Properties.Sanitize.Memory
 	case intOverflow:
 		return sanitize.Properties.Sanitize.Integer_overflow
 	case cfi:
@@ -810,6 +862,7 @@ func (sanitize *sanitize) isUnsanitizedVariant() bool {
 	return !sanitize.isSanitizerEnabled(Asan) &&
 		!sanitize.isSanitizerEnabled(Hwasan) &&
 		!sanitize.isSanitizerEnabled(tsan) &&
+		!sanitize.isSanitizerEnabled(msan) &&
 		!sanitize.isSanitizerEnabled(cfi) &&
 		!sanitize.isSanitizerEnabled(scs) &&
 		!sanitize.isSanitizerEnabled(Memtag_heap) &&
@@ -821,6 +874,7 @@ func (sanitize *sanitize) isVariantOnProductionDevice() bool {
 	return !sanitize.isSanitizerEnabled(Asan) &&
 		!sanitize.isSanitizerEnabled(Hwasan) &&
 		!sanitize.isSanitizerEnabled(tsan) &&
+		!sanitize.isSanitizerEnabled(msan) &&
 		!sanitize.isSanitizerEnabled(Fuzzer)
 }
 
@@ -836,6 +890,8 @@ func (sanitize *sanitize) SetSanitizer(t SanitizerType, b bool) {
 		sanitize.Properties.Sanitize.Hwaddress = bPtr
 	case tsan:
 		sanitize.Properties.Sanitize.Thread = bPtr
+	case msan:
+		sanitize.Properties.Sanitize.Memory = bPtr
 	case intOverflow:
 		sanitize.Properties.Sanitize.Integer_overflow = bPtr
 	case cfi:
@@ -947,7 +1003,7 @@ func sanitizerDepsMutator(t SanitizerType) func(android.TopDownMutatorContext) {
 					if d, ok := child.(PlatformSanitizeable); ok && d.SanitizePropDefined() &&
 						!d.SanitizeNever() &&
 						!d.IsSanitizerExplicitlyDisabled(t) {
-						if t == cfi || t == Hwasan || t == scs || t == Asan {
+						if t == cfi || t == Hwasan || t == scs || t == Asan || t == msan {
 							if d.StaticallyLinked() && d.SanitizerSupported(t) {
 								// Rust does not support some of these sanitizers, so we need to check if it's
 								// supported before setting this true.
@@ -1091,6 +1147,11 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 			sanitizers = append(sanitizers, "thread")
 		}
 
+		if Bool(c.sanitize.Properties.Sanitize.Memory) {
+			sanitizers = append(sanitizers, "memory")
+			diagSanitizers = append(diagSanitizers, "memory")
+		}
+
 		if Bool(c.sanitize.Properties.Sanitize.Safestack) {
 			sanitizers = append(sanitizers, "safe-stack")
 		}
@@ -1169,6 +1230,8 @@ func sanitizerRuntimeMutator(mctx android.BottomUpMutatorContext) {
 			}
 		} else if Bool(c.sanitize.Properties.Sanitize.Thread) {
 			runtimeLibrary = config.ThreadSanitizerRuntimeLibrary(toolchain)
+		} else if Bool(c.sanitize.Properties.Sanitize.Memory) {
+			runtimeLibrary = config.MemorySanitizerRuntimeLibrary(toolchain)
 		} else if Bool(c.sanitize.Properties.Sanitize.Scudo) {
 			if len(diagSanitizers) == 0 && !c.sanitize.Properties.UbsanRuntimeDep {
 				runtimeLibrary = config.ScudoMinimalRuntimeLibrary(toolchain)
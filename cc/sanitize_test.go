@@ -202,6 +202,157 @@ func TestAsan(t *testing.T) {
 	t.Run("device", func(t *testing.T) { check(t, result, "android_arm64_armv8-a") })
 }
 
+func TestMsan(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	bp := `
+		cc_binary {
+			name: "bin_with_msan",
+			host_supported: true,
+			static_libs: ["libstatic"],
+			shared_libs: ["libshared"],
+			sanitize: {
+				memory: true,
+			}
+		}
+
+		cc_binary {
+			name: "bin_no_msan",
+			host_supported: true,
+			shared_libs: ["libshared"],
+		}
+
+		cc_library_static {
+			name: "libstatic",
+			host_supported: true,
+		}
+
+		cc_library_shared {
+			name: "libshared",
+			host_supported: true,
+			static_libs: ["libstatic"],
+		}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+
+	hostVariant := result.Config.BuildOSTarget.String()
+	binWithMsan := result.ModuleForTests("bin_with_msan", hostVariant+"_msan")
+	libStaticMsan := result.ModuleForTests("libstatic", hostVariant+"_static_msan")
+	libcxxStaticMsan := result.ModuleForTests("libc++_static", hostVariant+"_static_msan")
+
+	android.AssertStringDoesContain(t, "bin_with_msan cflags",
+		binWithMsan.Rule("cc").Args["cFlags"], "-fsanitize=memory")
+	android.AssertStringDoesContain(t, "libstatic cflags",
+		libStaticMsan.Rule("cc").Args["cFlags"], "-fsanitize=memory")
+	android.AssertStringDoesContain(t, "libc++_static cflags",
+		libcxxStaticMsan.Rule("cc").Args["cFlags"], "-fsanitize=memory")
+
+	binLink := binWithMsan.Description("link")
+	android.AssertStringListContains(t, "bin_with_msan links sanitized static library",
+		binLink.Implicits.Strings(), libStaticMsan.Description("static link").Output.String())
+	android.AssertStringListContains(t, "bin_with_msan links sanitized libc++_static",
+		binLink.Implicits.Strings(), libcxxStaticMsan.Description("static link").Output.String())
+	libcxx := result.ModuleForTests("libc++", hostVariant+"_shared")
+	android.AssertStringListDoesNotContain(t, "bin_with_msan doesn't link libc++",
+		binLink.OrderOnly.Strings(), libcxx.Description("strip").Output.String())
+
+	// Shared libraries are not split or instrumented for the modules that link them, so the
+	// library used by the binary is the installed one.
+	for _, variant := range result.ModuleVariantsForTests("libshared") {
+		android.AssertStringDoesNotContain(t, "libshared variant", variant, "msan")
+	}
+	libShared := result.ModuleForTests("libshared", hostVariant+"_shared")
+	android.AssertBoolEquals(t, "libshared is installed", false, libShared.Module().IsSkipInstall())
+	android.AssertStringListContains(t, "bin_with_msan links libshared",
+		binLink.OrderOnly.Strings(), libShared.Description("strip").Output.String())
+	android.AssertStringListContains(t, "bin_no_msan links libshared",
+		result.ModuleForTests("bin_no_msan", hostVariant).Description("link").OrderOnly.Strings(),
+		libShared.Description("strip").Output.String())
+
+	// MSan is host only, the device variant is not sanitized.
+	result.ModuleForTests("bin_with_msan", "android_arm64_armv8-a")
+}
+
+func TestMsanSharedLibrary(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	bp := `
+		cc_binary {
+			name: "bin_with_msan",
+			host_supported: true,
+			shared_libs: ["libshared_msan"],
+			sanitize: {
+				memory: true,
+			}
+		}
+
+		cc_library_shared {
+			name: "libshared_msan",
+			host_supported: true,
+			static_libs: ["libstatic"],
+			sanitize: {
+				memory: true,
+			}
+		}
+
+		cc_library_static {
+			name: "libstatic",
+			host_supported: true,
+		}
+	`
+
+	result := prepareForCcTest.RunTestWithBp(t, bp)
+
+	// A shared library that enables MSan itself only has an instrumented variant, which is
+	// installed and links the instrumented variants of its static libraries and libc++_static.
+	hostVariant := result.Config.BuildOSTarget.String()
+	libShared := result.ModuleForTests("libshared_msan", hostVariant+"_shared_msan")
+	android.AssertBoolEquals(t, "libshared_msan is installed", false, libShared.Module().IsSkipInstall())
+	android.AssertBoolEquals(t, "libshared_msan is exported to make", false, libShared.Module().IsHideFromMake())
+
+	libSharedLink := libShared.Description("link")
+	android.AssertStringDoesContain(t, "libshared_msan cflags",
+		libShared.Rule("cc").Args["cFlags"], "-fsanitize=memory")
+	for _, lib := range []string{"libstatic", "libc++_static"} {
+		android.AssertStringListContains(t, "libshared_msan links sanitized "+lib,
+			libSharedLink.Implicits.Strings(),
+			result.ModuleForTests(lib, hostVariant+"_static_msan").Description("static link").Output.String())
+	}
+
+	binLink := result.ModuleForTests("bin_with_msan", hostVariant+"_msan").Description("link")
+	android.AssertStringListContains(t, "bin_with_msan links libshared_msan",
+		binLink.OrderOnly.Strings(), libShared.Description("strip").Output.String())
+}
+
+func TestMsanIncompatibleSanitizers(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("requires linux")
+	}
+
+	bp := `
+		cc_binary {
+			name: "bin_with_msan_and_asan",
+			host_supported: true,
+			sanitize: {
+				address: true,
+				memory: true,
+			}
+		}
+	`
+
+	android.GroupFixturePreparers(
+		prepareForCcTest,
+		prepareForAsanTest,
+	).ExtendWithErrorHandler(android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+		"memory sanitizer cannot be used with address, hwaddress or thread sanitizers")).
+		RunTestWithBp(t, bp)
+}
+
 func TestUbsan(t *testing.T) {
 	if runtime.GOOS != "linux" {
 		t.Skip("requires linux")
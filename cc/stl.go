@@ -142,10 +142,16 @@ func (stl *stl) deps(ctx BaseModuleContext, deps Deps) Deps {
 	case "libstdc++":
 		// Nothing
 	case "libc++", "libc++_static":
-		if stl.Properties.SelectedStl == "libc++" {
+		// MSan reports false positives in code that isn't instrumented, so link libc++_static,
+		// whose MSan variant is created along with the other static libraries of the module.
+		useMsan := false
+		if m, ok := ctx.Module().(*Module); ok && m.sanitize != nil {
+			useMsan = m.sanitize.isSanitizerEnabled(msan)
+		}
+		if stl.Properties.SelectedStl == "libc++" && !useMsan {
 			deps.SharedLibs = append(deps.SharedLibs, stl.Properties.SelectedStl)
 		} else {
-			deps.StaticLibs = append(deps.StaticLibs, stl.Properties.SelectedStl)
+			deps.StaticLibs = append(deps.StaticLibs, "libc++_static")
 		}
 		if ctx.Device() && !ctx.useSdk() {
 			// __cxa_demangle is not a part of libc++.so on the device since
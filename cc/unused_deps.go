@@ -0,0 +1,175 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cc
+
+import (
+	"encoding/json"
+
+	"android/soong/android"
+)
+
+// This singleton collects the shared_libs, static_libs and header_libs listed in the Android.bp
+// files of linked cc modules, together with the files needed to tell whether they are used, into
+// a json file. After a build, the cc_unused_deps tool uses it together with the ninja deps log to
+// report the dependencies that contributed no symbols and no headers to a module, and optionally
+// removes them from the Android.bp files.
+// The info file is generated in $OUT/soong/module_bp_cc_link_deps.json when SOONG_CC_UNUSED_DEPS
+// is set.
+
+func init() {
+	android.RegisterSingletonType("cc_unused_deps", ccUnusedDepsSingletonFactory)
+}
+
+const ccLinkDepsJsonFileName = "module_bp_cc_link_deps.json"
+
+func unusedDepsEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_CC_UNUSED_DEPS")
+}
+
+// linkDep is a library dependency of a module that is listed in its Android.bp file.
+type linkDep struct {
+	name string
+	// kind is the name of the property that lists the dependency.
+	kind string
+	// library is the shared library or the archive of a static library.
+	library  android.OptionalPath
+	exporter FlagExporterInfo
+}
+
+// recordLinkDep records a direct library dependency of the module for the unused dependency
+// report if it is listed in the shared_libs, static_libs or header_libs properties of the module.
+// Dependencies added implicitly, like the system shared libraries or the STL, are ignored as they
+// can't be removed from the Android.bp file.
+func (c *Module) recordLinkDep(depName string, tag libraryDependencyTag, linkFile android.OptionalPath,
+	exporter FlagExporterInfo) {
+
+	linker, ok := c.linker.(interface {
+		baseLinkerProperties() *BaseLinkerProperties
+	})
+	if !ok || tag.Order != normalLibraryDependency || tag.wholeStatic {
+		return
+	}
+	props := linker.baseLinkerProperties()
+	name := android.RemoveOptionalPrebuiltPrefix(depName)
+
+	dep := linkDep{name: name, exporter: exporter}
+	switch {
+	case tag.header() && android.InList(name, props.Header_libs):
+		dep.kind = "header_libs"
+	case tag.shared() && android.InList(name, props.Shared_libs):
+		dep.kind = "shared_libs"
+		dep.library = linkFile
+	case tag.static() && android.InList(name, props.Static_libs):
+		dep.kind = "static_libs"
+		dep.library = linkFile
+	default:
+		return
+	}
+	c.linkDeps = append(c.linkDeps, dep)
+}
+
+type ccLinkDepsDependency struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+	// Shared library, or archive of a static library.
+	Library string `json:"library,omitempty"`
+	// Include directories and generated headers exported by the dependency.
+	Include_dirs      []string `json:"include_dirs,omitempty"`
+	Generated_headers []string `json:"generated_headers,omitempty"`
+}
+
+type ccLinkDepsModule struct {
+	Name      string `json:"name"`
+	Variant   string `json:"variant"`
+	Blueprint string `json:"blueprint"`
+	// The unstripped binary or shared library linked by the module.
+	Output  string                 `json:"output"`
+	Objects []string               `json:"objects,omitempty"`
+	Deps    []ccLinkDepsDependency `json:"deps"`
+}
+
+type ccLinkDeps struct {
+	Llvm_nm string             `json:"llvm_nm"`
+	Modules []ccLinkDepsModule `json:"modules"`
+}
+
+func ccUnusedDepsSingletonFactory() android.Singleton {
+	return &ccUnusedDepsSingleton{}
+}
+
+type ccUnusedDepsSingleton struct{}
+
+func (c *ccUnusedDepsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !unusedDepsEnabled(ctx.Config()) {
+		return
+	}
+
+	llvmNm, _ := evalVariable(ctx, "${config.ClangBin}/llvm-nm")
+	linkDeps := ccLinkDeps{
+		Llvm_nm: llvmNm,
+		Modules: []ccLinkDepsModule{},
+	}
+
+	ctx.VisitAllModules(func(module android.Module) {
+		ccModule, ok := module.(*Module)
+		if !ok || !ccModule.Enabled() || len(ccModule.linkDeps) == 0 {
+			return
+		}
+		if !(ccModule.Binary() || ccModule.Shared()) || ccModule.IsStubs() || ccModule.IsPrebuilt() ||
+			ccModule.UnstrippedOutputFile() == nil {
+			return
+		}
+
+		m := ccLinkDepsModule{
+			Name:      ctx.ModuleName(module),
+			Variant:   ctx.ModuleSubDir(module),
+			Blueprint: ctx.BlueprintFile(module),
+			Output:    ccModule.UnstrippedOutputFile().String(),
+			Objects:   ccModule.objFiles.Strings(),
+		}
+		for _, dep := range ccModule.linkDeps {
+			d := ccLinkDepsDependency{
+				Name:              dep.name,
+				Kind:              dep.kind,
+				Generated_headers: dep.exporter.GeneratedHeaders.Strings(),
+			}
+			if dep.library.Valid() {
+				d.Library = dep.library.String()
+			}
+			d.Include_dirs = append(d.Include_dirs, dep.exporter.IncludeDirs.Strings()...)
+			d.Include_dirs = append(d.Include_dirs, dep.exporter.SystemIncludeDirs.Strings()...)
+			m.Deps = append(m.Deps, d)
+		}
+		linkDeps.Modules = append(linkDeps.Modules, m)
+	})
+
+	buf, err := json.MarshalIndent(linkDeps, "", "\t")
+	if err != nil {
+		ctx.Errorf("JSON marshal of cc link deps failed: %s", err)
+		return
+	}
+
+	outputPath := android.PathForOutput(ctx, ccLinkDepsJsonFileName)
+	if err := android.WriteFileToOutputDir(outputPath, buf, 0666); err != nil {
+		ctx.Errorf("Writing cc link deps to %s failed: %s", outputPath.String(), err)
+		return
+	}
+
+	// This is necessary to satisfy the dangling rules check as this file is written by Soong rather than a rule.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:   android.Touch,
+		Output: outputPath,
+	})
+}
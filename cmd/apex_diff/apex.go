@@ -0,0 +1,268 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const apexManifestFile = "apex_manifest.pb"
+
+// apexFile is a file in the payload of an APEX.
+type apexFile struct {
+	size int64
+	// hash is the SHA-256 of a regular file or the target of a symlink, or empty if only the size
+	// of the file is known.
+	hash string
+	// path is the path of the extracted file, or empty if the file isn't available.
+	path string
+}
+
+// apexContents are the files of the payload of an APEX, by their path in the payload, and the
+// fields of its manifest.
+type apexContents struct {
+	files    map[string]apexFile
+	manifest map[string]string
+}
+
+// tools are the host tools used to extract the payload of an .apex file.
+type tools struct {
+	deapexer string
+	debugfs  string
+}
+
+// loadApex reads an .apex or a .capex file, a directory with the extracted payload of an APEX or
+// with its image in the intermediates of the build, or the installed-files.txt of an APEX.
+// Files are extracted in tmpDir.
+func loadApex(input string, t tools, tmpDir string) (*apexContents, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return loadDir(input)
+	}
+	if strings.HasSuffix(input, ".txt") {
+		return loadInstalledFiles(input)
+	}
+
+	r, err := zip.OpenReader(input)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", input, err)
+	}
+	defer r.Close()
+
+	// A .capex file is a zip with the original .apex file.
+	for _, f := range r.File {
+		if f.Name == "original_apex" {
+			apex := filepath.Join(tmpDir, "original.apex")
+			if err := extractFile(f, apex); err != nil {
+				return nil, fmt.Errorf("%s: %s", input, err)
+			}
+			return loadApex(apex, t, tmpDir)
+		}
+	}
+
+	payload := filepath.Join(tmpDir, "payload")
+	cmd := exec.Command(t.deapexer, "--debugfs_path", t.debugfs, "extract", input, payload)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%s: extracting with %s failed: %s", input, t.deapexer, err)
+	}
+	contents, err := loadDir(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Older payloads don't have the manifest, which is also at the top of the .apex file.
+	if contents.manifest == nil {
+		for _, f := range r.File {
+			if f.Name == apexManifestFile {
+				manifest := filepath.Join(tmpDir, apexManifestFile)
+				if err := extractFile(f, manifest); err != nil {
+					return nil, err
+				}
+				if contents.manifest, err = readManifest(manifest); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return contents, nil
+}
+
+// loadDir reads the files of the payload of an APEX extracted in dir.
+func loadDir(dir string) (*apexContents, error) {
+	contents := &apexContents{files: make(map[string]apexFile)}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if rel == "lost+found" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		file := apexFile{size: info.Size()}
+		if info.Mode()&os.ModeSymlink != 0 {
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			file.hash = "-> " + target
+		} else if info.Mode().IsRegular() {
+			if file.hash, err = hashFile(path); err != nil {
+				return err
+			}
+			file.path = path
+		} else {
+			return nil
+		}
+		contents.files[rel] = file
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := contents.files[apexManifestFile]; ok {
+		contents.manifest, err = readManifest(filepath.Join(dir, apexManifestFile))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return contents, nil
+}
+
+// loadInstalledFiles reads the installed-files.txt of an APEX, which lists the size and the path
+// of each of its files.
+func loadInstalledFiles(file string) (*apexContents, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	contents := &apexContents{files: make(map[string]apexFile)}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected <size> <path>", file, line)
+		}
+		size, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: bad size %q", file, line, fields[0])
+		}
+		contents.files[strings.TrimPrefix(fields[1], "./")] = apexFile{size: size}
+	}
+	return contents, scanner.Err()
+}
+
+func readManifest(file string) (map[string]string, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := decodeApexManifest(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", file, err)
+	}
+	return manifest, nil
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func extractFile(f *zip.File, dst string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isApexFile returns true if the file name is the name of an .apex or a .capex file.
+func isApexFile(name string) bool {
+	return strings.HasSuffix(name, ".apex") || strings.HasSuffix(name, ".capex")
+}
+
+// apexesInDir returns the APEXes in a directory of the output of a build, like
+// $OUT/system/apex, by their name without extension, or nil if dir is the payload of an APEX.  An
+// APEX is an .apex or a .capex file, or a subdirectory with the payload of a flattened APEX.
+func apexesInDir(dir string) (map[string]string, error) {
+	if _, err := os.Stat(filepath.Join(dir, apexManifestFile)); err == nil {
+		return nil, nil
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	apexes := make(map[string]string)
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if entry.IsDir() {
+			if _, err := os.Stat(filepath.Join(path, apexManifestFile)); err == nil {
+				apexes[entry.Name()] = path
+			}
+		} else if isApexFile(entry.Name()) {
+			apexes[strings.TrimSuffix(strings.TrimSuffix(entry.Name(), ".apex"), ".capex")] = path
+		}
+	}
+	if len(apexes) == 0 {
+		return nil, nil
+	}
+	return apexes, nil
+}
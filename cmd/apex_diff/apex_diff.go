@@ -0,0 +1,167 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apex_diff reports what changed inside an APEX between two builds: the added, removed, resized
+// and changed files of its payload, the exported symbols and the needed libraries of its native
+// libraries and binaries, the classes of its jars and apks, and the fields of its manifest.
+//
+// The APEXes compared can be .apex or .capex files, directories with their extracted payload or
+// their image in the intermediates of the build, or their installed-files.txt, which only lists
+// the sizes of their files.  Two directories of APEXes of the output of two builds, like
+// $OUT/system/apex, are compared APEX by APEX.  The payload of .apex files is extracted with
+// deapexer, which needs debugfs.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [-json] [-deapexer <path>] [-debugfs <path>] <old apex> <new apex>\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	jsonOutput := flag.Bool("json", false, "write the report as JSON instead of text")
+	deapexer := flag.String("deapexer", "deapexer", "path to deapexer, to extract the payload of .apex files")
+	debugfs := flag.String("debugfs", "debugfs", "path to debugfs, used by deapexer")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() != 2 {
+		usage()
+	}
+
+	report, err := diff(flag.Arg(0), flag.Arg(1), tools{deapexer: *deapexer, debugfs: *debugfs})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			os.Exit(1)
+		}
+	} else {
+		writeReportText(os.Stdout, report)
+	}
+}
+
+// diff compares two APEXes, or two directories of APEXes.
+func diff(oldInput, newInput string, t tools) (*Report, error) {
+	oldApexes, err := apexesIn(oldInput)
+	if err != nil {
+		return nil, err
+	}
+	newApexes, err := apexesIn(newInput)
+	if err != nil {
+		return nil, err
+	}
+	if (oldApexes == nil) != (newApexes == nil) {
+		return nil, fmt.Errorf("can't compare a directory of APEXes with a single APEX")
+	}
+
+	report := &Report{}
+	if oldApexes == nil {
+		name := apexName(newInput)
+		apexDiff, err := diffInputs(name, oldInput, newInput, t)
+		if err != nil {
+			return nil, err
+		}
+		report.Apexes = append(report.Apexes, apexDiff)
+		return report, nil
+	}
+
+	var names []string
+	for name := range oldApexes {
+		if _, ok := newApexes[name]; ok {
+			names = append(names, name)
+		} else {
+			report.RemovedApexes = append(report.RemovedApexes, name)
+		}
+	}
+	for name := range newApexes {
+		if _, ok := oldApexes[name]; !ok {
+			report.AddedApexes = append(report.AddedApexes, name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(report.AddedApexes)
+	sort.Strings(report.RemovedApexes)
+
+	for _, name := range names {
+		apexDiff, err := diffInputs(name, oldApexes[name], newApexes[name], t)
+		if err != nil {
+			return nil, err
+		}
+		report.Apexes = append(report.Apexes, apexDiff)
+	}
+	return report, nil
+}
+
+// apexesIn returns the APEXes in a directory of APEXes, or nil if input is a single APEX.
+func apexesIn(input string) (map[string]string, error) {
+	info, err := os.Stat(input)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil
+	}
+	return apexesInDir(input)
+}
+
+// apexName returns the name of an APEX from its path.
+func apexName(input string) string {
+	name := filepath.Base(filepath.Clean(input))
+	for _, ext := range []string{".apex", ".capex"} {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return name
+}
+
+func diffInputs(name, oldInput, newInput string, t tools) (ApexDiff, error) {
+	tmpDir, err := ioutil.TempDir("", "apex_diff")
+	if err != nil {
+		return ApexDiff{}, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	load := func(input, dir string) (*apexContents, error) {
+		dir = filepath.Join(tmpDir, dir)
+		if err := os.MkdirAll(dir, 0777); err != nil {
+			return nil, err
+		}
+		return loadApex(input, t, dir)
+	}
+	oldApex, err := load(oldInput, "old")
+	if err != nil {
+		return ApexDiff{}, err
+	}
+	newApex, err := load(newInput, "new")
+	if err != nil {
+		return ApexDiff{}, err
+	}
+	return diffApexes(name, oldInput, newInput, oldApex, newApex)
+}
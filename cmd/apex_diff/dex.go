@@ -0,0 +1,136 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// readClasses returns the sorted names of the classes in a jar or an apk, from its dex files and
+// its class files.
+func readClasses(file string) ([]string, error) {
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var classes []string
+	for _, f := range r.File {
+		switch {
+		case strings.HasSuffix(f.Name, ".class"):
+			classes = append(classes, strings.ReplaceAll(strings.TrimSuffix(f.Name, ".class"), "/", "."))
+		case path.Dir(f.Name) == "." && strings.HasPrefix(f.Name, "classes") && strings.HasSuffix(f.Name, ".dex"):
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			buf, err := ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+			dexClasses, err := readDexClasses(buf)
+			if err != nil {
+				return nil, fmt.Errorf("%s!%s: %s", file, f.Name, err)
+			}
+			classes = append(classes, dexClasses...)
+		}
+	}
+	return sortedUnique(classes), nil
+}
+
+// readDexClasses returns the names of the classes defined in a dex file.
+func readDexClasses(buf []byte) ([]string, error) {
+	const headerSize = 0x70
+	if len(buf) < headerSize || !bytes.HasPrefix(buf, []byte("dex\n")) {
+		return nil, fmt.Errorf("not a dex file")
+	}
+	u32 := func(off uint32) (uint32, error) {
+		if uint64(off)+4 > uint64(len(buf)) {
+			return 0, fmt.Errorf("truncated dex file")
+		}
+		return binary.LittleEndian.Uint32(buf[off:]), nil
+	}
+
+	stringIdsSize, _ := u32(0x38)
+	stringIdsOff, _ := u32(0x3c)
+	typeIdsSize, _ := u32(0x40)
+	typeIdsOff, _ := u32(0x44)
+	classDefsSize, _ := u32(0x60)
+	classDefsOff, _ := u32(0x64)
+
+	var classes []string
+	for i := uint32(0); i < classDefsSize; i++ {
+		// Each class_def_item is 32 bytes and starts with the type index of the class.
+		typeIdx, err := u32(classDefsOff + i*32)
+		if err != nil {
+			return nil, err
+		}
+		if typeIdx >= typeIdsSize {
+			return nil, fmt.Errorf("bad type index %d", typeIdx)
+		}
+		stringIdx, err := u32(typeIdsOff + typeIdx*4)
+		if err != nil {
+			return nil, err
+		}
+		if stringIdx >= stringIdsSize {
+			return nil, fmt.Errorf("bad string index %d", stringIdx)
+		}
+		stringOff, err := u32(stringIdsOff + stringIdx*4)
+		if err != nil {
+			return nil, err
+		}
+		descriptor, err := dexString(buf, stringOff)
+		if err != nil {
+			return nil, err
+		}
+		classes = append(classes, descriptorToClassName(descriptor))
+	}
+	return classes, nil
+}
+
+// dexString returns the string at off in a dex file, which is its length in UTF-16 code units as a
+// ULEB128 followed by its null-terminated MUTF-8 encoding.
+func dexString(buf []byte, off uint32) (string, error) {
+	if uint64(off) >= uint64(len(buf)) {
+		return "", fmt.Errorf("bad string offset %d", off)
+	}
+	_, n := binary.Uvarint(buf[off:])
+	if n <= 0 {
+		return "", fmt.Errorf("bad string length at %d", off)
+	}
+	data := buf[int(off)+n:]
+	end := bytes.IndexByte(data, 0)
+	if end < 0 {
+		return "", fmt.Errorf("unterminated string at %d", off)
+	}
+	return string(data[:end]), nil
+}
+
+// descriptorToClassName converts a type descriptor like Lcom/android/Foo; to a class name like
+// com.android.Foo.
+func descriptorToClassName(descriptor string) string {
+	if strings.HasPrefix(descriptor, "L") && strings.HasSuffix(descriptor, ";") {
+		descriptor = descriptor[1 : len(descriptor)-1]
+	}
+	return strings.ReplaceAll(descriptor, "/", ".")
+}
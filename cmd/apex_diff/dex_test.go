@@ -0,0 +1,134 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// buildDex returns a minimal dex file that defines classes with the descriptors.
+func buildDex(descriptors ...string) []byte {
+	const headerSize = 0x70
+	n := uint32(len(descriptors))
+	stringIdsOff := uint32(headerSize)
+	typeIdsOff := stringIdsOff + 4*n
+	classDefsOff := typeIdsOff + 4*n
+	dataOff := classDefsOff + 32*n
+
+	buf := make([]byte, dataOff)
+	copy(buf, "dex\n035\x00")
+	put := func(off, v uint32) { binary.LittleEndian.PutUint32(buf[off:], v) }
+	put(0x38, n)
+	put(0x3c, stringIdsOff)
+	put(0x40, n)
+	put(0x44, typeIdsOff)
+	put(0x60, n)
+	put(0x64, classDefsOff)
+	for i, descriptor := range descriptors {
+		i := uint32(i)
+		put(stringIdsOff+4*i, uint32(len(buf)))
+		put(typeIdsOff+4*i, i)
+		// Define the classes in the reverse order of their type ids.
+		put(classDefsOff+32*i, n-1-i)
+		buf = append(buf, byte(len(descriptor)))
+		buf = append(buf, descriptor...)
+		buf = append(buf, 0)
+	}
+	return buf
+}
+
+func writeZip(t *testing.T, file string, entries map[string][]byte) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range entries {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(file, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadDexClasses(t *testing.T) {
+	got, err := readDexClasses(buildDex("Lcom/android/Foo;", "Lcom/android/Foo$Bar;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"com.android.Foo$Bar", "com.android.Foo"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected classes %q, got %q", expected, got)
+	}
+
+	if _, err := readDexClasses([]byte("not a dex file")); err == nil {
+		t.Errorf("expected an error for a file that isn't a dex file")
+	}
+}
+
+func TestReadClasses(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	jar := filepath.Join(dir, "foo.jar")
+	writeZip(t, jar, map[string][]byte{
+		"classes.dex":              buildDex("Lcom/android/Foo;"),
+		"classes2.dex":             buildDex("Lcom/android/Bar;"),
+		"com/android/Baz.class":    nil,
+		"META-INF/MANIFEST.MF":     nil,
+		"assets/classes.dex":       []byte("not a dex file"),
+		"com/android/Foo.class":    nil,
+		"com/android/foo.txt":      nil,
+		"META-INF/services/a.b.C":  nil,
+		"com/android/Qux$1.class":  nil,
+		"com/android/Qux.class":    nil,
+		"com/android/Baz$In.class": nil,
+	})
+
+	got, err := readClasses(jar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"com.android.Bar",
+		"com.android.Baz",
+		"com.android.Baz$In",
+		"com.android.Foo",
+		"com.android.Qux",
+		"com.android.Qux$1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected classes %q, got %q", expected, got)
+	}
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"sort"
+)
+
+// Report is the difference between the APEXes of two builds.
+type Report struct {
+	// AddedApexes and RemovedApexes are the names of the APEXes that are only in one of the builds,
+	// when directories of APEXes are compared.
+	AddedApexes   []string   `json:"added_apexes,omitempty"`
+	RemovedApexes []string   `json:"removed_apexes,omitempty"`
+	Apexes        []ApexDiff `json:"apexes"`
+}
+
+// ApexDiff is the difference between two versions of an APEX.
+type ApexDiff struct {
+	Name string `json:"name"`
+	Old  string `json:"old"`
+	New  string `json:"new"`
+
+	Manifest     []ManifestChange `json:"manifest,omitempty"`
+	AddedFiles   []FileSize       `json:"added_files,omitempty"`
+	RemovedFiles []FileSize       `json:"removed_files,omitempty"`
+	ResizedFiles []FileResize     `json:"resized_files,omitempty"`
+	// ChangedFiles are the files whose contents changed without changing their size.
+	ChangedFiles []string        `json:"changed_files,omitempty"`
+	NativeLibs   []NativeLibDiff `json:"native_libs,omitempty"`
+	Jars         []JarDiff       `json:"jars,omitempty"`
+}
+
+// Empty returns true if the two versions of the APEX are the same.
+func (d ApexDiff) Empty() bool {
+	return len(d.Manifest) == 0 && len(d.AddedFiles) == 0 && len(d.RemovedFiles) == 0 &&
+		len(d.ResizedFiles) == 0 && len(d.ChangedFiles) == 0
+}
+
+type FileSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+type FileResize struct {
+	Path    string `json:"path"`
+	OldSize int64  `json:"old_size"`
+	NewSize int64  `json:"new_size"`
+}
+
+// NativeLibDiff is the change of the exported dynamic symbols and of the DT_NEEDED entries of a
+// native library or binary.
+type NativeLibDiff struct {
+	Path           string   `json:"path"`
+	AddedSymbols   []string `json:"added_symbols,omitempty"`
+	RemovedSymbols []string `json:"removed_symbols,omitempty"`
+	AddedNeeded    []string `json:"added_needed,omitempty"`
+	RemovedNeeded  []string `json:"removed_needed,omitempty"`
+}
+
+// JarDiff is the change of the classes of a jar or an apk.
+type JarDiff struct {
+	Path           string   `json:"path"`
+	AddedClasses   []string `json:"added_classes,omitempty"`
+	RemovedClasses []string `json:"removed_classes,omitempty"`
+}
+
+func isJar(file string) bool {
+	ext := path.Ext(file)
+	return ext == ".jar" || ext == ".apk"
+}
+
+// diffApexes compares two versions of an APEX.  The symbols of the native libraries and the classes
+// of the jars are only compared for the files whose contents changed and were extracted.
+func diffApexes(name, oldName, newName string, oldApex, newApex *apexContents) (ApexDiff, error) {
+	diff := ApexDiff{
+		Name:     name,
+		Old:      oldName,
+		New:      newName,
+		Manifest: diffManifests(oldApex.manifest, newApex.manifest),
+	}
+
+	var paths []string
+	for p := range oldApex.files {
+		paths = append(paths, p)
+	}
+	for p := range newApex.files {
+		if _, ok := oldApex.files[p]; !ok {
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		oldFile, inOld := oldApex.files[p]
+		newFile, inNew := newApex.files[p]
+		switch {
+		case !inOld:
+			diff.AddedFiles = append(diff.AddedFiles, FileSize{p, newFile.size})
+			continue
+		case !inNew:
+			diff.RemovedFiles = append(diff.RemovedFiles, FileSize{p, oldFile.size})
+			continue
+		case oldFile.size != newFile.size:
+			diff.ResizedFiles = append(diff.ResizedFiles, FileResize{p, oldFile.size, newFile.size})
+		case oldFile.hash != "" && newFile.hash != "" && oldFile.hash != newFile.hash:
+			diff.ChangedFiles = append(diff.ChangedFiles, p)
+		default:
+			continue
+		}
+
+		if oldFile.path == "" || newFile.path == "" {
+			continue
+		}
+		if err := diffContents(&diff, p, oldFile.path, newFile.path); err != nil {
+			return diff, err
+		}
+	}
+	return diff, nil
+}
+
+// diffContents compares the exported symbols and the needed libraries of two versions of an ELF
+// file, or the classes of two versions of a jar.
+func diffContents(diff *ApexDiff, p, oldPath, newPath string) error {
+	if isJar(p) {
+		oldClasses, err := readClasses(oldPath)
+		if err != nil {
+			return err
+		}
+		newClasses, err := readClasses(newPath)
+		if err != nil {
+			return err
+		}
+		added, removed := diffLists(oldClasses, newClasses)
+		if len(added) > 0 || len(removed) > 0 {
+			diff.Jars = append(diff.Jars, JarDiff{Path: p, AddedClasses: added, RemovedClasses: removed})
+		}
+		return nil
+	}
+
+	oldElf, err := readElf(oldPath)
+	if err != nil {
+		return fmt.Errorf("%s: %s", oldPath, err)
+	}
+	newElf, err := readElf(newPath)
+	if err != nil {
+		return fmt.Errorf("%s: %s", newPath, err)
+	}
+	if oldElf == nil || newElf == nil {
+		return nil
+	}
+	libDiff := NativeLibDiff{Path: p}
+	libDiff.AddedSymbols, libDiff.RemovedSymbols = diffLists(oldElf.symbols, newElf.symbols)
+	libDiff.AddedNeeded, libDiff.RemovedNeeded = diffLists(oldElf.needed, newElf.needed)
+	if len(libDiff.AddedSymbols) > 0 || len(libDiff.RemovedSymbols) > 0 ||
+		len(libDiff.AddedNeeded) > 0 || len(libDiff.RemovedNeeded) > 0 {
+		diff.NativeLibs = append(diff.NativeLibs, libDiff)
+	}
+	return nil
+}
+
+func writeReportText(w io.Writer, report *Report) {
+	for _, name := range report.AddedApexes {
+		fmt.Fprintf(w, "added apex %s\n", name)
+	}
+	for _, name := range report.RemovedApexes {
+		fmt.Fprintf(w, "removed apex %s\n", name)
+	}
+	changed := false
+	for _, diff := range report.Apexes {
+		if diff.Empty() {
+			continue
+		}
+		changed = true
+		writeApexDiffText(w, diff)
+	}
+	if !changed && len(report.AddedApexes) == 0 && len(report.RemovedApexes) == 0 {
+		fmt.Fprintln(w, "No changes.")
+	}
+}
+
+func writeApexDiffText(w io.Writer, diff ApexDiff) {
+	fmt.Fprintf(w, "%s (%s -> %s):\n", diff.Name, diff.Old, diff.New)
+	if len(diff.Manifest) > 0 {
+		fmt.Fprintln(w, "  manifest:")
+		for _, c := range diff.Manifest {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", c.Field, c.Old, c.New)
+		}
+	}
+	if len(diff.AddedFiles) > 0 {
+		fmt.Fprintln(w, "  added files:")
+		for _, f := range diff.AddedFiles {
+			fmt.Fprintf(w, "    %s (%d bytes)\n", f.Path, f.Size)
+		}
+	}
+	if len(diff.RemovedFiles) > 0 {
+		fmt.Fprintln(w, "  removed files:")
+		for _, f := range diff.RemovedFiles {
+			fmt.Fprintf(w, "    %s (%d bytes)\n", f.Path, f.Size)
+		}
+	}
+	if len(diff.ResizedFiles) > 0 {
+		fmt.Fprintln(w, "  resized files:")
+		for _, f := range diff.ResizedFiles {
+			fmt.Fprintf(w, "    %s: %d -> %d (%+d)\n", f.Path, f.OldSize, f.NewSize, f.NewSize-f.OldSize)
+		}
+	}
+	if len(diff.ChangedFiles) > 0 {
+		fmt.Fprintln(w, "  changed files:")
+		for _, p := range diff.ChangedFiles {
+			fmt.Fprintf(w, "    %s\n", p)
+		}
+	}
+	for _, lib := range diff.NativeLibs {
+		fmt.Fprintf(w, "  %s:\n", lib.Path)
+		writeList(w, "added symbol", lib.AddedSymbols)
+		writeList(w, "removed symbol", lib.RemovedSymbols)
+		writeList(w, "added needed", lib.AddedNeeded)
+		writeList(w, "removed needed", lib.RemovedNeeded)
+	}
+	for _, jar := range diff.Jars {
+		fmt.Fprintf(w, "  %s:\n", jar.Path)
+		writeList(w, "added class", jar.AddedClasses)
+		writeList(w, "removed class", jar.RemovedClasses)
+	}
+}
+
+func writeList(w io.Writer, title string, list []string) {
+	for _, s := range list {
+		fmt.Fprintf(w, "    %s %s\n", title, s)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string][]byte) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, content, 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func manifest(name, version string) []byte {
+	return append(protoString(1, name), protoString(5, version)...)
+}
+
+func TestDiffApexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	oldDir := filepath.Join(dir, "old")
+	newDir := filepath.Join(dir, "new")
+	writeFiles(t, oldDir, map[string][]byte{
+		"apex_manifest.pb":   manifest("com.android.foo", "1.0"),
+		"etc/removed.txt":    []byte("removed"),
+		"etc/resized.txt":    []byte("small"),
+		"etc/changed.txt":    []byte("before"),
+		"etc/same.txt":       []byte("same"),
+		"lost+found/ignored": []byte("ignored"),
+	})
+	writeFiles(t, newDir, map[string][]byte{
+		"apex_manifest.pb": manifest("com.android.foo", "2.0"),
+		"etc/added.txt":    []byte("added"),
+		"etc/resized.txt":  []byte("bigger"),
+		"etc/changed.txt":  []byte("after!"),
+		"etc/same.txt":     []byte("same"),
+	})
+	writeZip(t, filepath.Join(oldDir, "javalib/foo.jar"), map[string][]byte{
+		"classes.dex": buildDex("Lcom/android/Foo;", "Lcom/android/Old;"),
+	})
+	writeZip(t, filepath.Join(newDir, "javalib/foo.jar"), map[string][]byte{
+		"classes.dex": buildDex("Lcom/android/Foo;", "Lcom/android/New;"),
+	})
+
+	oldApex, err := loadDir(oldDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newApex, err := loadDir(newDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := diffApexes("com.android.foo", "old", "new", oldApex, newApex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := ApexDiff{
+		Name:         "com.android.foo",
+		Old:          "old",
+		New:          "new",
+		Manifest:     []ManifestChange{{Field: "versionName", Old: "1.0", New: "2.0"}},
+		AddedFiles:   []FileSize{{Path: "etc/added.txt", Size: 5}},
+		RemovedFiles: []FileSize{{Path: "etc/removed.txt", Size: 7}},
+		ResizedFiles: []FileResize{{Path: "etc/resized.txt", OldSize: 5, NewSize: 6}},
+		ChangedFiles: []string{"apex_manifest.pb", "etc/changed.txt", "javalib/foo.jar"},
+		Jars: []JarDiff{{
+			Path:           "javalib/foo.jar",
+			AddedClasses:   []string{"com.android.New"},
+			RemovedClasses: []string{"com.android.Old"},
+		}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected diff:\n%+v\ngot:\n%+v", expected, got)
+	}
+
+	buf := &bytes.Buffer{}
+	writeReportText(buf, &Report{Apexes: []ApexDiff{got}})
+	for _, s := range []string{
+		"com.android.foo (old -> new):",
+		`    versionName: "1.0" -> "2.0"`,
+		"    etc/added.txt (5 bytes)",
+		"    etc/resized.txt: 5 -> 6 (+1)",
+		"    added class com.android.New",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in the text report, got:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestDiffDirectoriesOfApexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// Directories of flattened APEXes, as in $OUT/system/apex.
+	writeFiles(t, filepath.Join(dir, "old"), map[string][]byte{
+		"com.android.foo/apex_manifest.pb": manifest("com.android.foo", "1"),
+		"com.android.bar/apex_manifest.pb": manifest("com.android.bar", "1"),
+	})
+	writeFiles(t, filepath.Join(dir, "new"), map[string][]byte{
+		"com.android.foo/apex_manifest.pb": manifest("com.android.foo", "1"),
+		"com.android.baz/apex_manifest.pb": manifest("com.android.baz", "1"),
+	})
+
+	report, err := diff(filepath.Join(dir, "old"), filepath.Join(dir, "new"), tools{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"com.android.baz"}; !reflect.DeepEqual(report.AddedApexes, expected) {
+		t.Errorf("expected added apexes %q, got %q", expected, report.AddedApexes)
+	}
+	if expected := []string{"com.android.bar"}; !reflect.DeepEqual(report.RemovedApexes, expected) {
+		t.Errorf("expected removed apexes %q, got %q", expected, report.RemovedApexes)
+	}
+	if len(report.Apexes) != 1 || report.Apexes[0].Name != "com.android.foo" || !report.Apexes[0].Empty() {
+		t.Errorf("expected no changes in com.android.foo, got %+v", report.Apexes)
+	}
+
+	if _, err := diff(filepath.Join(dir, "old"), filepath.Join(dir, "new", "com.android.foo"), tools{}); err == nil {
+		t.Errorf("expected an error comparing a directory of APEXes with an APEX")
+	}
+}
+
+func TestLoadInstalledFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex_diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "installed-files.txt")
+	writeFiles(t, dir, map[string][]byte{
+		"installed-files.txt": []byte("1024 ./lib64/libfoo.so\n12 ./etc/foo.txt\n"),
+	})
+	contents, err := loadInstalledFiles(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]apexFile{
+		"lib64/libfoo.so": {size: 1024},
+		"etc/foo.txt":     {size: 12},
+	}
+	if !reflect.DeepEqual(contents.files, expected) {
+		t.Errorf("expected files %+v, got %+v", expected, contents.files)
+	}
+}
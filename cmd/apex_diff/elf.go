@@ -0,0 +1,124 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"debug/elf"
+	"io"
+	"os"
+	"sort"
+)
+
+// elfInfo is what is compared of the native libraries and binaries of an APEX.
+type elfInfo struct {
+	// symbols are the sorted names of the dynamic symbols that the file exports.
+	symbols []string
+	// needed are the libraries in the DT_NEEDED entries of the file.
+	needed []string
+}
+
+// readElf returns the exported symbols and the needed libraries of an ELF file, or nil if the file
+// isn't an ELF file.
+func readElf(path string) (*elfInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	magic := make([]byte, len(elf.ELFMAG))
+	if _, err := io.ReadFull(f, magic); err != nil || !bytes.Equal(magic, []byte(elf.ELFMAG)) {
+		return nil, nil
+	}
+
+	ef, err := elf.NewFile(f)
+	if err != nil {
+		return nil, err
+	}
+	defer ef.Close()
+
+	info := &elfInfo{}
+	symbols, err := ef.DynamicSymbols()
+	if err != nil && err != elf.ErrNoSymbols {
+		return nil, err
+	}
+	for _, sym := range symbols {
+		if isExported(sym) {
+			info.symbols = append(info.symbols, sym.Name)
+		}
+	}
+	info.symbols = sortedUnique(info.symbols)
+
+	info.needed, err = ef.ImportedLibraries()
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// isExported returns true if the dynamic symbol is defined by the file and visible to others.
+func isExported(sym elf.Symbol) bool {
+	if sym.Section == elf.SHN_UNDEF || sym.Name == "" {
+		return false
+	}
+	switch elf.ST_BIND(sym.Info) {
+	case elf.STB_GLOBAL, elf.STB_WEAK:
+	default:
+		return false
+	}
+	switch elf.ST_TYPE(sym.Info) {
+	case elf.STT_SECTION, elf.STT_FILE:
+		return false
+	}
+	switch elf.ST_VISIBILITY(sym.Other) {
+	case elf.STV_HIDDEN, elf.STV_INTERNAL:
+		return false
+	}
+	return true
+}
+
+func sortedUnique(list []string) []string {
+	sort.Strings(list)
+	var ret []string
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+// diffLists returns the strings that are only in the new list and those that are only in the old
+// one, sorted.
+func diffLists(oldList, newList []string) (added, removed []string) {
+	oldSet := make(map[string]bool)
+	for _, s := range oldList {
+		oldSet[s] = true
+	}
+	newSet := make(map[string]bool)
+	for _, s := range newList {
+		newSet[s] = true
+		if !oldSet[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range oldList {
+		if !newSet[s] {
+			removed = append(removed, s)
+		}
+	}
+	return sortedUnique(added), sortedUnique(removed)
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apexManifestFields are the names of the fields of the ApexManifest message of
+// system/apex/proto/apex_manifest.proto.  Other fields are named by number.
+var apexManifestFields = map[uint64]string{
+	1: "name",
+	2: "version",
+	3: "preInstallHook",
+	4: "postInstallHook",
+	5: "versionName",
+	6: "noCode",
+	7: "provideNativeLibs",
+	8: "requireNativeLibs",
+	9: "jniLibs",
+}
+
+// decodeApexManifest decodes the fields of an apex_manifest.pb.  The values of repeated fields are
+// joined with commas.  The schema isn't needed to compare manifests, so all the fields are
+// decoded from the wire format, with varints as numbers and length-delimited fields as strings.
+func decodeApexManifest(buf []byte) (map[string]string, error) {
+	values := make(map[string][]string)
+	for len(buf) > 0 {
+		tag, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid apex manifest: bad field tag")
+		}
+		buf = buf[n:]
+
+		field := apexManifestFields[tag>>3]
+		if field == "" {
+			field = "field_" + strconv.FormatUint(tag>>3, 10)
+		}
+
+		var value string
+		switch tag & 7 {
+		case 0:
+			v, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return nil, fmt.Errorf("invalid apex manifest: bad varint in %s", field)
+			}
+			value = strconv.FormatInt(int64(v), 10)
+			buf = buf[n:]
+		case 1:
+			if len(buf) < 8 {
+				return nil, fmt.Errorf("invalid apex manifest: truncated %s", field)
+			}
+			value = strconv.FormatUint(binary.LittleEndian.Uint64(buf), 10)
+			buf = buf[8:]
+		case 2:
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return nil, fmt.Errorf("invalid apex manifest: truncated %s", field)
+			}
+			value = string(buf[n : n+int(l)])
+			buf = buf[n+int(l):]
+		case 5:
+			if len(buf) < 4 {
+				return nil, fmt.Errorf("invalid apex manifest: truncated %s", field)
+			}
+			value = strconv.FormatUint(uint64(binary.LittleEndian.Uint32(buf)), 10)
+			buf = buf[4:]
+		default:
+			return nil, fmt.Errorf("invalid apex manifest: unsupported wire type %d in %s", tag&7, field)
+		}
+		values[field] = append(values[field], value)
+	}
+
+	manifest := make(map[string]string)
+	for field, v := range values {
+		manifest[field] = strings.Join(v, ",")
+	}
+	return manifest, nil
+}
+
+// ManifestChange is a field of the apex manifest whose value differs between the two APEXes.
+type ManifestChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// diffManifests returns the fields of the manifests that differ, sorted by name.
+func diffManifests(oldManifest, newManifest map[string]string) []ManifestChange {
+	fields := make(map[string]bool)
+	for field := range oldManifest {
+		fields[field] = true
+	}
+	for field := range newManifest {
+		fields[field] = true
+	}
+	var sorted []string
+	for field := range fields {
+		sorted = append(sorted, field)
+	}
+	sort.Strings(sorted)
+
+	var changes []ManifestChange
+	for _, field := range sorted {
+		if oldManifest[field] != newManifest[field] {
+			changes = append(changes, ManifestChange{Field: field, Old: oldManifest[field], New: newManifest[field]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// protoString encodes a length-delimited protobuf field.
+func protoString(field byte, s string) []byte {
+	return append([]byte{field<<3 | 2, byte(len(s))}, s...)
+}
+
+func TestDecodeApexManifest(t *testing.T) {
+	var buf []byte
+	buf = append(buf, protoString(1, "com.android.foo")...)
+	buf = append(buf, 2<<3, 0xb9, 0x60) // version: 12345
+	buf = append(buf, protoString(7, "libfoo.so")...)
+	buf = append(buf, protoString(7, "libbar.so")...)
+	buf = append(buf, 12<<3, 1)
+
+	got, err := decodeApexManifest(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"name":              "com.android.foo",
+		"version":           "12345",
+		"provideNativeLibs": "libfoo.so,libbar.so",
+		"field_12":          "1",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected manifest %q, got %q", expected, got)
+	}
+
+	if _, err := decodeApexManifest(protoString(1, "truncated")[:5]); err == nil {
+		t.Errorf("expected an error for a truncated manifest")
+	}
+}
+
+func TestDiffManifests(t *testing.T) {
+	oldManifest := map[string]string{"name": "com.android.foo", "version": "1", "jniLibs": "libfoo.so"}
+	newManifest := map[string]string{"name": "com.android.foo", "version": "2", "versionName": "2.0"}
+
+	expected := []ManifestChange{
+		{Field: "jniLibs", Old: "libfoo.so", New: ""},
+		{Field: "version", Old: "1", New: "2"},
+		{Field: "versionName", Old: "", New: "2.0"},
+	}
+	if got := diffManifests(oldManifest, newManifest); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected changes %+v, got %+v", expected, got)
+	}
+}
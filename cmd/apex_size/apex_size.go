@@ -0,0 +1,137 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// apex_size attributes the size of an APEX to the modules whose files are included in it, checks
+// the APEX against its size budget, and merges the reports of all APEXes into a tree-wide report.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"android/soong/response"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s report -apex <apex file> -contents <contents json> -o <output>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s check [-top <n>] -o <stamp> <report>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge [-top <n>] -o <output> [-text <text output>] [<report>...|@<rsp file>]\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "report":
+		err = reportCommand(os.Args[2:])
+	case "check":
+		err = checkCommand(os.Args[2:])
+	case "merge":
+		err = mergeCommand(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func reportCommand(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	apex := flags.String("apex", "", "path to the built APEX")
+	contentsFile := flags.String("contents", "", "JSON file listing the files of each module in the APEX")
+	out := flags.String("o", "", "output report")
+	flags.Parse(args)
+
+	if *apex == "" || *contentsFile == "" || *out == "" {
+		return fmt.Errorf("-apex, -contents and -o are required")
+	}
+
+	var contents Contents
+	if err := readJSON(*contentsFile, &contents); err != nil {
+		return err
+	}
+	report, err := newReport(contents, *apex)
+	if err != nil {
+		return err
+	}
+	return writeJSON(*out, report)
+}
+
+func checkCommand(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	top := flags.Int("top", 10, "number of modules to list when the APEX is over budget, -1 to list all")
+	out := flags.String("o", "", "stamp file written when the APEX is within its budget")
+	flags.Parse(args)
+
+	if *out == "" || flags.NArg() != 1 {
+		return fmt.Errorf("expected -o and a report, got %q", args)
+	}
+
+	var report Report
+	if err := readJSON(flags.Arg(0), &report); err != nil {
+		return err
+	}
+	if err := checkBudget(report, *top); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*out, nil, 0666)
+}
+
+func mergeCommand(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	top := flags.Int("top", 5, "number of modules to list per APEX in the text report, -1 to list all")
+	out := flags.String("o", "", "output report")
+	textOut := flags.String("text", "", "output text report")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	inputs, err := response.ExpandRspFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	var reports []Report
+	for _, input := range inputs {
+		var report Report
+		if err := readJSON(input, &report); err != nil {
+			return err
+		}
+		reports = append(reports, report)
+	}
+	merged := mergeReports(reports)
+
+	if *textOut != "" {
+		f, err := os.Create(*textOut)
+		if err != nil {
+			return err
+		}
+		writeReportsText(f, merged, *top)
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return writeJSON(*out, merged)
+}
@@ -0,0 +1,235 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Contents lists the files that the modules in an APEX contribute to it. It is written by Soong
+// when it creates the rules to build the APEX.
+type Contents struct {
+	Apex string `json:"apex"`
+
+	// Budget is the maximum size of the APEX file in bytes, or 0 if the APEX has no budget.
+	Budget int64 `json:"budget,omitempty"`
+
+	Files []ContentsFile `json:"files"`
+
+	// RequiredBy maps the modules in the APEX to the modules in the APEX that depend on them,
+	// from the dependency info of the APEX.
+	RequiredBy map[string][]string `json:"required_by,omitempty"`
+}
+
+// ContentsFile is a built file that is copied into an APEX.
+type ContentsFile struct {
+	Module string `json:"module"`
+	Path   string `json:"path"`
+	File   string `json:"file"`
+}
+
+// Report attributes the size of an APEX to the modules whose files are included in it.
+type Report struct {
+	Apex   string `json:"apex"`
+	File   string `json:"file"`
+	Size   int64  `json:"size"`
+	Budget int64  `json:"budget,omitempty"`
+
+	// Modules are sorted by decreasing size. The sizes are those of the files before they are
+	// put in the APEX, so they don't add up to the size of the APEX when its payload is
+	// compressed.
+	Modules []Module `json:"modules"`
+}
+
+// Module is a module and the files it contributes to an APEX.
+type Module struct {
+	Name       string   `json:"name"`
+	Size       int64    `json:"size"`
+	Files      []File   `json:"files"`
+	RequiredBy []string `json:"required_by,omitempty"`
+}
+
+// File is a file in an APEX and its size.
+type File struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// Reports is the tree-wide collection of APEX size reports.
+type Reports struct {
+	Apexes []Report `json:"apexes"`
+}
+
+// OverBudget returns true if the APEX has a size budget and is larger than it.
+func (r Report) OverBudget() bool {
+	return r.Budget > 0 && r.Size > r.Budget
+}
+
+// newReport measures the APEX file and the files listed in contents.
+func newReport(contents Contents, apexFile string) (Report, error) {
+	apexInfo, err := os.Stat(apexFile)
+	if err != nil {
+		return Report{}, err
+	}
+
+	modules := make(map[string]*Module)
+	for _, f := range contents.Files {
+		info, err := os.Stat(f.File)
+		if err != nil {
+			return Report{}, err
+		}
+		m := modules[f.Module]
+		if m == nil {
+			m = &Module{Name: f.Module, RequiredBy: contents.RequiredBy[f.Module]}
+			modules[f.Module] = m
+		}
+		m.Size += info.Size()
+		m.Files = append(m.Files, File{Path: f.Path, Size: info.Size()})
+	}
+
+	report := Report{
+		Apex:    contents.Apex,
+		File:    apexFile,
+		Size:    apexInfo.Size(),
+		Budget:  contents.Budget,
+		Modules: []Module{},
+	}
+	for _, m := range modules {
+		sort.Slice(m.Files, func(i, j int) bool {
+			if m.Files[i].Size != m.Files[j].Size {
+				return m.Files[i].Size > m.Files[j].Size
+			}
+			return m.Files[i].Path < m.Files[j].Path
+		})
+		report.Modules = append(report.Modules, *m)
+	}
+	sort.Slice(report.Modules, func(i, j int) bool {
+		if report.Modules[i].Size != report.Modules[j].Size {
+			return report.Modules[i].Size > report.Modules[j].Size
+		}
+		return report.Modules[i].Name < report.Modules[j].Name
+	})
+	return report, nil
+}
+
+// checkBudget returns an error listing the top largest modules of the APEX if it is larger than
+// its size budget.
+func checkBudget(report Report, top int) error {
+	if !report.OverBudget() {
+		return nil
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s is %s, which exceeds its size budget of %s by %s.\n", report.Apex,
+		formatSize(report.Size), formatSize(report.Budget), formatSize(report.Size-report.Budget))
+	fmt.Fprintf(b, "Largest modules in %s (before compression):\n", report.Apex)
+	writeModules(b, report.Modules, top)
+	fmt.Fprintf(b, "Reduce the size of %s, or raise its size_budget property or its entry in "+
+		"PRODUCT_APEX_SIZE_BUDGETS.", report.Apex)
+	return fmt.Errorf("%s", b.String())
+}
+
+// writeModules writes the top largest modules, -1 for all of them, one per line.
+func writeModules(w io.Writer, modules []Module, top int) {
+	for i, m := range modules {
+		if top >= 0 && i >= top {
+			fmt.Fprintf(w, "  ... and %d more\n", len(modules)-top)
+			break
+		}
+		var paths []string
+		for _, f := range m.Files {
+			paths = append(paths, f.Path)
+		}
+		fmt.Fprintf(w, "  %10s  %s (%s)", formatSize(m.Size), m.Name, strings.Join(paths, ", "))
+		if len(m.RequiredBy) > 0 {
+			fmt.Fprintf(w, " required by %s", strings.Join(m.RequiredBy, ", "))
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+// writeReportsText writes the tree-wide report as a table of the APEXes sorted by decreasing
+// size, followed by the top largest modules of each APEX.
+func writeReportsText(w io.Writer, reports Reports, top int) {
+	fmt.Fprintf(w, "%10s  %10s  %6s  %s\n", "SIZE", "BUDGET", "USED", "APEX")
+	for _, r := range reports.Apexes {
+		budget, used := "-", "-"
+		if r.Budget > 0 {
+			budget = formatSize(r.Budget)
+			used = fmt.Sprintf("%d%%", r.Size*100/r.Budget)
+		}
+		fmt.Fprintf(w, "%10s  %10s  %6s  %s", formatSize(r.Size), budget, used, r.Apex)
+		if r.OverBudget() {
+			fmt.Fprint(w, "  OVER BUDGET")
+		}
+		fmt.Fprintln(w)
+	}
+
+	for _, r := range reports.Apexes {
+		fmt.Fprintf(w, "\n%s (%s):\n", r.Apex, formatSize(r.Size))
+		writeModules(w, r.Modules, top)
+	}
+}
+
+// mergeReports merges the reports of individual APEXes, sorted by decreasing size.
+func mergeReports(reports []Report) Reports {
+	merged := Reports{Apexes: append([]Report{}, reports...)}
+	sort.SliceStable(merged.Apexes, func(i, j int) bool {
+		if merged.Apexes[i].Size != merged.Apexes[j].Size {
+			return merged.Apexes[i].Size > merged.Apexes[j].Size
+		}
+		return merged.Apexes[i].Apex < merged.Apexes[j].Apex
+	})
+	return merged
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(size int64) string {
+	switch {
+	case size >= 1<<30:
+		return fmt.Sprintf("%.1f GiB", float64(size)/(1<<30))
+	case size >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(size)/(1<<20))
+	case size >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(size)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", size)
+	}
+}
+
+func readJSON(path string, v interface{}) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return nil
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0666)
+}
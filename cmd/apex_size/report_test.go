@@ -0,0 +1,195 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNewReport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "apex_size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]int{
+		"myapex.apex": 4096,
+		"libfoo.so":   1000,
+		"libfoo32.so": 800,
+		"libbar.so":   2000,
+		"foo.jar":     300,
+	}
+	for name, size := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, size), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	contents := Contents{
+		Apex:   "myapex",
+		Budget: 8192,
+		Files: []ContentsFile{
+			{Module: "libfoo", Path: "lib64/libfoo.so", File: filepath.Join(dir, "libfoo.so")},
+			{Module: "libfoo", Path: "lib/libfoo.so", File: filepath.Join(dir, "libfoo32.so")},
+			{Module: "libbar", Path: "lib64/libbar.so", File: filepath.Join(dir, "libbar.so")},
+			{Module: "foo", Path: "javalib/foo.jar", File: filepath.Join(dir, "foo.jar")},
+		},
+		RequiredBy: map[string][]string{"libbar": {"libfoo"}},
+	}
+
+	got, err := newReport(contents, filepath.Join(dir, "myapex.apex"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Report{
+		Apex:   "myapex",
+		File:   filepath.Join(dir, "myapex.apex"),
+		Size:   4096,
+		Budget: 8192,
+		Modules: []Module{
+			{
+				Name:       "libbar",
+				Size:       2000,
+				Files:      []File{{Path: "lib64/libbar.so", Size: 2000}},
+				RequiredBy: []string{"libfoo"},
+			},
+			{
+				Name: "libfoo",
+				Size: 1800,
+				Files: []File{
+					{Path: "lib64/libfoo.so", Size: 1000},
+					{Path: "lib/libfoo.so", Size: 800},
+				},
+			},
+			{
+				Name:  "foo",
+				Size:  300,
+				Files: []File{{Path: "javalib/foo.jar", Size: 300}},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected report:\n%+v\ngot:\n%+v", expected, got)
+	}
+
+	contents.Files = append(contents.Files, ContentsFile{Module: "missing", File: filepath.Join(dir, "missing")})
+	if _, err := newReport(contents, filepath.Join(dir, "myapex.apex")); err == nil {
+		t.Errorf("expected an error for a missing file")
+	}
+}
+
+func TestCheckBudget(t *testing.T) {
+	report := Report{
+		Apex:   "myapex",
+		Size:   3 << 20,
+		Budget: 2 << 20,
+		Modules: []Module{
+			{
+				Name:       "libbar",
+				Size:       2 << 20,
+				Files:      []File{{Path: "lib64/libbar.so", Size: 2 << 20}},
+				RequiredBy: []string{"libfoo"},
+			},
+			{
+				Name:  "libfoo",
+				Size:  1 << 20,
+				Files: []File{{Path: "lib64/libfoo.so", Size: 1 << 20}},
+			},
+			{
+				Name:  "foo",
+				Size:  100,
+				Files: []File{{Path: "javalib/foo.jar", Size: 100}},
+			},
+		},
+	}
+
+	err := checkBudget(report, 2)
+	if err == nil {
+		t.Fatal("expected an error for an APEX over its budget")
+	}
+	for _, s := range []string{
+		"myapex is 3.0 MiB, which exceeds its size budget of 2.0 MiB by 1.0 MiB.",
+		"     2.0 MiB  libbar (lib64/libbar.so) required by libfoo\n",
+		"     1.0 MiB  libfoo (lib64/libfoo.so)\n",
+		"  ... and 1 more\n",
+	} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("expected %q in the error, got:\n%s", s, err)
+		}
+	}
+
+	report.Budget = 3 << 20
+	if err := checkBudget(report, 2); err != nil {
+		t.Errorf("unexpected error for an APEX within its budget: %s", err)
+	}
+
+	report.Budget = 0
+	if err := checkBudget(report, 2); err != nil {
+		t.Errorf("unexpected error for an APEX without a budget: %s", err)
+	}
+}
+
+func TestMergeReports(t *testing.T) {
+	merged := mergeReports([]Report{
+		{Apex: "small", Size: 100, Modules: []Module{}},
+		{Apex: "large", Size: 3 << 20, Budget: 2 << 20, Modules: []Module{}},
+		{Apex: "medium", Size: 1 << 20, Budget: 2 << 20, Modules: []Module{}},
+	})
+
+	var names []string
+	for _, r := range merged.Apexes {
+		names = append(names, r.Apex)
+	}
+	if expected := []string{"large", "medium", "small"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected apexes %q, got %q", expected, names)
+	}
+
+	buf := &bytes.Buffer{}
+	writeReportsText(buf, merged, 5)
+	for _, s := range []string{
+		"   3.0 MiB     2.0 MiB    150%  large  OVER BUDGET\n",
+		"   1.0 MiB     2.0 MiB     50%  medium\n",
+		"     100 B           -       -  small\n",
+	} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("expected %q in the text report, got:\n%s", s, buf.String())
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 30, "3.0 GiB"},
+	}
+	for _, tc := range testCases {
+		if got := formatSize(tc.size); got != tc.expected {
+			t.Errorf("formatSize(%d): expected %q, got %q", tc.size, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,379 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// bpArch holds the dependencies of a module that only apply to some architectures.
+type bpArch struct {
+	Name       string
+	Rustlibs   []string
+	ProcMacros []string
+}
+
+// bpModule is a rust_library, rust_proc_macro or rust_test module generated for a crate.
+type bpModule struct {
+	Type      string
+	Name      string
+	CrateName string
+	Srcs      []string
+	Edition   string
+	Features  []string
+	Cfgs      []string
+
+	Rustlibs   []string
+	ProcMacros []string
+	Arch       []bpArch
+
+	// Version is the version of the crate, which is exposed through CARGO_PKG_VERSION.
+	Version string
+	// BuildScript is the rust_build_script module that runs the build script of the crate.
+	BuildScript string
+
+	Test     bool
+	UnitTest bool
+	// Doctests is true for member libraries whose documentation tests are run.
+	Doctests bool
+}
+
+var bpTemplate = template.Must(template.New("bp").Parse(`
+{{.Type}} {
+    name: "{{.Name}}",
+    {{- if and (ne .Type "rust_proc_macro") (ne .Type "rust_build_script")}}
+    host_supported: true,
+    {{- end}}
+    crate_name: "{{.CrateName}}",
+    cargo_env_compat: true,
+    cargo_pkg_version: "{{.Version}}",
+    srcs: [
+        {{- range .Srcs}}
+        "{{.}}",
+        {{- end}}
+    ],
+    {{- if .Doctests}}
+    doctests: true,
+    {{- end}}
+    {{- if .Test}}
+    test_suites: ["general-tests"],
+    auto_gen_config: true,
+    {{- if .UnitTest}}
+    test_options: {
+        unit_test: true,
+    },
+    {{- end}}
+    {{- end}}
+    edition: "{{.Edition}}",
+    {{- if .BuildScript}}
+    build_script: "{{.BuildScript}}",
+    {{- end}}
+    {{- if .Features}}
+    features: [
+        {{- range .Features}}
+        "{{.}}",
+        {{- end}}
+    ],
+    {{- end}}
+    {{- if .Cfgs}}
+    cfgs: [
+        {{- range .Cfgs}}
+        "{{.}}",
+        {{- end}}
+    ],
+    {{- end}}
+    {{- if .Rustlibs}}
+    rustlibs: [
+        {{- range .Rustlibs}}
+        "{{.}}",
+        {{- end}}
+    ],
+    {{- end}}
+    {{- if .ProcMacros}}
+    proc_macros: [
+        {{- range .ProcMacros}}
+        "{{.}}",
+        {{- end}}
+    ],
+    {{- end}}
+    {{- if .Arch}}
+    arch: {
+        {{- range .Arch}}
+        {{.Name}}: {
+            {{- if .Rustlibs}}
+            rustlibs: [
+                {{- range .Rustlibs}}
+                "{{.}}",
+                {{- end}}
+            ],
+            {{- end}}
+            {{- if .ProcMacros}}
+            proc_macros: [
+                {{- range .ProcMacros}}
+                "{{.}}",
+                {{- end}}
+            ],
+            {{- end}}
+        },
+        {{- end}}
+    },
+    {{- end}}
+}
+`))
+
+// generator turns resolved crates into Android.bp modules.
+type generator struct {
+	// root is the directory the Android.bp file is generated for, which all the sources are
+	// relative to.
+	root string
+	lock *LockFile
+	// cfgs are extra cfgs to pass to each crate, by crate name.
+	cfgs map[string][]string
+	// tests is true if tests are generated for the members of the workspace.
+	tests bool
+	// warn is called for the parts of crates that can't be expressed in Android.bp files.
+	warn func(format string, args ...interface{})
+}
+
+// moduleName returns the name of the library module of a crate, which has a version suffix if
+// several versions of the crate are locked.
+func (g *generator) moduleName(c *Crate) string {
+	return "lib" + c.Manifest.CrateName() + g.versionSuffix(c)
+}
+
+func (g *generator) versionSuffix(c *Crate) string {
+	if g.lock.versions(c.Locked.Name) > 1 {
+		return "_" + strings.NewReplacer(".", "_", "-", "_", "+", "_").Replace(c.Locked.Version)
+	}
+	return ""
+}
+
+// src returns the path of a source file of a crate relative to the root directory.
+func (g *generator) src(c *Crate, path string) (string, error) {
+	rel, err := filepath.Rel(g.root, filepath.Join(c.Manifest.Dir, path))
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s: %s is outside of %s", c.Locked.ID(), path, g.root)
+	}
+	return rel, nil
+}
+
+// modules returns the modules generated for the crates.
+func (g *generator) modules(crates []*Crate) ([]bpModule, error) {
+	var modules []bpModule
+	for _, c := range crates {
+		if c.Manifest.Build != "" {
+			buildScript, err := g.buildScript(c)
+			if err != nil {
+				return nil, err
+			}
+			modules = append(modules, buildScript)
+		}
+
+		if c.Manifest.Lib != nil {
+			lib, err := g.module(c, c.Manifest.Lib.Path, false)
+			if err != nil {
+				return nil, err
+			}
+			lib.Name = g.moduleName(c)
+			if c.Manifest.IsProcMacro() {
+				lib.Type = "rust_proc_macro"
+			} else {
+				lib.Type = "rust_library"
+				lib.Doctests = g.tests && c.Member && c.Manifest.Lib.Doctest
+			}
+			modules = append(modules, lib)
+		}
+
+		if !g.tests || !c.Member {
+			continue
+		}
+
+		if c.Manifest.Lib != nil && c.Manifest.Lib.Test {
+			test, err := g.module(c, c.Manifest.Lib.Path, true)
+			if err != nil {
+				return nil, err
+			}
+			test.Name = g.testName(c, c.Manifest.Lib.Path)
+			test.UnitTest = true
+			modules = append(modules, test)
+		}
+
+		for _, t := range c.Manifest.Tests {
+			if !t.Test {
+				continue
+			}
+			test, err := g.module(c, t.Path, true)
+			if err != nil {
+				return nil, err
+			}
+			test.Name = g.testName(c, t.Path)
+			test.CrateName = strings.ReplaceAll(t.Name, "-", "_")
+			// Integration tests use the library of the crate like any other dependency.
+			if c.Manifest.Lib != nil {
+				if c.Manifest.IsProcMacro() {
+					test.ProcMacros = addDep(test.ProcMacros, g.moduleName(c))
+				} else {
+					test.Rustlibs = addDep(test.Rustlibs, g.moduleName(c))
+				}
+				sort.Strings(test.ProcMacros)
+				sort.Strings(test.Rustlibs)
+			}
+			modules = append(modules, test)
+		}
+	}
+	return modules, nil
+}
+
+// buildScript returns the rust_build_script module that runs the build script of a crate.
+func (g *generator) buildScript(c *Crate) (bpModule, error) {
+	src, err := g.src(c, c.Manifest.Build)
+	if err != nil {
+		return bpModule{}, err
+	}
+	if len(c.Manifest.BuildDependencies) > 0 {
+		g.warn("%s: the build dependencies of the build script %s are not generated",
+			c.Locked.ID(), c.Manifest.Build)
+	}
+	g.warn("%s: list the files the build script %s writes to OUT_DIR in the out property of %s",
+		c.Locked.ID(), c.Manifest.Build, g.buildScriptName(c))
+	return bpModule{
+		Type:      "rust_build_script",
+		Name:      g.buildScriptName(c),
+		CrateName: "build_script_build",
+		Srcs:      []string{src},
+		Edition:   c.Manifest.Edition,
+		Version:   c.Locked.Version,
+	}, nil
+}
+
+func (g *generator) buildScriptName(c *Crate) string {
+	return g.moduleName(c) + "_build_script"
+}
+
+// testName returns the name of the test module for a test source of a crate, following the
+// <crate>_test_<path> convention, e.g. foo_test_src_lib for the unit tests in src/lib.rs.
+func (g *generator) testName(c *Crate, path string) string {
+	path = strings.TrimSuffix(filepath.ToSlash(path), ".rs")
+	path = strings.NewReplacer("/", "_", "-", "_", ".", "_").Replace(path)
+	return c.Manifest.CrateName() + g.versionSuffix(c) + "_test_" + path
+}
+
+// module returns a module that builds a source of a crate with its enabled features and
+// dependencies, and with its development dependencies if it is a test.
+func (g *generator) module(c *Crate, path string, test bool) (bpModule, error) {
+	src, err := g.src(c, path)
+	if err != nil {
+		return bpModule{}, err
+	}
+	m := bpModule{
+		Type:      "rust_test",
+		CrateName: c.Manifest.CrateName(),
+		Srcs:      []string{src},
+		Edition:   c.Manifest.Edition,
+		Features:  c.SortedFeatures(),
+		Cfgs:      g.cfgs[c.Manifest.CrateName()],
+		Version:   c.Locked.Version,
+		Test:      test,
+	}
+	if c.Manifest.Build != "" {
+		m.BuildScript = g.buildScriptName(c)
+	}
+
+	arches := make(map[string]*bpArch)
+	for _, dep := range c.deps {
+		if !c.Enabled(dep) || (dep.Dev && !test) {
+			continue
+		}
+		target := c.resolved[dep.Name]
+		if target == nil || target.Manifest.Lib == nil {
+			continue
+		}
+		if dep.Name != dep.Package {
+			g.warn("%s: dependency %s is renamed from %s, which is not supported",
+				c.Locked.ID(), dep.Name, dep.Package)
+		}
+
+		name := g.moduleName(target)
+		if dep.Arches == nil {
+			if target.Manifest.IsProcMacro() {
+				m.ProcMacros = addDep(m.ProcMacros, name)
+			} else {
+				m.Rustlibs = addDep(m.Rustlibs, name)
+			}
+			continue
+		}
+		for _, arch := range dep.Arches {
+			if arches[arch] == nil {
+				arches[arch] = &bpArch{Name: arch}
+			}
+			if target.Manifest.IsProcMacro() {
+				arches[arch].ProcMacros = addDep(arches[arch].ProcMacros, name)
+			} else {
+				arches[arch].Rustlibs = addDep(arches[arch].Rustlibs, name)
+			}
+		}
+	}
+
+	sort.Strings(m.Rustlibs)
+	sort.Strings(m.ProcMacros)
+	for _, arch := range androidArches {
+		if a, ok := arches[arch.Name]; ok {
+			// Dependencies that also apply to all architectures are already listed.
+			a.Rustlibs = removeDeps(a.Rustlibs, m.Rustlibs)
+			a.ProcMacros = removeDeps(a.ProcMacros, m.ProcMacros)
+			if len(a.Rustlibs) > 0 || len(a.ProcMacros) > 0 {
+				sort.Strings(a.Rustlibs)
+				sort.Strings(a.ProcMacros)
+				m.Arch = append(m.Arch, *a)
+			}
+		}
+	}
+	return m, nil
+}
+
+// addDep appends a module to a list of dependencies unless it is already present.
+func addDep(deps []string, dep string) []string {
+	if contains(deps, dep) {
+		return deps
+	}
+	return append(deps, dep)
+}
+
+// removeDeps returns the dependencies that are not in remove.
+func removeDeps(deps []string, remove []string) []string {
+	var ret []string
+	for _, d := range deps {
+		if !contains(remove, d) {
+			ret = append(ret, d)
+		}
+	}
+	return ret
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
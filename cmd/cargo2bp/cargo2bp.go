@@ -0,0 +1,171 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cargo2bp generates Android.bp modules for a Cargo package or workspace and the crates it
+// depends on. It only reads the Cargo.toml and Cargo.lock files of the workspace and of the
+// vendored crates, and never accesses the network.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/bpfix/bpfix"
+)
+
+// Cfgs maps crate names to extra cfgs passed to them, from -cfgs <crate>=<cfg>[,<cfg>...]
+// flags.
+type Cfgs map[string][]string
+
+func (c Cfgs) String() string {
+	return ""
+}
+
+func (c Cfgs) Set(v string) error {
+	split := strings.SplitN(v, "=", 2)
+	if len(split) != 2 || split[0] == "" {
+		return fmt.Errorf("Must be in the form of <crate>=<cfg>[,<cfg>...]")
+	}
+	c[split[0]] = append(c[split[0]], strings.Split(split[1], ",")...)
+	return nil
+}
+
+type StringList []string
+
+func (l *StringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *StringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, `cargo2bp, a tool to create Android.bp files from Cargo packages
+
+The tool reads the Cargo.toml and Cargo.lock files of a package or workspace and of the crates it
+depends on, which must be vendored, and writes rust_library, rust_proc_macro, rust_build_script and
+rust_test modules for them to stdout. Source paths are relative to <dir>, which is where the Android.bp file is
+expected to be written.
+
+Usage: %s [-vendor <dir>] [-features <feature>[,<feature>...]] [-cfgs <crate>=<cfg>[,<cfg>...]]
+          [-skip-tests] [<dir>]
+
+  -vendor <dir>
+     Directory that contains the vendored crates, as written by "cargo vendor". Defaults to
+     <dir>/vendor.
+  -features <feature>[,<feature>...]
+     Features to enable on the members of the workspace instead of their default features.
+  -cfgs <crate>=<cfg>[,<cfg>...]
+     Extra cfgs to pass to a crate. Can be specified multiple times.
+  -skip-tests
+     Don't generate rust_test modules for the members of the workspace.
+`, os.Args[0])
+	}
+
+	var vendorDir string
+	var skipTests bool
+	features := StringList{}
+	cfgs := make(Cfgs)
+
+	flag.StringVar(&vendorDir, "vendor", "", "Directory that contains the vendored crates")
+	flag.Var(&features, "features", "Features to enable on the members of the workspace")
+	flag.Var(cfgs, "cfgs", "Extra cfgs to pass to a crate")
+	flag.BoolVar(&skipTests, "skip-tests", false, "Whether to skip tests")
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() == 1 {
+		dir = flag.Arg(0)
+	} else if flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Unused argument detected: %v\n", flag.Args()[1:])
+		os.Exit(1)
+	}
+	if vendorDir == "" {
+		vendorDir = filepath.Join(dir, "vendor")
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "// This is a generated file. Do not modify directly.")
+	fmt.Fprintln(buf, "// Automatically generated with:")
+	fmt.Fprintln(buf, "// cargo2bp", strings.Join(proptools.ShellEscapeList(os.Args[1:]), " "))
+
+	err := generate(buf, osFileSystem{}, dir, vendorDir, features, cfgs, !skipTests, os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	out, err := bpfix.Reformat(buf.String())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error formatting output", err)
+		os.Exit(1)
+	}
+
+	os.Stdout.WriteString(out)
+}
+
+// generate writes the modules for the package or workspace in dir to w, and warnings about the
+// parts of the crates that can't be expressed in Android.bp files to warnings.
+func generate(w io.Writer, fs fileSystem, dir, vendorDir string, features []string, cfgs Cfgs,
+	tests bool, warnings io.Writer) error {
+
+	root, err := readManifest(fs, dir)
+	if err != nil {
+		return err
+	}
+	lock, err := readLockFile(fs, dir)
+	if err != nil {
+		return err
+	}
+
+	r := newResolver(fs, lock, vendorDir, tests)
+	if err := r.addMembers(workspaceMembers(fs, root), features); err != nil {
+		return err
+	}
+
+	g := &generator{
+		root:  dir,
+		lock:  lock,
+		cfgs:  cfgs,
+		tests: tests,
+		warn: func(format string, args ...interface{}) {
+			fmt.Fprintf(warnings, "warning: "+format+"\n", args...)
+		},
+	}
+	modules, err := g.modules(r.sortedCrates())
+	if err != nil {
+		return err
+	}
+
+	for _, m := range modules {
+		if err := bpTemplate.Execute(w, m); err != nil {
+			return fmt.Errorf("Error writing %s: %s", m.Name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testWorkspace = mapFileSystem{
+	"ws/Cargo.toml": `
+[workspace]
+members = ["crates/*"]
+exclude = ["crates/skip"]
+`,
+	"ws/Cargo.lock": `
+version = 3
+
+[[package]]
+name = "app"
+version = "0.1.0"
+dependencies = [
+ "neon",
+ "pretty",
+ "serde",
+ "util",
+ "winapi",
+]
+
+[[package]]
+name = "itoa"
+version = "1.0.1"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "neon"
+version = "0.3.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "pretty"
+version = "0.1.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "serde"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+dependencies = [
+ "serde_derive",
+]
+
+[[package]]
+name = "serde_derive"
+version = "1.0.0"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+
+[[package]]
+name = "util"
+version = "0.2.0"
+dependencies = [
+ "itoa",
+]
+
+[[package]]
+name = "winapi"
+version = "0.3.9"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`,
+	"ws/crates/app/Cargo.toml": `
+[package]
+name = "app"
+version = "0.1.0"
+edition = "2021"
+
+[dependencies]
+util = { path = "../util", features = ["fast"] }
+serde = { version = "1", features = ["derive"] }
+
+[target.'cfg(target_arch = "aarch64")'.dependencies]
+neon = "0.3"
+
+[target.'cfg(windows)'.dependencies]
+winapi = "0.3"
+
+[dev-dependencies]
+pretty = "0.1"
+`,
+	"ws/crates/app/src/lib.rs":     "",
+	"ws/crates/app/tests/smoke.rs": "",
+	"ws/crates/util/Cargo.toml": `
+[package]
+name = "util"
+version = "0.2.0"
+edition = "2018"
+
+[features]
+default = []
+fast = ["dep:itoa"]
+
+[dependencies]
+itoa = { version = "1", optional = true }
+`,
+	"ws/crates/util/src/lib.rs": "",
+	"ws/crates/skip/Cargo.toml": `
+[package]
+name = "skip"
+version = "0.0.1"
+`,
+	"ws/vendor/itoa-1.0.1/Cargo.toml": `
+[package]
+name = "itoa"
+version = "1.0.1"
+`,
+	"ws/vendor/itoa-1.0.1/src/lib.rs": "",
+	"ws/vendor/neon-0.3.0/Cargo.toml": `
+[package]
+name = "neon"
+version = "0.3.0"
+edition = "2018"
+`,
+	"ws/vendor/neon-0.3.0/src/lib.rs": "",
+	"ws/vendor/pretty/Cargo.toml": `
+[package]
+name = "pretty"
+version = "0.1.0"
+`,
+	"ws/vendor/pretty/src/lib.rs": "",
+	"ws/vendor/serde/Cargo.toml": `
+[package]
+name = "serde"
+version = "1.0.0"
+edition = "2018"
+
+[features]
+default = ["std"]
+std = []
+derive = ["serde_derive"]
+
+[dependencies]
+serde_derive = { version = "1", optional = true }
+`,
+	"ws/vendor/serde/build.rs":   "",
+	"ws/vendor/serde/src/lib.rs": "",
+	"ws/vendor/serde_derive/Cargo.toml": `
+[package]
+name = "serde_derive"
+version = "1.0.0"
+
+[lib]
+proc-macro = true
+`,
+	"ws/vendor/serde_derive/src/lib.rs": "",
+}
+
+func TestGenerate(t *testing.T) {
+	buf := &bytes.Buffer{}
+	warnings := &bytes.Buffer{}
+	cfgs := Cfgs{"serde": {"std_backtrace"}}
+	err := generate(buf, testWorkspace, "ws", "ws/vendor", nil, cfgs, true, warnings)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := `
+rust_library {
+    name: "libapp",
+    host_supported: true,
+    crate_name: "app",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.1.0",
+    srcs: [
+        "crates/app/src/lib.rs",
+    ],
+    doctests: true,
+    edition: "2021",
+    rustlibs: [
+        "libserde",
+        "libutil",
+    ],
+    arch: {
+        arm64: {
+            rustlibs: [
+                "libneon",
+            ],
+        },
+    },
+}
+
+rust_test {
+    name: "app_test_src_lib",
+    host_supported: true,
+    crate_name: "app",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.1.0",
+    srcs: [
+        "crates/app/src/lib.rs",
+    ],
+    test_suites: ["general-tests"],
+    auto_gen_config: true,
+    test_options: {
+        unit_test: true,
+    },
+    edition: "2021",
+    rustlibs: [
+        "libpretty",
+        "libserde",
+        "libutil",
+    ],
+    arch: {
+        arm64: {
+            rustlibs: [
+                "libneon",
+            ],
+        },
+    },
+}
+
+rust_test {
+    name: "app_test_tests_smoke",
+    host_supported: true,
+    crate_name: "smoke",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.1.0",
+    srcs: [
+        "crates/app/tests/smoke.rs",
+    ],
+    test_suites: ["general-tests"],
+    auto_gen_config: true,
+    edition: "2021",
+    rustlibs: [
+        "libapp",
+        "libpretty",
+        "libserde",
+        "libutil",
+    ],
+    arch: {
+        arm64: {
+            rustlibs: [
+                "libneon",
+            ],
+        },
+    },
+}
+
+rust_library {
+    name: "libitoa",
+    host_supported: true,
+    crate_name: "itoa",
+    cargo_env_compat: true,
+    cargo_pkg_version: "1.0.1",
+    srcs: [
+        "vendor/itoa-1.0.1/src/lib.rs",
+    ],
+    edition: "2015",
+}
+
+rust_library {
+    name: "libneon",
+    host_supported: true,
+    crate_name: "neon",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.3.0",
+    srcs: [
+        "vendor/neon-0.3.0/src/lib.rs",
+    ],
+    edition: "2018",
+}
+
+rust_library {
+    name: "libpretty",
+    host_supported: true,
+    crate_name: "pretty",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.1.0",
+    srcs: [
+        "vendor/pretty/src/lib.rs",
+    ],
+    edition: "2015",
+}
+
+rust_build_script {
+    name: "libserde_build_script",
+    crate_name: "build_script_build",
+    cargo_env_compat: true,
+    cargo_pkg_version: "1.0.0",
+    srcs: [
+        "vendor/serde/build.rs",
+    ],
+    edition: "2018",
+}
+
+rust_library {
+    name: "libserde",
+    host_supported: true,
+    crate_name: "serde",
+    cargo_env_compat: true,
+    cargo_pkg_version: "1.0.0",
+    srcs: [
+        "vendor/serde/src/lib.rs",
+    ],
+    edition: "2018",
+    build_script: "libserde_build_script",
+    features: [
+        "default",
+        "derive",
+        "serde_derive",
+        "std",
+    ],
+    cfgs: [
+        "std_backtrace",
+    ],
+    proc_macros: [
+        "libserde_derive",
+    ],
+}
+
+rust_proc_macro {
+    name: "libserde_derive",
+    crate_name: "serde_derive",
+    cargo_env_compat: true,
+    cargo_pkg_version: "1.0.0",
+    srcs: [
+        "vendor/serde_derive/src/lib.rs",
+    ],
+    edition: "2015",
+}
+
+rust_library {
+    name: "libutil",
+    host_supported: true,
+    crate_name: "util",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.2.0",
+    srcs: [
+        "crates/util/src/lib.rs",
+    ],
+    doctests: true,
+    edition: "2018",
+    features: [
+        "default",
+        "fast",
+    ],
+    rustlibs: [
+        "libitoa",
+    ],
+}
+
+rust_test {
+    name: "util_test_src_lib",
+    host_supported: true,
+    crate_name: "util",
+    cargo_env_compat: true,
+    cargo_pkg_version: "0.2.0",
+    srcs: [
+        "crates/util/src/lib.rs",
+    ],
+    test_suites: ["general-tests"],
+    auto_gen_config: true,
+    test_options: {
+        unit_test: true,
+    },
+    edition: "2018",
+    features: [
+        "default",
+        "fast",
+    ],
+    rustlibs: [
+        "libitoa",
+    ],
+}
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+
+	expectedWarnings := "warning: serde 1.0.0: list the files the build script build.rs writes to OUT_DIR " +
+		"in the out property of libserde_build_script\n"
+	if got := warnings.String(); got != expectedWarnings {
+		t.Errorf("expected warnings:\n%s\ngot:\n%s", expectedWarnings, got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		vendorDir string
+		features  []string
+		err       string
+	}{
+		{
+			name:      "unknown feature",
+			vendorDir: "ws/vendor",
+			features:  []string{"missing"},
+			err:       `app 0.1.0: unknown feature "missing"`,
+		},
+		{
+			name:      "not vendored",
+			vendorDir: "ws/third_party",
+			err:       "is not vendored in ws/third_party",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := generate(&bytes.Buffer{}, testWorkspace, "ws", tc.vendorDir, tc.features, nil, false, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// androidArch describes one of the architectures that Android modules are built for, as seen by
+// cfg() expressions.
+type androidArch struct {
+	// Name is the name of the architecture in Android.bp files.
+	Name         string
+	Triple       string
+	TargetArch   string
+	PointerWidth string
+}
+
+var androidArches = []androidArch{
+	{Name: "arm", Triple: "armv7-linux-androideabi", TargetArch: "arm", PointerWidth: "32"},
+	{Name: "arm64", Triple: "aarch64-linux-android", TargetArch: "aarch64", PointerWidth: "64"},
+	{Name: "x86", Triple: "i686-linux-android", TargetArch: "x86", PointerWidth: "32"},
+	{Name: "x86_64", Triple: "x86_64-linux-android", TargetArch: "x86_64", PointerWidth: "64"},
+}
+
+// cfgs returns the cfg options and key/value pairs that rustc sets for the architecture.
+func (a androidArch) cfgs() map[string][]string {
+	return map[string][]string{
+		"unix":                 nil,
+		"target_os":            {"android"},
+		"target_family":        {"unix"},
+		"target_env":           {""},
+		"target_vendor":        {"unknown"},
+		"target_endian":        {"little"},
+		"target_arch":          {a.TargetArch},
+		"target_pointer_width": {a.PointerWidth},
+	}
+}
+
+// enabledArches returns the Android architectures for which a target specific dependency
+// applies. target is either a cfg() expression or a target triple.
+func enabledArches(target string) ([]string, error) {
+	var expr cfgExpr
+	if strings.HasPrefix(target, "cfg(") {
+		var err error
+		expr, err = parseCfg(target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ret []string
+	for _, arch := range androidArches {
+		if expr != nil {
+			if expr.eval(arch.cfgs()) {
+				ret = append(ret, arch.Name)
+			}
+		} else if target == arch.Triple {
+			ret = append(ret, arch.Name)
+		}
+	}
+	return ret, nil
+}
+
+// cfgExpr is a parsed cfg() expression.
+type cfgExpr interface {
+	eval(cfgs map[string][]string) bool
+}
+
+type cfgAll []cfgExpr
+type cfgAny []cfgExpr
+type cfgNot struct{ expr cfgExpr }
+
+// cfgOption is either a name like unix, or a key/value pair like target_os = "android".
+type cfgOption struct {
+	key      string
+	value    string
+	hasValue bool
+}
+
+func (c cfgAll) eval(cfgs map[string][]string) bool {
+	for _, e := range c {
+		if !e.eval(cfgs) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c cfgAny) eval(cfgs map[string][]string) bool {
+	for _, e := range c {
+		if e.eval(cfgs) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c cfgNot) eval(cfgs map[string][]string) bool {
+	return !c.expr.eval(cfgs)
+}
+
+func (c cfgOption) eval(cfgs map[string][]string) bool {
+	values, ok := cfgs[c.key]
+	if !ok {
+		return false
+	}
+	if !c.hasValue {
+		return values == nil
+	}
+	for _, v := range values {
+		if v == c.value {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCfg parses a cfg(...) expression.
+func parseCfg(s string) (cfgExpr, error) {
+	p := &cfgParser{s: s}
+	if p.ident() != "cfg" || !p.consume("(") {
+		return nil, fmt.Errorf("invalid cfg expression %q", s)
+	}
+	expr, err := p.expr()
+	if err != nil {
+		return nil, fmt.Errorf("invalid cfg expression %q: %w", s, err)
+	}
+	if !p.consume(")") {
+		return nil, fmt.Errorf("invalid cfg expression %q: expected )", s)
+	}
+	if p.skipSpace(); p.pos != len(p.s) {
+		return nil, fmt.Errorf("invalid cfg expression %q: trailing characters", s)
+	}
+	return expr, nil
+}
+
+type cfgParser struct {
+	s   string
+	pos int
+}
+
+func (p *cfgParser) skipSpace() {
+	for p.pos < len(p.s) && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *cfgParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.s[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *cfgParser) ident() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.s) && (p.s[p.pos] == '_' || unicode.IsLetter(rune(p.s[p.pos])) ||
+		unicode.IsDigit(rune(p.s[p.pos]))) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+func (p *cfgParser) expr() (cfgExpr, error) {
+	ident := p.ident()
+	if ident == "" {
+		return nil, fmt.Errorf("expected identifier at offset %d", p.pos)
+	}
+
+	switch ident {
+	case "all", "any", "not":
+		if !p.consume("(") {
+			return nil, fmt.Errorf("expected ( after %s", ident)
+		}
+		var exprs []cfgExpr
+		for !p.consume(")") {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			exprs = append(exprs, e)
+			if !p.consume(",") {
+				if !p.consume(")") {
+					return nil, fmt.Errorf("expected , or ) at offset %d", p.pos)
+				}
+				break
+			}
+		}
+		switch ident {
+		case "all":
+			return cfgAll(exprs), nil
+		case "any":
+			return cfgAny(exprs), nil
+		default:
+			if len(exprs) != 1 {
+				return nil, fmt.Errorf("not() takes exactly one argument")
+			}
+			return cfgNot{exprs[0]}, nil
+		}
+	}
+
+	if !p.consume("=") {
+		return cfgOption{key: ident}, nil
+	}
+	if !p.consume(`"`) {
+		return nil, fmt.Errorf("expected string after %s =", ident)
+	}
+	end := strings.IndexByte(p.s[p.pos:], '"')
+	if end < 0 {
+		return nil, fmt.Errorf("unterminated string")
+	}
+	value := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return cfgOption{key: ident, value: value, hasValue: true}, nil
+}
@@ -0,0 +1,59 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEnabledArches(t *testing.T) {
+	testCases := []struct {
+		target string
+		arches []string
+		err    bool
+	}{
+		{target: "cfg(unix)", arches: []string{"arm", "arm64", "x86", "x86_64"}},
+		{target: "cfg(windows)", arches: nil},
+		{target: `cfg(target_os = "android")`, arches: []string{"arm", "arm64", "x86", "x86_64"}},
+		{target: `cfg(target_os = "linux")`, arches: nil},
+		{target: `cfg(target_arch = "aarch64")`, arches: []string{"arm64"}},
+		{target: `cfg(any(target_arch = "x86", target_arch = "x86_64"))`, arches: []string{"x86", "x86_64"}},
+		{target: `cfg(all(unix, target_pointer_width = "64"))`, arches: []string{"arm64", "x86_64"}},
+		{target: `cfg(not(target_arch = "arm"))`, arches: []string{"arm64", "x86", "x86_64"}},
+		{target: "armv7-linux-androideabi", arches: []string{"arm"}},
+		{target: "x86_64-unknown-linux-gnu", arches: nil},
+		{target: "cfg(any(unix", err: true},
+		{target: `cfg(unix) extra`, err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.target, func(t *testing.T) {
+			arches, err := enabledArches(tc.target)
+			if tc.err {
+				if err == nil {
+					t.Fatalf("expected error, got %q", arches)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(arches, tc.arches) {
+				t.Errorf("expected %q, got %q", tc.arches, arches)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// fileSystem is the subset of file system operations used to read vendored crates, so that tests
+// can use an in-memory file system.
+type fileSystem interface {
+	readFile(path string) (string, error)
+	exists(path string) bool
+	// glob returns the files and directories matching a filepath.Match pattern, sorted.
+	glob(pattern string) []string
+}
+
+type osFileSystem struct{}
+
+func (osFileSystem) readFile(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	return string(data), err
+}
+
+func (osFileSystem) exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (osFileSystem) glob(pattern string) []string {
+	matches, _ := filepath.Glob(pattern)
+	sort.Strings(matches)
+	return matches
+}
+
+// mapFileSystem is an in-memory fileSystem that maps file paths to their contents.
+type mapFileSystem map[string]string
+
+func (fs mapFileSystem) readFile(path string) (string, error) {
+	data, ok := fs[filepath.Clean(path)]
+	if !ok {
+		return "", &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+	return data, nil
+}
+
+func (fs mapFileSystem) exists(path string) bool {
+	path = filepath.Clean(path)
+	for file := range fs {
+		if file == path || strings.HasPrefix(file, path+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (fs mapFileSystem) glob(pattern string) []string {
+	pattern = filepath.Clean(pattern)
+	depth := strings.Count(pattern, "/")
+	found := make(map[string]bool)
+	for file := range fs {
+		// Match the files and all of their parent directories at the depth of the pattern.
+		parts := strings.Split(file, "/")
+		if len(parts) <= depth {
+			continue
+		}
+		candidate := strings.Join(parts[:depth+1], "/")
+		if match, _ := filepath.Match(pattern, candidate); match {
+			found[candidate] = true
+		}
+	}
+	var ret []string
+	for f := range found {
+		ret = append(ret, f)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// LockedPackage is a [[package]] entry of a Cargo.lock file.
+type LockedPackage struct {
+	Name    string
+	Version string
+	// Source is the registry or git source of the package, or empty for path dependencies.
+	Source string
+	// Dependencies are the locked dependencies of the package, as "<name>" when only one
+	// version of the package is locked, or "<name> <version>" otherwise.
+	Dependencies []string
+}
+
+// ID returns the "<name> <version>" identifier of the package.
+func (p *LockedPackage) ID() string {
+	return p.Name + " " + p.Version
+}
+
+// LockFile is a Cargo.lock file.
+type LockFile struct {
+	Packages []*LockedPackage
+
+	byName map[string][]*LockedPackage
+}
+
+func readLockFile(fs fileSystem, dir string) (*LockFile, error) {
+	filename := filepath.Join(dir, "Cargo.lock")
+	data, err := fs.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseToml(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &LockFile{byName: make(map[string][]*LockedPackage)}
+	for _, p := range t.tables("package") {
+		pkg := &LockedPackage{
+			Name:         p.str("name"),
+			Version:      p.str("version"),
+			Source:       p.str("source"),
+			Dependencies: p.strings("dependencies"),
+		}
+		lock.Packages = append(lock.Packages, pkg)
+		lock.byName[pkg.Name] = append(lock.byName[pkg.Name], pkg)
+	}
+	return lock, nil
+}
+
+// find returns the locked package with the given name and version.
+func (l *LockFile) find(name, version string) *LockedPackage {
+	for _, p := range l.byName[name] {
+		if p.Version == version {
+			return p
+		}
+	}
+	return nil
+}
+
+// versions returns the number of locked versions of a package.
+func (l *LockFile) versions(name string) int {
+	return len(l.byName[name])
+}
+
+// resolve returns the locked package a dependency of a locked package refers to.
+func (l *LockFile) resolve(from *LockedPackage, dep Dependency) (*LockedPackage, error) {
+	var candidates []*LockedPackage
+	for _, d := range from.Dependencies {
+		fields := strings.Fields(d)
+		if len(fields) == 0 || fields[0] != dep.Package {
+			continue
+		}
+		if len(fields) == 1 {
+			candidates = append(candidates, l.byName[dep.Package]...)
+		} else if p := l.find(fields[0], fields[1]); p != nil {
+			candidates = append(candidates, p)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil, fmt.Errorf("%s: dependency %q is not in Cargo.lock", from.ID(), dep.Package)
+	case 1:
+		return candidates[0], nil
+	}
+
+	// The package depends on multiple versions of the same package, which happens when it is
+	// a dependency with different requirements, for example as a normal and a build
+	// dependency.
+	for _, c := range candidates {
+		if semverMatches(dep.Version, c.Version) {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no locked version of %q matches %q", from.ID(), dep.Package, dep.Version)
+}
+
+// semverMatches returns true if a version is compatible with the first requirement of a Cargo
+// version requirement. Only the major version, or the minor version for 0.x versions, is compared
+// as that is enough to disambiguate the versions locked for a single package.
+func semverMatches(requirement, version string) bool {
+	requirement = strings.TrimSpace(strings.Split(requirement, ",")[0])
+	requirement = strings.TrimLeft(requirement, "^~=<>* ")
+	if requirement == "" {
+		return true
+	}
+	req := strings.Split(requirement, ".")
+	ver := strings.Split(version, ".")
+	if req[0] != ver[0] {
+		return false
+	}
+	if req[0] == "0" && len(req) > 1 && len(ver) > 1 {
+		return req[1] == ver[1]
+	}
+	return true
+}
@@ -0,0 +1,269 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Dependency is a dependency of a crate, from one of the [dependencies], [dev-dependencies]
+// or [build-dependencies] tables of a Cargo.toml file or their target specific variants.
+type Dependency struct {
+	// Name is the name the dependency is referred to by the crate, which is also the name of
+	// the crate it imports as unless it was renamed.
+	Name string
+	// Package is the name of the package the dependency refers to.
+	Package string
+	// Version is the semver requirement of the dependency.
+	Version string
+	// Path is the path of a path dependency, relative to the directory of the crate.
+	Path string
+
+	Optional        bool
+	DefaultFeatures bool
+	Features        []string
+
+	// Target is the cfg() expression or target triple of a target specific dependency, or
+	// empty if the dependency applies to all targets.
+	Target string
+}
+
+// Target is a library, test or binary target of a crate.
+type Target struct {
+	Name string
+	Path string
+	// ProcMacro is true for libraries that are procedural macros.
+	ProcMacro bool
+	// Test is false for targets that disable tests with test = false.
+	Test bool
+	// Doctest is false for libraries that disable documentation tests with doctest = false.
+	Doctest bool
+}
+
+// Manifest is a Cargo.toml file.
+type Manifest struct {
+	// Dir is the directory that contains the Cargo.toml file.
+	Dir string
+
+	Name    string
+	Version string
+	Edition string
+	// Build is the path of the build script, or empty if the crate doesn't have one.
+	Build string
+
+	Lib *Target
+	// Tests are the integration tests of the crate.
+	Tests []Target
+
+	Features        map[string][]string
+	Dependencies    []Dependency
+	DevDependencies []Dependency
+	// BuildDependencies are only used by the build script.
+	BuildDependencies []Dependency
+
+	// WorkspaceMembers are the member globs of a [workspace] table, or nil if the manifest
+	// doesn't define a workspace.
+	WorkspaceMembers []string
+	WorkspaceExclude []string
+	IsWorkspace      bool
+}
+
+// IsPackage returns true if the manifest defines a package, as opposed to a virtual workspace
+// manifest.
+func (m *Manifest) IsPackage() bool {
+	return m.Name != ""
+}
+
+// CrateName returns the name of the library crate, which is the package name with dashes
+// replaced by underscores unless the [lib] table overrides it.
+func (m *Manifest) CrateName() string {
+	if m.Lib != nil && m.Lib.Name != "" {
+		return strings.ReplaceAll(m.Lib.Name, "-", "_")
+	}
+	return strings.ReplaceAll(m.Name, "-", "_")
+}
+
+// IsProcMacro returns true if the library of the crate is a procedural macro.
+func (m *Manifest) IsProcMacro() bool {
+	return m.Lib != nil && m.Lib.ProcMacro
+}
+
+// ImplicitFeatures returns true if optional dependencies define implicit features named after
+// them, which is the case unless one of the features refers to a dependency with "dep:".
+func (m *Manifest) ImplicitFeatures() bool {
+	for _, values := range m.Features {
+		for _, v := range values {
+			if strings.HasPrefix(v, "dep:") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func readManifest(fs fileSystem, dir string) (*Manifest, error) {
+	filename := filepath.Join(dir, "Cargo.toml")
+	data, err := fs.readFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t, err := parseToml(filename, data)
+	if err != nil {
+		return nil, err
+	}
+	return newManifest(fs, dir, t), nil
+}
+
+func newManifest(fs fileSystem, dir string, t tomlTable) *Manifest {
+	m := &Manifest{Dir: dir}
+
+	pkg := t.table("package")
+	m.Name = pkg.str("name")
+	m.Version = pkg.str("version")
+	m.Edition = pkg.str("edition")
+	if m.Edition == "" {
+		m.Edition = "2015"
+	}
+	if build, ok := pkg["build"].(string); ok {
+		m.Build = build
+	} else if b, ok := pkg.boolean("build"); !ok || b {
+		if fs.exists(filepath.Join(dir, "build.rs")) {
+			m.Build = "build.rs"
+		}
+	}
+
+	if lib := t.table("lib"); lib != nil {
+		m.Lib = newTarget(lib, "src/lib.rs")
+		if procMacro, ok := lib.boolean("proc-macro"); ok {
+			m.Lib.ProcMacro = procMacro
+		} else if procMacro, ok := lib.boolean("proc_macro"); ok {
+			m.Lib.ProcMacro = procMacro
+		}
+	} else if m.IsPackage() && fs.exists(filepath.Join(dir, "src/lib.rs")) {
+		m.Lib = &Target{Path: "src/lib.rs", Test: true, Doctest: true}
+	}
+
+	for _, test := range t.tables("test") {
+		target := newTarget(test, "")
+		if target.Path == "" {
+			target.Path = filepath.Join("tests", target.Name+".rs")
+		}
+		m.Tests = append(m.Tests, *target)
+	}
+	autotests, ok := pkg.boolean("autotests")
+	if m.IsPackage() && (autotests || !ok) {
+		for _, path := range fs.glob(filepath.Join(dir, "tests", "*.rs")) {
+			rel, _ := filepath.Rel(dir, path)
+			if !hasTargetPath(m.Tests, rel) {
+				name := strings.TrimSuffix(filepath.Base(path), ".rs")
+				m.Tests = append(m.Tests, Target{Name: name, Path: rel, Test: true})
+			}
+		}
+	}
+
+	m.Features = make(map[string][]string)
+	for name := range t.table("features") {
+		m.Features[name] = t.table("features").strings(name)
+	}
+
+	m.Dependencies = newDependencies(t.table("dependencies"), "")
+	m.DevDependencies = newDependencies(t.table("dev-dependencies"), "")
+	m.BuildDependencies = newDependencies(t.table("build-dependencies"), "")
+	targets := t.table("target")
+	for _, target := range sortedKeys(targets) {
+		targetTable, _ := targets[target].(tomlTable)
+		m.Dependencies = append(m.Dependencies, newDependencies(targetTable.table("dependencies"), target)...)
+		m.DevDependencies = append(m.DevDependencies, newDependencies(targetTable.table("dev-dependencies"), target)...)
+		m.BuildDependencies = append(m.BuildDependencies, newDependencies(targetTable.table("build-dependencies"), target)...)
+	}
+
+	if workspace := t.table("workspace"); workspace != nil {
+		m.IsWorkspace = true
+		m.WorkspaceMembers = workspace.strings("members")
+		m.WorkspaceExclude = workspace.strings("exclude")
+	}
+
+	return m
+}
+
+func hasTargetPath(targets []Target, path string) bool {
+	for _, t := range targets {
+		if filepath.Clean(t.Path) == path {
+			return true
+		}
+	}
+	return false
+}
+
+func newTarget(t tomlTable, defaultPath string) *Target {
+	target := &Target{
+		Name:    t.str("name"),
+		Path:    t.str("path"),
+		Test:    true,
+		Doctest: true,
+	}
+	if target.Path == "" {
+		target.Path = defaultPath
+	}
+	if test, ok := t.boolean("test"); ok {
+		target.Test = test
+	}
+	if doctest, ok := t.boolean("doctest"); ok {
+		target.Doctest = doctest
+	}
+	return target
+}
+
+func newDependencies(t tomlTable, target string) []Dependency {
+	var deps []Dependency
+	for _, name := range sortedKeys(t) {
+		dep := Dependency{
+			Name:            name,
+			Package:         name,
+			DefaultFeatures: true,
+			Target:          target,
+		}
+		switch v := t[name].(type) {
+		case string:
+			dep.Version = v
+		case tomlTable:
+			dep.Version = v.str("version")
+			dep.Path = v.str("path")
+			if pkg := v.str("package"); pkg != "" {
+				dep.Package = pkg
+			}
+			dep.Optional, _ = v.boolean("optional")
+			if defaultFeatures, ok := v.boolean("default-features"); ok {
+				dep.DefaultFeatures = defaultFeatures
+			} else if defaultFeatures, ok := v.boolean("default_features"); ok {
+				dep.DefaultFeatures = defaultFeatures
+			}
+			dep.Features = v.strings("features")
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
+
+func sortedKeys(t tomlTable) []string {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
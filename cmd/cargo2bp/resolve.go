@@ -0,0 +1,396 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Crate is a package to generate modules for, along with the features and optional dependencies
+// enabled by the crates that depend on it.
+type Crate struct {
+	Manifest *Manifest
+	Locked   *LockedPackage
+	// Member is true for the members of the workspace, whose tests are generated.
+	Member bool
+	// Features are the enabled features, including the implicit features of enabled optional
+	// dependencies.
+	Features map[string]bool
+
+	// enabledDeps are the names of the enabled dependencies.
+	enabledDeps map[string]bool
+	// weakFeatures are the features requested with "dep?/feature" for each dependency, which
+	// are enabled if the dependency is enabled by other means.
+	weakFeatures map[string][]string
+	// resolved maps dependency names to the crates they resolved to.
+	resolved map[string]*Crate
+	// deps are the dependencies of the crate, including the development dependencies of
+	// workspace members when their tests are generated.
+	deps []crateDep
+}
+
+// Enabled returns true if the dependency is used to build the crate.
+func (c *Crate) Enabled(dep crateDep) bool {
+	return !dep.Optional || c.enabledDeps[dep.Name]
+}
+
+// crateDep is a dependency of a crate that applies to at least one Android architecture.
+type crateDep struct {
+	Dependency
+	// Dev is true for development dependencies, which are only used by tests.
+	Dev bool
+	// Arches are the architectures a target specific dependency applies to, or nil if it
+	// applies to all of them.
+	Arches []string
+}
+
+// SortedFeatures returns the enabled features of the crate, sorted.
+func (c *Crate) SortedFeatures() []string {
+	var features []string
+	for f := range c.Features {
+		features = append(features, f)
+	}
+	sort.Strings(features)
+	return features
+}
+
+// resolver loads the crates of a workspace and their transitive dependencies, and unifies the
+// features each crate is used with, like cargo does when building the workspace.
+type resolver struct {
+	fs        fileSystem
+	lock      *LockFile
+	vendorDir string
+	// tests is true if the development dependencies of workspace members are used.
+	tests bool
+
+	crates map[string]*Crate
+	// members are the IDs of the members of the workspace.
+	members map[string]bool
+	// dirs maps the IDs of path dependencies to their directories.
+	dirs map[string]string
+}
+
+func newResolver(fs fileSystem, lock *LockFile, vendorDir string, tests bool) *resolver {
+	return &resolver{
+		fs:        fs,
+		lock:      lock,
+		vendorDir: vendorDir,
+		tests:     tests,
+		crates:    make(map[string]*Crate),
+		members:   make(map[string]bool),
+		dirs:      make(map[string]string),
+	}
+}
+
+// workspaceMembers returns the directories of the packages of the workspace defined by a root
+// manifest, including the root package itself if the manifest isn't a virtual manifest.
+func workspaceMembers(fs fileSystem, root *Manifest) []string {
+	var dirs []string
+	if root.IsPackage() {
+		dirs = append(dirs, root.Dir)
+	}
+
+	excluded := make(map[string]bool)
+	for _, exclude := range root.WorkspaceExclude {
+		excluded[filepath.Join(root.Dir, exclude)] = true
+	}
+	for _, member := range root.WorkspaceMembers {
+		for _, dir := range fs.glob(filepath.Join(root.Dir, member)) {
+			if !excluded[dir] && fs.exists(filepath.Join(dir, "Cargo.toml")) {
+				dirs = append(dirs, dir)
+			}
+		}
+	}
+	return dirs
+}
+
+// addMembers loads the members of the workspace and enables the requested features on each of
+// them, or their default features if none are requested.
+func (r *resolver) addMembers(dirs []string, features []string) error {
+	var members []*LockedPackage
+	for _, dir := range dirs {
+		m, err := readManifest(r.fs, dir)
+		if err != nil {
+			return err
+		}
+		locked := r.lock.find(m.Name, m.Version)
+		if locked == nil {
+			return fmt.Errorf("%s: package %s %s is not in Cargo.lock", dir, m.Name, m.Version)
+		}
+		r.dirs[locked.ID()] = dir
+		r.members[locked.ID()] = true
+		members = append(members, locked)
+	}
+
+	if len(features) == 0 {
+		features = []string{"default"}
+	}
+	for _, locked := range members {
+		c, err := r.load(locked)
+		if err != nil {
+			return err
+		}
+		for _, f := range features {
+			if err := r.enableFeature(c, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// crateDir returns the directory of a locked package, which is either a path dependency or a
+// vendored crate named <name>-<version> or <name> in the vendor directory.
+func (r *resolver) crateDir(locked *LockedPackage) (string, *Manifest, error) {
+	var candidates []string
+	if dir, ok := r.dirs[locked.ID()]; ok {
+		candidates = []string{dir}
+	} else {
+		candidates = []string{
+			filepath.Join(r.vendorDir, locked.Name+"-"+locked.Version),
+			filepath.Join(r.vendorDir, locked.Name),
+		}
+	}
+
+	for _, dir := range candidates {
+		if !r.fs.exists(filepath.Join(dir, "Cargo.toml")) {
+			continue
+		}
+		m, err := readManifest(r.fs, dir)
+		if err != nil {
+			return "", nil, err
+		}
+		if m.Name == locked.Name && m.Version == locked.Version {
+			return dir, m, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%s is not vendored in %s", locked.ID(), r.vendorDir)
+}
+
+// load returns the crate for a locked package, loading it and enabling its non-optional
+// dependencies the first time it is seen.
+func (r *resolver) load(locked *LockedPackage) (*Crate, error) {
+	if c, ok := r.crates[locked.ID()]; ok {
+		return c, nil
+	}
+
+	_, m, err := r.crateDir(locked)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Crate{
+		Manifest:     m,
+		Locked:       locked,
+		Member:       r.members[locked.ID()],
+		Features:     make(map[string]bool),
+		enabledDeps:  make(map[string]bool),
+		weakFeatures: make(map[string][]string),
+		resolved:     make(map[string]*Crate),
+	}
+	r.crates[locked.ID()] = c
+
+	c.deps, err = crateDeps(m.Dependencies, false)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", m.Dir, err)
+	}
+	if r.tests && c.Member {
+		devDeps, err := crateDeps(m.DevDependencies, true)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", m.Dir, err)
+		}
+		c.deps = append(c.deps, devDeps...)
+	}
+
+	for _, dep := range c.deps {
+		if !dep.Optional {
+			if err := r.enableDep(c, dep.Name); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return c, nil
+}
+
+// crateDeps returns the dependencies that apply to at least one Android architecture.
+func crateDeps(deps []Dependency, dev bool) ([]crateDep, error) {
+	var ret []crateDep
+	for _, dep := range deps {
+		d := crateDep{Dependency: dep, Dev: dev}
+		if dep.Target != "" {
+			arches, err := enabledArches(dep.Target)
+			if err != nil {
+				return nil, fmt.Errorf("dependency %s: %s", dep.Name, err)
+			}
+			if len(arches) == 0 {
+				continue
+			}
+			if len(arches) < len(androidArches) {
+				d.Arches = arches
+			}
+		}
+		ret = append(ret, d)
+	}
+	return ret, nil
+}
+
+// enableDep enables a dependency of a crate, and the features it requests on the dependency.
+func (r *resolver) enableDep(c *Crate, name string) error {
+	first := !c.enabledDeps[name]
+	c.enabledDeps[name] = true
+
+	for _, dep := range c.deps {
+		if dep.Name != name {
+			continue
+		}
+		if dep.Optional && c.Manifest.ImplicitFeatures() {
+			c.Features[name] = true
+		}
+
+		target, err := r.resolveDep(c, dep.Dependency)
+		if err != nil {
+			return err
+		}
+		if !first {
+			continue
+		}
+
+		features := append([]string(nil), dep.Features...)
+		if dep.DefaultFeatures {
+			features = append(features, "default")
+		}
+		features = append(features, c.weakFeatures[name]...)
+		for _, f := range features {
+			if err := r.enableFeature(target, f); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDep returns the crate a dependency of a crate refers to.
+func (r *resolver) resolveDep(c *Crate, dep Dependency) (*Crate, error) {
+	if target, ok := c.resolved[dep.Name]; ok {
+		return target, nil
+	}
+
+	locked, err := r.lock.resolve(c.Locked, dep)
+	if err != nil {
+		return nil, err
+	}
+	if locked.Source == "" && dep.Path != "" {
+		if _, ok := r.dirs[locked.ID()]; !ok {
+			r.dirs[locked.ID()] = filepath.Join(c.Manifest.Dir, dep.Path)
+		}
+	}
+
+	target, err := r.load(locked)
+	if err != nil {
+		return nil, err
+	}
+	c.resolved[dep.Name] = target
+	return target, nil
+}
+
+// enableFeature enables a feature of a crate, and everything the feature enables.
+func (r *resolver) enableFeature(c *Crate, feature string) error {
+	if c.Features[feature] {
+		return nil
+	}
+
+	values, ok := c.Manifest.Features[feature]
+	if !ok {
+		if feature == "default" {
+			// Crates without a default feature.
+			return nil
+		}
+		if c.Manifest.ImplicitFeatures() && c.hasOptionalDep(feature) {
+			return r.enableDep(c, feature)
+		}
+		return fmt.Errorf("%s: unknown feature %q", c.Locked.ID(), feature)
+	}
+	c.Features[feature] = true
+
+	for _, v := range values {
+		switch {
+		case strings.HasPrefix(v, "dep:"):
+			if err := r.enableDep(c, strings.TrimPrefix(v, "dep:")); err != nil {
+				return err
+			}
+		case strings.Contains(v, "/"):
+			parts := strings.SplitN(v, "/", 2)
+			name, depFeature := parts[0], parts[1]
+			if strings.HasSuffix(name, "?") {
+				name = strings.TrimSuffix(name, "?")
+				c.weakFeatures[name] = append(c.weakFeatures[name], depFeature)
+				if !c.enabledDeps[name] {
+					continue
+				}
+			} else if err := r.enableDep(c, name); err != nil {
+				return err
+			}
+			if err := r.enableDepFeature(c, name, depFeature); err != nil {
+				return err
+			}
+		default:
+			if err := r.enableFeature(c, v); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// enableDepFeature enables a feature on all the crates a dependency name resolves to.
+func (r *resolver) enableDepFeature(c *Crate, name, feature string) error {
+	for _, dep := range c.deps {
+		if dep.Name != name {
+			continue
+		}
+		target, err := r.resolveDep(c, dep.Dependency)
+		if err != nil {
+			return err
+		}
+		if err := r.enableFeature(target, feature); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Crate) hasOptionalDep(name string) bool {
+	for _, dep := range c.deps {
+		if dep.Name == name && dep.Optional {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedCrates returns the loaded crates sorted by name and version.
+func (r *resolver) sortedCrates() []*Crate {
+	var crates []*Crate
+	for _, c := range r.crates {
+		crates = append(crates, c)
+	}
+	sort.Slice(crates, func(i, j int) bool {
+		return crates[i].Locked.ID() < crates[j].Locked.ID()
+	})
+	return crates
+}
@@ -0,0 +1,565 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// tomlTable is a TOML table. Values are strings, int64s, float64s, bools, []interface{}
+// arrays and nested tomlTables. Dates and times are kept as strings.
+type tomlTable map[string]interface{}
+
+// parseToml parses the subset of TOML used by Cargo.toml and Cargo.lock files, which is all of
+// TOML 1.0 except that dates and times are not validated.
+func parseToml(filename string, data string) (tomlTable, error) {
+	p := &tomlParser{filename: filename, s: data, line: 1}
+	root := tomlTable{}
+	if err := p.parse(root); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type tomlParser struct {
+	filename string
+	s        string
+	pos      int
+	line     int
+}
+
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s:%d: %s", p.filename, p.line, fmt.Sprintf(format, args...))
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.s[p.pos]
+}
+
+func (p *tomlParser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.s[p.pos:], prefix)
+}
+
+// skipSpace skips spaces and tabs.
+func (p *tomlParser) skipSpace() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment skips a comment up to, but not including, the end of the line.
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, newlines and comments.
+func (p *tomlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if p.hasPrefix("\r\n") {
+			p.pos += 2
+			p.line++
+		} else if p.peek() == '\n' {
+			p.pos++
+			p.line++
+		} else {
+			return
+		}
+	}
+}
+
+// expectEndOfLine consumes the rest of a line after a key/value pair or a table header.
+func (p *tomlParser) expectEndOfLine() error {
+	p.skipSpace()
+	p.skipComment()
+	if p.eof() {
+		return nil
+	}
+	if p.hasPrefix("\r\n") || p.peek() == '\n' {
+		return nil
+	}
+	return p.errorf("unexpected %q after value", p.peek())
+}
+
+func (p *tomlParser) parse(root tomlTable) error {
+	current := root
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+
+		if p.peek() == '[' {
+			array := p.hasPrefix("[[")
+			if array {
+				p.pos += 2
+			} else {
+				p.pos++
+			}
+			p.skipSpace()
+			key, err := p.parseKey()
+			if err != nil {
+				return err
+			}
+			p.skipSpace()
+			if array {
+				if !p.hasPrefix("]]") {
+					return p.errorf("expected ]] after table name")
+				}
+				p.pos += 2
+				current, err = p.arrayTable(root, key)
+			} else {
+				if p.peek() != ']' {
+					return p.errorf("expected ] after table name")
+				}
+				p.pos++
+				current, err = p.table(root, key)
+			}
+			if err != nil {
+				return err
+			}
+		} else {
+			if err := p.parseKeyValue(current); err != nil {
+				return err
+			}
+		}
+
+		if err := p.expectEndOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// table returns the table for a [a.b.c] header, creating it if necessary.
+func (p *tomlParser) table(root tomlTable, key []string) (tomlTable, error) {
+	t := root
+	for _, k := range key {
+		switch v := t[k].(type) {
+		case nil:
+			next := tomlTable{}
+			t[k] = next
+			t = next
+		case tomlTable:
+			t = v
+		case []interface{}:
+			// [a.b] after [[a]] refers to the last element of the array of tables.
+			last, ok := v[len(v)-1].(tomlTable)
+			if !ok {
+				return nil, p.errorf("key %q is not a table", strings.Join(key, "."))
+			}
+			t = last
+		default:
+			return nil, p.errorf("key %q is not a table", strings.Join(key, "."))
+		}
+	}
+	return t, nil
+}
+
+// arrayTable appends a new table to the array of tables for a [[a.b.c]] header.
+func (p *tomlParser) arrayTable(root tomlTable, key []string) (tomlTable, error) {
+	parent, err := p.table(root, key[:len(key)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := key[len(key)-1]
+	next := tomlTable{}
+	switch v := parent[last].(type) {
+	case nil:
+		parent[last] = []interface{}{next}
+	case []interface{}:
+		parent[last] = append(v, next)
+	default:
+		return nil, p.errorf("key %q is not an array of tables", strings.Join(key, "."))
+	}
+	return next, nil
+}
+
+func (p *tomlParser) parseKeyValue(t tomlTable) error {
+	key, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	p.skipSpace()
+	if p.peek() != '=' {
+		return p.errorf("expected = after key %q", strings.Join(key, "."))
+	}
+	p.pos++
+	p.skipSpace()
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	// Dotted keys create intermediate tables.
+	for _, k := range key[:len(key)-1] {
+		next, ok := t[k].(tomlTable)
+		if !ok {
+			if t[k] != nil {
+				return p.errorf("key %q is not a table", k)
+			}
+			next = tomlTable{}
+			t[k] = next
+		}
+		t = next
+	}
+	last := key[len(key)-1]
+	if _, exists := t[last]; exists {
+		return p.errorf("duplicate key %q", strings.Join(key, "."))
+	}
+	t[last] = value
+	return nil
+}
+
+// parseKey parses a possibly dotted key made of bare or quoted keys.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var key []string
+	for {
+		p.skipSpace()
+		var k string
+		switch p.peek() {
+		case '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		case '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			k = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("expected key, found %q", p.peek())
+			}
+			k = p.s[start:p.pos]
+		}
+		key = append(key, k)
+
+		p.skipSpace()
+		if p.peek() != '.' {
+			return key, nil
+		}
+		p.pos++
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch {
+	case p.hasPrefix(`"""`):
+		return p.parseMultiLineBasicString()
+	case p.hasPrefix(`'''`):
+		return p.parseMultiLineLiteralString()
+	case p.peek() == '"':
+		return p.parseBasicString()
+	case p.peek() == '\'':
+		return p.parseLiteralString()
+	case p.peek() == '[':
+		return p.parseArray()
+	case p.peek() == '{':
+		return p.parseInlineTable()
+	case p.hasPrefix("true"):
+		p.pos += len("true")
+		return true, nil
+	case p.hasPrefix("false"):
+		p.pos += len("false")
+		return false, nil
+	}
+
+	// Numbers, dates and times.
+	start := p.pos
+	for !p.eof() && !strings.ContainsRune(" \t\r\n,]}#", rune(p.peek())) {
+		p.pos++
+	}
+	token := p.s[start:p.pos]
+	if token == "" {
+		return nil, p.errorf("expected value, found %q", p.peek())
+	}
+	number := strings.ReplaceAll(token, "_", "")
+	if i, err := strconv.ParseInt(number, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(number, 64); err == nil {
+		return f, nil
+	}
+	if token[0] >= '0' && token[0] <= '9' {
+		// Dates and times are kept as strings.
+		return token, nil
+	}
+	return nil, p.errorf("invalid value %q", token)
+}
+
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	sb := strings.Builder{}
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.peek()
+		if c == '"' {
+			p.pos++
+			return sb.String(), nil
+		}
+		if c == '\\' {
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseMultiLineBasicString() (string, error) {
+	p.pos += 3
+	p.skipNewline()
+	sb := strings.Builder{}
+	for {
+		if p.eof() {
+			return "", p.errorf("unterminated string")
+		}
+		if p.hasPrefix(`"""`) {
+			p.pos += 3
+			// Up to two quotes are allowed right before the closing delimiter.
+			for i := 0; i < 2 && p.peek() == '"'; i++ {
+				sb.WriteByte('"')
+				p.pos++
+			}
+			return sb.String(), nil
+		}
+		c := p.peek()
+		if c == '\\' {
+			// A backslash at the end of a line trims all whitespace up to the next
+			// non-whitespace character.
+			rest := strings.TrimLeft(p.s[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos++
+				for !p.eof() && strings.ContainsRune(" \t\r\n", rune(p.peek())) {
+					if p.peek() == '\n' {
+						p.line++
+					}
+					p.pos++
+				}
+				continue
+			}
+			if err := p.parseEscape(&sb); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if c == '\n' {
+			p.line++
+		}
+		sb.WriteByte(c)
+		p.pos++
+	}
+}
+
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	end := strings.IndexAny(p.s[p.pos:], "'\n")
+	if end < 0 || p.s[p.pos+end] != '\'' {
+		return "", p.errorf("unterminated string")
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.pos += end + 1
+	return s, nil
+}
+
+func (p *tomlParser) parseMultiLineLiteralString() (string, error) {
+	p.pos += 3
+	p.skipNewline()
+	end := strings.Index(p.s[p.pos:], `'''`)
+	if end < 0 {
+		return "", p.errorf("unterminated string")
+	}
+	// Up to two quotes are allowed right before the closing delimiter.
+	for i := 0; i < 2 && p.pos+end+3 < len(p.s) && p.s[p.pos+end+3] == '\''; i++ {
+		end++
+	}
+	s := p.s[p.pos : p.pos+end]
+	p.line += strings.Count(s, "\n")
+	p.pos += end + 3
+	return s, nil
+}
+
+// skipNewline skips a newline immediately following the opening delimiter of a multi-line string.
+func (p *tomlParser) skipNewline() {
+	if p.hasPrefix("\r\n") {
+		p.pos += 2
+		p.line++
+	} else if p.peek() == '\n' {
+		p.pos++
+		p.line++
+	}
+}
+
+func (p *tomlParser) parseEscape(sb *strings.Builder) error {
+	p.pos++
+	if p.eof() {
+		return p.errorf("unterminated escape sequence")
+	}
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		sb.WriteByte('\b')
+	case 't':
+		sb.WriteByte('\t')
+	case 'n':
+		sb.WriteByte('\n')
+	case 'f':
+		sb.WriteByte('\f')
+	case 'r':
+		sb.WriteByte('\r')
+	case '"':
+		sb.WriteByte('"')
+	case '\\':
+		sb.WriteByte('\\')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.s) {
+			return p.errorf("invalid unicode escape sequence")
+		}
+		r, err := strconv.ParseUint(p.s[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			return p.errorf("invalid unicode escape sequence %q", p.s[p.pos:p.pos+n])
+		}
+		sb.WriteRune(rune(r))
+		p.pos += n
+	default:
+		return p.errorf("invalid escape sequence \\%c", c)
+	}
+	return nil
+}
+
+func (p *tomlParser) parseArray() ([]interface{}, error) {
+	p.pos++
+	values := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.peek() == ']' {
+			p.pos++
+			return values, nil
+		}
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("expected , or ] in array, found %q", p.peek())
+		}
+	}
+}
+
+func (p *tomlParser) parseInlineTable() (tomlTable, error) {
+	p.pos++
+	t := tomlTable{}
+	p.skipSpace()
+	if p.peek() == '}' {
+		p.pos++
+		return t, nil
+	}
+	for {
+		if err := p.parseKeyValue(t); err != nil {
+			return nil, err
+		}
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return t, nil
+		default:
+			return nil, p.errorf("expected , or } in inline table, found %q", p.peek())
+		}
+	}
+}
+
+// Helpers to read typed values from a parsed table, ignoring values of unexpected types.
+
+func (t tomlTable) table(key string) tomlTable {
+	v, _ := t[key].(tomlTable)
+	return v
+}
+
+func (t tomlTable) str(key string) string {
+	v, _ := t[key].(string)
+	return v
+}
+
+func (t tomlTable) boolean(key string) (value bool, ok bool) {
+	value, ok = t[key].(bool)
+	return value, ok
+}
+
+func (t tomlTable) strings(key string) []string {
+	array, _ := t[key].([]interface{})
+	var ret []string
+	for _, v := range array {
+		if s, ok := v.(string); ok {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (t tomlTable) tables(key string) []tomlTable {
+	array, _ := t[key].([]interface{})
+	var ret []tomlTable
+	for _, v := range array {
+		if table, ok := v.(tomlTable); ok {
+			ret = append(ret, table)
+		}
+	}
+	return ret
+}
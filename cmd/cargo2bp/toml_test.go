@@ -0,0 +1,152 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseToml(t *testing.T) {
+	testCases := []struct {
+		name string
+		in   string
+		out  tomlTable
+		err  string
+	}{
+		{
+			name: "tables and values",
+			in: `
+# comment
+[package]
+name = "foo" # trailing comment
+version = '1.0.0'
+edition = "2018"
+autotests = false
+tags = ["a", 'b',
+  "c",  # comment
+]
+
+[dependencies]
+bar = "1"
+baz = { version = "2", features = ["x"], optional = true }
+
+[target.'cfg(unix)'.dependencies]
+libc = "0.2"
+`,
+			out: tomlTable{
+				"package": tomlTable{
+					"name":      "foo",
+					"version":   "1.0.0",
+					"edition":   "2018",
+					"autotests": false,
+					"tags":      []interface{}{"a", "b", "c"},
+				},
+				"dependencies": tomlTable{
+					"bar": "1",
+					"baz": tomlTable{
+						"version":  "2",
+						"features": []interface{}{"x"},
+						"optional": true,
+					},
+				},
+				"target": tomlTable{
+					"cfg(unix)": tomlTable{
+						"dependencies": tomlTable{
+							"libc": "0.2",
+						},
+					},
+				},
+			},
+		},
+		{
+			name: "arrays of tables and dotted keys",
+			in: `
+[[package]]
+name = "a"
+dependencies = []
+
+[[package]]
+name = "b"
+source.kind = "registry"
+
+[[package.extra]]
+x = 1
+`,
+			out: tomlTable{
+				"package": []interface{}{
+					tomlTable{
+						"name":         "a",
+						"dependencies": []interface{}{},
+					},
+					tomlTable{
+						"name":   "b",
+						"source": tomlTable{"kind": "registry"},
+						"extra":  []interface{}{tomlTable{"x": int64(1)}},
+					},
+				},
+			},
+		},
+		{
+			name: "strings",
+			in: `
+basic = "tab\tquote\"unicode\u00e9"
+literal = 'C:\path'
+multi = """
+line 1 \
+  line 2"""
+multiliteral = '''
+raw\n'''
+`,
+			out: tomlTable{
+				"basic":        "tab\tquote\"unicode\u00e9",
+				"literal":      `C:\path`,
+				"multi":        "line 1 line 2",
+				"multiliteral": `raw\n`,
+			},
+		},
+		{
+			name: "duplicate key",
+			in: `
+a = 1
+a = 2
+`,
+			err: `test.toml:3: duplicate key "a"`,
+		},
+		{
+			name: "unterminated string",
+			in:   `a = "b`,
+			err:  `test.toml:1: unterminated string`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := parseToml("test.toml", tc.in)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("expected error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !reflect.DeepEqual(out, tc.out) {
+				t.Errorf("expected:\n%#v\ngot:\n%#v", tc.out, out)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cargo_build_script runs the build script of a crate the way Cargo does, and converts the
+// cargo:rustc-cfg and cargo:rustc-env directives it prints into files that are passed to rustc
+// when the crate is compiled: a rustc argument file with the cfgs, and a shell script that
+// exports the environment variables.
+//
+// The link directives (cargo:rustc-link-lib, cargo:rustc-link-search, ...) are ignored, native
+// dependencies are declared in the Android.bp file of the crate instead.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	script      = flag.String("script", "", "path to the build script executable")
+	manifestDir = flag.String("manifest_dir", "", "directory of the crate, the build script is run from it")
+	outDir      = flag.String("out_dir", "", "directory where the build script writes its outputs (OUT_DIR)")
+	finalOutDir = flag.String("final_out_dir", "",
+		"path of the OUT_DIR directory after the build script has run, used to rewrite the paths in environment variables")
+	cfgsFile = flag.String("cfgs", "", "rustc argument file to write the cfgs to")
+	envFile  = flag.String("env", "", "shell script to write the environment variables to")
+)
+
+// scriptOutput holds the directives printed by a build script.
+type scriptOutput struct {
+	// cfgs are the arguments of cargo:rustc-cfg directives.
+	cfgs []string
+	// env are the KEY=VALUE arguments of cargo:rustc-env directives, in order.
+	env []envVar
+	// warnings are the messages of cargo:warning directives.
+	warnings []string
+}
+
+type envVar struct {
+	key, value string
+}
+
+// parseOutput parses the directives printed by a build script on stdout. Both the cargo:KEY=VALUE
+// and the newer cargo::KEY=VALUE syntax are supported, other lines are ignored like Cargo does.
+func parseOutput(r io.Reader) (*scriptOutput, error) {
+	ret := &scriptOutput{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		var directive string
+		if strings.HasPrefix(line, "cargo::") {
+			directive = strings.TrimPrefix(line, "cargo::")
+		} else if strings.HasPrefix(line, "cargo:") {
+			directive = strings.TrimPrefix(line, "cargo:")
+		} else {
+			continue
+		}
+
+		split := strings.SplitN(directive, "=", 2)
+		if len(split) != 2 {
+			return nil, fmt.Errorf("invalid build script output %q, expected cargo:KEY=VALUE", line)
+		}
+		key, value := split[0], split[1]
+
+		switch key {
+		case "rustc-cfg":
+			ret.cfgs = append(ret.cfgs, value)
+		case "rustc-env":
+			env := strings.SplitN(value, "=", 2)
+			if len(env) != 2 || env[0] == "" {
+				return nil, fmt.Errorf("invalid build script output %q, expected cargo:rustc-env=VAR=VALUE", line)
+			}
+			ret.env = append(ret.env, envVar{env[0], env[1]})
+		case "warning":
+			ret.warnings = append(ret.warnings, value)
+		}
+	}
+	return ret, scanner.Err()
+}
+
+// writeCfgs writes the cfgs as a rustc argument file, which has one argument per line.
+func writeCfgs(w io.Writer, cfgs []string) {
+	for _, cfg := range cfgs {
+		fmt.Fprintln(w, "--cfg")
+		fmt.Fprintln(w, cfg)
+	}
+}
+
+// writeEnv writes the environment variables as a shell script that exports them. The absolute
+// path of the OUT_DIR the build script ran with is replaced by the path the outputs end up in,
+// relative to the directory the compiler runs in.
+func writeEnv(w io.Writer, env []envVar, outDir, finalOutDir string) {
+	for _, e := range env {
+		value := shellEscapeDoubleQuoted(e.value)
+		if outDir != "" && finalOutDir != "" {
+			replacement := finalOutDir
+			if !filepath.IsAbs(finalOutDir) {
+				replacement = "${PWD}/" + finalOutDir
+			}
+			value = strings.ReplaceAll(value, shellEscapeDoubleQuoted(outDir), replacement)
+		}
+		fmt.Fprintf(w, "export %s=\"%s\"\n", e.key, value)
+	}
+}
+
+func shellEscapeDoubleQuoted(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s)
+}
+
+// toolEnvVars are the environment variables that hold the paths of the tools passed to the build
+// script, which are relative to the directory cargo_build_script runs from.
+var toolEnvVars = []string{"RUSTC", "RUSTDOC", "CARGO", "RUSTC_LINKER"}
+
+// absToolEnv returns the environment variables of toolEnvVars set in environ with relative paths,
+// with their paths made absolute, as the build script runs from the manifest directory.
+func absToolEnv(environ []string) ([]string, error) {
+	var env []string
+	for _, e := range environ {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 || !inList(kv[0], toolEnvVars) || kv[1] == "" || filepath.IsAbs(kv[1]) {
+			continue
+		}
+		abs, err := filepath.Abs(kv[1])
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, kv[0]+"="+abs)
+	}
+	return env, nil
+}
+
+func inList(s string, list []string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+func run() error {
+	absOutDir, err := filepath.Abs(*outDir)
+	if err != nil {
+		return err
+	}
+	absManifestDir, err := filepath.Abs(*manifestDir)
+	if err != nil {
+		return err
+	}
+	absScript, err := filepath.Abs(*script)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(absOutDir, 0777); err != nil {
+		return err
+	}
+
+	toolEnv, err := absToolEnv(os.Environ())
+	if err != nil {
+		return err
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(absScript)
+	cmd.Dir = absManifestDir
+	cmd.Env = append(os.Environ(), "OUT_DIR="+absOutDir, "CARGO_MANIFEST_DIR="+absManifestDir)
+	cmd.Env = append(cmd.Env, toolEnv...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("build script %s failed: %s\n--- stdout\n%s--- stderr\n%s",
+			*script, err, stdout.String(), stderr.String())
+	}
+
+	output, err := parseOutput(&stdout)
+	if err != nil {
+		return err
+	}
+	for _, warning := range output.warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s: %s\n", *script, warning)
+	}
+
+	var cfgs bytes.Buffer
+	writeCfgs(&cfgs, output.cfgs)
+	if err := ioutil.WriteFile(*cfgsFile, cfgs.Bytes(), 0666); err != nil {
+		return err
+	}
+
+	var env bytes.Buffer
+	writeEnv(&env, output.env, absOutDir, *finalOutDir)
+	return ioutil.WriteFile(*envFile, env.Bytes(), 0666)
+}
+
+func main() {
+	flag.Parse()
+
+	if *script == "" || *manifestDir == "" || *outDir == "" || *cfgsFile == "" || *envFile == "" {
+		fmt.Fprintln(os.Stderr, "-script, -manifest_dir, -out_dir, -cfgs and -env are required")
+		os.Exit(1)
+	}
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
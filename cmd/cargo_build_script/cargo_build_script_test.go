@@ -0,0 +1,124 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOutput(t *testing.T) {
+	in := `cargo:rerun-if-changed=build.rs
+cargo:rustc-cfg=has_foo
+cargo::rustc-cfg=feature="bar"
+cargo:rustc-env=VERSION=1.2.3
+cargo:rustc-env=GENERATED=/sandbox/out/gen.rs
+cargo:rustc-link-lib=static=foo
+cargo:warning=something happened
+not a directive
+`
+	output, err := parseOutput(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if expected := []string{"has_foo", `feature="bar"`}; !reflect.DeepEqual(output.cfgs, expected) {
+		t.Errorf("expected cfgs %q, got %q", expected, output.cfgs)
+	}
+	expectedEnv := []envVar{{"VERSION", "1.2.3"}, {"GENERATED", "/sandbox/out/gen.rs"}}
+	if !reflect.DeepEqual(output.env, expectedEnv) {
+		t.Errorf("expected env %q, got %q", expectedEnv, output.env)
+	}
+	if expected := []string{"something happened"}; !reflect.DeepEqual(output.warnings, expected) {
+		t.Errorf("expected warnings %q, got %q", expected, output.warnings)
+	}
+}
+
+func TestParseOutputErrors(t *testing.T) {
+	testCases := []struct {
+		in  string
+		err string
+	}{
+		{
+			in:  "cargo:rustc-cfg\n",
+			err: `invalid build script output "cargo:rustc-cfg", expected cargo:KEY=VALUE`,
+		},
+		{
+			in:  "cargo:rustc-env=VERSION\n",
+			err: `invalid build script output "cargo:rustc-env=VERSION", expected cargo:rustc-env=VAR=VALUE`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.in, func(t *testing.T) {
+			_, err := parseOutput(strings.NewReader(tc.in))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
+
+func TestWriteCfgs(t *testing.T) {
+	buf := &bytes.Buffer{}
+	writeCfgs(buf, []string{"has_foo", `feature="bar"`})
+
+	expected := "--cfg\nhas_foo\n--cfg\nfeature=\"bar\"\n"
+	if got := buf.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestWriteEnv(t *testing.T) {
+	env := []envVar{
+		{"VERSION", "1.2.3"},
+		{"GENERATED", "/sandbox/out/gen.rs"},
+		{"QUOTED", `say "$HOME"`},
+	}
+
+	buf := &bytes.Buffer{}
+	writeEnv(buf, env, "/sandbox/out", "out/soong/.intermediates/foo/build_script/out")
+
+	expected := `export VERSION="1.2.3"
+export GENERATED="${PWD}/out/soong/.intermediates/foo/build_script/out/gen.rs"
+export QUOTED="say \"\$HOME\""
+`
+	if got := buf.String(); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestAbsToolEnv(t *testing.T) {
+	wd, err := filepath.Abs(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, err := absToolEnv([]string{
+		"RUSTC=tools/src/prebuilts/rust/bin/rustc",
+		"CARGO=/abs/cargo",
+		"RUSTDOC=",
+		"TARGET=x86_64-unknown-linux-gnu",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := []string{"RUSTC=" + filepath.Join(wd, "tools/src/prebuilts/rust/bin/rustc")}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("expected %q, got %q", expected, env)
+	}
+}
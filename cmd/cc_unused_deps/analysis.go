@@ -0,0 +1,261 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// linkDepsDependency is a dependency of a module, as written by Soong into
+// module_bp_cc_link_deps.json.
+type linkDepsDependency struct {
+	Name              string   `json:"name"`
+	Kind              string   `json:"kind"`
+	Library           string   `json:"library,omitempty"`
+	Include_dirs      []string `json:"include_dirs,omitempty"`
+	Generated_headers []string `json:"generated_headers,omitempty"`
+}
+
+// linkDepsModule is a variant of a module, as written by Soong into
+// module_bp_cc_link_deps.json.
+type linkDepsModule struct {
+	Name      string               `json:"name"`
+	Variant   string               `json:"variant"`
+	Blueprint string               `json:"blueprint"`
+	Output    string               `json:"output"`
+	Objects   []string             `json:"objects,omitempty"`
+	Deps      []linkDepsDependency `json:"deps"`
+}
+
+type linkDeps struct {
+	Llvm_nm string           `json:"llvm_nm"`
+	Modules []linkDepsModule `json:"modules"`
+}
+
+func readLinkDeps(r io.Reader) (linkDeps, error) {
+	var deps linkDeps
+	if err := json.NewDecoder(r).Decode(&deps); err != nil {
+		return linkDeps{}, err
+	}
+	return deps, nil
+}
+
+// Dependency is a dependency listed in the shared_libs, static_libs or header_libs property of a
+// module.
+type Dependency struct {
+	Name string `json:"name"`
+	Kind string `json:"kind"`
+}
+
+func (d Dependency) String() string {
+	return d.Kind + ": " + d.Name
+}
+
+// ModuleReport lists the unused dependencies of a module.
+type ModuleReport struct {
+	Module    string `json:"module"`
+	Blueprint string `json:"blueprint"`
+	// Unused lists the dependencies that contributed no symbols and no headers to any variant
+	// of the module. They can be removed from the Android.bp file.
+	Unused []Dependency `json:"unused,omitempty"`
+	// UnusedInVariants lists the dependencies that are only unused in some variants of the
+	// module, along with those variants. They may be moved to a target or arch specific property.
+	UnusedInVariants map[string][]string `json:"unused_in_variants,omitempty"`
+	// SkippedVariants lists the variants that could not be analyzed because they were not built.
+	// Dependencies of modules with skipped variants are never reported as unused in all variants.
+	SkippedVariants []string `json:"skipped_variants,omitempty"`
+}
+
+// analyzer finds the dependencies of modules that contributed neither symbols to the linked
+// output nor headers to the compiled objects.
+type analyzer struct {
+	symbols symbolReader
+	// headers maps object files to the headers they included, from the ninja deps log.
+	headers map[string][]string
+	// exists returns true if a file exists, used to skip the variants that were not built.
+	exists func(string) bool
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// unusedDeps returns the dependencies of a module variant that are unused, or ok == false if the
+// variant could not be analyzed because it was not built.
+func (a *analyzer) unusedDeps(m linkDepsModule) (unused []Dependency, ok bool, err error) {
+	if !a.exists(m.Output) {
+		return nil, false, nil
+	}
+
+	var headers []string
+	for _, object := range m.Objects {
+		objectHeaders, found := a.headers[filepath.Clean(object)]
+		if !found {
+			// Without the headers of every object a header could be missed and reported
+			// as unused.
+			return nil, false, nil
+		}
+		headers = append(headers, objectHeaders...)
+	}
+
+	var defined, undefined symbolTable
+	for _, dep := range m.Deps {
+		if usesHeaders(headers, dep) {
+			continue
+		}
+
+		used := false
+		switch dep.Kind {
+		case "shared_libs":
+			if undefined == nil {
+				if undefined, err = a.symbols.undefinedSymbols(m.Output); err != nil {
+					return nil, false, err
+				}
+			}
+			exported, err := a.symbols.exportedSymbols(dep.Library, false)
+			if err != nil {
+				return nil, false, err
+			}
+			used = exported.intersects(undefined)
+		case "static_libs":
+			if defined == nil {
+				if defined, err = a.symbols.definedSymbols(m.Output); err != nil {
+					return nil, false, err
+				}
+			}
+			exported, err := a.symbols.exportedSymbols(dep.Library, true)
+			if err != nil {
+				return nil, false, err
+			}
+			used = exported.intersects(defined)
+		}
+
+		if !used {
+			unused = append(unused, Dependency{Name: dep.Name, Kind: dep.Kind})
+		}
+	}
+	return unused, true, nil
+}
+
+// usesHeaders returns true if any of the headers was exported by the dependency.
+func usesHeaders(headers []string, dep linkDepsDependency) bool {
+	var prefixes []string
+	for _, dir := range dep.Include_dirs {
+		prefixes = append(prefixes, filepath.Clean(dir)+"/")
+	}
+	generated := make(map[string]bool)
+	for _, header := range dep.Generated_headers {
+		generated[filepath.Clean(header)] = true
+	}
+
+	for _, header := range headers {
+		if generated[header] {
+			return true
+		}
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(header, prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// analyze returns the reports of all modules that have unused dependencies or variants that could
+// not be analyzed. A dependency is only reported as unused when it is unused in every analyzed
+// variant of the module that lists it.
+func (a *analyzer) analyze(deps linkDeps) ([]ModuleReport, error) {
+	type moduleKey struct{ name, blueprint string }
+	type depState struct {
+		variants       []string
+		unusedVariants []string
+	}
+
+	var keys []moduleKey
+	states := make(map[moduleKey]map[Dependency]*depState)
+	skipped := make(map[moduleKey][]string)
+
+	for _, m := range deps.Modules {
+		key := moduleKey{m.Name, m.Blueprint}
+		if _, exists := states[key]; !exists {
+			keys = append(keys, key)
+			states[key] = make(map[Dependency]*depState)
+		}
+
+		unused, ok, err := a.unusedDeps(m)
+		if err != nil {
+			return nil, fmt.Errorf("module %q variant %q: %w", m.Name, m.Variant, err)
+		}
+		if !ok {
+			skipped[key] = append(skipped[key], m.Variant)
+			continue
+		}
+
+		for _, dep := range m.Deps {
+			d := Dependency{Name: dep.Name, Kind: dep.Kind}
+			if states[key][d] == nil {
+				states[key][d] = &depState{}
+			}
+			states[key][d].variants = append(states[key][d].variants, m.Variant)
+		}
+		for _, d := range unused {
+			states[key][d].unusedVariants = append(states[key][d].unusedVariants, m.Variant)
+		}
+	}
+
+	var reports []ModuleReport
+	for _, key := range keys {
+		report := ModuleReport{
+			Module:          key.name,
+			Blueprint:       key.blueprint,
+			SkippedVariants: skipped[key],
+		}
+		for d, state := range states[key] {
+			if len(state.unusedVariants) == 0 {
+				continue
+			}
+			if len(state.unusedVariants) == len(state.variants) && len(skipped[key]) == 0 {
+				report.Unused = append(report.Unused, d)
+			} else {
+				if report.UnusedInVariants == nil {
+					report.UnusedInVariants = make(map[string][]string)
+				}
+				report.UnusedInVariants[d.String()] = state.unusedVariants
+			}
+		}
+		sort.Slice(report.Unused, func(i, j int) bool {
+			return report.Unused[i].String() < report.Unused[j].String()
+		})
+
+		if len(report.Unused) > 0 || len(report.UnusedInVariants) > 0 {
+			reports = append(reports, report)
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Blueprint != reports[j].Blueprint {
+			return reports[i].Blueprint < reports[j].Blueprint
+		}
+		return reports[i].Module < reports[j].Module
+	})
+	return reports, nil
+}
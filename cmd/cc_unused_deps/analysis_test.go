@@ -0,0 +1,182 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// fakeSymbols is a symbolReader that returns fixed symbol tables.
+type fakeSymbols struct {
+	defined   map[string]symbolTable
+	undefined map[string]symbolTable
+	exported  map[string]symbolTable
+}
+
+func (s fakeSymbols) definedSymbols(file string) (symbolTable, error) {
+	return s.defined[file], nil
+}
+
+func (s fakeSymbols) undefinedSymbols(file string) (symbolTable, error) {
+	return s.undefined[file], nil
+}
+
+func (s fakeSymbols) exportedSymbols(file string, archive bool) (symbolTable, error) {
+	return s.exported[file], nil
+}
+
+func testAnalyzer(built ...string) *analyzer {
+	return &analyzer{
+		symbols: fakeSymbols{
+			defined: map[string]symbolTable{
+				"out/foo_arm64/foo": {"main": true, "used_static": true},
+				"out/foo_arm/foo":   {"main": true},
+			},
+			undefined: map[string]symbolTable{
+				"out/foo_arm64/foo": {"used_shared": true, "arm64_only": true},
+				"out/foo_arm/foo":   {"used_shared": true},
+			},
+			exported: map[string]symbolTable{
+				"out/libused.so":         {"used_shared": true},
+				"out/libunused.so":       {"unused_shared": true},
+				"out/libarm64.so":        {"arm64_only": true},
+				"out/libheaders_only.so": {"unused_shared2": true},
+				"out/libstatic.a":        {"used_static": true},
+				"out/libstatic_unused.a": {"unused_static": true},
+			},
+		},
+		headers: map[string][]string{
+			"out/foo_arm64/obj/foo.o": {"bionic/libc/include/stdio.h", "headers_only/include/h.h"},
+			"out/foo_arm/obj/foo.o":   {"bionic/libc/include/stdio.h", "headers_only/include/h.h"},
+		},
+		exists: func(path string) bool {
+			for _, b := range built {
+				if b == path {
+					return true
+				}
+			}
+			return false
+		},
+	}
+}
+
+func testLinkDeps() linkDeps {
+	deps := []linkDepsDependency{
+		{Name: "libused", Kind: "shared_libs", Library: "out/libused.so"},
+		{Name: "libunused", Kind: "shared_libs", Library: "out/libunused.so"},
+		{Name: "libarm64", Kind: "shared_libs", Library: "out/libarm64.so"},
+		{Name: "libheaders_only", Kind: "shared_libs", Library: "out/libheaders_only.so",
+			Include_dirs: []string{"headers_only/include"}},
+		{Name: "libstatic", Kind: "static_libs", Library: "out/libstatic.a"},
+		{Name: "libstatic_unused", Kind: "static_libs", Library: "out/libstatic_unused.a"},
+		{Name: "libheader", Kind: "header_libs", Include_dirs: []string{"header/include"}},
+	}
+	return linkDeps{
+		Modules: []linkDepsModule{
+			{
+				Name:      "foo",
+				Variant:   "android_arm64_armv8-a",
+				Blueprint: "foo/Android.bp",
+				Output:    "out/foo_arm64/foo",
+				Objects:   []string{"out/foo_arm64/obj/foo.o"},
+				Deps:      deps,
+			},
+			{
+				Name:      "foo",
+				Variant:   "android_arm_armv7-a-neon",
+				Blueprint: "foo/Android.bp",
+				Output:    "out/foo_arm/foo",
+				Objects:   []string{"out/foo_arm/obj/foo.o"},
+				Deps:      deps,
+			},
+		},
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	reports, err := testAnalyzer("out/foo_arm64/foo", "out/foo_arm/foo").analyze(testLinkDeps())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []ModuleReport{
+		{
+			Module:    "foo",
+			Blueprint: "foo/Android.bp",
+			Unused: []Dependency{
+				{Name: "libheader", Kind: "header_libs"},
+				{Name: "libunused", Kind: "shared_libs"},
+				{Name: "libstatic_unused", Kind: "static_libs"},
+			},
+			UnusedInVariants: map[string][]string{
+				"shared_libs: libarm64":  {"android_arm_armv7-a-neon"},
+				"static_libs: libstatic": {"android_arm_armv7-a-neon"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("incorrect reports\nwant: %#v\n got: %#v", want, reports)
+	}
+
+	buf := &bytes.Buffer{}
+	writeText(buf, reports)
+	wantText := `foo/Android.bp: foo
+  unused header_libs: libheader
+  unused shared_libs: libunused
+  unused static_libs: libstatic_unused
+  unused shared_libs: libarm64 in android_arm_armv7-a-neon
+  unused static_libs: libstatic in android_arm_armv7-a-neon
+`
+	if buf.String() != wantText {
+		t.Errorf("incorrect text\nwant:\n%s\n got:\n%s", wantText, buf.String())
+	}
+}
+
+func TestAnalyzeSkipsVariantsThatWereNotBuilt(t *testing.T) {
+	reports, err := testAnalyzer("out/foo_arm64/foo").analyze(testLinkDeps())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(reports) != 1 {
+		t.Fatalf("expected 1 report, got %#v", reports)
+	}
+	if len(reports[0].Unused) != 0 {
+		t.Errorf("expected no dependency to be unused in all variants, got %q", reports[0].Unused)
+	}
+	wantSkipped := []string{"android_arm_armv7-a-neon"}
+	if !reflect.DeepEqual(reports[0].SkippedVariants, wantSkipped) {
+		t.Errorf("expected skipped variants %q, got %q", wantSkipped, reports[0].SkippedVariants)
+	}
+	if got := reports[0].UnusedInVariants["shared_libs: libunused"]; !reflect.DeepEqual(got,
+		[]string{"android_arm64_armv8-a"}) {
+		t.Errorf("expected libunused to be unused in android_arm64_armv8-a, got %q", got)
+	}
+}
+
+func TestAnalyzeSkipsObjectsMissingFromDepsLog(t *testing.T) {
+	a := testAnalyzer("out/foo_arm64/foo", "out/foo_arm/foo")
+	delete(a.headers, "out/foo_arm/obj/foo.o")
+
+	reports, err := a.analyze(testLinkDeps())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(reports) != 1 || !reflect.DeepEqual(reports[0].SkippedVariants, []string{"android_arm_armv7-a-neon"}) {
+		t.Errorf("expected android_arm_armv7-a-neon to be skipped, got %#v", reports)
+	}
+}
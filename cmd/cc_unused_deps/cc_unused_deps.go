@@ -0,0 +1,185 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// cc_unused_deps reports the shared_libs, static_libs and header_libs of cc modules that contributed
+// no symbols to the linked binary or shared library and no headers to its objects, and can remove
+// them from the Android.bp files.
+//
+// It reads module_bp_cc_link_deps.json, which Soong writes when SOONG_CC_UNUSED_DEPS=true is set,
+// and the ninja deps log through the output of `ninja -t deps`, after a build:
+//
+//	SOONG_CC_UNUSED_DEPS=true m
+//	prebuilts/build-tools/linux-x86/bin/ninja -f out/combined-<product>.ninja -t deps > deps.txt
+//	cc_unused_deps -link_deps out/soong/module_bp_cc_link_deps.json -deps deps.txt
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"android/soong/bpfix/removedeps"
+	"android/soong/makedeps"
+)
+
+var (
+	linkDepsFile = flag.String("link_deps", "", "module_bp_cc_link_deps.json written by Soong")
+	depsFile     = flag.String("deps", "", "output of `ninja -t deps`, or - for stdin")
+	llvmNmPath   = flag.String("llvm_nm", "", "path to llvm-nm, defaults to the one used by the build")
+	output       = flag.String("o", "", "optional JSON report")
+	fix          = flag.Bool("fix", false, "remove the unused dependencies from the Android.bp files")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -link_deps <module_bp_cc_link_deps.json> -deps <ninja -t deps output> [-o <report>] [-fix]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *linkDepsFile == "" || *depsFile == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	f, err := os.Open(*linkDepsFile)
+	if err != nil {
+		return err
+	}
+	deps, err := readLinkDeps(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", *linkDepsFile, err)
+	}
+
+	headers, err := readNinjaDeps(*depsFile)
+	if err != nil {
+		return err
+	}
+
+	nm := *llvmNmPath
+	if nm == "" {
+		nm = deps.Llvm_nm
+	}
+
+	a := &analyzer{
+		symbols: newLlvmNm(nm),
+		headers: headers,
+		exists:  fileExists,
+	}
+	reports, err := a.analyze(deps)
+	if err != nil {
+		return err
+	}
+
+	writeText(os.Stdout, reports)
+
+	if *output != "" {
+		buf, err := json.MarshalIndent(reports, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*output, append(buf, '\n'), 0666); err != nil {
+			return err
+		}
+	}
+
+	if *fix {
+		return fixBlueprints(reports)
+	}
+	return nil
+}
+
+// readNinjaDeps returns the headers included by each object file in the ninja deps log. The
+// first dependency of each object is the source file it was compiled from.
+func readNinjaDeps(file string) (map[string][]string, error) {
+	r := os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	deps, err := makedeps.ParseNinjaDeps(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+
+	headers := make(map[string][]string)
+	for _, d := range deps {
+		if !strings.HasSuffix(d.Output, ".o") {
+			continue
+		}
+		var objectHeaders []string
+		for i, input := range d.Inputs {
+			if i > 0 {
+				objectHeaders = append(objectHeaders, filepath.Clean(input))
+			}
+		}
+		headers[filepath.Clean(d.Output)] = objectHeaders
+	}
+	return headers, nil
+}
+
+func writeText(w io.Writer, reports []ModuleReport) {
+	for _, report := range reports {
+		fmt.Fprintf(w, "%s: %s\n", report.Blueprint, report.Module)
+		for _, dep := range report.Unused {
+			fmt.Fprintf(w, "  unused %s\n", dep)
+		}
+		var partial []string
+		for dep := range report.UnusedInVariants {
+			partial = append(partial, dep)
+		}
+		sort.Strings(partial)
+		for _, dep := range partial {
+			fmt.Fprintf(w, "  unused %s in %s\n", dep, strings.Join(report.UnusedInVariants[dep], ", "))
+		}
+		if len(report.SkippedVariants) > 0 {
+			fmt.Fprintf(w, "  not built: %s\n", strings.Join(report.SkippedVariants, ", "))
+		}
+	}
+}
+
+// fixBlueprints removes the unused dependencies listed in the reports from the blueprint files
+// that define the modules.
+func fixBlueprints(reports []ModuleReport) error {
+	deps := make(map[string]map[string][]removedeps.Dependency)
+	for _, report := range reports {
+		for _, dep := range report.Unused {
+			if deps[report.Blueprint] == nil {
+				deps[report.Blueprint] = make(map[string][]removedeps.Dependency)
+			}
+			deps[report.Blueprint][report.Module] = append(deps[report.Blueprint][report.Module],
+				removedeps.Dependency{Name: dep.Name, Property: dep.Kind})
+		}
+	}
+	return removedeps.FixBlueprints(deps)
+}
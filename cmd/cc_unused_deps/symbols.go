@@ -0,0 +1,116 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// symbolTable is a set of symbol names.
+type symbolTable map[string]bool
+
+// intersects returns true if the two tables have at least one symbol in common.
+func (t symbolTable) intersects(other symbolTable) bool {
+	if len(other) < len(t) {
+		t, other = other, t
+	}
+	for symbol := range t {
+		if other[symbol] {
+			return true
+		}
+	}
+	return false
+}
+
+// symbolReader reads the symbols of binaries, shared libraries and static archives.
+type symbolReader interface {
+	// definedSymbols returns the symbols defined in a binary or shared library, including local
+	// symbols.
+	definedSymbols(file string) (symbolTable, error)
+	// undefinedSymbols returns the symbols referenced but not defined by a binary or shared library.
+	undefinedSymbols(file string) (symbolTable, error)
+	// exportedSymbols returns the dynamic symbols exported by a shared library, or the global
+	// symbols defined by the members of a static archive.
+	exportedSymbols(file string, archive bool) (symbolTable, error)
+}
+
+// llvmNm reads symbols by running llvm-nm. The results are cached as the same library is usually
+// a dependency of many modules.
+type llvmNm struct {
+	path  string
+	cache map[string]symbolTable
+}
+
+func newLlvmNm(path string) *llvmNm {
+	return &llvmNm{path: path, cache: make(map[string]symbolTable)}
+}
+
+func (nm *llvmNm) definedSymbols(file string) (symbolTable, error) {
+	return nm.run(file, "--defined-only")
+}
+
+func (nm *llvmNm) undefinedSymbols(file string) (symbolTable, error) {
+	return nm.run(file, "--undefined-only")
+}
+
+func (nm *llvmNm) exportedSymbols(file string, archive bool) (symbolTable, error) {
+	if archive {
+		return nm.run(file, "--defined-only", "--extern-only")
+	}
+	return nm.run(file, "--defined-only", "--dynamic")
+}
+
+func (nm *llvmNm) run(file string, args ...string) (symbolTable, error) {
+	key := file + " " + strings.Join(args, " ")
+	if symbols, ok := nm.cache[key]; ok {
+		return symbols, nil
+	}
+
+	args = append(args, "--format=just-symbols", file)
+	cmd := exec.Command(nm.path, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("%s %s failed: %w\n%s", nm.path, strings.Join(args, " "), err, stderr.String())
+	}
+
+	symbols := parseNmOutput(string(output))
+	nm.cache[key] = symbols
+	return symbols, nil
+}
+
+// parseNmOutput parses the output of `llvm-nm --format=just-symbols`, which lists one symbol per
+// line. For static archives the symbols of each member are preceded by a blank line and a line
+// containing the member name followed by a colon. Symbol versions are dropped, as the references
+// in a binary and the definitions in a shared library don't necessarily use the same version
+// syntax.
+func parseNmOutput(output string) symbolTable {
+	symbols := make(symbolTable)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasSuffix(line, ":") {
+			continue
+		}
+		if i := strings.Index(line, "@"); i > 0 {
+			line = line[:i]
+		}
+		symbols[line] = true
+	}
+	return symbols
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNmOutput(t *testing.T) {
+	output := `
+foo.o:
+foo
+_ZN3foo3barEv
+
+bar.o:
+bar
+memcpy@LIBC
+strlen@@LIBC_N
+`
+	want := symbolTable{
+		"foo":           true,
+		"_ZN3foo3barEv": true,
+		"bar":           true,
+		"memcpy":        true,
+		"strlen":        true,
+	}
+	if got := parseNmOutput(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect symbols\nwant: %v\n got: %v", want, got)
+	}
+}
+
+func TestSymbolTableIntersects(t *testing.T) {
+	a := symbolTable{"foo": true, "bar": true}
+	if !a.intersects(symbolTable{"bar": true, "baz": true, "qux": true}) {
+		t.Errorf("expected tables sharing bar to intersect")
+	}
+	if a.intersects(symbolTable{"baz": true}) {
+		t.Errorf("expected tables without common symbols not to intersect")
+	}
+	if a.intersects(symbolTable{}) {
+		t.Errorf("expected empty table not to intersect")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// idea_project writes an IntelliJ IDEA or Android Studio project for the modules of the
+// idea-project goal.  The project description written by Soong lists the sources, generated
+// sources, srcjars, Android resources and dependencies of each module, and the project is written
+// to a zip to be extracted at the top of the source tree.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s -project <project.json> -o <zip>\n", os.Args[0])
+	flag.PrintDefaults()
+	os.Exit(2)
+}
+
+func main() {
+	projectFile := flag.String("project", "", "the project description written by Soong")
+	output := flag.String("o", "", "the zip of the project files to write")
+	flag.Usage = usage
+	flag.Parse()
+	if *projectFile == "" || *output == "" || flag.NArg() > 0 {
+		usage()
+	}
+
+	if err := run(*projectFile, *output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(projectFile, output string) error {
+	buf, err := ioutil.ReadFile(projectFile)
+	if err != nil {
+		return err
+	}
+	var p project
+	if err := json.Unmarshal(buf, &p); err != nil {
+		return fmt.Errorf("%s: %s", projectFile, err)
+	}
+
+	out, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := writeProject(out, &p); err != nil {
+		out.Close()
+		os.Remove(output)
+		return err
+	}
+	return out.Close()
+}
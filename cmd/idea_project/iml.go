@@ -0,0 +1,209 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const miscXML = `<?xml version="1.0" encoding="UTF-8"?>
+<project version="4">
+  <component name="ProjectRootManager" version="2" />
+</project>
+`
+
+// attr escapes s for an XML attribute value.
+func attr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+// fileURL returns the IntelliJ URL of a path relative to the top of the source tree, which is the
+// project directory, or of an absolute path.
+func fileURL(p string) string {
+	if path.IsAbs(p) {
+		return "file://" + p
+	}
+	if p == "" || p == "." {
+		return "file://$PROJECT_DIR$"
+	}
+	return "file://$PROJECT_DIR$/" + p
+}
+
+func jarURL(p string) string {
+	return "jar" + strings.TrimPrefix(fileURL(p), "file") + "!/"
+}
+
+// facetPath returns a path relative to the project directory as the Android facet expects it,
+// relative to the directory of the module file.
+func facetPath(p string) string {
+	if path.IsAbs(p) {
+		return p
+	}
+	return "/../../" + p
+}
+
+// isUnder returns true if p is dir or in dir.
+func isUnder(p, dir string) bool {
+	return dir == "." || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+func modulesXML(modules []module) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<project version="4">` + "\n")
+	buf.WriteString(`  <component name="ProjectModuleManager">` + "\n")
+	buf.WriteString(`    <modules>` + "\n")
+	for _, m := range modules {
+		iml := "$PROJECT_DIR$/" + imlFile(m)
+		fmt.Fprintf(&buf, "      <module fileurl=\"file://%s\" filepath=\"%s\" />\n", attr(iml), attr(iml))
+	}
+	buf.WriteString(`    </modules>` + "\n")
+	buf.WriteString(`  </component>` + "\n")
+	buf.WriteString(`</project>` + "\n")
+	return buf.Bytes()
+}
+
+// contentRoot is a content root of a module and its source folders.
+type contentRoot struct {
+	dir     string
+	folders []sourceFolder
+}
+
+type sourceFolder struct {
+	sourceRoot
+	generated bool
+}
+
+// contentRoots returns the content roots of a module.  The directory of the module is the first
+// content root unless another module of the project owns it, as IntelliJ doesn't allow modules to
+// share a content root.  Each source folder outside of it is in a content root of its own, unless
+// it is in another source folder.
+func contentRoots(m module, ownsDir bool, srcs, genSrcs []sourceRoot) []*contentRoot {
+	var roots []*contentRoot
+	if ownsDir {
+		roots = append(roots, &contentRoot{dir: m.Dir})
+	}
+	add := func(root sourceRoot, generated bool) {
+		folder := sourceFolder{root, generated}
+		for _, r := range roots {
+			if isUnder(root.dir, r.dir) {
+				r.folders = append(r.folders, folder)
+				return
+			}
+		}
+		roots = append(roots, &contentRoot{dir: root.dir, folders: []sourceFolder{folder}})
+	}
+	for _, root := range srcs {
+		add(root, false)
+	}
+	for _, root := range genSrcs {
+		add(root, true)
+	}
+	return roots
+}
+
+// moduleIml returns the IntelliJ module file of a module with the source folders of its sources
+// and its generated sources.
+func moduleIml(m module, ownsDir bool, srcs, genSrcs []sourceRoot) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	buf.WriteString(`<module type="JAVA_MODULE" version="4">` + "\n")
+
+	if a := m.Android; a != nil {
+		option := func(name, value string) {
+			fmt.Fprintf(&buf, "        <option name=\"%s\" value=\"%s\" />\n", name, attr(value))
+		}
+		buf.WriteString(`  <component name="FacetManager">` + "\n")
+		buf.WriteString(`    <facet type="android" name="Android">` + "\n")
+		buf.WriteString(`      <configuration>` + "\n")
+		if a.Manifest != "" {
+			option("MANIFEST_FILE_RELATIVE_PATH", facetPath(a.Manifest))
+		}
+		if len(a.Res_dirs) > 0 {
+			option("RES_FOLDER_RELATIVE_PATH", facetPath(a.Res_dirs[0]))
+			var urls []string
+			for _, dir := range a.Res_dirs {
+				urls = append(urls, fileURL(dir))
+			}
+			option("RES_FOLDERS_RELATIVE_PATH", strings.Join(urls, ";"))
+		}
+		if len(a.Asset_dirs) > 0 {
+			option("ASSETS_FOLDER_RELATIVE_PATH", facetPath(a.Asset_dirs[0]))
+		}
+		if a.Library {
+			option("PROJECT_TYPE", "1")
+		}
+		buf.WriteString(`      </configuration>` + "\n")
+		buf.WriteString(`    </facet>` + "\n")
+		buf.WriteString(`  </component>` + "\n")
+	}
+
+	buf.WriteString(`  <component name="NewModuleRootManager" inherit-compiler-output="true">` + "\n")
+	buf.WriteString(`    <exclude-output />` + "\n")
+	for _, root := range contentRoots(m, ownsDir, srcs, genSrcs) {
+		fmt.Fprintf(&buf, "    <content url=\"%s\">\n", attr(fileURL(root.dir)))
+		for _, folder := range root.folders {
+			fmt.Fprintf(&buf, "      <sourceFolder url=\"%s\" isTestSource=\"%t\"", attr(fileURL(folder.dir)), m.Test)
+			if folder.packagePrefix != "" {
+				fmt.Fprintf(&buf, " packagePrefix=\"%s\"", attr(folder.packagePrefix))
+			}
+			if folder.generated {
+				buf.WriteString(` generated="true"`)
+			}
+			buf.WriteString(" />\n")
+		}
+		buf.WriteString(`    </content>` + "\n")
+	}
+
+	if m.Sdk_version != "" {
+		fmt.Fprintf(&buf, "    <orderEntry type=\"jdk\" jdkName=\"Android API %s Platform\" jdkType=\"Android SDK\" />\n",
+			attr(m.Sdk_version))
+	} else {
+		buf.WriteString(`    <orderEntry type="inheritedJdk" />` + "\n")
+	}
+	buf.WriteString(`    <orderEntry type="sourceFolder" forTests="false" />` + "\n")
+
+	for _, dep := range m.Deps {
+		exported := ""
+		if dep.Exported {
+			exported = ` exported=""`
+		}
+		if dep.Module != "" {
+			fmt.Fprintf(&buf, "    <orderEntry type=\"module\" module-name=\"%s\"%s />\n", attr(dep.Module), exported)
+			continue
+		}
+		fmt.Fprintf(&buf, "    <orderEntry type=\"module-library\"%s>\n", exported)
+		buf.WriteString(`      <library>` + "\n")
+		buf.WriteString(`        <CLASSES>` + "\n")
+		for _, jar := range dep.Jars {
+			fmt.Fprintf(&buf, "          <root url=\"%s\" />\n", attr(jarURL(jar)))
+		}
+		buf.WriteString(`        </CLASSES>` + "\n")
+		buf.WriteString(`        <JAVADOC />` + "\n")
+		buf.WriteString(`        <SOURCES />` + "\n")
+		buf.WriteString(`      </library>` + "\n")
+		buf.WriteString(`    </orderEntry>` + "\n")
+	}
+
+	buf.WriteString(`  </component>` + "\n")
+	buf.WriteString(`</module>` + "\n")
+	return buf.Bytes()
+}
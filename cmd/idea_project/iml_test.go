@@ -0,0 +1,120 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestModuleIml(t *testing.T) {
+	m := module{
+		Name:        "FooTests",
+		Dir:         "packages/apps/Foo/tests",
+		Test:        true,
+		Sdk_version: "33",
+		Deps: []dependency{
+			{Module: "Foo"},
+			{Jars: []string{"out/soong/.intermediates/junit/android_common/turbine-combined/junit.jar"}, Exported: true},
+		},
+		Android: &androidFacet{
+			Manifest: "packages/apps/Foo/tests/AndroidManifest.xml",
+			Res_dirs: []string{"packages/apps/Foo/tests/res", "packages/apps/Foo/tests/res-extra"},
+		},
+	}
+	srcs := []sourceRoot{
+		{dir: "packages/apps/Foo/tests/src"},
+		{dir: "packages/apps/Foo/common", packagePrefix: "com.android.foo.common"},
+	}
+	genSrcs := []sourceRoot{
+		{dir: ".idea/gen/FooTests"},
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<module type="JAVA_MODULE" version="4">
+  <component name="FacetManager">
+    <facet type="android" name="Android">
+      <configuration>
+        <option name="MANIFEST_FILE_RELATIVE_PATH" value="/../../packages/apps/Foo/tests/AndroidManifest.xml" />
+        <option name="RES_FOLDER_RELATIVE_PATH" value="/../../packages/apps/Foo/tests/res" />
+        <option name="RES_FOLDERS_RELATIVE_PATH" value="file://$PROJECT_DIR$/packages/apps/Foo/tests/res;file://$PROJECT_DIR$/packages/apps/Foo/tests/res-extra" />
+      </configuration>
+    </facet>
+  </component>
+  <component name="NewModuleRootManager" inherit-compiler-output="true">
+    <exclude-output />
+    <content url="file://$PROJECT_DIR$/packages/apps/Foo/tests">
+      <sourceFolder url="file://$PROJECT_DIR$/packages/apps/Foo/tests/src" isTestSource="true" />
+    </content>
+    <content url="file://$PROJECT_DIR$/packages/apps/Foo/common">
+      <sourceFolder url="file://$PROJECT_DIR$/packages/apps/Foo/common" isTestSource="true" packagePrefix="com.android.foo.common" />
+    </content>
+    <content url="file://$PROJECT_DIR$/.idea/gen/FooTests">
+      <sourceFolder url="file://$PROJECT_DIR$/.idea/gen/FooTests" isTestSource="true" generated="true" />
+    </content>
+    <orderEntry type="jdk" jdkName="Android API 33 Platform" jdkType="Android SDK" />
+    <orderEntry type="sourceFolder" forTests="false" />
+    <orderEntry type="module" module-name="Foo" />
+    <orderEntry type="module-library" exported="">
+      <library>
+        <CLASSES>
+          <root url="jar://$PROJECT_DIR$/out/soong/.intermediates/junit/android_common/turbine-combined/junit.jar!/" />
+        </CLASSES>
+        <JAVADOC />
+        <SOURCES />
+      </library>
+    </orderEntry>
+  </component>
+</module>
+`
+	if got := string(moduleIml(m, true, srcs, genSrcs)); got != expected {
+		t.Errorf("expected module file:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+func TestContentRoots(t *testing.T) {
+	m := module{Name: "foo-tests", Dir: "foo"}
+	srcs := []sourceRoot{
+		{dir: "foo/tests/src"},
+		{dir: "foo/tests/src/com/android/foo/flat", packagePrefix: "com.android.foo.flat"},
+	}
+	genSrcs := []sourceRoot{
+		{dir: "/out/soong/.intermediates/foo/gen"},
+	}
+
+	check := func(ownsDir bool, expected [][]string) {
+		t.Helper()
+		var got [][]string
+		for _, root := range contentRoots(m, ownsDir, srcs, genSrcs) {
+			dirs := []string{root.dir}
+			for _, folder := range root.folders {
+				dirs = append(dirs, folder.dir)
+			}
+			got = append(got, dirs)
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("expected content roots %q, got %q", expected, got)
+		}
+	}
+
+	check(true, [][]string{
+		{"foo", "foo/tests/src", "foo/tests/src/com/android/foo/flat"},
+		{"/out/soong/.intermediates/foo/gen", "/out/soong/.intermediates/foo/gen"},
+	})
+	check(false, [][]string{
+		{"foo/tests/src", "foo/tests/src", "foo/tests/src/com/android/foo/flat"},
+		{"/out/soong/.intermediates/foo/gen", "/out/soong/.intermediates/foo/gen"},
+	})
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// project is the project description written by Soong.  Paths are relative to the top of the
+// source tree, or absolute if the output directory is outside of it.
+type project struct {
+	Modules []module `json:"modules"`
+}
+
+type module struct {
+	Name string `json:"name"`
+	// Dir is the directory of the Android.bp file of the module.
+	Dir string `json:"dir"`
+	// Test is set for test modules, whose sources are all test sources.
+	Test bool `json:"test"`
+	// Sdk_version is the API level of the Android SDK that the module is compiled against, or
+	// empty if it is compiled against the platform.
+	Sdk_version string        `json:"sdk_version"`
+	Srcs        []string      `json:"srcs"`
+	Gen_srcs    []string      `json:"gen_srcs"`
+	Srcjars     []string      `json:"srcjars"`
+	Deps        []dependency  `json:"deps"`
+	Android     *androidFacet `json:"android"`
+}
+
+// dependency is either a module of the project or a library of jars.
+type dependency struct {
+	Module   string   `json:"module"`
+	Jars     []string `json:"jars"`
+	Exported bool     `json:"exported"`
+}
+
+// androidFacet is the Android manifest and resources of an Android app or library.
+type androidFacet struct {
+	Manifest   string   `json:"manifest"`
+	Res_dirs   []string `json:"res_dirs"`
+	Asset_dirs []string `json:"asset_dirs"`
+	Library    bool     `json:"library"`
+}
+
+const ideaDir = ".idea"
+
+// genDir returns the directory in the project that the srcjars of a module are extracted to.
+func genDir(m module) string {
+	return path.Join(ideaDir, "gen", m.Name)
+}
+
+func imlFile(m module) string {
+	return path.Join(ideaDir, "modules", m.Name+".iml")
+}
+
+// writeProject writes the zip of the project files of p, which include the sources extracted from
+// the srcjars of its modules.
+func writeProject(out io.Writer, p *project) error {
+	modules := append([]module(nil), p.Modules...)
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Name < modules[j].Name })
+	for i := 1; i < len(modules); i++ {
+		if modules[i].Name == modules[i-1].Name {
+			return fmt.Errorf("duplicate module %q", modules[i].Name)
+		}
+	}
+
+	w := zip.NewWriter(out)
+	if err := addEntry(w, path.Join(ideaDir, "modules.xml"), modulesXML(modules)); err != nil {
+		return err
+	}
+	if err := addEntry(w, path.Join(ideaDir, "misc.xml"), []byte(miscXML)); err != nil {
+		return err
+	}
+
+	// The first module in each directory owns it as a content root.
+	dirOwners := make(map[string]string)
+	for _, m := range modules {
+		if _, exists := dirOwners[m.Dir]; !exists {
+			dirOwners[m.Dir] = m.Name
+		}
+	}
+
+	for _, m := range modules {
+		srcs, err := sourceRoots(m.Srcs)
+		if err != nil {
+			return fmt.Errorf("module %s: %s", m.Name, err)
+		}
+		genSrcs, err := sourceRoots(m.Gen_srcs)
+		if err != nil {
+			return fmt.Errorf("module %s: %s", m.Name, err)
+		}
+		extracted, err := extractSrcJars(w, genDir(m), m.Srcjars)
+		if err != nil {
+			return fmt.Errorf("module %s: %s", m.Name, err)
+		}
+		if extracted {
+			genSrcs = append(genSrcs, sourceRoot{dir: genDir(m)})
+		}
+		if err := addEntry(w, imlFile(m), moduleIml(m, dirOwners[m.Dir] == m.Name, srcs, genSrcs)); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+// extractSrcJars adds the Java and Kotlin sources of the srcjars to dir in the zip, and returns
+// true if there were any.  The first of the sources with the same path is used.
+func extractSrcJars(w *zip.Writer, dir string, srcJars []string) (bool, error) {
+	seen := make(map[string]bool)
+	for _, srcJar := range srcJars {
+		r, err := zip.OpenReader(srcJar)
+		if err != nil {
+			return false, err
+		}
+		for _, f := range r.File {
+			name := path.Clean(f.Name)
+			if !isSource(name) || seen[name] || path.IsAbs(name) || name == ".." ||
+				strings.HasPrefix(name, "../") {
+				continue
+			}
+			seen[name] = true
+			if err := copyEntry(w, path.Join(dir, name), f); err != nil {
+				r.Close()
+				return false, fmt.Errorf("%s: %s", srcJar, err)
+			}
+		}
+		r.Close()
+	}
+	return len(seen) > 0, nil
+}
+
+func copyEntry(w *zip.Writer, name string, f *zip.File) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, src)
+	return err
+}
+
+func addEntry(w *zip.Writer, name string, content []byte) error {
+	dst, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, bytes.NewReader(content))
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func writeSrcJar(t *testing.T, path string, files map[string]string) {
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range files {
+		if err := addEntry(w, name, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, buf.Bytes(), 0666); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWriteProject(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idea_project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	srcs := writeFiles(t, dir, map[string]string{
+		"foo/src/com/android/foo/Foo.java": "package com.android.foo;\nclass Foo {}\n",
+	})
+	srcJar := filepath.Join(dir, "aidl.srcjar")
+	writeSrcJar(t, srcJar, map[string]string{
+		"com/android/foo/IFoo.java": "package com.android.foo;\ninterface IFoo {}\n",
+		"com/android/foo/R.txt":     "int id foo 0x1\n",
+	})
+	rJar := filepath.Join(dir, "R.srcjar")
+	writeSrcJar(t, rJar, map[string]string{
+		"com/android/foo/IFoo.java": "package com.android.foo;\ninterface IFoo { void duplicate(); }\n",
+		"com/android/foo/R.java":    "package com.android.foo;\nclass R {}\n",
+	})
+
+	p := &project{
+		Modules: []module{
+			{Name: "foo", Dir: "foo", Srcs: srcs, Srcjars: []string{srcJar, rJar}},
+			{Name: "bar", Dir: "bar", Deps: []dependency{{Module: "foo"}}},
+		},
+	}
+	buf := &bytes.Buffer{}
+	if err := writeProject(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string]string)
+	var names []string
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		entries[f.Name] = string(content)
+	}
+
+	expected := []string{
+		".idea/modules.xml",
+		".idea/misc.xml",
+		".idea/modules/bar.iml",
+		".idea/gen/foo/com/android/foo/IFoo.java",
+		".idea/gen/foo/com/android/foo/R.java",
+		".idea/modules/foo.iml",
+	}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected entries %q, got %q", expected, names)
+	}
+
+	if got := entries[".idea/gen/foo/com/android/foo/IFoo.java"]; strings.Contains(got, "duplicate") {
+		t.Errorf("expected the first of the duplicate sources to be extracted, got %q", got)
+	}
+	if got := entries[".idea/modules.xml"]; !strings.Contains(got, `filepath="$PROJECT_DIR$/.idea/modules/bar.iml"`) ||
+		!strings.Contains(got, `filepath="$PROJECT_DIR$/.idea/modules/foo.iml"`) {
+		t.Errorf("expected the modules in modules.xml, got:\n%s", got)
+	}
+	foo := entries[".idea/modules/foo.iml"]
+	for _, s := range []string{
+		`<sourceFolder url="file://` + filepath.Join(dir, "foo/src") + `" isTestSource="false" />`,
+		`<sourceFolder url="file://$PROJECT_DIR$/.idea/gen/foo" isTestSource="false" generated="true" />`,
+	} {
+		if !strings.Contains(foo, s) {
+			t.Errorf("expected %q in foo.iml, got:\n%s", s, foo)
+		}
+	}
+	if bar := entries[".idea/modules/bar.iml"]; !strings.Contains(bar, `<orderEntry type="module" module-name="foo" />`) {
+		t.Errorf("expected a dependency on foo in bar.iml, got:\n%s", bar)
+	}
+}
+
+func TestWriteProjectDuplicateModules(t *testing.T) {
+	p := &project{
+		Modules: []module{{Name: "foo", Dir: "foo"}, {Name: "foo", Dir: "bar"}},
+	}
+	err := writeProject(&bytes.Buffer{}, p)
+	if err == nil || !strings.Contains(err.Error(), `duplicate module "foo"`) {
+		t.Errorf("expected a duplicate module error, got %v", err)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"android/soong/javasrc"
+)
+
+func isSource(file string) bool {
+	ext := path.Ext(file)
+	return ext == ".java" || ext == ".kt"
+}
+
+// sourceRoot is a source folder of a module.  The package prefix is set when the sources in the
+// folder aren't laid out by package, which IntelliJ supports for the folder as a whole.
+type sourceRoot struct {
+	dir           string
+	packagePrefix string
+}
+
+// sourceRoots returns the source folders of the Java and Kotlin source files, sorted by directory.
+// The source folder of a file is the directory that its package directory is relative to, or the
+// directory of the file with a package prefix if it isn't in its package directory.
+func sourceRoots(files []string) ([]sourceRoot, error) {
+	roots := make(map[string]sourceRoot)
+	for _, file := range files {
+		if !isSource(file) {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		pkg, err := javasrc.PackagePath(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		root := sourceRoot{dir: path.Dir(file)}
+		if pkg != "" {
+			if root.dir == pkg {
+				root.dir = "."
+			} else if strings.HasSuffix(root.dir, "/"+pkg) {
+				root.dir = strings.TrimSuffix(root.dir, "/"+pkg)
+			} else {
+				root.packagePrefix = strings.ReplaceAll(pkg, "/", ".")
+			}
+		}
+		// Files in the same directory may disagree on their package, the first one is used.
+		if _, exists := roots[root.dir]; !exists {
+			roots[root.dir] = root
+		}
+	}
+
+	var dirs []string
+	for dir := range roots {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	ret := make([]sourceRoot, 0, len(dirs))
+	for _, dir := range dirs {
+		ret = append(ret, roots[dir])
+	}
+	return ret, nil
+}
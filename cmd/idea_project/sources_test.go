@@ -0,0 +1,73 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// writeFiles writes files with their contents in dir, and returns their paths sorted by name.
+func writeFiles(t *testing.T, dir string, files map[string]string) []string {
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func TestSourceRoots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "idea_project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	paths := writeFiles(t, dir, map[string]string{
+		"src/com/android/foo/Foo.java":    "package com.android.foo;\nclass Foo {}\n",
+		"src/com/android/foo/FooTest.kt":  "package com.android.foo\nclass FooTest\n",
+		"kotlin/com/android/bar/Bar.kt":   "package com.android.bar\nclass Bar\n",
+		"flat/Baz.java":                   "package com.android.baz;\nclass Baz {}\n",
+		"flat/Qux.java":                   "package com.android.qux;\nclass Qux {}\n",
+		"default/Default.java":            "class Default {}\n",
+		"src/com/android/foo/foo.logtags": "42 foo\n",
+	})
+
+	got, err := sourceRoots(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []sourceRoot{
+		{dir: filepath.Join(dir, "default")},
+		{dir: filepath.Join(dir, "flat"), packagePrefix: "com.android.baz"},
+		{dir: filepath.Join(dir, "kotlin")},
+		{dir: filepath.Join(dir, "src")},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected source roots %+v, got %+v", expected, got)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"android/soong/makedeps"
+)
+
+// objectDeps contains the dependencies recorded for an object file while compiling it.
+type objectDeps struct {
+	// Object is the path of the object file.
+	Object string
+	// Source is the source file that was compiled into the object file, which is the first
+	// dependency written by the compiler.
+	Source string
+	// Headers are all the other dependencies of the object file, which includes headers
+	// included both directly and transitively.
+	Headers []string
+}
+
+func newObjectDeps(object string, inputs []string) objectDeps {
+	deps := objectDeps{Object: filepath.Clean(object)}
+	for i, input := range inputs {
+		input = filepath.Clean(input)
+		if i == 0 {
+			deps.Source = input
+		} else {
+			deps.Headers = append(deps.Headers, input)
+		}
+	}
+	return deps
+}
+
+// parseNinjaDeps parses the output of `ninja -t deps` and returns the dependencies of the object
+// files. Outputs that are not .o files, such as the outputs of aidl or protoc, are skipped.
+func parseNinjaDeps(r io.Reader) ([]objectDeps, error) {
+	deps, err := makedeps.ParseNinjaDeps(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []objectDeps
+	for _, d := range deps {
+		if strings.HasSuffix(d.Output, ".o") {
+			ret = append(ret, newObjectDeps(d.Output, d.Inputs))
+		}
+	}
+	return ret, nil
+}
+
+// parseDepFile parses a make style depfile written by the compiler with -MD.
+func parseDepFile(filename string, r io.Reader) (objectDeps, error) {
+	deps, err := makedeps.Parse(filename, r)
+	if err != nil {
+		return objectDeps{}, err
+	}
+	if deps.Output == "" {
+		return objectDeps{}, fmt.Errorf("%s: missing output", filename)
+	}
+	return newObjectDeps(deps.Output, deps.Inputs), nil
+}
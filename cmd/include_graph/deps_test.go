@@ -0,0 +1,77 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNinjaDeps(t *testing.T) {
+	input := `out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o: #deps 3, deps mtime 1652 (VALID)
+    foo/foo.cpp
+    foo/include/foo.h
+    bionic/libc/include/stdio.h
+
+out/soong/.intermediates/foo/foo-aidl/gen/IFoo.cpp: #deps 1, deps mtime 1652 (VALID)
+    foo/IFoo.aidl
+
+out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o: #deps 2, deps mtime 1652 (STALE)
+    bar/bar.cpp
+    foo/include/../include/foo.h
+`
+
+	got, err := parseNinjaDeps(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []objectDeps{
+		{
+			Object:  "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o",
+			Source:  "foo/foo.cpp",
+			Headers: []string{"foo/include/foo.h", "bionic/libc/include/stdio.h"},
+		},
+		{
+			Object:  "out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o",
+			Source:  "bar/bar.cpp",
+			Headers: []string{"foo/include/foo.h"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect deps\nwant: %#v\n got: %#v", want, got)
+	}
+}
+
+func TestParseDepFile(t *testing.T) {
+	input := `out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o: \
+  foo/foo.cpp foo/include/foo.h \
+  bionic/libc/include/stdio.h
+`
+	got, err := parseDepFile("foo.o.d", strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := objectDeps{
+		Object:  "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o",
+		Source:  "foo/foo.cpp",
+		Headers: []string{"foo/include/foo.h", "bionic/libc/include/stdio.h"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect deps\nwant: %#v\n got: %#v", want, got)
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// moduleInfo is the information about a module written by Soong into
+// module_bp_cc_include_graph.json.
+type moduleInfo struct {
+	Path                     string   `json:"path"`
+	Export_include_dirs      []string `json:"export_include_dirs,omitempty"`
+	Export_generated_headers []string `json:"export_generated_headers,omitempty"`
+}
+
+type moduleInfos struct {
+	Modules map[string]moduleInfo `json:"modules"`
+}
+
+func readModuleInfos(r io.Reader) (moduleInfos, error) {
+	var infos moduleInfos
+	if err := json.NewDecoder(r).Decode(&infos); err != nil {
+		return moduleInfos{}, err
+	}
+	return infos, nil
+}
+
+// Object is an object file in the include graph.
+type Object struct {
+	Object  string   `json:"object"`
+	Module  string   `json:"module,omitempty"`
+	Source  string   `json:"source"`
+	Headers []string `json:"headers"`
+}
+
+// Header is a header in the include graph.
+type Header struct {
+	Header string `json:"header"`
+	// ExportedBy lists the modules that export the header, either through one of their exported
+	// include directories or as an exported generated header.
+	ExportedBy []string `json:"exported_by,omitempty"`
+	// Owner is the module whose Android.bp file is closest to the header when no module exports it.
+	Owner string `json:"owner,omitempty"`
+	// Objects lists the objects that include the header directly or transitively.
+	Objects []string `json:"objects"`
+	// Modules lists the modules whose objects include the header directly or transitively.
+	Modules []string `json:"modules"`
+}
+
+// Graph is the include graph built from the dependencies of the object files.
+type Graph struct {
+	Objects []*Object `json:"objects"`
+	Headers []*Header `json:"headers"`
+
+	headers map[string]*Header
+}
+
+// newGraph builds the include graph of the given object files, using the module information
+// written by Soong to map object files to the modules that compiled them and headers to the
+// modules that export them.
+func newGraph(infos moduleInfos, deps []objectDeps) *Graph {
+	index := newModuleIndex(infos)
+
+	g := &Graph{headers: make(map[string]*Header)}
+	for _, d := range deps {
+		object := &Object{
+			Object:  d.Object,
+			Module:  index.moduleForObject(d.Object),
+			Source:  d.Source,
+			Headers: d.Headers,
+		}
+		g.Objects = append(g.Objects, object)
+
+		for _, h := range d.Headers {
+			header := g.headers[h]
+			if header == nil {
+				header = &Header{Header: h}
+				header.ExportedBy, header.Owner = index.modulesForHeader(h)
+				g.headers[h] = header
+				g.Headers = append(g.Headers, header)
+			}
+			header.Objects = append(header.Objects, object.Object)
+			if object.Module != "" {
+				header.Modules = append(header.Modules, object.Module)
+			}
+		}
+	}
+
+	sort.Slice(g.Objects, func(i, j int) bool { return g.Objects[i].Object < g.Objects[j].Object })
+	sort.Slice(g.Headers, func(i, j int) bool { return g.Headers[i].Header < g.Headers[j].Header })
+	for _, header := range g.Headers {
+		header.Objects = sortedUniqueStrings(header.Objects)
+		header.Modules = sortedUniqueStrings(header.Modules)
+	}
+
+	return g
+}
+
+// ModuleUsers lists the objects of a module that include a header.
+type ModuleUsers struct {
+	Module  string   `json:"module"`
+	Objects []string `json:"objects"`
+}
+
+// WhoIncludes is the answer to the question "which modules and objects would recompile if this
+// header was modified".
+type WhoIncludes struct {
+	Header     string        `json:"header"`
+	ExportedBy []string      `json:"exported_by,omitempty"`
+	Owner      string        `json:"owner,omitempty"`
+	Objects    int           `json:"objects"`
+	Modules    []ModuleUsers `json:"modules"`
+}
+
+// matchHeaders returns the headers in the graph that match query, either exactly or, if there is
+// no exact match, as a path suffix so that "include/foo.h" matches "foo/include/foo.h".
+func (g *Graph) matchHeaders(query string) []*Header {
+	query = filepath.Clean(query)
+	if header := g.headers[query]; header != nil {
+		return []*Header{header}
+	}
+
+	var ret []*Header
+	for _, header := range g.Headers {
+		if strings.HasSuffix(header.Header, "/"+query) {
+			ret = append(ret, header)
+		}
+	}
+	return ret
+}
+
+// whoIncludes returns the modules and objects that include each header matching query.
+func (g *Graph) whoIncludes(query string) []WhoIncludes {
+	var ret []WhoIncludes
+	for _, header := range g.matchHeaders(query) {
+		byModule := make(map[string][]string)
+		for _, object := range header.Objects {
+			module := g.moduleForObject(object)
+			byModule[module] = append(byModule[module], object)
+		}
+
+		w := WhoIncludes{
+			Header:     header.Header,
+			ExportedBy: header.ExportedBy,
+			Owner:      header.Owner,
+			Objects:    len(header.Objects),
+			Modules:    []ModuleUsers{},
+		}
+		for _, module := range sortedKeys(byModule) {
+			w.Modules = append(w.Modules, ModuleUsers{Module: module, Objects: byModule[module]})
+		}
+		ret = append(ret, w)
+	}
+	return ret
+}
+
+func (g *Graph) moduleForObject(object string) string {
+	i := sort.Search(len(g.Objects), func(i int) bool { return g.Objects[i].Object >= object })
+	if i < len(g.Objects) && g.Objects[i].Object == object {
+		return g.Objects[i].Module
+	}
+	return ""
+}
+
+// fanIn returns the headers of the graph sorted by the number of objects that include them,
+// largest first.
+func (g *Graph) fanIn() []*Header {
+	ret := append([]*Header(nil), g.Headers...)
+	sort.SliceStable(ret, func(i, j int) bool {
+		if len(ret[i].Objects) != len(ret[j].Objects) {
+			return len(ret[i].Objects) > len(ret[j].Objects)
+		}
+		return len(ret[i].Modules) > len(ret[j].Modules)
+	})
+	return ret
+}
+
+// moduleIndex maps paths to the modules that produce or export them.
+type moduleIndex struct {
+	// objectDirs maps "<module dir>/<module name>" to the module name, which is the prefix of
+	// the intermediates directory of all variants of the module.
+	objectDirs map[string]string
+	// includeDirs maps exported include directories to the modules that export them.
+	includeDirs map[string][]string
+	// generatedHeaders maps exported generated headers to the modules that export them.
+	generatedHeaders map[string][]string
+	// moduleDirs maps directories containing an Android.bp file to the modules defined in it.
+	moduleDirs map[string][]string
+}
+
+func newModuleIndex(infos moduleInfos) *moduleIndex {
+	index := &moduleIndex{
+		objectDirs:       make(map[string]string),
+		includeDirs:      make(map[string][]string),
+		generatedHeaders: make(map[string][]string),
+		moduleDirs:       make(map[string][]string),
+	}
+	names := make([]string, 0, len(infos.Modules))
+	for name := range infos.Modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		info := infos.Modules[name]
+		index.objectDirs[filepath.Join(info.Path, name)] = name
+		moduleDir := filepath.Clean(info.Path)
+		index.moduleDirs[moduleDir] = append(index.moduleDirs[moduleDir], name)
+		for _, dir := range info.Export_include_dirs {
+			dir = filepath.Clean(dir)
+			index.includeDirs[dir] = append(index.includeDirs[dir], name)
+		}
+		for _, header := range info.Export_generated_headers {
+			header = filepath.Clean(header)
+			index.generatedHeaders[header] = append(index.generatedHeaders[header], name)
+		}
+	}
+	return index
+}
+
+// moduleForObject returns the module whose intermediates directory contains object, or an empty
+// string if there is none.
+func (index *moduleIndex) moduleForObject(object string) string {
+	const intermediates = ".intermediates/"
+	i := strings.Index(object, intermediates)
+	if i < 0 {
+		return ""
+	}
+	rel := object[i+len(intermediates):]
+
+	// Try the longest prefix first, as module directories may contain directories that happen to
+	// be named after a module.
+	for dir := filepath.Dir(rel); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if module, ok := index.objectDirs[dir]; ok {
+			return module
+		}
+	}
+	return ""
+}
+
+// modulesForHeader returns the modules that export header. If no module exports it, it returns
+// the module defined in the Android.bp file closest to the header as its owner instead.
+func (index *moduleIndex) modulesForHeader(header string) (exportedBy []string, owner string) {
+	if modules, ok := index.generatedHeaders[header]; ok {
+		return modules, ""
+	}
+
+	for dir := filepath.Dir(header); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if modules, ok := index.includeDirs[dir]; ok {
+			return modules, ""
+		}
+	}
+
+	for dir := filepath.Dir(header); dir != "." && dir != "/"; dir = filepath.Dir(dir) {
+		if modules, ok := index.moduleDirs[dir]; ok {
+			return nil, modules[0]
+		}
+	}
+	return nil, ""
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedUniqueStrings(list []string) []string {
+	sort.Strings(list)
+	ret := list[:0]
+	for i, s := range list {
+		if i == 0 || s != list[i-1] {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
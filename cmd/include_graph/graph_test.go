@@ -0,0 +1,187 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+var testModuleInfos = moduleInfos{
+	Modules: map[string]moduleInfo{
+		"libfoo": {
+			Path:                     "foo",
+			Export_include_dirs:      []string{"foo/include"},
+			Export_generated_headers: []string{"out/soong/.intermediates/foo/libfoo_gen/gen/foo_gen.h"},
+		},
+		"libfoo_headers": {
+			Path:                "foo",
+			Export_include_dirs: []string{"foo/include"},
+		},
+		"bar": {
+			Path: "bar",
+		},
+		"libbaz": {
+			Path: "baz",
+		},
+	},
+}
+
+var testObjectDeps = []objectDeps{
+	{
+		Object:  "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o",
+		Source:  "foo/foo.cpp",
+		Headers: []string{"foo/include/foo.h", "foo/private.h"},
+	},
+	{
+		Object:  "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_static/obj/foo/foo.o",
+		Source:  "foo/foo.cpp",
+		Headers: []string{"foo/include/foo.h", "foo/private.h"},
+	},
+	{
+		Object: "out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o",
+		Source: "bar/bar.cpp",
+		Headers: []string{"foo/include/foo.h", "foo/include/foo/detail.h",
+			"out/soong/.intermediates/foo/libfoo_gen/gen/foo_gen.h"},
+	},
+	{
+		Object:  "out/soong/.intermediates/baz/libbaz/android_arm64_armv8-a_shared/obj/baz/baz.o",
+		Source:  "baz/baz.cpp",
+		Headers: []string{"foo/include/foo/detail.h", "external/other/other.h"},
+	},
+}
+
+func TestModuleForObject(t *testing.T) {
+	index := newModuleIndex(moduleInfos{
+		Modules: map[string]moduleInfo{
+			"foo":  {Path: "a"},
+			"bar":  {Path: "a/foo"},
+			"obj":  {Path: "b"},
+			"libx": {Path: "b/obj"},
+		},
+	})
+
+	testCases := []struct {
+		object string
+		want   string
+	}{
+		{"out/soong/.intermediates/a/foo/android_arm64/obj/foo.o", "foo"},
+		{"out/soong/.intermediates/a/foo/bar/android_arm64/obj/bar.o", "bar"},
+		{"out/soong/.intermediates/b/obj/libx/android_arm64/obj/x.o", "libx"},
+		{"out/soong/.intermediates/c/baz/android_arm64/obj/baz.o", ""},
+		{"out/target/product/generic/obj/foo.o", ""},
+	}
+	for _, tc := range testCases {
+		if got := index.moduleForObject(tc.object); got != tc.want {
+			t.Errorf("moduleForObject(%q): want %q, got %q", tc.object, tc.want, got)
+		}
+	}
+}
+
+func TestModulesForHeader(t *testing.T) {
+	index := newModuleIndex(testModuleInfos)
+
+	testCases := []struct {
+		header         string
+		wantExportedBy []string
+		wantOwner      string
+	}{
+		{"foo/include/foo.h", []string{"libfoo", "libfoo_headers"}, ""},
+		{"foo/include/foo/detail.h", []string{"libfoo", "libfoo_headers"}, ""},
+		{"out/soong/.intermediates/foo/libfoo_gen/gen/foo_gen.h", []string{"libfoo"}, ""},
+		{"foo/private.h", nil, "libfoo"},
+		{"external/other/other.h", nil, ""},
+	}
+	for _, tc := range testCases {
+		exportedBy, owner := index.modulesForHeader(tc.header)
+		if !reflect.DeepEqual(exportedBy, tc.wantExportedBy) || owner != tc.wantOwner {
+			t.Errorf("modulesForHeader(%q): want %q, %q, got %q, %q", tc.header,
+				tc.wantExportedBy, tc.wantOwner, exportedBy, owner)
+		}
+	}
+}
+
+func TestWhoIncludes(t *testing.T) {
+	g := newGraph(testModuleInfos, testObjectDeps)
+
+	got := g.whoIncludes("include/foo.h")
+	want := []WhoIncludes{
+		{
+			Header:     "foo/include/foo.h",
+			ExportedBy: []string{"libfoo", "libfoo_headers"},
+			Objects:    3,
+			Modules: []ModuleUsers{
+				{
+					Module:  "bar",
+					Objects: []string{"out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o"},
+				},
+				{
+					Module: "libfoo",
+					Objects: []string{
+						"out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o",
+						"out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_static/obj/foo/foo.o",
+					},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect result\nwant: %#v\n got: %#v", want, got)
+	}
+
+	if got := g.whoIncludes("foo/missing.h"); len(got) != 0 {
+		t.Errorf("expected no result for a header that is not included, got %#v", got)
+	}
+
+	buf := &bytes.Buffer{}
+	writeWhoIncludesText(buf, g.whoIncludes("foo/private.h"), false)
+	wantText := `foo/private.h (not exported, owned by libfoo)
+  recompiles 2 objects in 1 modules:
+    libfoo: 2 objects
+`
+	if buf.String() != wantText {
+		t.Errorf("incorrect text\nwant:\n%s\n got:\n%s", wantText, buf.String())
+	}
+}
+
+func TestFanIn(t *testing.T) {
+	g := newGraph(testModuleInfos, testObjectDeps)
+
+	var got []string
+	for _, header := range g.fanIn() {
+		got = append(got, header.Header)
+	}
+	want := []string{
+		"foo/include/foo.h",
+		"foo/include/foo/detail.h",
+		"foo/private.h",
+		"external/other/other.h",
+		"out/soong/.intermediates/foo/libfoo_gen/gen/foo_gen.h",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect fan-in order\nwant: %q\n got: %q", want, got)
+	}
+
+	buf := &bytes.Buffer{}
+	writeFanInText(buf, g.fanIn()[:2])
+	wantText := `   objects    modules  header
+         3          2  foo/include/foo.h (exported by libfoo, libfoo_headers)
+         2          2  foo/include/foo/detail.h (exported by libfoo, libfoo_headers)
+`
+	if buf.String() != wantText {
+		t.Errorf("incorrect text\nwant:\n%s\n got:\n%s", wantText, buf.String())
+	}
+}
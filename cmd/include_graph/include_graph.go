@@ -0,0 +1,232 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// include_graph builds the header include graph of the object files compiled by cc modules from
+// the ninja deps log or from compiler depfiles, and maps object files back to the modules that
+// compiled them and headers to the modules that export them. It can export the whole graph as
+// JSON, answer which modules and objects would recompile if a header was modified, and list the
+// headers with the largest include fan-in.
+//
+// The module info file is written by Soong when SOONG_COLLECT_CC_INCLUDE_GRAPH is set, and the
+// ninja deps log is read through the output of `ninja -t deps`, for example:
+//
+//	SOONG_COLLECT_CC_INCLUDE_GRAPH=true m
+//	prebuilts/build-tools/linux-x86/bin/ninja -f out/combined-<product>.ninja -t deps > deps.txt
+//	include_graph who-includes -modules out/soong/module_bp_cc_include_graph.json -deps deps.txt foo/include/foo.h
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"android/soong/response"
+)
+
+type multiString []string
+
+func (m *multiString) String() string     { return strings.Join(*m, ", ") }
+func (m *multiString) Set(s string) error { *m = append(*m, s); return nil }
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s export <inputs> -o <output>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s who-includes <inputs> [-v] [-json] <header>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s fan-in <inputs> [-top <n>] [-json]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "where <inputs> are:\n")
+	fmt.Fprintf(os.Stderr, "  -modules <module_bp_cc_include_graph.json> [-deps <ninja -t deps output>]... [-depfile <depfile>|@<rsp file>]...\n")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "export":
+		err = exportCommand(os.Args[2:])
+	case "who-includes":
+		err = whoIncludesCommand(os.Args[2:])
+	case "fan-in":
+		err = fanInCommand(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+// graphInputs are the flags shared by all commands that describe where to read the graph from.
+type graphInputs struct {
+	modules  *string
+	deps     multiString
+	depfiles multiString
+}
+
+func registerGraphInputs(flags *flag.FlagSet) *graphInputs {
+	inputs := &graphInputs{}
+	inputs.modules = flags.String("modules", "",
+		"module_bp_cc_include_graph.json written by Soong, used to map objects and headers to modules")
+	flags.Var(&inputs.deps, "deps", "output of `ninja -t deps`, or - for stdin (may be repeated)")
+	flags.Var(&inputs.depfiles, "depfile", "make style depfile, or @<rsp file> listing depfiles (may be repeated)")
+	return inputs
+}
+
+func (inputs *graphInputs) readGraph() (*Graph, error) {
+	if len(inputs.deps) == 0 && len(inputs.depfiles) == 0 {
+		return nil, fmt.Errorf("at least one of -deps or -depfile is required")
+	}
+
+	var infos moduleInfos
+	if *inputs.modules != "" {
+		f, err := os.Open(*inputs.modules)
+		if err != nil {
+			return nil, err
+		}
+		infos, err = readModuleInfos(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", *inputs.modules, err)
+		}
+	}
+
+	var deps []objectDeps
+	for _, input := range inputs.deps {
+		f := os.Stdin
+		if input != "-" {
+			var err error
+			f, err = os.Open(input)
+			if err != nil {
+				return nil, err
+			}
+		}
+		objects, err := parseNinjaDeps(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", input, err)
+		}
+		deps = append(deps, objects...)
+	}
+
+	depfiles, err := response.ExpandRspFiles(inputs.depfiles)
+	if err != nil {
+		return nil, err
+	}
+	for _, depfile := range depfiles {
+		f, err := os.Open(depfile)
+		if err != nil {
+			return nil, err
+		}
+		object, err := parseDepFile(depfile, f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", depfile, err)
+		}
+		deps = append(deps, object)
+	}
+
+	return newGraph(infos, deps), nil
+}
+
+func exportCommand(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	inputs := registerGraphInputs(flags)
+	out := flags.String("o", "", "output file")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	g, err := inputs.readGraph()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeJSON(f, g)
+}
+
+func whoIncludesCommand(args []string) error {
+	flags := flag.NewFlagSet("who-includes", flag.ExitOnError)
+	inputs := registerGraphInputs(flags)
+	verbose := flags.Bool("v", false, "list the objects of each module that include the header")
+	jsonOutput := flags.Bool("json", false, "write the result as JSON instead of text")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("expected at least one header")
+	}
+
+	g, err := inputs.readGraph()
+	if err != nil {
+		return err
+	}
+
+	var results []WhoIncludes
+	for _, query := range flags.Args() {
+		matches := g.whoIncludes(query)
+		if len(matches) == 0 {
+			return fmt.Errorf("%s is not included by any object", query)
+		}
+		results = append(results, matches...)
+	}
+
+	if *jsonOutput {
+		return writeJSON(os.Stdout, results)
+	}
+	writeWhoIncludesText(os.Stdout, results, *verbose)
+	return nil
+}
+
+func fanInCommand(args []string) error {
+	flags := flag.NewFlagSet("fan-in", flag.ExitOnError)
+	inputs := registerGraphInputs(flags)
+	top := flags.Int("top", 50, "number of headers to list, -1 to list all")
+	jsonOutput := flags.Bool("json", false, "write the result as JSON instead of text")
+	flags.Parse(args)
+
+	g, err := inputs.readGraph()
+	if err != nil {
+		return err
+	}
+
+	headers := g.fanIn()
+	if *top >= 0 && len(headers) > *top {
+		headers = headers[:*top]
+	}
+
+	if *jsonOutput {
+		return writeJSON(os.Stdout, headers)
+	}
+	writeFanInText(os.Stdout, headers)
+	return nil
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
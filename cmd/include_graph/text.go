@@ -0,0 +1,68 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// headerOwnerText describes which module a header belongs to.
+func headerOwnerText(exportedBy []string, owner string) string {
+	if len(exportedBy) > 0 {
+		return "exported by " + strings.Join(exportedBy, ", ")
+	} else if owner != "" {
+		return "not exported, owned by " + owner
+	}
+	return "not owned by any module"
+}
+
+func moduleText(module string) string {
+	if module == "" {
+		return "<unknown module>"
+	}
+	return module
+}
+
+// writeWhoIncludesText writes the modules that include each header, optionally listing the
+// objects of each module.
+func writeWhoIncludesText(w io.Writer, results []WhoIncludes, verbose bool) {
+	for i, result := range results {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s (%s)\n", result.Header, headerOwnerText(result.ExportedBy, result.Owner))
+		fmt.Fprintf(w, "  recompiles %d objects in %d modules:\n", result.Objects, len(result.Modules))
+		for _, users := range result.Modules {
+			fmt.Fprintf(w, "    %s: %d objects\n", moduleText(users.Module), len(users.Objects))
+			if verbose {
+				for _, object := range users.Objects {
+					fmt.Fprintf(w, "      %s\n", object)
+				}
+			}
+		}
+	}
+}
+
+// writeFanInText writes a table of headers and the number of objects and modules that include
+// them.
+func writeFanInText(w io.Writer, headers []*Header) {
+	fmt.Fprintf(w, "%10s %10s  %s\n", "objects", "modules", "header")
+	for _, header := range headers {
+		fmt.Fprintf(w, "%10d %10d  %s (%s)\n", len(header.Objects), len(header.Modules), header.Header,
+			headerOwnerText(header.ExportedBy, header.Owner))
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// jacoco_report supports the JaCoCo coverage reports of the jacoco-report goal.  The "sources"
+// subcommand lays out the sources of a module by package so that JaCoCo finds them, and the
+// "summary" subcommand summarizes the XML reports of the modules and directories in a JSON file.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type multiFlag []string
+
+func (m *multiFlag) String() string { return strings.Join(*m, ", ") }
+
+func (m *multiFlag) Set(s string) error {
+	*m = append(*m, s)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s sources -o <zip> <sources or @rspfile>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s summary -o <json> [-module <name>:<variant>:<xml report>]... [-dir <dir>:<xml report>]...\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "sources":
+		err = sourcesCmd(os.Args[2:])
+	case "summary":
+		err = summaryCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func sourcesCmd(args []string) error {
+	flags := flag.NewFlagSet("sources", flag.ExitOnError)
+	output := flags.String("o", "", "the zip of sources to write")
+	flags.Parse(args)
+	if *output == "" {
+		usage()
+	}
+
+	var files []string
+	for _, arg := range flags.Args() {
+		if strings.HasPrefix(arg, "@") {
+			buf, err := ioutil.ReadFile(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return err
+			}
+			files = append(files, strings.Fields(string(buf))...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeSources(f, files); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func summaryCmd(args []string) error {
+	var modules, dirs multiFlag
+	flags := flag.NewFlagSet("summary", flag.ExitOnError)
+	output := flags.String("o", "", "the JSON summary to write")
+	flags.Var(&modules, "module", "the XML report of a module, as <name>:<variant>:<report>")
+	flags.Var(&dirs, "dir", "the XML report of a directory, as <dir>:<report>")
+	flags.Parse(args)
+	if *output == "" || flags.NArg() > 0 {
+		usage()
+	}
+
+	s := summary{Modules: []coverage{}, Dirs: []coverage{}}
+	for _, m := range modules {
+		parts := strings.SplitN(m, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid -module %q", m)
+		}
+		c, err := readCoverage(parts[2])
+		if err != nil {
+			return err
+		}
+		c.Name, c.Variant = parts[0], parts[1]
+		s.Modules = append(s.Modules, c)
+	}
+	for _, d := range dirs {
+		parts := strings.SplitN(d, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid -dir %q", d)
+		}
+		c, err := readCoverage(parts[1])
+		if err != nil {
+			return err
+		}
+		c.Name = parts[0]
+		s.Dirs = append(s.Dirs, c)
+	}
+	sortCoverage(s.Modules)
+	sortCoverage(s.Dirs)
+
+	buf, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(*output, append(buf, '\n'), 0666)
+}
+
+func readCoverage(report string) (coverage, error) {
+	f, err := os.Open(report)
+	if err != nil {
+		return coverage{}, err
+	}
+	defer f.Close()
+	counters, err := parseReport(f)
+	if err != nil {
+		return coverage{}, fmt.Errorf("failed to parse %s: %w", report, err)
+	}
+	return coverage{Counters: counters}, nil
+}
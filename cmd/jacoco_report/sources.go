@@ -0,0 +1,75 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"android/soong/javasrc"
+)
+
+// writeSources writes a zip of Java and Kotlin source files laid out by package, as JaCoCo expects
+// to find them in source roots.  Other files are ignored.
+func writeSources(out io.Writer, files []string) error {
+	var entries []string
+	sources := make(map[string]string)
+	for _, file := range files {
+		if ext := filepath.Ext(file); ext != ".java" && ext != ".kt" {
+			continue
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		dir, err := javasrc.PackagePath(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+		entry := path.Join(dir, filepath.Base(file))
+		if _, exists := sources[entry]; !exists {
+			entries = append(entries, entry)
+			sources[entry] = file
+		}
+	}
+	sort.Strings(entries)
+
+	w := zip.NewWriter(out)
+	for _, entry := range entries {
+		if err := addFile(w, entry, sources[entry]); err != nil {
+			return err
+		}
+	}
+	return w.Close()
+}
+
+func addFile(w *zip.Writer, entry, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	dst, err := w.CreateHeader(&zip.FileHeader{Name: entry, Method: zip.Deflate})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, f)
+	return err
+}
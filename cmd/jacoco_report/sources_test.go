@@ -0,0 +1,69 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWriteSources(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jacoco_report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"src/Foo.java":     "package com.android.foo;\nclass Foo {}\n",
+		"src/bar/Bar.kt":   "package com.android.bar\nclass Bar\n",
+		"other/Foo.java":   "package com.android.foo;\nclass Foo {}\n",
+		"src/foo.logtags":  "42 foo\n",
+		"src/Default.java": "class Default {}\n",
+	}
+	var paths []string
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeSources(buf, paths); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []string
+	for _, f := range r.File {
+		entries = append(entries, f.Name)
+	}
+	expected := []string{"Default.java", "com/android/bar/Bar.kt", "com/android/foo/Foo.java"}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected entries %q, got %q", expected, entries)
+	}
+}
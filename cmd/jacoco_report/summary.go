@@ -0,0 +1,74 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+)
+
+// counter is a coverage counter of a JaCoCo XML report, like the number of lines covered and
+// missed.
+type counter struct {
+	Covered int `json:"covered"`
+	Missed  int `json:"missed"`
+}
+
+// coverage is the summary of a JaCoCo report, with the counters of all the classes in it by type:
+// INSTRUCTION, BRANCH, LINE, COMPLEXITY, METHOD and CLASS.
+type coverage struct {
+	Name     string             `json:"name"`
+	Variant  string             `json:"variant,omitempty"`
+	Counters map[string]counter `json:"counters"`
+}
+
+type summary struct {
+	Modules []coverage `json:"modules"`
+	Dirs    []coverage `json:"dirs"`
+}
+
+// parseReport returns the counters of a JaCoCo XML report, which are the counter elements directly
+// in its report element.
+func parseReport(r io.Reader) (map[string]counter, error) {
+	var report struct {
+		Counters []struct {
+			Type    string `xml:"type,attr"`
+			Missed  int    `xml:"missed,attr"`
+			Covered int    `xml:"covered,attr"`
+		} `xml:"counter"`
+	}
+	d := xml.NewDecoder(r)
+	// JaCoCo reports reference a DTD that isn't available.
+	d.Strict = false
+	if err := d.Decode(&report); err != nil {
+		return nil, err
+	}
+
+	counters := make(map[string]counter)
+	for _, c := range report.Counters {
+		counters[c.Type] = counter{Covered: c.Covered, Missed: c.Missed}
+	}
+	return counters, nil
+}
+
+func sortCoverage(c []coverage) {
+	sort.SliceStable(c, func(i, j int) bool {
+		if c[i].Name != c[j].Name {
+			return c[i].Name < c[j].Name
+		}
+		return c[i].Variant < c[j].Variant
+	})
+}
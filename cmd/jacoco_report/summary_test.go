@@ -0,0 +1,69 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testReport = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<!DOCTYPE report PUBLIC "-//JACOCO//DTD Report 1.1//EN" "report.dtd">
+<report name="foo">
+  <sessioninfo id="session" start="1" dump="2"/>
+  <package name="com/android/foo">
+    <class name="com/android/foo/Foo" sourcefilename="Foo.java">
+      <counter type="LINE" missed="1" covered="2"/>
+    </class>
+    <counter type="LINE" missed="1" covered="2"/>
+  </package>
+  <counter type="INSTRUCTION" missed="3" covered="7"/>
+  <counter type="LINE" missed="1" covered="2"/>
+  <counter type="METHOD" missed="0" covered="1"/>
+</report>
+`
+
+func TestParseReport(t *testing.T) {
+	got, err := parseReport(strings.NewReader(testReport))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]counter{
+		"INSTRUCTION": {Covered: 7, Missed: 3},
+		"LINE":        {Covered: 2, Missed: 1},
+		"METHOD":      {Covered: 1, Missed: 0},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected counters %v, got %v", expected, got)
+	}
+}
+
+func TestSortCoverage(t *testing.T) {
+	c := []coverage{
+		{Name: "foo", Variant: "linux_glibc_common"},
+		{Name: "bar", Variant: "android_common"},
+		{Name: "foo", Variant: "android_common"},
+	}
+	sortCoverage(c)
+	expected := []coverage{
+		{Name: "bar", Variant: "android_common"},
+		{Name: "foo", Variant: "android_common"},
+		{Name: "foo", Variant: "linux_glibc_common"},
+	}
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("expected %v, got %v", expected, c)
+	}
+}
@@ -0,0 +1,285 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// java_strict_deps checks that the classes compiled from the sources of a Java module only use
+// classes from the module itself and from the modules it lists in libs or static_libs, and not
+// classes that are only on its classpath because a dependency bundles them from its own
+// static_libs.
+//
+// Soong runs it for every Java module with strict_deps: true.  The "classes" subcommand writes the
+// list of classes defined by a module, and the "check" subcommand compares the classes used by a
+// module against the class lists of its direct and transitive dependencies.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"android/soong/jar"
+	"android/soong/response"
+)
+
+// classListHeader is the prefix of the first line of a class list, which names the module that
+// defines the classes.
+const classListHeader = "# module "
+
+// classList is the set of classes defined by a module.
+type classList struct {
+	module  string
+	classes []string
+}
+
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return strings.Join(*m, " ")
+}
+
+func (m *multiFlag) Set(s string) error {
+	*m = append(*m, s)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s classes -module <name> -o <class list> <jar>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s check -module <name> -o <stamp> [-direct <class list>]... [-transitive <class list or @rspfile>]... <jar>...\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "classes":
+		err = classesCmd(os.Args[2:])
+	case "check":
+		err = checkCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func classesCmd(args []string) error {
+	flags := flag.NewFlagSet("classes", flag.ExitOnError)
+	module := flags.String("module", "", "the name of the module")
+	output := flags.String("o", "", "the class list to write")
+	flags.Parse(args)
+	if *module == "" || *output == "" {
+		usage()
+	}
+
+	l := &classList{module: *module}
+	for _, file := range flags.Args() {
+		err := jar.VisitClasses(file, func(name string, open func() (io.ReadCloser, error)) error {
+			l.classes = append(l.classes, name)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := writeClassList(f, l); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func checkCmd(args []string) error {
+	var direct, transitive multiFlag
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	module := flags.String("module", "", "the name of the module")
+	output := flags.String("o", "", "the stamp file to write when the check passes")
+	flags.Var(&direct, "direct", "the class list of a module in libs or static_libs")
+	flags.Var(&transitive, "transitive", "the class list of a transitive dependency, or an @rspfile of class lists")
+	flags.Parse(args)
+	if *module == "" || *output == "" {
+		usage()
+	}
+
+	var directLists, transitiveLists []*classList
+	for _, file := range direct {
+		l, err := readClassListFile(file)
+		if err != nil {
+			return err
+		}
+		directLists = append(directLists, l)
+	}
+	transitiveFiles, err := response.ExpandRspFiles(transitive)
+	if err != nil {
+		return err
+	}
+	for _, file := range transitiveFiles {
+		l, err := readClassListFile(file)
+		if err != nil {
+			return err
+		}
+		transitiveLists = append(transitiveLists, l)
+	}
+
+	var classes []*jar.ClassFile
+	for _, file := range flags.Args() {
+		jarClasses, err := jar.ReadClassFiles(file)
+		if err != nil {
+			return err
+		}
+		classes = append(classes, jarClasses...)
+	}
+
+	if violations := findViolations(classes, directLists, transitiveLists); len(violations) > 0 {
+		return fmt.Errorf("%s", formatViolations(*module, violations))
+	}
+
+	return ioutil.WriteFile(*output, nil, 0666)
+}
+
+// violation is a module that provides classes used by the checked module without being one of
+// its direct dependencies.
+type violation struct {
+	module  string
+	classes []string
+}
+
+// findViolations returns the modules that are only transitive dependencies of the checked module
+// but provide classes referenced by it, sorted by module name.  Classes that are not defined by
+// the checked module or any of its dependencies, for example those from the bootclasspath or the
+// SDK, are ignored.
+func findViolations(classes []*jar.ClassFile, direct, transitive []*classList) []violation {
+	available := make(map[string]bool)
+	for _, c := range classes {
+		available[c.Name] = true
+	}
+	for _, l := range direct {
+		for _, class := range l.classes {
+			available[class] = true
+		}
+	}
+
+	owners := make(map[string]string)
+	for _, l := range transitive {
+		for _, class := range l.classes {
+			if _, exists := owners[class]; !exists {
+				owners[class] = l.module
+			}
+		}
+	}
+
+	used := make(map[string]map[string]bool)
+	for _, c := range classes {
+		for _, ref := range c.References {
+			if available[ref] {
+				continue
+			}
+			if owner, ok := owners[ref]; ok {
+				if used[owner] == nil {
+					used[owner] = make(map[string]bool)
+				}
+				used[owner][ref] = true
+			}
+		}
+	}
+
+	var violations []violation
+	for module, refs := range used {
+		v := violation{module: module}
+		for ref := range refs {
+			v.classes = append(v.classes, ref)
+		}
+		sort.Strings(v.classes)
+		violations = append(violations, v)
+	}
+	sort.Slice(violations, func(i, j int) bool { return violations[i].module < violations[j].module })
+	return violations
+}
+
+func formatViolations(module string, violations []violation) string {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "%s uses classes from modules that are not in its libs or static_libs:\n", module)
+	for _, v := range violations {
+		fmt.Fprintf(&msg, "  %q provides:\n", v.module)
+		for _, class := range v.classes {
+			fmt.Fprintf(&msg, "    %s\n", strings.ReplaceAll(class, "/", "."))
+		}
+	}
+	fmt.Fprintf(&msg, "Add the modules to the libs or static_libs of %s:\n", module)
+	for _, v := range violations {
+		fmt.Fprintf(&msg, "  libs: [%q],\n", v.module)
+	}
+	return strings.TrimSuffix(msg.String(), "\n")
+}
+
+func writeClassList(w io.Writer, l *classList) error {
+	classes := append([]string(nil), l.classes...)
+	sort.Strings(classes)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s%s\n", classListHeader, l.module)
+	for i, class := range classes {
+		if i > 0 && classes[i-1] == class {
+			continue
+		}
+		fmt.Fprintln(bw, class)
+	}
+	return bw.Flush()
+}
+
+func readClassList(r io.Reader) (*classList, error) {
+	l := &classList{}
+	s := bufio.NewScanner(r)
+	if !s.Scan() || !strings.HasPrefix(s.Text(), classListHeader) {
+		if s.Err() != nil {
+			return nil, s.Err()
+		}
+		return nil, fmt.Errorf("missing %q header", strings.TrimSpace(classListHeader))
+	}
+	l.module = strings.TrimPrefix(s.Text(), classListHeader)
+	for s.Scan() {
+		if line := strings.TrimSpace(s.Text()); line != "" {
+			l.classes = append(l.classes, line)
+		}
+	}
+	return l, s.Err()
+}
+
+func readClassListFile(file string) (*classList, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	l, err := readClassList(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read class list %s: %w", file, err)
+	}
+	return l, nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"android/soong/jar"
+)
+
+func TestClassListRoundTrip(t *testing.T) {
+	l := &classList{module: "foo", classes: []string{"b/B", "a/A", "b/B"}}
+
+	var buf bytes.Buffer
+	if err := writeClassList(&buf, l); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# module foo\na/A\nb/B\n"; buf.String() != expected {
+		t.Errorf("expected class list %q, got %q", expected, buf.String())
+	}
+
+	got, err := readClassList(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &classList{module: "foo", classes: []string{"a/A", "b/B"}}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %#v, got %#v", expected, got)
+	}
+
+	if _, err := readClassList(strings.NewReader("a/A\n")); err == nil {
+		t.Error("expected an error for a class list without a header")
+	}
+}
+
+func TestFindViolations(t *testing.T) {
+	classes := []*jar.ClassFile{
+		{Name: "foo/Foo", References: []string{"bar/Bar", "baz/Baz", "foo/Helper", "java/lang/Object"}},
+		{Name: "foo/Helper", References: []string{"baz/Other", "qux/Qux"}},
+	}
+	direct := []*classList{
+		{module: "bar", classes: []string{"bar/Bar"}},
+	}
+	transitive := []*classList{
+		{module: "bar", classes: []string{"bar/Bar"}},
+		{module: "qux", classes: []string{"qux/Qux"}},
+		{module: "baz", classes: []string{"baz/Baz", "baz/Other"}},
+		{module: "baz_copy", classes: []string{"baz/Baz"}},
+	}
+
+	got := findViolations(classes, direct, transitive)
+	expected := []violation{
+		{module: "baz", classes: []string{"baz/Baz", "baz/Other"}},
+		{module: "qux", classes: []string{"qux/Qux"}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected violations:\n%#v\ngot:\n%#v", expected, got)
+	}
+
+	if got := findViolations(classes, transitive, transitive); len(got) != 0 {
+		t.Errorf("expected no violations when all modules are direct dependencies, got %#v", got)
+	}
+}
+
+func TestFormatViolations(t *testing.T) {
+	got := formatViolations("foo", []violation{
+		{module: "baz", classes: []string{"baz/Baz", "baz/Baz$Inner"}},
+		{module: "qux", classes: []string{"qux/Qux"}},
+	})
+	expected := strings.Join([]string{
+		`foo uses classes from modules that are not in its libs or static_libs:`,
+		`  "baz" provides:`,
+		`    baz.Baz`,
+		`    baz.Baz$Inner`,
+		`  "qux" provides:`,
+		`    qux.Qux`,
+		`Add the modules to the libs or static_libs of foo:`,
+		`  libs: ["baz"],`,
+		`  libs: ["qux"],`,
+	}, "\n")
+	if got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+}
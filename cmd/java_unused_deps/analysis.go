@@ -0,0 +1,183 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"sort"
+
+	"android/soong/jar"
+)
+
+// Dependency is a library listed in the libs or static_libs of a module.
+type Dependency struct {
+	Name string `json:"name"`
+	// Kind is the name of the property that lists the dependency.
+	Kind string `json:"kind"`
+}
+
+// variantReport is the analysis of a variant of a module, written by the analyze subcommand.
+type variantReport struct {
+	Module    string `json:"module"`
+	Variant   string `json:"variant"`
+	Blueprint string `json:"blueprint"`
+	// Deps lists the dependencies that were analyzed, and Unused the ones that contributed no
+	// classes to the variant.  Verify lists the unused dependencies that may still be used in ways
+	// that aren't recorded in class files.
+	Deps   []Dependency `json:"deps"`
+	Unused []Dependency `json:"unused"`
+	Verify []Dependency `json:"verify"`
+}
+
+// ModuleReport lists the unused dependencies of a module.
+type ModuleReport struct {
+	Module    string `json:"module"`
+	Blueprint string `json:"blueprint"`
+	// Unused lists the libs that contributed no classes to any variant of the module. They can be
+	// removed from the Android.bp file.
+	Unused []Dependency `json:"unused,omitempty"`
+	// Verify lists the libs that contributed no classes to any variant of the module, but that
+	// declare compile time constants or annotations with source retention.  Their uses aren't
+	// recorded in class files, so the module must be rebuilt without them before they are removed.
+	Verify []Dependency `json:"verify,omitempty"`
+	// UnusedStaticLibs lists the static_libs that contributed no classes to any variant of the
+	// module. They may still be used at runtime, so they must be reviewed before being removed.
+	UnusedStaticLibs []string `json:"unused_static_libs,omitempty"`
+	// UnusedInVariants lists the dependencies that are only unused in some variants of the
+	// module, along with those variants. They may be moved to a target specific property.
+	UnusedInVariants map[string][]string `json:"unused_in_variants,omitempty"`
+}
+
+// treeReport is the aggregated report of the modules with unused dependencies.
+type treeReport struct {
+	// Total is the number of dependencies that can be removed from the Android.bp files.
+	Total   int            `json:"total"`
+	Modules []ModuleReport `json:"modules"`
+}
+
+// dependencyClasses is a dependency with the classes in its header jars.
+type dependencyClasses struct {
+	Dependency
+	classes []string
+	// unrecordedUses is true if the dependency declares compile time constants, which javac
+	// inlines, or annotations with source retention, which javac discards.
+	unrecordedUses bool
+}
+
+// unusedDeps returns the dependencies none of whose classes are referenced by the compiled
+// classes of a module, in the order they were given, and the subset of them that may still be
+// used through compile time constants or annotations with source retention.
+func unusedDeps(classes []*jar.ClassFile, deps []dependencyClasses) (unused, verify []Dependency) {
+	referenced := make(map[string]bool)
+	for _, c := range classes {
+		for _, ref := range c.References {
+			referenced[ref] = true
+		}
+	}
+
+	unused, verify = []Dependency{}, []Dependency{}
+	for _, dep := range deps {
+		used := false
+		for _, class := range dep.classes {
+			if referenced[class] {
+				used = true
+				break
+			}
+		}
+		if !used {
+			unused = append(unused, dep.Dependency)
+			if dep.unrecordedUses {
+				verify = append(verify, dep.Dependency)
+			}
+		}
+	}
+	return unused, verify
+}
+
+// merge aggregates the reports of module variants into reports of the modules that have unused
+// dependencies, sorted by module name.  A dependency is unused by a module if it is unused by
+// every variant that lists it.
+func merge(reports []variantReport) *treeReport {
+	type moduleKey struct{ blueprint, module string }
+	type depVariants struct {
+		declared, unused []string
+		verify           bool
+	}
+
+	var keys []moduleKey
+	modules := make(map[moduleKey]map[Dependency]*depVariants)
+	depOrder := make(map[moduleKey][]Dependency)
+	for _, r := range reports {
+		key := moduleKey{r.Blueprint, r.Module}
+		if modules[key] == nil {
+			modules[key] = make(map[Dependency]*depVariants)
+			keys = append(keys, key)
+		}
+		for _, dep := range r.Deps {
+			if modules[key][dep] == nil {
+				modules[key][dep] = &depVariants{}
+				depOrder[key] = append(depOrder[key], dep)
+			}
+			modules[key][dep].declared = append(modules[key][dep].declared, r.Variant)
+		}
+		for _, dep := range r.Unused {
+			if v := modules[key][dep]; v != nil {
+				v.unused = append(v.unused, r.Variant)
+			}
+		}
+		for _, dep := range r.Verify {
+			if v := modules[key][dep]; v != nil {
+				v.verify = true
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].module != keys[j].module {
+			return keys[i].module < keys[j].module
+		}
+		return keys[i].blueprint < keys[j].blueprint
+	})
+
+	t := &treeReport{Modules: []ModuleReport{}}
+	for _, key := range keys {
+		m := ModuleReport{Module: key.module, Blueprint: key.blueprint}
+		for _, dep := range depOrder[key] {
+			v := modules[key][dep]
+			if len(v.unused) == 0 {
+				continue
+			}
+			if len(v.unused) == len(v.declared) {
+				if dep.Kind == "static_libs" {
+					m.UnusedStaticLibs = append(m.UnusedStaticLibs, dep.Name)
+				} else if v.verify {
+					m.Verify = append(m.Verify, dep)
+				} else {
+					m.Unused = append(m.Unused, dep)
+				}
+			} else {
+				if m.UnusedInVariants == nil {
+					m.UnusedInVariants = make(map[string][]string)
+				}
+				sort.Strings(v.unused)
+				m.UnusedInVariants[dep.Name] = v.unused
+			}
+		}
+		if len(m.Unused) > 0 || len(m.Verify) > 0 || len(m.UnusedStaticLibs) > 0 || len(m.UnusedInVariants) > 0 {
+			t.Total += len(m.Unused)
+			t.Modules = append(t.Modules, m)
+		}
+	}
+	return t
+}
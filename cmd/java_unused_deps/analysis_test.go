@@ -0,0 +1,111 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"android/soong/jar"
+)
+
+func TestUnusedDeps(t *testing.T) {
+	classes := []*jar.ClassFile{
+		{Name: "foo/Foo", References: []string{"bar/Bar", "foo/Helper", "java/lang/Object"}},
+		{Name: "foo/Helper", References: []string{"baz/Baz$Inner"}},
+	}
+	deps := []dependencyClasses{
+		{Dependency: Dependency{Name: "bar", Kind: "libs"}, classes: []string{"bar/Bar", "bar/Other"}},
+		{Dependency: Dependency{Name: "unused", Kind: "static_libs"}, classes: []string{"unused/Unused"}},
+		{Dependency: Dependency{Name: "baz", Kind: "static_libs"}, classes: []string{"baz/Baz", "baz/Baz$Inner"}},
+		{Dependency: Dependency{Name: "empty", Kind: "libs"}},
+		{Dependency: Dependency{Name: "constants", Kind: "libs"}, classes: []string{"constants/Constants"}, unrecordedUses: true},
+		{Dependency: Dependency{Name: "used_constants", Kind: "libs"}, classes: []string{"bar/Bar"}, unrecordedUses: true},
+	}
+
+	unused, verify := unusedDeps(classes, deps)
+	expectedUnused := []Dependency{
+		{Name: "unused", Kind: "static_libs"},
+		{Name: "empty", Kind: "libs"},
+		{Name: "constants", Kind: "libs"},
+	}
+	if !reflect.DeepEqual(unused, expectedUnused) {
+		t.Errorf("expected unused deps %v, got %v", expectedUnused, unused)
+	}
+	expectedVerify := []Dependency{{Name: "constants", Kind: "libs"}}
+	if !reflect.DeepEqual(verify, expectedVerify) {
+		t.Errorf("expected deps to verify %v, got %v", expectedVerify, verify)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	libsDep := func(name string) Dependency { return Dependency{Name: name, Kind: "libs"} }
+
+	reports := []variantReport{
+		{
+			Module:    "foo",
+			Variant:   "android_common",
+			Blueprint: "a/Android.bp",
+			Deps:      []Dependency{libsDep("bar"), libsDep("baz"), libsDep("qux"), libsDep("constants")},
+			Unused:    []Dependency{libsDep("baz"), libsDep("qux"), libsDep("constants")},
+			Verify:    []Dependency{libsDep("constants")},
+		},
+		{
+			Module:    "foo",
+			Variant:   "linux_glibc_common",
+			Blueprint: "a/Android.bp",
+			Deps:      []Dependency{libsDep("bar"), libsDep("qux")},
+			Unused:    []Dependency{libsDep("bar"), libsDep("qux")},
+		},
+		{
+			Module:    "all_used",
+			Variant:   "android_common",
+			Blueprint: "b/Android.bp",
+			Deps:      []Dependency{libsDep("bar")},
+			Unused:    []Dependency{},
+		},
+		{
+			Module:    "bar",
+			Variant:   "android_common",
+			Blueprint: "b/Android.bp",
+			Deps:      []Dependency{{Name: "baz", Kind: "static_libs"}},
+			Unused:    []Dependency{{Name: "baz", Kind: "static_libs"}},
+		},
+	}
+
+	got := merge(reports)
+	expected := &treeReport{
+		Total: 2,
+		Modules: []ModuleReport{
+			{
+				Module:           "bar",
+				Blueprint:        "b/Android.bp",
+				UnusedStaticLibs: []string{"baz"},
+			},
+			{
+				Module:    "foo",
+				Blueprint: "a/Android.bp",
+				Unused:    []Dependency{libsDep("baz"), libsDep("qux")},
+				Verify:    []Dependency{libsDep("constants")},
+				UnusedInVariants: map[string][]string{
+					"bar": {"linux_glibc_common"},
+				},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected:\n%#v\ngot:\n%#v", expected, got)
+	}
+}
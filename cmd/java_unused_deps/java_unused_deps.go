@@ -0,0 +1,228 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// java_unused_deps reports the libs and static_libs of Java modules that contributed no classes
+// to the classes compiled from their sources, and can remove them from the Android.bp files.
+//
+// Soong runs the "analyze" subcommand for every Java module with sources when
+// SOONG_JAVA_UNUSED_DEPS=true is set, and the "merge" subcommand to aggregate the results into
+// $OUT/soong/java_unused_deps/report.json:
+//
+//	SOONG_JAVA_UNUSED_DEPS=true m java-unused-deps
+//	java_unused_deps fix out/soong/java_unused_deps/report.json
+//
+// A dependency is only reported if none of the classes in its header jars are referenced by the
+// compiled classes.  Uses of compile time constants, which javac inlines, and of annotations with
+// source retention, which javac discards, aren't recorded in class files, so unused libs that
+// declare either are listed in verify instead of unused.  static_libs are included in the module,
+// so they may still be used at runtime through ServiceLoader, reflection or their resources; the
+// unused ones are listed in unused_static_libs.  The "fix" subcommand only removes the libs listed
+// in unused; the others must be reviewed, and removed by hand after checking that the module still
+// builds without them.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"android/soong/bpfix/removedeps"
+	"android/soong/jar"
+)
+
+type multiFlag []string
+
+func (m *multiFlag) String() string {
+	return strings.Join(*m, " ")
+}
+
+func (m *multiFlag) Set(s string) error {
+	*m = append(*m, s)
+	return nil
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s analyze -module <name> -variant <variant> -blueprint <Android.bp> -o <report> [-dep <kind>:<name>:<header jar>]... <jar>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge -o <report> <variant reports or @rspfile>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s fix <report>\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "analyze":
+		err = analyzeCmd(os.Args[2:])
+	case "merge":
+		err = mergeCmd(os.Args[2:])
+	case "fix":
+		err = fixCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func analyzeCmd(args []string) error {
+	var depFlags multiFlag
+	flags := flag.NewFlagSet("analyze", flag.ExitOnError)
+	module := flags.String("module", "", "the name of the module")
+	variant := flags.String("variant", "", "the variant of the module")
+	blueprint := flags.String("blueprint", "", "the Android.bp file that defines the module")
+	output := flags.String("o", "", "the report to write")
+	flags.Var(&depFlags, "dep", "a header jar of a dependency, as <kind>:<name>:<header jar>")
+	flags.Parse(args)
+	if *module == "" || *output == "" {
+		usage()
+	}
+
+	var deps []dependencyClasses
+	index := make(map[Dependency]int)
+	for _, f := range depFlags {
+		parts := strings.SplitN(f, ":", 3)
+		if len(parts) != 3 {
+			return fmt.Errorf("invalid -dep %q, expected <kind>:<name>:<header jar>", f)
+		}
+		dep := Dependency{Kind: parts[0], Name: parts[1]}
+		i, ok := index[dep]
+		if !ok {
+			i = len(deps)
+			index[dep] = i
+			deps = append(deps, dependencyClasses{Dependency: dep})
+		}
+		depClasses, err := jar.ReadClassFiles(parts[2])
+		if err != nil {
+			return err
+		}
+		for _, c := range depClasses {
+			deps[i].classes = append(deps[i].classes, c.Name)
+			if c.HasConstants || c.SourceRetention {
+				deps[i].unrecordedUses = true
+			}
+		}
+	}
+
+	var classes []*jar.ClassFile
+	for _, file := range flags.Args() {
+		jarClasses, err := jar.ReadClassFiles(file)
+		if err != nil {
+			return err
+		}
+		classes = append(classes, jarClasses...)
+	}
+
+	unused, verify := unusedDeps(classes, deps)
+	r := variantReport{
+		Module:    *module,
+		Variant:   *variant,
+		Blueprint: *blueprint,
+		Deps:      []Dependency{},
+		Unused:    unused,
+		Verify:    verify,
+	}
+	for _, dep := range deps {
+		r.Deps = append(r.Deps, dep.Dependency)
+	}
+	return writeJSON(*output, r)
+}
+
+func mergeCmd(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "the aggregated report to write")
+	flags.Parse(args)
+	if *output == "" {
+		usage()
+	}
+
+	var files []string
+	for _, arg := range flags.Args() {
+		if strings.HasPrefix(arg, "@") {
+			buf, err := ioutil.ReadFile(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return err
+			}
+			files = append(files, strings.Fields(string(buf))...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	var reports []variantReport
+	for _, file := range files {
+		var r variantReport
+		if err := readJSON(file, &r); err != nil {
+			return err
+		}
+		reports = append(reports, r)
+	}
+
+	return writeJSON(*output, merge(reports))
+}
+
+func fixCmd(args []string) error {
+	if len(args) != 1 {
+		usage()
+	}
+	var t treeReport
+	if err := readJSON(args[0], &t); err != nil {
+		return err
+	}
+	return fixBlueprints(t.Modules)
+}
+
+// fixBlueprints removes the libs listed as unused in the reports from the blueprint files
+// that define the modules.
+func fixBlueprints(reports []ModuleReport) error {
+	deps := make(map[string]map[string][]removedeps.Dependency)
+	for _, report := range reports {
+		for _, dep := range report.Unused {
+			if deps[report.Blueprint] == nil {
+				deps[report.Blueprint] = make(map[string][]removedeps.Dependency)
+			}
+			deps[report.Blueprint][report.Module] = append(deps[report.Blueprint][report.Module],
+				removedeps.Dependency{Name: dep.Name, Property: dep.Kind})
+		}
+	}
+	return removedeps.FixBlueprints(deps)
+}
+
+func readJSON(file string, v interface{}) error {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0666)
+}
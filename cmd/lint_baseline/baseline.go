@@ -0,0 +1,166 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// issue is an issue in an Android Lint baseline.
+type issue struct {
+	Id        string     `xml:"id,attr"`
+	Message   string     `xml:"message,attr"`
+	Locations []location `xml:"location"`
+}
+
+type location struct {
+	File string `xml:"file,attr"`
+}
+
+// issueKey identifies an issue independently of its line number, which changes whenever the code
+// above it is edited.  This matches how lint finds issues in a baseline.
+type issueKey struct {
+	id, message, file string
+}
+
+func (i issue) key() issueKey {
+	k := issueKey{id: i.Id, message: i.Message}
+	if len(i.Locations) > 0 {
+		k.file = i.Locations[0].File
+	}
+	return k
+}
+
+// issueSpan is an issue in a baseline file, with the byte range of its <issue> element including
+// the indentation before it and the newline after it.
+type issueSpan struct {
+	issue
+	start, end int64
+}
+
+// parseBaseline returns the issues in a lint baseline.
+func parseBaseline(data []byte) ([]issueSpan, error) {
+	var issues []issueSpan
+	d := xml.NewDecoder(bytes.NewReader(data))
+	depth := 0
+	for {
+		offset := d.InputOffset()
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if depth == 0 && t.Name.Local != "issues" {
+				return nil, fmt.Errorf("expected <issues>, found <%s>", t.Name.Local)
+			}
+			if depth == 1 && t.Name.Local == "issue" {
+				var i issue
+				if err := d.DecodeElement(&i, &t); err != nil {
+					return nil, err
+				}
+				issues = append(issues, issueSpan{
+					issue: i,
+					start: lineStart(data, offset),
+					end:   lineEnd(data, d.InputOffset()),
+				})
+				continue
+			}
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return issues, nil
+}
+
+// lineStart returns the offset of the start of the line containing offset if only whitespace
+// precedes offset on that line, and offset otherwise.
+func lineStart(data []byte, offset int64) int64 {
+	i := offset
+	for i > 0 && (data[i-1] == ' ' || data[i-1] == '\t') {
+		i--
+	}
+	if i == 0 || data[i-1] == '\n' {
+		return i
+	}
+	return offset
+}
+
+// lineEnd returns the offset after the newline that ends the line containing offset if only
+// whitespace follows offset on that line, and offset otherwise.  When offset is at the start of a
+// line, it returns the offset of the next line if the line is blank.
+func lineEnd(data []byte, offset int64) int64 {
+	i := offset
+	for i < int64(len(data)) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\r') {
+		i++
+	}
+	if i < int64(len(data)) && data[i] == '\n' {
+		return i + 1
+	}
+	return offset
+}
+
+// issueCounts returns the number of issues with each key.
+func issueCounts(issues []issueSpan) map[issueKey]int {
+	counts := make(map[issueKey]int)
+	for _, i := range issues {
+		counts[i.key()]++
+	}
+	return counts
+}
+
+// prune removes the issues that are no longer reported by lint from a baseline, keeping the rest
+// of the file unchanged, and returns the pruned baseline and the number of issues removed.
+func prune(data []byte, issues []issueSpan, current map[issueKey]int) ([]byte, int) {
+	remaining := make(map[issueKey]int, len(current))
+	for k, v := range current {
+		remaining[k] = v
+	}
+
+	var out bytes.Buffer
+	var last int64
+	removed := 0
+	for _, i := range issues {
+		k := i.key()
+		if remaining[k] > 0 {
+			remaining[k]--
+			continue
+		}
+		out.Write(data[last:i.start])
+		// Remove the blank line that separates the issue from the next one.
+		last = lineEnd(data, i.end)
+		removed++
+	}
+	out.Write(data[last:])
+	return out.Bytes(), removed
+}
+
+// added returns the number of current issues that are not in a baseline.
+func added(baseline []issueSpan, current map[issueKey]int) int {
+	remaining := issueCounts(baseline)
+	n := 0
+	for k, count := range current {
+		if count > remaining[k] {
+			n += count - remaining[k]
+		}
+	}
+	return n
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+)
+
+const testBaseline = `<?xml version="1.0" encoding="UTF-8"?>
+<issues format="6" by="lint 7.2.0">
+
+    <issue
+        id="NewApi"
+        message="Call requires API level 30"
+        errorLine1="        foo();">
+        <location
+            file="packages/apps/Foo/src/Foo.java"
+            line="12"
+            column="9"/>
+    </issue>
+
+    <issue
+        id="NewApi"
+        message="Call requires API level 30"
+        errorLine1="        foo();">
+        <location
+            file="packages/apps/Foo/src/Foo.java"
+            line="20"
+            column="9"/>
+    </issue>
+
+    <issue
+        id="HardcodedText"
+        message="Hardcoded string &quot;Foo&quot;">
+        <location
+            file="packages/apps/Foo/res/layout/foo.xml"
+            line="4"/>
+    </issue>
+
+</issues>
+`
+
+func TestParseBaseline(t *testing.T) {
+	issues, err := parseBaseline([]byte(testBaseline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 3 {
+		t.Fatalf("expected 3 issues, got %d", len(issues))
+	}
+
+	expected := issueKey{
+		id:      "HardcodedText",
+		message: `Hardcoded string "Foo"`,
+		file:    "packages/apps/Foo/res/layout/foo.xml",
+	}
+	if got := issues[2].key(); got != expected {
+		t.Errorf("expected key %v, got %v", expected, got)
+	}
+}
+
+func TestParseBaselineError(t *testing.T) {
+	if _, err := parseBaseline([]byte(`<lint></lint>`)); err == nil {
+		t.Error("expected an error for a file that is not a baseline")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	issues, err := parseBaseline([]byte(testBaseline))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One of the NewApi issues has been fixed, and the other one moved to another line.
+	current := map[issueKey]int{
+		issues[0].key(): 1,
+		issues[2].key(): 1,
+		{id: "NewApi", message: "Call requires API level 31", file: "packages/apps/Foo/src/Bar.java"}: 1,
+	}
+
+	pruned, removed := prune([]byte(testBaseline), issues, current)
+	if removed != 1 {
+		t.Errorf("expected 1 removed issue, got %d", removed)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<issues format="6" by="lint 7.2.0">
+
+    <issue
+        id="NewApi"
+        message="Call requires API level 30"
+        errorLine1="        foo();">
+        <location
+            file="packages/apps/Foo/src/Foo.java"
+            line="12"
+            column="9"/>
+    </issue>
+
+    <issue
+        id="HardcodedText"
+        message="Hardcoded string &quot;Foo&quot;">
+        <location
+            file="packages/apps/Foo/res/layout/foo.xml"
+            line="4"/>
+    </issue>
+
+</issues>
+`
+	if string(pruned) != expected {
+		t.Errorf("expected pruned baseline:\n%s\ngot:\n%s", expected, pruned)
+	}
+
+	if n := added(issues, current); n != 1 {
+		t.Errorf("expected 1 added issue, got %d", n)
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// lint_baseline updates the Android Lint baselines of modules from the reference baselines
+// written by lint, and reports the modules whose baselines changed.
+//
+// Soong runs the "update" subcommand for every module linted with SOONG_LINT_BASELINES=true set,
+// and the "merge" subcommand to aggregate the reports.  The existing baselines are pruned of the
+// issues that have been fixed, and the baselines of the modules or directories listed in
+// SOONG_LINT_BASELINE_REGENERATE are replaced with all the issues that lint currently reports:
+//
+//	SOONG_LINT_BASELINES=true SOONG_LINT_BASELINE_REGENERATE=frameworks/base/packages,Foo m lint-baselines
+//	unzip -o out/soong/lint_baselines/baselines.zip
+//
+// out/soong/lint_baselines/report.json lists the modules whose baselines grew.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// moduleReport describes the update of the baseline of a module.
+type moduleReport struct {
+	Module string `json:"module"`
+	// Baseline is the path of the baseline in the source tree.
+	Baseline    string `json:"baseline"`
+	Regenerated bool   `json:"regenerated,omitempty"`
+	// The number of issues in the baseline before and after the update, the number of issues that
+	// were removed because they have been fixed, and the number of new issues that were added.
+	Before int `json:"before"`
+	After  int `json:"after"`
+	Fixed  int `json:"fixed"`
+	Added  int `json:"added"`
+}
+
+// treeReport aggregates the reports of the modules whose baselines changed.
+type treeReport struct {
+	Fixed int `json:"fixed"`
+	Added int `json:"added"`
+	// Grown lists the modules whose baselines have more issues than before.
+	Grown   []string       `json:"grown"`
+	Modules []moduleReport `json:"modules"`
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s update -module <name> -path <baseline path> [-baseline <baseline>] -reference <reference baseline> [-regenerate] -o <baseline> -report <report>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge -o <report> <module reports or @rspfile>...\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "update":
+		err = updateCmd(os.Args[2:])
+	case "merge":
+		err = mergeCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func updateCmd(args []string) error {
+	flags := flag.NewFlagSet("update", flag.ExitOnError)
+	module := flags.String("module", "", "the name of the module")
+	path := flags.String("path", "", "the path of the baseline in the source tree")
+	baseline := flags.String("baseline", "", "the existing baseline of the module")
+	reference := flags.String("reference", "", "the reference baseline written by lint")
+	regenerate := flags.Bool("regenerate", false, "replace the baseline with the reference baseline")
+	output := flags.String("o", "", "the updated baseline to write")
+	reportFile := flags.String("report", "", "the report to write")
+	flags.Parse(args)
+	if *module == "" || *reference == "" || *output == "" || *reportFile == "" {
+		usage()
+	}
+
+	var old []byte
+	var oldIssues []issueSpan
+	if *baseline != "" {
+		var err error
+		if old, err = ioutil.ReadFile(*baseline); err != nil {
+			return err
+		}
+		if oldIssues, err = parseBaseline(old); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", *baseline, err)
+		}
+	}
+
+	ref, err := ioutil.ReadFile(*reference)
+	if err != nil {
+		return err
+	}
+	refIssues, err := parseBaseline(ref)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", *reference, err)
+	}
+
+	r, updated := update(old, oldIssues, ref, refIssues, *regenerate)
+	r.Module = *module
+	r.Baseline = *path
+
+	if err := ioutil.WriteFile(*output, updated, 0666); err != nil {
+		return err
+	}
+	return writeJSON(*reportFile, r)
+}
+
+// update returns the updated baseline of a module and the report of the update.
+func update(old []byte, oldIssues []issueSpan, ref []byte, refIssues []issueSpan,
+	regenerate bool) (*moduleReport, []byte) {
+
+	current := issueCounts(refIssues)
+	r := &moduleReport{Before: len(oldIssues)}
+	var updated []byte
+	if regenerate || old == nil {
+		updated = ref
+		r.Regenerated = true
+		r.After = len(refIssues)
+		r.Added = added(oldIssues, current)
+		r.Fixed = r.Before + r.Added - r.After
+	} else {
+		updated, r.Fixed = prune(old, oldIssues, current)
+		r.After = r.Before - r.Fixed
+	}
+	return r, updated
+}
+
+func mergeCmd(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "the aggregated report to write")
+	flags.Parse(args)
+	if *output == "" {
+		usage()
+	}
+
+	var files []string
+	for _, arg := range flags.Args() {
+		if strings.HasPrefix(arg, "@") {
+			buf, err := ioutil.ReadFile(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return err
+			}
+			files = append(files, strings.Fields(string(buf))...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	var reports []moduleReport
+	for _, file := range files {
+		buf, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		var r moduleReport
+		if err := json.Unmarshal(buf, &r); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", file, err)
+		}
+		reports = append(reports, r)
+	}
+
+	return writeJSON(*output, merge(reports))
+}
+
+// merge aggregates the reports of the modules whose baselines changed, sorted by module.
+func merge(reports []moduleReport) *treeReport {
+	t := &treeReport{Grown: []string{}, Modules: []moduleReport{}}
+	for _, r := range reports {
+		if r.Fixed == 0 && r.Added == 0 {
+			continue
+		}
+		t.Modules = append(t.Modules, r)
+		t.Fixed += r.Fixed
+		t.Added += r.Added
+	}
+	sort.SliceStable(t.Modules, func(i, j int) bool { return t.Modules[i].Module < t.Modules[j].Module })
+	for _, r := range t.Modules {
+		if r.After > r.Before {
+			t.Grown = append(t.Grown, r.Module)
+		}
+	}
+	return t
+}
+
+func writeJSON(file string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0666)
+}
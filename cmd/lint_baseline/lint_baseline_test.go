@@ -0,0 +1,109 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+const testReference = `<?xml version="1.0" encoding="UTF-8"?>
+<issues format="6" by="lint 7.2.0">
+
+    <issue
+        id="HardcodedText"
+        message="Hardcoded string &quot;Foo&quot;">
+        <location
+            file="packages/apps/Foo/res/layout/foo.xml"
+            line="6"/>
+    </issue>
+
+    <issue
+        id="MissingPermission"
+        message="Missing permissions required by Foo.bar">
+        <location
+            file="packages/apps/Foo/src/Foo.java"
+            line="40"/>
+    </issue>
+
+</issues>
+`
+
+func TestUpdate(t *testing.T) {
+	old, err := parseBaseline([]byte(testBaseline))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := parseBaseline([]byte(testReference))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("prune", func(t *testing.T) {
+		r, updated := update([]byte(testBaseline), old, []byte(testReference), ref, false)
+		expected := &moduleReport{Before: 3, After: 1, Fixed: 2}
+		if !reflect.DeepEqual(r, expected) {
+			t.Errorf("expected report %#v, got %#v", expected, r)
+		}
+		issues, err := parseBaseline(updated)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(issues) != 1 || issues[0].Id != "HardcodedText" {
+			t.Errorf("expected only the HardcodedText issue, got %v", issues)
+		}
+	})
+
+	t.Run("regenerate", func(t *testing.T) {
+		r, updated := update([]byte(testBaseline), old, []byte(testReference), ref, true)
+		expected := &moduleReport{Regenerated: true, Before: 3, After: 2, Fixed: 2, Added: 1}
+		if !reflect.DeepEqual(r, expected) {
+			t.Errorf("expected report %#v, got %#v", expected, r)
+		}
+		if string(updated) != testReference {
+			t.Errorf("expected the reference baseline, got:\n%s", updated)
+		}
+	})
+
+	t.Run("new", func(t *testing.T) {
+		r, _ := update(nil, nil, []byte(testReference), ref, true)
+		expected := &moduleReport{Regenerated: true, Before: 0, After: 2, Added: 2}
+		if !reflect.DeepEqual(r, expected) {
+			t.Errorf("expected report %#v, got %#v", expected, r)
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	reports := []moduleReport{
+		{Module: "foo", Baseline: "a/lint-baseline.xml", Before: 3, After: 1, Fixed: 2},
+		{Module: "unchanged", Baseline: "b/lint-baseline.xml", Before: 2, After: 2},
+		{Module: "bar", Baseline: "c/lint-baseline.xml", Regenerated: true, Before: 1, After: 4, Fixed: 1, Added: 4},
+	}
+
+	got := merge(reports)
+	expected := &treeReport{
+		Fixed: 3,
+		Added: 4,
+		Grown: []string{"bar"},
+		Modules: []moduleReport{
+			{Module: "bar", Baseline: "c/lint-baseline.xml", Regenerated: true, Before: 1, After: 4, Fixed: 1, Added: 4},
+			{Module: "foo", Baseline: "a/lint-baseline.xml", Before: 3, After: 1, Fixed: 2},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected report %#v, got %#v", expected, got)
+	}
+}
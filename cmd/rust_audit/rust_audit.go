@@ -0,0 +1,161 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// rust_audit writes the audit report of a Rust binary or FFI library: the crates linked into it,
+// with their edition, features and dependencies, whether they are third-party crates, and how much
+// unsafe code they contain.
+//
+// Soong runs it for every Rust binary and FFI library when SOONG_RUST_AUDIT=true is set, and writes
+// the reports to $OUT/soong/rust_audit/<module>/<variant>.json:
+//
+//	SOONG_RUST_AUDIT=true m rust-audit
+//
+// Crates are listed in a stable order so that the reports of two releases can be compared with
+// diff. Prebuilt crates aren't compiled from source, so their unsafe code isn't reported.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+var (
+	output = flag.String("o", "", "the audit report to write")
+)
+
+// crate is a crate linked into the audited module. The manifest written by Soong lists the file
+// with the unsafe_code diagnostics of the crate, which the report replaces with their counts.
+type crate struct {
+	Module      string   `json:"module"`
+	Crate_name  string   `json:"crate_name"`
+	Kind        string   `json:"kind"`
+	Edition     string   `json:"edition,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Features    []string `json:"features,omitempty"`
+	Third_party bool     `json:"third_party"`
+	Prebuilt    bool     `json:"prebuilt,omitempty"`
+	Blueprint   string   `json:"blueprint"`
+	// Modules of the crates the crate depends on directly.
+	Deps []string `json:"deps,omitempty"`
+
+	Unsafe_diagnostics string        `json:"unsafe_diagnostics,omitempty"`
+	Unsafe             *unsafeCounts `json:"unsafe,omitempty"`
+}
+
+// manifest is the audit manifest of a module written by Soong. The first crate is the crate of the
+// module itself.
+type manifest struct {
+	Name      string  `json:"name"`
+	Variant   string  `json:"variant"`
+	Blueprint string  `json:"blueprint"`
+	Output    string  `json:"output"`
+	Crates    []crate `json:"crates"`
+}
+
+type report struct {
+	Name      string `json:"name"`
+	Variant   string `json:"variant"`
+	Blueprint string `json:"blueprint"`
+	Output    string `json:"output"`
+	// Modules of the third-party crates linked into the module.
+	Third_party_crates []string `json:"third_party_crates"`
+	// Modules of the crates that contain unsafe code.
+	Unsafe_crates []string `json:"unsafe_crates"`
+	Crates        []crate  `json:"crates"`
+}
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s -o <report> <manifest>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if *output == "" || flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *output); err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func run(manifestFile, reportFile string) error {
+	buf, err := ioutil.ReadFile(manifestFile)
+	if err != nil {
+		return err
+	}
+	var m manifest
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", manifestFile, err)
+	}
+
+	r, err := audit(m, func(name string) (io.ReadCloser, error) { return os.Open(name) })
+	if err != nil {
+		return err
+	}
+
+	buf, err = json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(reportFile, append(buf, '\n'), 0666)
+}
+
+// audit returns the report for a manifest, reading the unsafe_code diagnostics of the crates with
+// open.
+func audit(m manifest, open func(string) (io.ReadCloser, error)) (*report, error) {
+	r := &report{
+		Name:               m.Name,
+		Variant:            m.Variant,
+		Blueprint:          m.Blueprint,
+		Output:             m.Output,
+		Third_party_crates: []string{},
+		Unsafe_crates:      []string{},
+	}
+
+	for _, c := range m.Crates {
+		if c.Unsafe_diagnostics != "" {
+			f, err := open(c.Unsafe_diagnostics)
+			if err != nil {
+				return nil, err
+			}
+			counts, err := countUnsafe(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s: %w", c.Unsafe_diagnostics, err)
+			}
+			c.Unsafe = &counts
+			c.Unsafe_diagnostics = ""
+			if counts.total() > 0 {
+				r.Unsafe_crates = append(r.Unsafe_crates, c.Module)
+			}
+		}
+		if c.Third_party {
+			r.Third_party_crates = append(r.Third_party_crates, c.Module)
+		}
+		r.Crates = append(r.Crates, c)
+	}
+
+	sort.Strings(r.Third_party_crates)
+	sort.Strings(r.Unsafe_crates)
+	return r, nil
+}
@@ -0,0 +1,191 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const unsafeBlock = `{"message":"usage of an ` + "`unsafe`" + ` block","code":{"code":"unsafe_code","explanation":null}}`
+
+var testManifest = manifest{
+	Name:      "foo",
+	Variant:   "android_arm64_armv8-a",
+	Blueprint: "foo/Android.bp",
+	Output:    "out/foo",
+	Crates: []crate{
+		{
+			Module:             "foo",
+			Crate_name:         "foo",
+			Kind:               "bin",
+			Edition:            "2021",
+			Blueprint:          "foo/Android.bp",
+			Deps:               []string{"libbar", "libstd"},
+			Unsafe_diagnostics: "foo.unsafe.json",
+		},
+		{
+			Module:             "libbar",
+			Crate_name:         "bar",
+			Kind:               "rlib",
+			Edition:            "2018",
+			Version:            "1.2.3",
+			Features:           []string{"std"},
+			Third_party:        true,
+			Blueprint:          "external/rust/crates/bar/Android.bp",
+			Unsafe_diagnostics: "bar.unsafe.json",
+		},
+		{
+			Module:      "libstd",
+			Crate_name:  "std",
+			Kind:        "rlib",
+			Third_party: true,
+			Prebuilt:    true,
+			Blueprint:   "prebuilts/rust/Android.bp",
+		},
+	},
+}
+
+func TestAudit(t *testing.T) {
+	files := map[string]string{
+		"foo.unsafe.json": "",
+		"bar.unsafe.json": unsafeBlock + "\n" + unsafeBlock + "\n",
+	}
+	open := func(name string) (io.ReadCloser, error) {
+		if content, ok := files[name]; ok {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		}
+		return nil, fmt.Errorf("%s not found", name)
+	}
+
+	r, err := audit(testManifest, open)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if want := []string{"libbar", "libstd"}; !reflect.DeepEqual(r.Third_party_crates, want) {
+		t.Errorf("expected third-party crates %q, got %q", want, r.Third_party_crates)
+	}
+	if want := []string{"libbar"}; !reflect.DeepEqual(r.Unsafe_crates, want) {
+		t.Errorf("expected unsafe crates %q, got %q", want, r.Unsafe_crates)
+	}
+
+	wantUnsafe := []*unsafeCounts{{}, {Blocks: 2}, nil}
+	for i, c := range r.Crates {
+		if c.Unsafe_diagnostics != "" {
+			t.Errorf("%s: expected the unsafe diagnostics file to be dropped, got %q", c.Module, c.Unsafe_diagnostics)
+		}
+		if !reflect.DeepEqual(c.Unsafe, wantUnsafe[i]) {
+			t.Errorf("%s: expected unsafe counts %+v, got %+v", c.Module, wantUnsafe[i], c.Unsafe)
+		}
+	}
+
+	if _, err := audit(manifest{Crates: []crate{{Module: "foo", Unsafe_diagnostics: "missing.json"}}}, open); err == nil {
+		t.Errorf("expected an error for missing diagnostics")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rust_audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	writeFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("foo.unsafe.json", "")
+	writeFile("bar.unsafe.json", unsafeBlock+"\n")
+	writeFile("manifest.json", `{
+		"name": "foo",
+		"variant": "linux_glibc_x86_64",
+		"blueprint": "foo/Android.bp",
+		"output": "out/foo",
+		"crates": [
+			{"module": "foo", "crate_name": "foo", "kind": "bin", "blueprint": "foo/Android.bp",
+			 "deps": ["libbar"], "unsafe_diagnostics": "`+filepath.Join(dir, "foo.unsafe.json")+`"},
+			{"module": "libbar", "crate_name": "bar", "kind": "rlib", "third_party": true,
+			 "blueprint": "external/bar/Android.bp", "unsafe_diagnostics": "`+filepath.Join(dir, "bar.unsafe.json")+`"}
+		]
+	}`)
+
+	reportFile := filepath.Join(dir, "report.json")
+	if err := run(filepath.Join(dir, "manifest.json"), reportFile); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	got, err := ioutil.ReadFile(reportFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  "name": "foo",
+  "variant": "linux_glibc_x86_64",
+  "blueprint": "foo/Android.bp",
+  "output": "out/foo",
+  "third_party_crates": [
+    "libbar"
+  ],
+  "unsafe_crates": [
+    "libbar"
+  ],
+  "crates": [
+    {
+      "module": "foo",
+      "crate_name": "foo",
+      "kind": "bin",
+      "third_party": false,
+      "blueprint": "foo/Android.bp",
+      "deps": [
+        "libbar"
+      ],
+      "unsafe": {
+        "blocks": 0,
+        "functions": 0,
+        "impls": 0,
+        "traits": 0,
+        "other": 0
+      }
+    },
+    {
+      "module": "libbar",
+      "crate_name": "bar",
+      "kind": "rlib",
+      "third_party": true,
+      "blueprint": "external/bar/Android.bp",
+      "unsafe": {
+        "blocks": 1,
+        "functions": 0,
+        "impls": 0,
+        "traits": 0,
+        "other": 0
+      }
+    }
+  ]
+}
+`
+	if string(got) != want {
+		t.Errorf("expected report:\n%s\ngot:\n%s", want, got)
+	}
+}
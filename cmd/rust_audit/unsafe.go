@@ -0,0 +1,87 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// unsafeCounts is the unsafe code of a crate, as reported by the unsafe_code lint of rustc.
+type unsafeCounts struct {
+	// Unsafe blocks.
+	Blocks int `json:"blocks"`
+	// Declarations and implementations of unsafe functions and methods.
+	Functions int `json:"functions"`
+	// Implementations of unsafe traits.
+	Impls int `json:"impls"`
+	// Declarations of unsafe traits.
+	Traits int `json:"traits"`
+	// Other code the lint reports as unsafe, like no_mangle functions or unsafe extern blocks.
+	Other int `json:"other"`
+}
+
+func (c unsafeCounts) total() int {
+	return c.Blocks + c.Functions + c.Impls + c.Traits + c.Other
+}
+
+// add counts a diagnostic of the unsafe_code lint from its message.
+func (c *unsafeCounts) add(message string) {
+	switch {
+	case strings.Contains(message, "`unsafe` block"):
+		c.Blocks++
+	case strings.Contains(message, "`unsafe` function"), strings.Contains(message, "`unsafe` method"):
+		c.Functions++
+	case strings.HasPrefix(message, "implementation of an `unsafe` trait"):
+		c.Impls++
+	case strings.HasPrefix(message, "declaration of an `unsafe` trait"):
+		c.Traits++
+	default:
+		c.Other++
+	}
+}
+
+// rustcDiagnostic is a diagnostic printed by rustc with --error-format=json.
+type rustcDiagnostic struct {
+	Message string `json:"message"`
+	Code    *struct {
+		Code string `json:"code"`
+	} `json:"code"`
+}
+
+// countUnsafe counts the unsafe_code diagnostics in the output of rustc --error-format=json. Other
+// diagnostics and lines that aren't JSON diagnostics are ignored.
+func countUnsafe(r io.Reader) (unsafeCounts, error) {
+	var counts unsafeCounts
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var diag rustcDiagnostic
+		if err := json.Unmarshal(line, &diag); err != nil {
+			return unsafeCounts{}, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if diag.Code != nil && diag.Code.Code == "unsafe_code" {
+			counts.add(diag.Message)
+		}
+	}
+	return counts, scanner.Err()
+}
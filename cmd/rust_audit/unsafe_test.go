@@ -0,0 +1,53 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountUnsafe(t *testing.T) {
+	diagnostics := `{"message":"usage of an ` + "`unsafe`" + ` block","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"usage of an ` + "`unsafe`" + ` block","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"declaration of an ` + "`unsafe`" + ` function","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"implementation of an ` + "`unsafe`" + ` method","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"implementation of an ` + "`unsafe`" + ` trait","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"declaration of an ` + "`unsafe`" + ` trait","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"declaration of a ` + "`no_mangle`" + ` function","code":{"code":"unsafe_code","explanation":null},"level":"warning"}
+{"message":"unused variable: ` + "`x`" + `","code":{"code":"unused_variables","explanation":null},"level":"warning"}
+{"message":"1 warning emitted","code":null,"level":"warning"}
+
+not a diagnostic
+`
+	got, err := countUnsafe(strings.NewReader(diagnostics))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := unsafeCounts{Blocks: 2, Functions: 2, Impls: 1, Traits: 1, Other: 1}
+	if got != want {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if got.total() != 7 {
+		t.Errorf("expected 7 unsafe items, got %d", got.total())
+	}
+}
+
+func TestCountUnsafeInvalid(t *testing.T) {
+	_, err := countUnsafe(strings.NewReader("{\"message\":\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error on line 1, got %v", err)
+	}
+}
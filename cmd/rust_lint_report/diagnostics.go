@@ -0,0 +1,120 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// warning is a lint warning of a crate. Warnings are compared with the baseline by lint, file and
+// message, so that the baseline doesn't need to be updated when unrelated code moves.
+type warning struct {
+	Lint    string `json:"lint"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (w warning) key() string {
+	return w.Lint + "\x00" + w.File + "\x00" + w.Message
+}
+
+func (w warning) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", w.File, w.Line, w.Lint, w.Message)
+}
+
+// rustcDiagnostic is a diagnostic printed by rustc or clippy with --error-format=json.
+type rustcDiagnostic struct {
+	Message string `json:"message"`
+	Level   string `json:"level"`
+	Code    *struct {
+		Code string `json:"code"`
+	} `json:"code"`
+	Spans []struct {
+		File_name  string `json:"file_name"`
+		Line_start int    `json:"line_start"`
+		Is_primary bool   `json:"is_primary"`
+	} `json:"spans"`
+}
+
+// readWarnings returns the lint warnings in the output of rustc --error-format=json, sorted by
+// location. Diagnostics without a lint, like the summary of the warnings, and lines that aren't JSON
+// diagnostics are ignored.
+func readWarnings(r io.Reader) ([]warning, error) {
+	warnings := []warning{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Bytes()
+		if len(line) == 0 || line[0] != '{' {
+			continue
+		}
+		var diag rustcDiagnostic
+		if err := json.Unmarshal(line, &diag); err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		if diag.Level != "warning" || diag.Code == nil || diag.Code.Code == "" {
+			continue
+		}
+		w := warning{Lint: diag.Code.Code, Message: diag.Message}
+		for _, span := range diag.Spans {
+			if span.Is_primary {
+				w.File, w.Line = span.File_name, span.Line_start
+				break
+			}
+		}
+		warnings = append(warnings, w)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		a, b := warnings[i], warnings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Lint < b.Lint
+	})
+	return warnings, nil
+}
+
+// newWarnings returns the warnings that aren't in the baseline, and the number of warnings of the
+// baseline that are gone. A warning that appears more often than in the baseline is new.
+func newWarnings(warnings, baseline []warning) ([]warning, int) {
+	known := make(map[string]int)
+	for _, w := range baseline {
+		known[w.key()]++
+	}
+	var added []warning
+	for _, w := range warnings {
+		if known[w.key()] > 0 {
+			known[w.key()]--
+		} else {
+			added = append(added, w)
+		}
+	}
+	fixed := 0
+	for _, n := range known {
+		fixed += n
+	}
+	return added, fixed
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadWarnings(t *testing.T) {
+	diagnostics := `{"message":"unused variable: ` + "`x`" + `","code":{"code":"unused_variables","explanation":null},"level":"warning","spans":[{"file_name":"foo/src/lib.rs","line_start":12,"is_primary":true}]}
+{"message":"this looks like you are swapping elements","code":{"code":"clippy::manual_swap","explanation":null},"level":"warning","spans":[{"file_name":"foo/src/other.rs","line_start":1,"is_primary":false},{"file_name":"foo/src/lib.rs","line_start":3,"is_primary":true}]}
+{"message":"mismatched types","code":{"code":"E0308","explanation":"..."},"level":"error","spans":[]}
+{"message":"2 warnings emitted","code":null,"level":"warning","spans":[]}
+
+not a diagnostic
+`
+	got, err := readWarnings(strings.NewReader(diagnostics))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []warning{
+		{Lint: "clippy::manual_swap", File: "foo/src/lib.rs", Line: 3, Message: "this looks like you are swapping elements"},
+		{Lint: "unused_variables", File: "foo/src/lib.rs", Line: 12, Message: "unused variable: `x`"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestReadWarningsInvalid(t *testing.T) {
+	_, err := readWarnings(strings.NewReader("{\"message\":\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("expected an error on line 1, got %v", err)
+	}
+}
+
+func TestNewWarnings(t *testing.T) {
+	unused := warning{Lint: "unused_variables", File: "foo/src/lib.rs", Line: 12, Message: "unused variable: `x`"}
+	swap := warning{Lint: "clippy::manual_swap", File: "foo/src/lib.rs", Line: 3, Message: "this looks like you are swapping elements"}
+	moved := unused
+	moved.Line = 20
+
+	testCases := []struct {
+		name     string
+		warnings []warning
+		baseline []warning
+		added    []warning
+		fixed    int
+	}{
+		{name: "empty baseline", warnings: []warning{unused}, added: []warning{unused}},
+		{name: "moved warning", warnings: []warning{moved}, baseline: []warning{unused}},
+		{name: "fixed warning", warnings: []warning{swap}, baseline: []warning{swap, unused}, fixed: 1},
+		{name: "repeated warning", warnings: []warning{unused, moved}, baseline: []warning{unused}, added: []warning{moved}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			added, fixed := newWarnings(tc.warnings, tc.baseline)
+			if !reflect.DeepEqual(added, tc.added) {
+				t.Errorf("expected new warnings %+v, got %+v", tc.added, added)
+			}
+			if fixed != tc.fixed {
+				t.Errorf("expected %d fixed warnings, got %d", tc.fixed, fixed)
+			}
+		})
+	}
+}
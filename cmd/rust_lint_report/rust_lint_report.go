@@ -0,0 +1,219 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+// rust_lint_report writes the lint reports of Rust modules from the rustc and clippy warnings of
+// their crates, checks them against the lint baselines of the modules, and aggregates them into a
+// tree-wide report.
+//
+// Soong runs it for every Rust module with a lint_baseline, and for every Rust module when
+// SOONG_RUST_LINT_REPORT=true is set, which also writes the tree-wide report to
+// $OUT/soong/rust_lints/report.json:
+//
+//	SOONG_RUST_LINT_REPORT=true m rust-lint-report
+//
+// A lint baseline has the format of the lint report of a module, so a report can be used as the
+// baseline of the module.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+)
+
+// moduleReport is the lint report of a module variant.
+type moduleReport struct {
+	Module   string    `json:"module"`
+	Variant  string    `json:"variant"`
+	Warnings []warning `json:"warnings"`
+	// The lint baseline of the module, and the number of warnings in it that have been fixed.
+	Baseline string `json:"baseline,omitempty"`
+	Fixed    int    `json:"fixed,omitempty"`
+}
+
+// treeReport is the aggregated lint report of all the modules.
+type treeReport struct {
+	// The number of warnings, in total and of each lint.
+	Total int            `json:"total"`
+	Lints map[string]int `json:"lints"`
+	// The modules that have a lint baseline, and the number of warnings fixed in them.
+	Baselined []string       `json:"baselined"`
+	Fixed     int            `json:"fixed"`
+	Modules   []moduleReport `json:"modules"`
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s check -module <name> -variant <variant> [-baseline <baseline>] -o <report> <diagnostics>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge -o <report> <module reports or @rspfile>...\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "check":
+		err = checkCmd(os.Args[2:])
+	case "merge":
+		err = mergeCmd(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func checkCmd(args []string) error {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	module := flags.String("module", "", "the name of the module")
+	variant := flags.String("variant", "", "the variant of the module")
+	baseline := flags.String("baseline", "", "the lint baseline of the module")
+	output := flags.String("o", "", "the lint report to write")
+	flags.Parse(args)
+	if *module == "" || *output == "" || flags.NArg() != 1 {
+		usage()
+	}
+
+	diagnostics := flags.Arg(0)
+	f, err := os.Open(diagnostics)
+	if err != nil {
+		return err
+	}
+	warnings, err := readWarnings(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", diagnostics, err)
+	}
+
+	r := &moduleReport{Module: *module, Variant: *variant, Warnings: warnings}
+	if *baseline != "" {
+		base, err := readReport(*baseline)
+		if err != nil {
+			return err
+		}
+		added, fixed := newWarnings(warnings, base.Warnings)
+		if len(added) > 0 {
+			var msg strings.Builder
+			fmt.Fprintf(&msg, "%s has %d warnings that aren't in its lint baseline %s:\n",
+				*module, len(added), *baseline)
+			for _, w := range added {
+				fmt.Fprintf(&msg, "  %s\n", w)
+			}
+			fmt.Fprintf(&msg, "Fix them, or add all the current warnings of the module to the baseline with:\n")
+			fmt.Fprintf(&msg, "  %s check -module %s -variant %s -o %s %s",
+				os.Args[0], *module, *variant, *baseline, diagnostics)
+			return fmt.Errorf("%s", msg.String())
+		}
+		r.Baseline = *baseline
+		r.Fixed = fixed
+	}
+
+	return writeJSON(*output, r)
+}
+
+func mergeCmd(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	output := flags.String("o", "", "the aggregated lint report to write")
+	flags.Parse(args)
+	if *output == "" {
+		usage()
+	}
+
+	var files []string
+	for _, arg := range flags.Args() {
+		if strings.HasPrefix(arg, "@") {
+			buf, err := ioutil.ReadFile(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return err
+			}
+			files = append(files, strings.Fields(string(buf))...)
+		} else {
+			files = append(files, arg)
+		}
+	}
+
+	var reports []moduleReport
+	for _, file := range files {
+		r, err := readReport(file)
+		if err != nil {
+			return err
+		}
+		reports = append(reports, *r)
+	}
+
+	return writeJSON(*output, merge(reports))
+}
+
+// merge aggregates the lint reports of modules, sorted by module and variant.
+func merge(reports []moduleReport) *treeReport {
+	t := &treeReport{
+		Lints:     make(map[string]int),
+		Baselined: []string{},
+		Modules:   append([]moduleReport{}, reports...),
+	}
+	sort.SliceStable(t.Modules, func(i, j int) bool {
+		if t.Modules[i].Module != t.Modules[j].Module {
+			return t.Modules[i].Module < t.Modules[j].Module
+		}
+		return t.Modules[i].Variant < t.Modules[j].Variant
+	})
+
+	for _, r := range t.Modules {
+		t.Total += len(r.Warnings)
+		for _, w := range r.Warnings {
+			t.Lints[w.Lint]++
+		}
+		if r.Baseline != "" {
+			if len(t.Baselined) == 0 || t.Baselined[len(t.Baselined)-1] != r.Module {
+				t.Baselined = append(t.Baselined, r.Module)
+			}
+			t.Fixed += r.Fixed
+		}
+	}
+	return t
+}
+
+func readReport(file string) (*moduleReport, error) {
+	buf, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var r moduleReport
+	if len(bytes.TrimSpace(buf)) == 0 {
+		// An empty baseline has no warnings.
+		return &r, nil
+	}
+	if err := json.Unmarshal(buf, &r); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return &r, nil
+}
+
+func writeJSON(file string, v interface{}) error {
+	buf, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append(buf, '\n'), 0666)
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const unusedVariable = `{"message":"unused variable: ` + "`x`" + `","code":{"code":"unused_variables","explanation":null},"level":"warning","spans":[{"file_name":"foo/src/lib.rs","line_start":12,"is_primary":true}]}
+`
+
+func TestCheck(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rust_lint_report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	diagnostics := filepath.Join(dir, "libfoo.rlib.lints.json")
+	if err := ioutil.WriteFile(diagnostics, []byte(unusedVariable), 0666); err != nil {
+		t.Fatal(err)
+	}
+	emptyBaseline := filepath.Join(dir, "empty_baseline.json")
+	if err := ioutil.WriteFile(emptyBaseline, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without a baseline, the warnings are reported.
+	report := filepath.Join(dir, "report.json")
+	if err := checkCmd([]string{"-module", "libfoo", "-variant", "rlib", "-o", report, diagnostics}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r, err := readReport(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &moduleReport{
+		Module:  "libfoo",
+		Variant: "rlib",
+		Warnings: []warning{
+			{Lint: "unused_variables", File: "foo/src/lib.rs", Line: 12, Message: "unused variable: `x`"},
+		},
+	}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("expected report %+v, got %+v", want, r)
+	}
+
+	// New warnings are errors.
+	err = checkCmd([]string{"-module", "libfoo", "-variant", "rlib", "-baseline", emptyBaseline,
+		"-o", filepath.Join(dir, "failed.json"), diagnostics})
+	if err == nil || !strings.Contains(err.Error(), "foo/src/lib.rs:12: unused_variables") {
+		t.Errorf("expected an error for the new warning, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "failed.json")); !os.IsNotExist(err) {
+		t.Errorf("expected no report for a failed check, got %v", err)
+	}
+
+	// The report of the module can be used as its baseline.
+	baselined := filepath.Join(dir, "baselined.json")
+	if err := checkCmd([]string{"-module", "libfoo", "-variant", "rlib", "-baseline", report, "-o", baselined, diagnostics}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	r, err = readReport(baselined)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Baseline != report || r.Fixed != 0 || len(r.Warnings) != 1 {
+		t.Errorf("unexpected baselined report %+v", r)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	unused := warning{Lint: "unused_variables", File: "foo/src/lib.rs", Line: 12, Message: "unused variable: `x`"}
+	swap := warning{Lint: "clippy::manual_swap", File: "bar/src/lib.rs", Line: 3, Message: "this looks like you are swapping elements"}
+
+	got := merge([]moduleReport{
+		{Module: "libfoo", Variant: "rlib", Warnings: []warning{unused}, Baseline: "foo/baseline.json", Fixed: 2},
+		{Module: "libbar", Variant: "rlib", Warnings: []warning{swap, unused}},
+		{Module: "libfoo", Variant: "dylib", Warnings: []warning{unused}, Baseline: "foo/baseline.json", Fixed: 2},
+	})
+
+	if got.Total != 4 {
+		t.Errorf("expected 4 warnings, got %d", got.Total)
+	}
+	if want := map[string]int{"unused_variables": 3, "clippy::manual_swap": 1}; !reflect.DeepEqual(got.Lints, want) {
+		t.Errorf("expected lint counts %v, got %v", want, got.Lints)
+	}
+	if want := []string{"libfoo"}; !reflect.DeepEqual(got.Baselined, want) {
+		t.Errorf("expected baselined modules %v, got %v", want, got.Baselined)
+	}
+	if got.Fixed != 4 {
+		t.Errorf("expected 4 fixed warnings, got %d", got.Fixed)
+	}
+	var modules []string
+	for _, r := range got.Modules {
+		modules = append(modules, r.Module+":"+r.Variant)
+	}
+	if want := []string{"libbar:rlib", "libfoo:dylib", "libfoo:rlib"}; !reflect.DeepEqual(modules, want) {
+		t.Errorf("expected modules %v, got %v", want, modules)
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// parseBloatyCompileUnits parses the csv output of `bloaty -d compileunits,symbols --csv` and
+// returns the compile units sorted by decreasing VM size. Each compile unit keeps at most
+// maxSymbols of its largest symbols; a negative maxSymbols keeps all of them.
+func parseBloatyCompileUnits(r io.Reader, maxSymbols int) ([]CompileUnit, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bloaty header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"compileunits", "symbols", "vmsize", "filesize"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %q column in bloaty header %q", name, header)
+		}
+	}
+
+	units := make(map[string]*CompileUnit)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		vmSize, err := strconv.ParseUint(record[columns["vmsize"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vmsize %q: %w", record[columns["vmsize"]], err)
+		}
+		fileSize, err := strconv.ParseUint(record[columns["filesize"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filesize %q: %w", record[columns["filesize"]], err)
+		}
+
+		name := record[columns["compileunits"]]
+		unit := units[name]
+		if unit == nil {
+			unit = &CompileUnit{Name: name}
+			units[name] = unit
+		}
+		unit.VMSize += vmSize
+		unit.FileSize += fileSize
+		unit.Symbols = append(unit.Symbols, Symbol{
+			Name:     record[columns["symbols"]],
+			VMSize:   vmSize,
+			FileSize: fileSize,
+		})
+	}
+
+	ret := make([]CompileUnit, 0, len(units))
+	for _, unit := range units {
+		sort.SliceStable(unit.Symbols, func(i, j int) bool {
+			if unit.Symbols[i].VMSize != unit.Symbols[j].VMSize {
+				return unit.Symbols[i].VMSize > unit.Symbols[j].VMSize
+			}
+			return unit.Symbols[i].Name < unit.Symbols[j].Name
+		})
+		if maxSymbols >= 0 && len(unit.Symbols) > maxSymbols {
+			unit.Symbols = unit.Symbols[:maxSymbols]
+		}
+		ret = append(ret, *unit)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].VMSize != ret[j].VMSize {
+			return ret[i].VMSize > ret[j].VMSize
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret, nil
+}
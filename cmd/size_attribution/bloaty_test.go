@@ -0,0 +1,65 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseBloatyCompileUnits(t *testing.T) {
+	csv := `compileunits,symbols,vmsize,filesize
+foo.cpp,foo(),100,104
+foo.cpp,"bar(int, int)",300,300
+baz.cpp,baz(),200,210
+foo.cpp,[section .text],10,0
+`
+
+	units, err := parseBloatyCompileUnits(strings.NewReader(csv), 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []CompileUnit{
+		{
+			Name:     "foo.cpp",
+			VMSize:   410,
+			FileSize: 404,
+			Symbols: []Symbol{
+				{Name: "bar(int, int)", VMSize: 300, FileSize: 300},
+				{Name: "foo()", VMSize: 100, FileSize: 104},
+			},
+		},
+		{
+			Name:     "baz.cpp",
+			VMSize:   200,
+			FileSize: 210,
+			Symbols: []Symbol{
+				{Name: "baz()", VMSize: 200, FileSize: 210},
+			},
+		},
+	}
+	if !reflect.DeepEqual(units, want) {
+		t.Errorf("incorrect compile units:\nwant: %#v\n got: %#v", want, units)
+	}
+}
+
+func TestParseBloatyCompileUnitsMissingColumn(t *testing.T) {
+	_, err := parseBloatyCompileUnits(strings.NewReader("sections,vmsize,filesize\n"), -1)
+	if err == nil || !strings.Contains(err.Error(), `missing "compileunits" column`) {
+		t.Errorf("expected missing column error, got %v", err)
+	}
+}
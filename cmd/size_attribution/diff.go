@@ -0,0 +1,189 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// SizeDelta is the size of a binary or of one of its contributors in two builds.
+type SizeDelta struct {
+	Name string `json:"name"`
+	Old  uint64 `json:"old"`
+	New  uint64 `json:"new"`
+}
+
+// Delta returns the change in size between the two builds.
+func (d SizeDelta) Delta() int64 {
+	return int64(d.New) - int64(d.Old)
+}
+
+// BinaryDiff is the change in size of a binary and of its contributors between two builds.
+type BinaryDiff struct {
+	Module string `json:"module"`
+	Binary string `json:"binary"`
+
+	VMSize   SizeDelta `json:"vm_size"`
+	FileSize SizeDelta `json:"file_size"`
+
+	// ObjectFiles, StaticLibraries and CompileUnits only contain the contributors whose size
+	// changed, sorted by decreasing absolute change.
+	ObjectFiles     []SizeDelta `json:"object_files,omitempty"`
+	StaticLibraries []SizeDelta `json:"static_libraries,omitempty"`
+	CompileUnits    []SizeDelta `json:"compile_units,omitempty"`
+}
+
+// diffReports compares the binaries in two sets of reports, matching them by path. Binaries that
+// only exist in one of the sets are compared against a size of 0. Binaries whose size didn't
+// change are omitted. The result is sorted by decreasing VM size growth.
+func diffReports(oldReports, newReports *Reports) []BinaryDiff {
+	oldByBinary := make(map[string]Report)
+	for _, report := range oldReports.Binaries {
+		oldByBinary[report.Binary] = report
+	}
+	newByBinary := make(map[string]Report)
+	for _, report := range newReports.Binaries {
+		newByBinary[report.Binary] = report
+	}
+
+	var diffs []BinaryDiff
+	addDiff := func(oldReport, newReport Report) {
+		diff := BinaryDiff{
+			Module:   newReport.Module,
+			Binary:   newReport.Binary,
+			VMSize:   SizeDelta{Name: "vm_size", Old: oldReport.VMSize, New: newReport.VMSize},
+			FileSize: SizeDelta{Name: "file_size", Old: oldReport.FileSize, New: newReport.FileSize},
+			ObjectFiles: diffContributors(contributorSizes(oldReport.ObjectFiles),
+				contributorSizes(newReport.ObjectFiles)),
+			StaticLibraries: diffContributors(contributorSizes(oldReport.StaticLibraries),
+				contributorSizes(newReport.StaticLibraries)),
+			CompileUnits: diffContributors(compileUnitSizes(oldReport.CompileUnits),
+				compileUnitSizes(newReport.CompileUnits)),
+		}
+		if diff.Binary == "" {
+			diff.Module = oldReport.Module
+			diff.Binary = oldReport.Binary
+		}
+		if diff.VMSize.Delta() != 0 || diff.FileSize.Delta() != 0 || len(diff.ObjectFiles) > 0 ||
+			len(diff.StaticLibraries) > 0 || len(diff.CompileUnits) > 0 {
+			diffs = append(diffs, diff)
+		}
+	}
+
+	for binary, newReport := range newByBinary {
+		addDiff(oldByBinary[binary], newReport)
+	}
+	for binary, oldReport := range oldByBinary {
+		if _, ok := newByBinary[binary]; !ok {
+			addDiff(oldReport, Report{})
+		}
+	}
+
+	sort.Slice(diffs, func(i, j int) bool {
+		if diffs[i].VMSize.Delta() != diffs[j].VMSize.Delta() {
+			return diffs[i].VMSize.Delta() > diffs[j].VMSize.Delta()
+		}
+		return diffs[i].Binary < diffs[j].Binary
+	})
+	return diffs
+}
+
+func contributorSizes(contributors []Contributor) map[string]uint64 {
+	ret := make(map[string]uint64, len(contributors))
+	for _, c := range contributors {
+		ret[c.Name] += c.Size
+	}
+	return ret
+}
+
+func compileUnitSizes(units []CompileUnit) map[string]uint64 {
+	ret := make(map[string]uint64, len(units))
+	for _, unit := range units {
+		ret[unit.Name] += unit.VMSize
+	}
+	return ret
+}
+
+// diffContributors returns the contributors whose size changed, sorted by decreasing absolute
+// change.
+func diffContributors(oldSizes, newSizes map[string]uint64) []SizeDelta {
+	var ret []SizeDelta
+	for name, newSize := range newSizes {
+		if oldSize := oldSizes[name]; oldSize != newSize {
+			ret = append(ret, SizeDelta{Name: name, Old: oldSize, New: newSize})
+		}
+	}
+	for name, oldSize := range oldSizes {
+		if _, ok := newSizes[name]; !ok && oldSize != 0 {
+			ret = append(ret, SizeDelta{Name: name, Old: oldSize})
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if abs(ret[i].Delta()) != abs(ret[j].Delta()) {
+			return abs(ret[i].Delta()) > abs(ret[j].Delta())
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+func abs(i int64) int64 {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// writeDiffText writes a human readable summary of diffs, listing at most top contributors per
+// category for each binary.
+func writeDiffText(w io.Writer, diffs []BinaryDiff, top int) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "No size changes.")
+		return
+	}
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%s (%s): vm size %s, file size %s\n", diff.Module, diff.Binary,
+			formatDelta(diff.VMSize), formatDelta(diff.FileSize))
+		writeDeltas(w, "static libraries", diff.StaticLibraries, top)
+		writeDeltas(w, "object files", diff.ObjectFiles, top)
+		writeDeltas(w, "compile units", diff.CompileUnits, top)
+	}
+}
+
+func writeDeltas(w io.Writer, title string, deltas []SizeDelta, top int) {
+	if len(deltas) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "  %s:\n", title)
+	for i, d := range deltas {
+		if top >= 0 && i >= top {
+			fmt.Fprintf(w, "    ... %d more\n", len(deltas)-top)
+			break
+		}
+		fmt.Fprintf(w, "    %+8d  %s\n", d.Delta(), d.Name)
+	}
+}
+
+func formatDelta(d SizeDelta) string {
+	if d.Old == d.New {
+		return fmt.Sprintf("%d (unchanged)", d.New)
+	} else if d.Old == 0 {
+		return fmt.Sprintf("%d -> %d (new)", d.Old, d.New)
+	}
+	return fmt.Sprintf("%d -> %d (%+d, %+.1f%%)", d.Old, d.New, d.Delta(),
+		float64(d.Delta())*100/float64(d.Old))
+}
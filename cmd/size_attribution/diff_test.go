@@ -0,0 +1,110 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDiffReports(t *testing.T) {
+	oldReports := &Reports{Binaries: []Report{
+		{
+			Module: "libfoo",
+			Binary: "out/libfoo.so",
+			VMSize: 1000,
+			ObjectFiles: []Contributor{
+				{Name: "foo.o", Size: 600},
+				{Name: "libbar.a(bar.o)", Size: 400},
+			},
+			StaticLibraries: []Contributor{{Name: "libbar.a", Size: 400}},
+		},
+		{
+			Module: "libunchanged",
+			Binary: "out/libunchanged.so",
+			VMSize: 10,
+		},
+		{
+			Module: "libremoved",
+			Binary: "out/libremoved.so",
+			VMSize: 50,
+		},
+	}}
+	newReports := &Reports{Binaries: []Report{
+		{
+			Module: "libfoo",
+			Binary: "out/libfoo.so",
+			VMSize: 1300,
+			ObjectFiles: []Contributor{
+				{Name: "foo.o", Size: 600},
+				{Name: "libbar.a(bar.o)", Size: 300},
+				{Name: "libbaz.a(baz.o)", Size: 400},
+			},
+			StaticLibraries: []Contributor{
+				{Name: "libbaz.a", Size: 400},
+				{Name: "libbar.a", Size: 300},
+			},
+		},
+		{
+			Module: "libunchanged",
+			Binary: "out/libunchanged.so",
+			VMSize: 10,
+		},
+	}}
+
+	diffs := diffReports(oldReports, newReports)
+
+	want := []BinaryDiff{
+		{
+			Module:   "libfoo",
+			Binary:   "out/libfoo.so",
+			VMSize:   SizeDelta{Name: "vm_size", Old: 1000, New: 1300},
+			FileSize: SizeDelta{Name: "file_size"},
+			ObjectFiles: []SizeDelta{
+				{Name: "libbaz.a(baz.o)", Old: 0, New: 400},
+				{Name: "libbar.a(bar.o)", Old: 400, New: 300},
+			},
+			StaticLibraries: []SizeDelta{
+				{Name: "libbaz.a", Old: 0, New: 400},
+				{Name: "libbar.a", Old: 400, New: 300},
+			},
+		},
+		{
+			Module:   "libremoved",
+			Binary:   "out/libremoved.so",
+			VMSize:   SizeDelta{Name: "vm_size", Old: 50, New: 0},
+			FileSize: SizeDelta{Name: "file_size"},
+		},
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("incorrect diff:\nwant: %#v\n got: %#v", want, diffs)
+	}
+
+	buf := &bytes.Buffer{}
+	writeDiffText(buf, diffs, 1)
+	wantText := `libfoo (out/libfoo.so): vm size 1000 -> 1300 (+300, +30.0%), file size 0 (unchanged)
+  static libraries:
+        +400  libbaz.a
+    ... 1 more
+  object files:
+        +400  libbaz.a(baz.o)
+    ... 1 more
+libremoved (out/libremoved.so): vm size 50 -> 0 (-50, -100.0%), file size 0 (unchanged)
+`
+	if buf.String() != wantText {
+		t.Errorf("incorrect text output:\nwant:\n%s\ngot:\n%s", wantText, buf.String())
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// inputSection is an input section listed in a linker map, for example the .text section of an
+// object file, with the size it contributes to an allocated output section.
+type inputSection struct {
+	// file is the object file the section came from, e.g. "out/obj/foo.o" or
+	// "out/libbar.a(bar.o)".
+	file string
+	// archive is the static library the object file was extracted from, or empty if the object
+	// file was linked directly.
+	archive string
+	size    uint64
+}
+
+// parseLinkerMap parses a linker map written by lld's -Map option and returns the input sections
+// that were placed into allocated output sections. The map looks like:
+//
+//	   VMA      LMA     Size Align Out     In      Symbol
+//	201000   201000      1b4    16 .text
+//	201000   201000       2b    16         out/obj/foo.o:(.text)
+//	201000   201000        0     1                 _start
+//	201030   201030       10    16         out/libbar.a(bar.o):(.text.bar)
+//
+// Sections that are not allocated, like .comment or .debug_*, have a VMA of 0 and are skipped.
+func parseLinkerMap(r io.Reader) ([]inputSection, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 16*1024*1024)
+
+	inColumn, symbolColumn := -1, -1
+	allocated := false
+	var sections []inputSection
+
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if inColumn < 0 {
+			// The first line is the header, use it to find the columns of the input section and
+			// symbol names.
+			inColumn = strings.Index(line, " In ")
+			symbolColumn = strings.Index(line, " Symbol")
+			if inColumn < 0 || symbolColumn < 0 {
+				return nil, fmt.Errorf("line %d: missing linker map header, got %q", lineNum, line)
+			}
+			inColumn++
+			symbolColumn++
+			continue
+		}
+
+		vma, size, nameColumn, name, err := splitLinkerMapLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		switch {
+		case nameColumn >= symbolColumn:
+			// Symbols don't have sizes in lld maps, sizes come from bloaty instead.
+		case nameColumn >= inColumn:
+			if !allocated || size == 0 {
+				continue
+			}
+			file, archive := parseInputSectionName(name)
+			sections = append(sections, inputSection{file: file, archive: archive, size: size})
+		default:
+			// An output section.
+			allocated = vma != 0
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// splitLinkerMapLine splits a line of an lld map into its VMA and size columns and the name
+// column, returning the offset in the line where the name starts.
+func splitLinkerMapLine(line string) (vma, size uint64, nameColumn int, name string, err error) {
+	var fields []string
+	pos := 0
+	for len(fields) < 4 {
+		for pos < len(line) && line[pos] == ' ' {
+			pos++
+		}
+		start := pos
+		for pos < len(line) && line[pos] != ' ' {
+			pos++
+		}
+		if start == pos {
+			return 0, 0, 0, "", fmt.Errorf("expected 4 numeric columns, got %q", line)
+		}
+		fields = append(fields, line[start:pos])
+	}
+
+	if vma, err = strconv.ParseUint(fields[0], 16, 64); err != nil {
+		return 0, 0, 0, "", fmt.Errorf("invalid VMA %q: %w", fields[0], err)
+	}
+	if size, err = strconv.ParseUint(fields[2], 16, 64); err != nil {
+		return 0, 0, 0, "", fmt.Errorf("invalid size %q: %w", fields[2], err)
+	}
+
+	for pos < len(line) && line[pos] == ' ' {
+		pos++
+	}
+	return vma, size, pos, line[pos:], nil
+}
+
+// parseInputSectionName splits an input section name like "out/libbar.a(bar.o):(.text.bar)" into
+// the object file ("out/libbar.a(bar.o)") and the static library it came from ("out/libbar.a").
+func parseInputSectionName(name string) (file, archive string) {
+	file = name
+	if i := strings.LastIndex(name, ":("); i >= 0 {
+		file = name[:i]
+	}
+	if strings.HasSuffix(file, ")") {
+		if i := strings.LastIndex(file, "("); i > 0 {
+			archive = file[:i]
+		}
+	}
+	return file, archive
+}
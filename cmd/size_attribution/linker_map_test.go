@@ -0,0 +1,118 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testLinkerMap = `             VMA              LMA     Size Align Out     In      Symbol
+             2a8              2a8       13     1 .interp
+             2a8              2a8       13     1         <internal>:(.interp)
+            1000             1000      1b4    16 .text
+            1000             1000       2b    16         out/obj/foo.o:(.text)
+            1000             1000        0     1                 _start
+            1030             1030       10    16         out/libbar.a(bar.o):(.text.bar)
+            1030             1030        0     1                 bar
+            1040             1040       20    16         out/libbar.a(baz.o):(.text.baz)
+            1060             1060        0    16         out/obj/empty.o:(.text)
+               0                0       57     1 .comment
+               0                0       57     1         <internal>:(.comment)
+`
+
+func TestParseLinkerMap(t *testing.T) {
+	sections, err := parseLinkerMap(strings.NewReader(testLinkerMap))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []inputSection{
+		{file: "<internal>", size: 0x13},
+		{file: "out/obj/foo.o", size: 0x2b},
+		{file: "out/libbar.a(bar.o)", archive: "out/libbar.a", size: 0x10},
+		{file: "out/libbar.a(baz.o)", archive: "out/libbar.a", size: 0x20},
+	}
+	if !reflect.DeepEqual(sections, want) {
+		t.Errorf("incorrect sections:\nwant: %#v\n got: %#v", want, sections)
+	}
+}
+
+func TestParseLinkerMapErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents string
+		err      string
+	}{
+		{
+			name:     "missing header",
+			contents: "            1000             1000      1b4    16 .text\n",
+			err:      "missing linker map header",
+		},
+		{
+			name: "bad size",
+			contents: "             VMA              LMA     Size Align Out     In      Symbol\n" +
+				"            1000             1000      xyz    16 .text\n",
+			err: "invalid size",
+		},
+		{
+			name: "short line",
+			contents: "             VMA              LMA     Size Align Out     In      Symbol\n" +
+				"            1000             1000\n",
+			err: "expected 4 numeric columns",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseLinkerMap(strings.NewReader(tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	sections, err := parseLinkerMap(strings.NewReader(testLinkerMap))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	units := []CompileUnit{
+		{Name: "foo.cpp", VMSize: 100, FileSize: 120},
+		{Name: "bar.cpp", VMSize: 50, FileSize: 60},
+	}
+
+	report := newReport("foo", "out/foo", sections, units)
+
+	wantObjectFiles := []Contributor{
+		{Name: "out/obj/foo.o", Size: 0x2b},
+		{Name: "out/libbar.a(baz.o)", Size: 0x20},
+		{Name: "<internal>", Size: 0x13},
+		{Name: "out/libbar.a(bar.o)", Size: 0x10},
+	}
+	if !reflect.DeepEqual(report.ObjectFiles, wantObjectFiles) {
+		t.Errorf("incorrect object files:\nwant: %#v\n got: %#v", wantObjectFiles, report.ObjectFiles)
+	}
+
+	wantStaticLibraries := []Contributor{{Name: "out/libbar.a", Size: 0x30}}
+	if !reflect.DeepEqual(report.StaticLibraries, wantStaticLibraries) {
+		t.Errorf("incorrect static libraries:\nwant: %#v\n got: %#v", wantStaticLibraries, report.StaticLibraries)
+	}
+
+	if report.VMSize != 150 || report.FileSize != 180 {
+		t.Errorf("incorrect totals, want 150/180, got %d/%d", report.VMSize, report.FileSize)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+)
+
+// Report attributes the size of a linked binary to the object files, static libraries and compile
+// units that were linked into it.
+type Report struct {
+	Module string `json:"module"`
+	Binary string `json:"binary"`
+
+	// VMSize and FileSize are the totals measured by bloaty.
+	VMSize   uint64 `json:"vm_size"`
+	FileSize uint64 `json:"file_size"`
+
+	// ObjectFiles and StaticLibraries are the sizes of the allocated input sections in the linker
+	// map, sorted by decreasing size.
+	ObjectFiles     []Contributor `json:"object_files"`
+	StaticLibraries []Contributor `json:"static_libraries"`
+
+	CompileUnits []CompileUnit `json:"compile_units"`
+}
+
+// Contributor is an object file or a static library and the number of bytes it contributes to
+// the allocated sections of a binary.
+type Contributor struct {
+	Name string `json:"name"`
+	Size uint64 `json:"size"`
+}
+
+// CompileUnit is a compile unit measured by bloaty, along with its largest symbols.
+type CompileUnit struct {
+	Name     string   `json:"name"`
+	VMSize   uint64   `json:"vm_size"`
+	FileSize uint64   `json:"file_size"`
+	Symbols  []Symbol `json:"symbols,omitempty"`
+}
+
+// Symbol is a symbol measured by bloaty.
+type Symbol struct {
+	Name     string `json:"name"`
+	VMSize   uint64 `json:"vm_size"`
+	FileSize uint64 `json:"file_size"`
+}
+
+// Reports is the tree-wide collection of size attribution reports.
+type Reports struct {
+	Binaries []Report `json:"binaries"`
+}
+
+// newReport creates a Report from the input sections of a linker map and the compile units
+// measured by bloaty.
+func newReport(module, binary string, sections []inputSection, units []CompileUnit) Report {
+	objectFiles := make(map[string]uint64)
+	staticLibraries := make(map[string]uint64)
+	for _, section := range sections {
+		objectFiles[section.file] += section.size
+		if section.archive != "" {
+			staticLibraries[section.archive] += section.size
+		}
+	}
+
+	report := Report{
+		Module:          module,
+		Binary:          binary,
+		ObjectFiles:     sortedContributors(objectFiles),
+		StaticLibraries: sortedContributors(staticLibraries),
+		CompileUnits:    units,
+	}
+	for _, unit := range units {
+		report.VMSize += unit.VMSize
+		report.FileSize += unit.FileSize
+	}
+	return report
+}
+
+// sortedContributors converts a map of names to sizes into a list of contributors sorted by
+// decreasing size.
+func sortedContributors(sizes map[string]uint64) []Contributor {
+	ret := make([]Contributor, 0, len(sizes))
+	for name, size := range sizes {
+		ret = append(ret, Contributor{Name: name, Size: size})
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Size != ret[j].Size {
+			return ret[i].Size > ret[j].Size
+		}
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// readReports reads either a single Report written by the report command or a Reports file
+// written by the merge command.
+func readReports(path string) (*Reports, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	reports := &Reports{}
+	if _, ok := fields["binaries"]; ok {
+		err = json.Unmarshal(data, reports)
+	} else {
+		var report Report
+		err = json.Unmarshal(data, &report)
+		reports.Binaries = append(reports.Binaries, report)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return reports, nil
+}
+
+// writeJSON writes v to path as indented JSON.
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0666)
+}
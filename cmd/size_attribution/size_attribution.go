@@ -0,0 +1,173 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// size_attribution attributes the size of linked binaries to the object files, static libraries,
+// compile units and symbols that were linked into them, using the linker map written by lld and
+// the compile unit sizes measured by bloaty. It can also merge per-binary reports into a
+// tree-wide report, and compare the reports of two builds to find size regressions.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"android/soong/response"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage of %s:\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s report -module <name> -binary <path> -map <linker map> -bloaty <csv> -o <output>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s merge -o <output> [<report>...|@<rsp file>]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s diff [-top <n>] [-json] [-max_growth <bytes>] <old report> <new report>\n", os.Args[0])
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "report":
+		err = reportCommand(os.Args[2:])
+	case "merge":
+		err = mergeCommand(os.Args[2:])
+	case "diff":
+		err = diffCommand(os.Args[2:])
+	default:
+		usage()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		os.Exit(1)
+	}
+}
+
+func reportCommand(args []string) error {
+	flags := flag.NewFlagSet("report", flag.ExitOnError)
+	module := flags.String("module", "", "name of the module that built the binary")
+	binary := flags.String("binary", "", "path to the binary")
+	linkerMap := flags.String("map", "", "linker map written by lld while linking the binary")
+	bloatyCsv := flags.String("bloaty", "", "output of `bloaty -d compileunits,symbols --csv` for the binary")
+	maxSymbols := flags.Int("max_symbols", 20, "number of symbols to keep per compile unit, -1 to keep all")
+	out := flags.String("o", "", "output report")
+	flags.Parse(args)
+
+	if *binary == "" || *linkerMap == "" || *bloatyCsv == "" || *out == "" {
+		return fmt.Errorf("-binary, -map, -bloaty and -o are required")
+	}
+
+	mapFile, err := os.Open(*linkerMap)
+	if err != nil {
+		return err
+	}
+	defer mapFile.Close()
+	sections, err := parseLinkerMap(mapFile)
+	if err != nil {
+		return fmt.Errorf("failed to parse linker map %s: %w", *linkerMap, err)
+	}
+
+	csvFile, err := os.Open(*bloatyCsv)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+	units, err := parseBloatyCompileUnits(csvFile, *maxSymbols)
+	if err != nil {
+		return fmt.Errorf("failed to parse bloaty output %s: %w", *bloatyCsv, err)
+	}
+
+	return writeJSON(*out, newReport(*module, *binary, sections, units))
+}
+
+func mergeCommand(args []string) error {
+	flags := flag.NewFlagSet("merge", flag.ExitOnError)
+	out := flags.String("o", "", "output report")
+	flags.Parse(args)
+
+	if *out == "" {
+		return fmt.Errorf("-o is required")
+	}
+
+	inputs, err := response.ExpandRspFiles(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	merged := Reports{Binaries: []Report{}}
+	for _, input := range inputs {
+		reports, err := readReports(input)
+		if err != nil {
+			return err
+		}
+		merged.Binaries = append(merged.Binaries, reports.Binaries...)
+	}
+	sort.Slice(merged.Binaries, func(i, j int) bool {
+		return merged.Binaries[i].Binary < merged.Binaries[j].Binary
+	})
+
+	return writeJSON(*out, merged)
+}
+
+func diffCommand(args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	top := flags.Int("top", 10, "number of contributors to list per binary, -1 to list all")
+	jsonOutput := flags.Bool("json", false, "write the diff as JSON instead of text")
+	maxGrowth := flags.Int64("max_growth", -1,
+		"exit with an error if the VM size of a binary grew by more than this many bytes")
+	flags.Parse(args)
+
+	if flags.NArg() != 2 {
+		return fmt.Errorf("expected an old and a new report, got %q", flags.Args())
+	}
+
+	oldReports, err := readReports(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	newReports, err := readReports(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	diffs := diffReports(oldReports, newReports)
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(diffs); err != nil {
+			return err
+		}
+	} else {
+		writeDiffText(os.Stdout, diffs, *top)
+	}
+
+	if *maxGrowth >= 0 {
+		var regressions []string
+		for _, diff := range diffs {
+			if diff.VMSize.Delta() > *maxGrowth {
+				regressions = append(regressions, diff.Binary)
+			}
+		}
+		if len(regressions) > 0 {
+			return fmt.Errorf("vm size grew by more than %d bytes: %s", *maxGrowth,
+				strings.Join(regressions, ", "))
+		}
+	}
+	return nil
+}
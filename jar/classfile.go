@@ -0,0 +1,271 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jar
+
+import (
+	"archive/zip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Constant pool tags from section 4.4 of the JVM specification.
+const (
+	constantUtf8               = 1
+	constantInteger            = 3
+	constantFloat              = 4
+	constantLong               = 5
+	constantDouble             = 6
+	constantClass              = 7
+	constantString             = 8
+	constantFieldref           = 9
+	constantMethodref          = 10
+	constantInterfaceMethodref = 11
+	constantNameAndType        = 12
+	constantMethodHandle       = 15
+	constantMethodType         = 16
+	constantDynamic            = 17
+	constantInvokeDynamic      = 18
+	constantModule             = 19
+	constantPackage            = 20
+)
+
+const classFileMagic = 0xCAFEBABE
+
+// accAnnotation is the access flag of annotation interfaces.
+const accAnnotation = 0x2000
+
+// ClassFile is the subset of a parsed .class file needed to find the classes it references.
+type ClassFile struct {
+	// Name is the internal name of the class defined by the file, e.g. "com/example/Foo".
+	Name string
+
+	// References is the sorted list of internal names of other classes referenced by the file,
+	// either directly through the constant pool or through descriptors and generic signatures.
+	References []string
+
+	// HasConstants is true if the class declares fields with a constant value.  javac inlines
+	// compile time constants, so the classes that use them don't reference the class.
+	HasConstants bool
+
+	// SourceRetention is true if the class is an annotation with @Retention(SOURCE).  javac
+	// discards those annotations, so the classes annotated with them don't reference the class.
+	SourceRetention bool
+}
+
+// ParseClassFile reads the constant pool of a .class file and returns the name of the class it
+// defines and the classes it references.
+func ParseClassFile(r io.Reader) (*ClassFile, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &classParser{data: data}
+	if magic := p.u4(); magic != classFileMagic {
+		return nil, fmt.Errorf("bad magic %#x", magic)
+	}
+	p.skip(4) // minor_version, major_version
+
+	count := int(p.u2())
+	utf8s := make(map[int]string)
+	// classes maps the index of each CONSTANT_Class entry to the index of its name.
+	classes := make(map[int]int)
+	for i := 1; i < count && p.err == nil; i++ {
+		tag := p.u1()
+		switch tag {
+		case constantUtf8:
+			n := int(p.u2())
+			utf8s[i] = string(p.bytes(n))
+		case constantClass:
+			classes[i] = int(p.u2())
+		case constantString, constantMethodType, constantModule, constantPackage:
+			p.skip(2)
+		case constantMethodHandle:
+			p.skip(3)
+		case constantInteger, constantFloat, constantFieldref, constantMethodref,
+			constantInterfaceMethodref, constantNameAndType, constantDynamic, constantInvokeDynamic:
+			p.skip(4)
+		case constantLong, constantDouble:
+			p.skip(8)
+			// 8 byte constants take up two entries in the constant pool.
+			i++
+		default:
+			return nil, fmt.Errorf("unknown constant pool tag %d at index %d", tag, i)
+		}
+	}
+	accessFlags := p.u2()
+	thisClass := int(p.u2())
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	name, ok := utf8s[classes[thisClass]]
+	if !ok {
+		return nil, fmt.Errorf("invalid this_class index %d", thisClass)
+	}
+
+	refs := make(map[string]bool)
+	for _, nameIndex := range classes {
+		if s, ok := utf8s[nameIndex]; ok {
+			if strings.HasPrefix(s, "[") {
+				addSignatureReferences(refs, s)
+			} else {
+				refs[s] = true
+			}
+		}
+	}
+	for _, s := range utf8s {
+		if strings.HasPrefix(s, "(") || strings.HasPrefix(s, "<") ||
+			(strings.HasPrefix(s, "L") && strings.HasSuffix(s, ";")) {
+			addSignatureReferences(refs, s)
+		}
+	}
+	delete(refs, name)
+
+	// Attribute names and the values of annotations are in the constant pool, so the constant
+	// fields and the retention of annotations can be found without parsing the attributes.
+	strs := make(map[string]bool)
+	for _, s := range utf8s {
+		strs[s] = true
+	}
+	hasConstants := strs["ConstantValue"]
+	sourceRetention := accessFlags&accAnnotation != 0 && strs["Ljava/lang/annotation/Retention;"] &&
+		strs["Ljava/lang/annotation/RetentionPolicy;"] && strs["SOURCE"]
+
+	references := make([]string, 0, len(refs))
+	for ref := range refs {
+		references = append(references, ref)
+	}
+	sort.Strings(references)
+
+	return &ClassFile{
+		Name:            name,
+		References:      references,
+		HasConstants:    hasConstants,
+		SourceRetention: sourceRetention,
+	}, nil
+}
+
+// addSignatureReferences adds every class named by an "L<name>;" in a field or method descriptor
+// or generic signature to refs.
+func addSignatureReferences(refs map[string]bool, signature string) {
+	for i := 0; i < len(signature); i++ {
+		if signature[i] != 'L' || (i > 0 && !strings.ContainsRune("();[<>*+-:^BCDFIJSZV", rune(signature[i-1]))) {
+			continue
+		}
+		end := strings.IndexAny(signature[i:], ";<")
+		if end < 0 {
+			return
+		}
+		refs[signature[i+1:i+end]] = true
+		i += end
+	}
+}
+
+// classParser reads big-endian values from a class file, recording the first error encountered.
+type classParser struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (p *classParser) bytes(n int) []byte {
+	if p.err != nil {
+		return nil
+	}
+	if p.pos+n > len(p.data) {
+		p.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := p.data[p.pos : p.pos+n]
+	p.pos += n
+	return b
+}
+
+func (p *classParser) skip(n int) {
+	p.bytes(n)
+}
+
+func (p *classParser) u1() uint8 {
+	if b := p.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (p *classParser) u2() uint16 {
+	if b := p.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (p *classParser) u4() uint32 {
+	if b := p.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+// multiReleasePrefix matches the directory of version specific classes in multi-release jars.
+var multiReleasePrefix = regexp.MustCompile(`^META-INF/versions/[0-9]+/`)
+
+// VisitClasses calls visit with the internal name of each class in a jar, skipping module-info
+// and package-info classes.
+func VisitClasses(jar string, visit func(name string, open func() (io.ReadCloser, error)) error) error {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		if !strings.HasSuffix(f.Name, ".class") {
+			continue
+		}
+		name := strings.TrimSuffix(multiReleasePrefix.ReplaceAllString(f.Name, ""), ".class")
+		if name == "module-info" || strings.HasSuffix(name, "/package-info") {
+			continue
+		}
+		if err := visit(name, f.Open); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadClassFiles parses every class in a jar, skipping module-info and package-info classes.
+func ReadClassFiles(jar string) ([]*ClassFile, error) {
+	var classes []*ClassFile
+	err := VisitClasses(jar, func(name string, open func() (io.ReadCloser, error)) error {
+		r, err := open()
+		if err != nil {
+			return err
+		}
+		defer r.Close()
+		c, err := ParseClassFile(r)
+		if err != nil {
+			return fmt.Errorf("failed to parse %s in %s: %w", name, jar, err)
+		}
+		classes = append(classes, c)
+		return nil
+	})
+	return classes, err
+}
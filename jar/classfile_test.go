@@ -0,0 +1,182 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// classFileBuilder writes a minimal class file with a constant pool and no fields or methods.
+type classFileBuilder struct {
+	pool        bytes.Buffer
+	count       uint16
+	accessFlags uint16
+}
+
+func (b *classFileBuilder) utf8(s string) uint16 {
+	b.count++
+	b.pool.WriteByte(constantUtf8)
+	binary.Write(&b.pool, binary.BigEndian, uint16(len(s)))
+	b.pool.WriteString(s)
+	return b.count
+}
+
+func (b *classFileBuilder) class(name string) uint16 {
+	nameIndex := b.utf8(name)
+	b.count++
+	b.pool.WriteByte(constantClass)
+	binary.Write(&b.pool, binary.BigEndian, nameIndex)
+	return b.count
+}
+
+func (b *classFileBuilder) long(v uint64) {
+	b.count += 2
+	b.pool.WriteByte(constantLong)
+	binary.Write(&b.pool, binary.BigEndian, v)
+}
+
+func (b *classFileBuilder) bytes(thisClass uint16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint32(classFileMagic))
+	binary.Write(&buf, binary.BigEndian, uint16(0))  // minor_version
+	binary.Write(&buf, binary.BigEndian, uint16(52)) // major_version
+	binary.Write(&buf, binary.BigEndian, b.count+1)
+	buf.Write(b.pool.Bytes())
+	accessFlags := b.accessFlags
+	if accessFlags == 0 {
+		accessFlags = 0x21
+	}
+	binary.Write(&buf, binary.BigEndian, accessFlags)
+	binary.Write(&buf, binary.BigEndian, thisClass)
+	return buf.Bytes()
+}
+
+func TestParseClassFile(t *testing.T) {
+	b := &classFileBuilder{}
+	this := b.class("com/example/Foo")
+	b.class("java/lang/Object")
+	b.long(42)
+	b.class("com/example/dep/Bar")
+	b.class("[Lcom/example/dep/Array;")
+	b.utf8("(ILcom/example/dep/Param;[J)Lcom/example/dep/Result;")
+	b.utf8("Ljava/util/List<Lcom/example/dep/Element;>;")
+	b.utf8("Lcom/example/Foo;")
+	b.utf8("TLabel;")
+
+	c, err := ParseClassFile(bytes.NewReader(b.bytes(this)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Name != "com/example/Foo" {
+		t.Errorf("expected name com/example/Foo, got %q", c.Name)
+	}
+	expected := []string{
+		"com/example/dep/Array",
+		"com/example/dep/Bar",
+		"com/example/dep/Element",
+		"com/example/dep/Param",
+		"com/example/dep/Result",
+		"java/lang/Object",
+		"java/util/List",
+	}
+	if !reflect.DeepEqual(c.References, expected) {
+		t.Errorf("expected references:\n%q\ngot:\n%q", expected, c.References)
+	}
+}
+
+func TestParseClassFileUnreferencedUses(t *testing.T) {
+	constants := &classFileBuilder{}
+	this := constants.class("com/example/Constants")
+	constants.utf8("ConstantValue")
+
+	annotation := &classFileBuilder{accessFlags: 0x2601}
+	annotationClass := annotation.class("com/example/SourceAnnotation")
+	annotation.utf8("Ljava/lang/annotation/Retention;")
+	annotation.utf8("Ljava/lang/annotation/RetentionPolicy;")
+	annotation.utf8("SOURCE")
+
+	runtimeAnnotation := &classFileBuilder{accessFlags: 0x2601}
+	runtimeAnnotationClass := runtimeAnnotation.class("com/example/RuntimeAnnotation")
+	runtimeAnnotation.utf8("Ljava/lang/annotation/Retention;")
+	runtimeAnnotation.utf8("Ljava/lang/annotation/RetentionPolicy;")
+	runtimeAnnotation.utf8("RUNTIME")
+
+	testCases := []struct {
+		name            string
+		data            []byte
+		hasConstants    bool
+		sourceRetention bool
+	}{
+		{
+			name:         "constants",
+			data:         constants.bytes(this),
+			hasConstants: true,
+		},
+		{
+			name:            "source retention annotation",
+			data:            annotation.bytes(annotationClass),
+			sourceRetention: true,
+		},
+		{
+			name: "runtime retention annotation",
+			data: runtimeAnnotation.bytes(runtimeAnnotationClass),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := ParseClassFile(bytes.NewReader(tc.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.HasConstants != tc.hasConstants {
+				t.Errorf("expected HasConstants %v, got %v", tc.hasConstants, c.HasConstants)
+			}
+			if c.SourceRetention != tc.sourceRetention {
+				t.Errorf("expected SourceRetention %v, got %v", tc.sourceRetention, c.SourceRetention)
+			}
+		})
+	}
+}
+
+func TestParseClassFileErrors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data []byte
+	}{
+		{
+			name: "bad magic",
+			data: []byte{0xde, 0xad, 0xbe, 0xef, 0, 0, 0, 52, 0, 1, 0, 0, 0, 0},
+		},
+		{
+			name: "truncated",
+			data: []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52, 0, 2, constantUtf8, 0, 10},
+		},
+		{
+			name: "unknown tag",
+			data: []byte{0xca, 0xfe, 0xba, 0xbe, 0, 0, 0, 52, 0, 2, 99, 0, 0, 0, 0},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := ParseClassFile(bytes.NewReader(tc.data)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
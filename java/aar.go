@@ -112,6 +112,12 @@ type aapt struct {
 	LoggingParent           string
 	resourceFiles           android.Paths
 
+	// the manifest and the resource and asset directories in the source tree, for the
+	// idea-project goal.
+	manifestSrcPath android.Path
+	resourceDirs    android.Paths
+	assetDirs       android.Paths
+
 	splitNames []string
 	splits     []split
 
@@ -179,6 +185,8 @@ func (a *aapt) aapt2Flags(ctx android.ModuleContext, sdkContext android.SdkConte
 	assetDirs := android.PathsWithOptionalDefaultForModuleSrc(ctx, a.aaptProperties.Asset_dirs, "assets")
 	resourceDirs := android.PathsWithOptionalDefaultForModuleSrc(ctx, a.aaptProperties.Resource_dirs, "res")
 	resourceZips := android.PathsForModuleSrc(ctx, a.aaptProperties.Resource_zips)
+	a.resourceDirs = resourceDirs
+	a.assetDirs = assetDirs
 
 	// Glob directories into lists of paths
 	for _, dir := range resourceDirs {
@@ -279,6 +287,7 @@ func (a *aapt) buildActions(ctx android.ModuleContext, sdkContext android.SdkCon
 	// App manifest file
 	manifestFile := proptools.StringDefault(a.aaptProperties.Manifest, "AndroidManifest.xml")
 	manifestSrcPath := android.PathForModuleSrc(ctx, manifestFile)
+	a.manifestSrcPath = manifestSrcPath
 
 	manifestPath := ManifestFixer(ctx, manifestSrcPath, ManifestFixerParams{
 		SdkContext:            sdkContext,
@@ -556,6 +565,12 @@ func (a *AndroidLibrary) GenerateAndroidBuildActions(ctx android.ModuleContext)
 	if a.androidLibraryProperties.BuildAAR {
 		BuildAAR(ctx, a.aarFile, a.outputFile, a.manifestPath, a.rTxt, res)
 		ctx.CheckbuildFile(a.aarFile)
+		mavenAar := a.aarFile
+		if classesJar := a.mavenClassesJar(ctx); classesJar != nil {
+			mavenAar = android.PathForModuleOut(ctx, "maven", ctx.ModuleName()+".aar")
+			BuildAAR(ctx, mavenAar, classesJar, a.manifestPath, a.rTxt, res)
+		}
+		a.mavenPublicationBuildActions(ctx, mavenAar, "aar")
 	}
 
 	a.exportedProguardFlagFiles = append(a.exportedProguardFlagFiles,
@@ -584,7 +599,8 @@ func AndroidLibraryFactory() android.Module {
 	module.Module.addHostAndDeviceProperties()
 	module.AddProperties(
 		&module.aaptProperties,
-		&module.androidLibraryProperties)
+		&module.androidLibraryProperties,
+		&module.mavenPublicationProperties)
 
 	module.androidLibraryProperties.BuildAAR = true
 	module.Module.linter.library = true
@@ -415,6 +415,10 @@ func (app *AndroidApp) AndroidMkEntries() []android.AndroidMkEntries {
 					fmt.Fprintf(w, "$(call dist-for-goals,%s,%s:%s/$(notdir %s))\n",
 						app.installApkName, app.javaApiUsedByOutputFile.String(), "java_apis_used_by_apex", app.javaApiUsedByOutputFile.String())
 				}
+				if app.appBundleFile != nil {
+					fmt.Fprintf(w, "$(call dist-for-goals,apps_only,%s:%s)\n",
+						app.appBundleFile.String(), app.appBundleFile.Base())
+				}
 			},
 		}},
 	}
@@ -18,6 +18,7 @@ package java
 // related module types, including their override variants.
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 
@@ -106,6 +107,24 @@ type appProperties struct {
 	// Prefer using other specific properties if build behaviour must be changed; avoid using this
 	// flag for anything but neverallow rules (unless the behaviour change is invisible to owners).
 	Updatable *bool
+
+	// Properties for building an Android App Bundle (.aab) from the app.
+	Bundle struct {
+		// If true, build an Android App Bundle containing the resources, assets, dex files and
+		// native libraries for all the ABIs of the app, available with the ".aab" output tag.
+		// The bundle is not signed.  Defaults to false.
+		Enabled *bool
+
+		// Path to the BundleConfig.json file passed to bundletool, for example to configure how
+		// the bundle is split into APKs or which of its files are stored uncompressed.
+		Config *string `android:"path"`
+
+		// android_app modules to include in the bundle as dynamic feature modules.  The name of a
+		// feature module in the bundle is the name of the android_app module with the characters
+		// other than letters, digits and underscores replaced with underscores, and must match the
+		// split attribute of its manifest.
+		Feature_modules []string
+	}
 }
 
 // android_app properties that can be overridden by override_android_app
@@ -154,7 +173,8 @@ type AndroidApp struct {
 	embeddedJniLibs          bool
 	jniCoverageOutputs       android.Paths
 
-	bundleFile android.Path
+	bundleFile    android.Path
+	appBundleFile android.Path
 
 	// the install APK name is normally the same as the module name, but can be overridden with PRODUCT_PACKAGE_NAME_OVERRIDES.
 	installApkName string
@@ -252,6 +272,13 @@ func (a *AndroidApp) DepsMutator(ctx android.BottomUpMutatorContext) {
 	}
 
 	a.usesLibrary.deps(ctx, sdkDep.hasFrameworkLibs())
+
+	if len(a.appProperties.Bundle.Feature_modules) > 0 {
+		if !Bool(a.appProperties.Bundle.Enabled) {
+			ctx.PropertyErrorf("bundle.feature_modules", "requires bundle.enabled to be true")
+		}
+		ctx.AddVariationDependencies(nil, bundleFeatureTag, a.appProperties.Bundle.Feature_modules...)
+	}
 }
 
 func (a *AndroidApp) OverridablePropertiesDepsMutator(ctx android.BottomUpMutatorContext) {
@@ -643,8 +670,16 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	a.linter.resources = a.aapt.resourceFiles
 	a.linter.buildModuleReportZip = ctx.Config().UnbundledBuildApps()
 
+	a.dexpreopter.baselineProfile = a.mergeBaselineProfiles(ctx)
+
 	dexJarFile := a.dexBuildActions(ctx)
 
+	var baselineProfile, baselineProfileZip android.Path
+	if a.dexpreopter.baselineProfile.Valid() && dexJarFile != nil {
+		baselineProfile, baselineProfileZip = compileBaselineProfile(ctx,
+			a.dexpreopter.baselineProfile.Path(), dexJarFile)
+	}
+
 	jniLibs, certificates := collectAppDeps(ctx, a, a.shouldEmbedJnis(ctx), !Bool(a.appProperties.Jni_uses_platform_apis))
 	jniJarFile := a.jniBuildActions(jniLibs, ctx)
 
@@ -667,7 +702,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 	rotationMinSdkVersion := String(a.overridableAppProperties.RotationMinSdkVersion)
 
-	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, Bool(a.dexProperties.Optimize.Shrink_resources))
+	CreateAndSignAppPackage(ctx, packageFile, a.exportPackage, jniJarFile, dexJarFile, baselineProfileZip, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, Bool(a.dexProperties.Optimize.Shrink_resources))
 	a.outputFile = packageFile
 	if v4SigningRequested {
 		a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
@@ -696,7 +731,7 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 		if v4SigningRequested {
 			v4SignatureFile = android.PathForModuleOut(ctx, a.installApkName+"_"+split.suffix+".apk.idsig")
 		}
-		CreateAndSignAppPackage(ctx, packageFile, split.path, nil, nil, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, false)
+		CreateAndSignAppPackage(ctx, packageFile, split.path, nil, nil, nil, certificates, apkDeps, v4SignatureFile, lineageFile, rotationMinSdkVersion, false)
 		a.extraOutputFiles = append(a.extraOutputFiles, packageFile)
 		if v4SigningRequested {
 			a.extraOutputFiles = append(a.extraOutputFiles, v4SignatureFile)
@@ -704,10 +739,22 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	}
 
 	// Build an app bundle.
+	var bundleJniJarFile android.Path = jniJarFile
+	if Bool(a.appProperties.Bundle.Enabled) && jniJarFile == nil && len(jniLibs) > 0 {
+		// Android App Bundles always contain the native libraries, even when the APK doesn't.
+		bundleJniLibs, _ := collectAppDeps(ctx, a, true, false)
+		bundleJniJar := android.PathForModuleOut(ctx, "bundle", "jnilibs.zip")
+		TransformJniLibsToJar(ctx, bundleJniJar, bundleJniLibs, false)
+		bundleJniJarFile = bundleJniJar
+	}
 	bundleFile := android.PathForModuleOut(ctx, "base.zip")
-	BuildBundleModule(ctx, bundleFile, a.exportPackage, jniJarFile, dexJarFile)
+	BuildBundleModule(ctx, bundleFile, a.exportPackage, bundleJniJarFile, dexJarFile)
 	a.bundleFile = bundleFile
 
+	if Bool(a.appProperties.Bundle.Enabled) {
+		a.appBundleFile = a.buildAppBundle(ctx, bundleFile, baselineProfile)
+	}
+
 	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
 
 	// Install the app package.
@@ -725,6 +772,50 @@ func (a *AndroidApp) generateAndroidBuildActions(ctx android.ModuleContext) {
 	a.buildAppDependencyInfo(ctx)
 }
 
+// buildAppBundle builds an Android App Bundle from the base module of the app and the modules of
+// its dynamic features.
+func (a *AndroidApp) buildAppBundle(ctx android.ModuleContext, baseModule, baselineProfile android.Path) android.Path {
+	modules := android.Paths{baseModule}
+	ctx.VisitDirectDepsWithTag(bundleFeatureTag, func(module android.Module) {
+		feature, ok := module.(*AndroidApp)
+		if !ok {
+			ctx.PropertyErrorf("bundle.feature_modules", "%q is not an android_app module",
+				ctx.OtherModuleName(module))
+			return
+		}
+		// bundletool names the modules of the bundle after their files.
+		name := bundleModuleName(ctx.OtherModuleName(module))
+		featureModule := android.PathForModuleOut(ctx, "bundle", "features", name+".zip")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        android.Cp,
+			Input:       feature.bundleFile,
+			Output:      featureModule,
+			Description: "bundle feature " + name,
+		})
+		modules = append(modules, featureModule)
+	})
+
+	var bundleConfig android.Path
+	if config := String(a.appProperties.Bundle.Config); config != "" {
+		bundleConfig = android.PathForModuleSrc(ctx, config)
+	}
+
+	appBundleFile := android.PathForModuleOut(ctx, a.installApkName+".aab")
+	BuildAppBundle(ctx, appBundleFile, modules, bundleConfig, baselineProfile)
+	return appBundleFile
+}
+
+// bundleModuleName returns the name of the module of an Android App Bundle built from an
+// android_app module.
+func bundleModuleName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
 type appDepsInterface interface {
 	SdkVersion(ctx android.EarlyModuleContext) android.SdkSpec
 	MinSdkVersion(ctx android.EarlyModuleContext) android.SdkSpec
@@ -887,6 +978,11 @@ func (a *AndroidApp) OutputFiles(tag string) (android.Paths, error) {
 		return []android.Path{a.aaptSrcJar}, nil
 	case ".export-package.apk":
 		return []android.Path{a.exportPackage}, nil
+	case ".aab":
+		if a.appBundleFile == nil {
+			return nil, fmt.Errorf("%q does not build an app bundle, set bundle.enabled", a.Name())
+		}
+		return []android.Path{a.appBundleFile}, nil
 	}
 	return a.Library.OutputFiles(tag)
 }
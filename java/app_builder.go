@@ -52,7 +52,7 @@ var combineApk = pctx.AndroidStaticRule("combineApk",
 	})
 
 func CreateAndSignAppPackage(ctx android.ModuleContext, outputFile android.WritablePath,
-	packageFile, jniJarFile, dexJarFile android.Path, certificates []Certificate, deps android.Paths, v4SignatureFile android.WritablePath, lineageFile android.Path, rotationMinSdkVersion string, shrinkResources bool) {
+	packageFile, jniJarFile, dexJarFile, baselineProfileZip android.Path, certificates []Certificate, deps android.Paths, v4SignatureFile android.WritablePath, lineageFile android.Path, rotationMinSdkVersion string, shrinkResources bool) {
 
 	unsignedApkName := strings.TrimSuffix(outputFile.Base(), ".apk") + "-unsigned.apk"
 	unsignedApk := android.PathForModuleOut(ctx, unsignedApkName)
@@ -65,6 +65,9 @@ func CreateAndSignAppPackage(ctx android.ModuleContext, outputFile android.Writa
 	if jniJarFile != nil {
 		inputs = append(inputs, jniJarFile)
 	}
+	if baselineProfileZip != nil {
+		inputs = append(inputs, baselineProfileZip)
+	}
 	ctx.Build(pctx, android.BuildParams{
 		Rule:      combineApk,
 		Inputs:    inputs,
@@ -156,7 +159,7 @@ func BuildAAR(ctx android.ModuleContext, outputFile android.WritablePath,
 			"manifest":   manifest.String(),
 			"classesJar": classesJarPath,
 			"rTxt":       rTxt.String(),
-			"outDir":     android.PathForModuleOut(ctx, "aar").String(),
+			"outDir":     filepath.Join(filepath.Dir(outputFile.String()), "aar"),
 		},
 	})
 }
@@ -225,6 +228,42 @@ func BuildBundleModule(ctx android.ModuleContext, outputFile android.WritablePat
 	})
 }
 
+var buildAppBundle = pctx.AndroidStaticRule("buildAppBundle",
+	blueprint.RuleParams{
+		Command: `rm -f $out && ${config.JavaCmd} -jar ${config.BundletoolJar} build-bundle ` +
+			`--modules=$modules $flags --output=$out`,
+		CommandDeps: []string{"${config.JavaCmd}", "${config.BundletoolJar}"},
+	}, "modules", "flags")
+
+// BuildAppBundle builds an Android App Bundle from the modules built by BuildBundleModule with
+// bundletool.  The module built from the app itself must be named base.zip.
+func BuildAppBundle(ctx android.ModuleContext, outputFile android.WritablePath,
+	modules android.Paths, bundleConfig, baselineProfile android.Path) {
+
+	var flags []string
+	var implicits android.Paths
+	if bundleConfig != nil {
+		flags = append(flags, "--config="+bundleConfig.String())
+		implicits = append(implicits, bundleConfig)
+	}
+	if baselineProfile != nil {
+		flags = append(flags, "--metadata-file=com.android.tools.build.profiles:baseline.prof:"+baselineProfile.String())
+		implicits = append(implicits, baselineProfile)
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        buildAppBundle,
+		Inputs:      modules,
+		Implicits:   implicits,
+		Output:      outputFile,
+		Description: "app bundle",
+		Args: map[string]string{
+			"modules": strings.Join(modules.Strings(), ","),
+			"flags":   strings.Join(flags, " "),
+		},
+	})
+}
+
 func TransformJniLibsToJar(ctx android.ModuleContext, outputFile android.WritablePath,
 	jniLibs []jniLib, uncompressJNI bool) {
 
@@ -717,6 +717,155 @@ func TestAppJavaResources(t *testing.T) {
 	}
 }
 
+func TestAppBundle(t *testing.T) {
+	ctx, _ := testJava(t, cc.GatherRequiredDepsForTest(android.Android)+`
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			jni_libs: ["libjni"],
+			bundle: {
+				enabled: true,
+				config: "BundleConfig.json",
+				feature_modules: ["foo-feature"],
+			},
+		}
+
+		android_app {
+			name: "foo-feature",
+			srcs: ["b.java"],
+			sdk_version: "current",
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["c.java"],
+			sdk_version: "current",
+			jni_libs: ["libjni"],
+		}
+
+		cc_library {
+			name: "libjni",
+			stl: "none",
+			system_shared_libs: [],
+			sdk_version: "current",
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	feature := ctx.ModuleForTests("foo-feature", "android_common")
+
+	// Test that the bundle is built from the base module of foo and the copy of the base module of
+	// the feature named after the feature.
+	bundle := foo.Rule("buildAppBundle")
+	android.AssertPathRelativeToTopEquals(t, "bundle", "out/soong/.intermediates/foo/android_common/foo.aab", bundle.Output)
+	featureModule := foo.Output("bundle/features/foo_feature.zip")
+	android.AssertPathRelativeToTopEquals(t, "feature base module",
+		"out/soong/.intermediates/foo-feature/android_common/base.zip", featureModule.Input)
+	android.AssertStringEquals(t, "modules",
+		foo.Output("base.zip").Output.String()+","+featureModule.Output.String(), bundle.Args["modules"])
+	android.AssertStringEquals(t, "flags", "--config=BundleConfig.json", bundle.Args["flags"])
+
+	// Test that the native libraries are in the bundle even though they aren't embedded in the APK.
+	jniJar := foo.Output("bundle/jnilibs.zip")
+	android.AssertStringListContains(t, "base module inputs", foo.Output("base.zip").Inputs.Strings(), jniJar.Output.String())
+	if foo.MaybeOutput("jnilibs.zip").Rule != nil {
+		t.Errorf("expected no native libraries in the APK")
+	}
+
+	// Test that the bundle is available with the ".aab" tag.
+	app := foo.Module().(*AndroidApp)
+	outputs, err := app.OutputFiles(".aab")
+	android.AssertDeepEquals(t, "errors", nil, err)
+	android.AssertPathsRelativeToTopEquals(t, ".aab outputs",
+		[]string{"out/soong/.intermediates/foo/android_common/foo.aab"}, outputs)
+
+	// Test that no bundle is built by default.
+	if ctx.ModuleForTests("bar", "android_common").MaybeRule("buildAppBundle").Rule != nil {
+		t.Errorf("expected no app bundle for bar")
+	}
+	if feature.MaybeRule("buildAppBundle").Rule != nil {
+		t.Errorf("expected no app bundle for foo-feature")
+	}
+}
+
+func TestAppBundleFeatureModulesRequireBundle(t *testing.T) {
+	testJavaError(t, `bundle.feature_modules: requires bundle.enabled to be true`, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			bundle: {
+				feature_modules: ["bar"],
+			},
+		}
+
+		android_app {
+			name: "bar",
+			srcs: ["b.java"],
+			sdk_version: "current",
+		}
+	`)
+}
+
+func TestAppBaselineProfiles(t *testing.T) {
+	ctx, _ := testJava(t, `
+		android_app {
+			name: "foo",
+			srcs: ["a.java"],
+			sdk_version: "current",
+			baseline_profiles: ["foo-prof.txt"],
+			static_libs: ["bar"],
+			bundle: {
+				enabled: true,
+			},
+		}
+
+		android_library {
+			name: "bar",
+			srcs: ["b.java"],
+			sdk_version: "current",
+			baseline_profiles: ["bar-prof.txt"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			sdk_version: "current",
+			baseline_profiles: ["baz-prof.txt"],
+		}
+	`)
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+
+	// Test that the baseline profiles of the transitive static_libs are merged.
+	merge := foo.Rule("mergeBaselineProfiles")
+	android.AssertPathsRelativeToTopEquals(t, "merged profiles",
+		[]string{"baz-prof.txt", "bar-prof.txt", "foo-prof.txt"}, merge.Inputs)
+
+	// Test that the merged profile is compiled against the dex jar and embedded in the APK.
+	profman := foo.Output("baseline_profile/baseline.prof")
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command,
+		"--create-profile-from="+merge.Output.String())
+	dexJar := foo.Module().(*AndroidApp).dexJarFile.Path()
+	android.AssertStringDoesContain(t, "profman command", profman.RuleParams.Command,
+		"--apk="+dexJar.String())
+	profileZip := foo.Output("baseline_profile/baseline_profile.zip")
+	android.AssertStringDoesContain(t, "profile zip command", profileZip.RuleParams.Command,
+		"-P assets/dexopt")
+	android.AssertStringListContains(t, "apk inputs", foo.Output("foo-unsigned.apk").Inputs.Strings(),
+		profileZip.Output.String())
+
+	// Test that the compiled profile is added to the app bundle metadata.
+	android.AssertStringDoesContain(t, "bundle flags", foo.Rule("buildAppBundle").Args["flags"],
+		"--metadata-file=com.android.tools.build.profiles:baseline.prof:"+profman.Output.String())
+
+	// Test that the merged profile guides dexpreopt.
+	android.AssertStringDoesContain(t, "dexpreopt command", foo.Rule("dexpreopt").RuleParams.Command,
+		"--create-profile-from="+merge.Output.String())
+}
+
 func TestAndroidResources(t *testing.T) {
 	testCases := []struct {
 		name                       string
@@ -79,6 +79,11 @@ type CommonProperties struct {
 	// list of java libraries that will be compiled into the resulting jar
 	Static_libs []string `android:"arch_variant"`
 
+	// list of ART baseline profiles, text files listing the classes and methods used by the
+	// critical user journeys of the app (usually baseline-prof.txt).  The baseline profiles of
+	// a library are merged into the baseline profiles of the apps that have it in static_libs.
+	Baseline_profiles []string `android:"path"`
+
 	// manifest file to be included in resulting jar
 	Manifest *string `android:"path"`
 
@@ -99,9 +104,26 @@ type CommonProperties struct {
 	// This restriction is checked after applying jarjar rules and including static libs.
 	Permitted_packages []string
 
+	// If set to true, fail the build if the classes compiled from the sources of this module use
+	// classes from a module that is not in libs or static_libs, but is only on the classpath
+	// because one of those modules includes it in its own static_libs.
+	Strict_deps *bool
+
 	// List of modules to use as annotation processors
 	Plugins []string
 
+	// List of java_plugin modules to run as Kotlin Symbol Processing (KSP) processors over the
+	// Kotlin and Java sources of this module.  The generated sources are compiled into the module.
+	Ksp_plugins []string
+
+	// List of kotlin_plugin modules to use as compiler plugins when compiling the Kotlin sources
+	// of this module, which also apply to its header jar.
+	Kotlin_plugins []string
+
+	// Options passed to the compiler plugins in kotlin_plugins, in the form
+	// <plugin id>:<option>=<value>.
+	Kotlin_plugin_options []string
+
 	// List of modules to export to libraries that directly depend on this library as annotation
 	// processors.  Note that if the plugins set generates_api: true this will disable the turbine
 	// optimization on modules that depend on this module, which will reduce parallelism and cause
@@ -174,6 +196,10 @@ type CommonProperties struct {
 	// into the library.
 	Supports_static_instrumentation bool `blueprint:"mutated"`
 
+	// If true, a module that depends on this module through libs or static_libs, directly or
+	// transitively, has strict_deps set, and this module writes the list of its classes.
+	Strict_deps_class_list bool `blueprint:"mutated"`
+
 	// List of files to include in the META-INF/services folder of the resulting jar.
 	Services []string `android:"path,arch_variant"`
 
@@ -423,6 +449,10 @@ type Module struct {
 	// output file containing uninstrumented classes that will be instrumented by jacoco
 	jacocoReportClassesFile android.Path
 
+	// the sources of the module laid out by package for jacoco reports, when
+	// SOONG_JACOCO_COVERAGE_DATA is set.
+	jacocoReportSources android.Path
+
 	// output file of the module, which may be a classes jar or a dex jar
 	outputFile       android.Path
 	extraOutputFiles android.Paths
@@ -451,6 +481,23 @@ type Module struct {
 	// list of plugins that this java module is exporting
 	exportedPluginJars android.Paths
 
+	// list of the classes compiled from the sources of this module, and the class lists of this
+	// module and all of its transitive libs and static_libs, used for strict deps checks.
+	classList            android.Path
+	transitiveClassLists *android.DepSet
+
+	// the baseline profiles of this module and all of its transitive static_libs.
+	baselineProfiles *android.DepSet
+
+	// the jars and manifest to combine into the published Maven artifact of this module when some
+	// of its static_libs are published as Maven artifacts themselves, and are left out.
+	mavenJars     android.Paths
+	mavenManifest android.OptionalPath
+
+	// the report of the libs and static_libs that contributed no classes to this module, when
+	// SOONG_JAVA_UNUSED_DEPS is set.
+	unusedDepsReport android.OptionalPath
+
 	// list of plugins that this java module is exporting
 	exportedPluginClasses []string
 
@@ -461,6 +508,10 @@ type Module struct {
 	// will be used by android.IDEInfo struct
 	expandIDEInfoCompiledSrcs []string
 
+	// the sources and SDK version of the module for the idea-project goal, when
+	// SOONG_IDEA_MODULES is set.
+	ideaProjectInfo *ideaProjectModuleInfo
+
 	// expanded Jarjar_rules
 	expandJarjarRules android.Path
 
@@ -738,6 +789,8 @@ func (j *Module) deps(ctx android.BottomUpMutatorContext) {
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), pluginTag, j.properties.Plugins...)
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), errorpronePluginTag, j.properties.Errorprone.Extra_check_modules...)
 	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), exportedPluginTag, j.properties.Exported_plugins...)
+	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), kspPluginTag, j.properties.Ksp_plugins...)
+	ctx.AddFarVariationDependencies(ctx.Config().BuildOSCommonTarget.Variations(), kotlinPluginTag, j.properties.Kotlin_plugins...)
 
 	android.ProtoDeps(ctx, &j.protoProperties)
 	if j.hasSrcExt(".proto") {
@@ -1062,6 +1115,13 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	var kotlinJars android.Paths
 	var kotlinHeaderJars android.Paths
 
+	if len(j.properties.Ksp_plugins) > 0 && !srcFiles.HasExt(".kt") {
+		ctx.PropertyErrorf("ksp_plugins", "requires .kt sources")
+	}
+	if len(j.properties.Kotlin_plugins) > 0 && !srcFiles.HasExt(".kt") {
+		ctx.PropertyErrorf("kotlin_plugins", "requires .kt sources")
+	}
+
 	if srcFiles.HasExt(".kt") {
 		// When using kotlin sources turbine is used to generate annotation processor sources,
 		// including for annotation processors that generate API, so we can use turbine for
@@ -1087,6 +1147,7 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 			kotlincFlags = append(kotlincFlags, "-Xplugin="+plugin.String())
 		}
 		flags.kotlincDeps = append(flags.kotlincDeps, deps.kotlinPlugins...)
+		kotlincFlags = append(kotlincFlags, kotlinPluginOptionFlags(ctx, deps, j.properties.Kotlin_plugin_options)...)
 
 		if len(kotlincFlags) > 0 {
 			// optimization.
@@ -1108,6 +1169,19 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.bootClasspath...)
 		flags.kotlincClasspath = append(flags.kotlincClasspath, flags.classpath...)
 
+		// The srcjars that contain .kt sources to compile with kotlinc, which only compiles the .java
+		// sources of the other srcjars.
+		var kotlinSrcJars android.Paths
+		if len(deps.kspProcessorPath) > 0 {
+			// Run KSP before kapt and kotlinc so that the generated sources are visible to both
+			kspSrcJar := android.PathForModuleOut(ctx, "ksp", "ksp-sources.jar")
+			kspResJar := android.PathForModuleOut(ctx, "ksp", "ksp-res.jar")
+			kotlinKsp(ctx, kspSrcJar, kspResJar, kotlinSrcFiles, kotlinCommonSrcFiles, srcJars, deps.kspProcessorPath, flags)
+			srcJars = append(srcJars, kspSrcJar)
+			kotlinSrcJars = append(kotlinSrcJars, kspSrcJar)
+			kotlinJars = append(kotlinJars, kspResJar)
+		}
+
 		if len(flags.processorPath) > 0 {
 			// Use kapt for annotation processing
 			kaptSrcJar := android.PathForModuleOut(ctx, "kapt", "kapt-sources.jar")
@@ -1122,7 +1196,8 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 
 		kotlinJar := android.PathForModuleOut(ctx, "kotlin", jarName)
 		kotlinHeaderJar := android.PathForModuleOut(ctx, "kotlin_headers", jarName)
-		kotlinCompile(ctx, kotlinJar, kotlinHeaderJar, kotlinSrcFiles, kotlinCommonSrcFiles, srcJars, flags)
+		javaSrcJars, _ := android.FilterPathList(srcJars, kotlinSrcJars)
+		kotlinCompile(ctx, kotlinJar, kotlinHeaderJar, kotlinSrcFiles, kotlinCommonSrcFiles, javaSrcJars, kotlinSrcJars, flags)
 		if ctx.Failed() {
 			return
 		}
@@ -1150,6 +1225,7 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 	// Store the list of .java files that was passed to javac
 	j.compiledJavaSrcs = uniqueSrcFiles
 	j.compiledSrcJars = srcJars
+	j.setIdeaProjectInfo(ctx, srcFiles, kotlinCommonSrcFiles, srcJars)
 
 	enableSharding := false
 	var headerJarFileWithoutDepsOrJarjar android.Path
@@ -1218,6 +1294,24 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	// The jars compiled from the sources of this module, before any static libraries are added.
+	compiledJars := append(android.Paths(nil), jars...)
+	if j.properties.Strict_deps_class_list && len(compiledJars) > 0 {
+		classList := android.PathForModuleOut(ctx, "strict_deps", "classes.list")
+		TransformJarsToClassList(ctx, classList, compiledJars)
+		j.classList = classList
+	}
+	j.transitiveClassLists = android.NewDepSet(android.POSTORDER,
+		android.PathsIfNonNil(j.classList), deps.transitiveClassLists)
+	j.baselineProfiles = android.NewDepSet(android.POSTORDER,
+		android.PathsForModuleSrc(ctx, j.properties.Baseline_profiles), deps.baselineProfiles)
+
+	if unusedDepsEnabled(ctx.Config()) && len(compiledJars) > 0 && len(deps.declaredDeps) > 0 {
+		unusedDepsReport := android.PathForModuleOut(ctx, "unused_deps", "report.json")
+		TransformJarsToUnusedDepsReport(ctx, unusedDepsReport, compiledJars, deps.declaredDeps)
+		j.unusedDepsReport = android.OptionalPathForPath(unusedDepsReport)
+	}
+
 	j.srcJarArgs, j.srcJarDeps = resourcePathsToJarArgs(srcFiles), srcFiles
 
 	var includeSrcJar android.WritablePath
@@ -1304,6 +1398,12 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		jars = append(jars, servicesJar)
 	}
 
+	if len(deps.mavenPublishedStaticJars) > 0 {
+		allJars := append(append(android.Paths(nil), jars...), resourceJars...)
+		j.mavenJars, _ = android.FilterPathList(allJars, deps.mavenPublishedStaticJars)
+		j.mavenManifest = manifest
+	}
+
 	// Combine the classes built from sources, any manifests, and any static libraries into
 	// classes.jar. If there is only one input jar this step will be skipped.
 	var outputFile android.OutputPath
@@ -1386,6 +1486,26 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		}
 	}
 
+	// Check that the compiled classes only use classes from direct dependencies if necessary.
+	if Bool(j.properties.Strict_deps) && len(compiledJars) > 0 {
+		// Time stamp file created by the strict deps check rule.
+		strictDepsFile := android.PathForModuleOut(ctx, "strict_deps", "check.stamp")
+
+		// As with the package check, copy the output jar to another path with a validate dependency
+		// on the strict deps check, and make the new location the output file of this module.
+		inputFile := outputFile
+		outputFile = android.PathForModuleOut(ctx, "strict_deps", jarName).OutputPath
+		ctx.Build(pctx, android.BuildParams{
+			Rule:       android.Cp,
+			Input:      inputFile,
+			Output:     outputFile,
+			Validation: strictDepsFile,
+		})
+
+		CheckStrictDeps(ctx, strictDepsFile, compiledJars, deps.directClassLists,
+			android.NewDepSet(android.POSTORDER, nil, deps.transitiveClassLists).ToList())
+	}
+
 	j.implementationJarFile = outputFile
 	if j.headerJarFile == nil {
 		j.headerJarFile = j.implementationJarFile
@@ -1523,6 +1643,9 @@ func (j *Module) compile(ctx android.ModuleContext, aaptSrcJar android.Path) {
 		ExportedPluginClasses:          j.exportedPluginClasses,
 		ExportedPluginDisableTurbine:   j.exportedDisableTurbine,
 		JacocoReportClassesFile:        j.jacocoReportClassesFile,
+		ClassList:                      j.classList,
+		TransitiveClassLists:           j.transitiveClassLists,
+		BaselineProfiles:               j.baselineProfiles,
 	})
 
 	// Save the output file with no relative path so that it doesn't end up in a subdirectory when used as a resource
@@ -1645,6 +1768,7 @@ func (j *Module) instrument(ctx android.ModuleContext, flags javaBuilderFlags,
 	jacocoInstrumentJar(ctx, instrumentedJar, jacocoReportClassesFile, classesJar, specs)
 
 	j.jacocoReportClassesFile = jacocoReportClassesFile
+	j.buildJacocoReportSources(ctx)
 
 	return instrumentedJar
 }
@@ -1896,6 +2020,7 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				depHeaderJars := dep.SdkHeaderJars(ctx, j.SdkVersion(ctx))
 				deps.classpath = append(deps.classpath, depHeaderJars...)
 				deps.dexClasspath = append(deps.dexClasspath, depHeaderJars...)
+				j.addDeclaredDep(ctx, &deps, "libs", module, depHeaderJars)
 			case staticLibTag:
 				ctx.ModuleErrorf("dependency on java_sdk_library %q can only be in libs", otherName)
 			}
@@ -1916,8 +2041,12 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.classpath = append(deps.classpath, dep.HeaderJars...)
 				deps.dexClasspath = append(deps.dexClasspath, dep.HeaderJars...)
 				deps.aidlIncludeDirs = append(deps.aidlIncludeDirs, dep.AidlIncludeDirs...)
+				addClassLists(&deps, dep)
 				addPlugins(&deps, dep.ExportedPlugins, dep.ExportedPluginClasses...)
 				deps.disableTurbine = deps.disableTurbine || dep.ExportedPluginDisableTurbine
+				if tag == libTag && len(dep.ExportedPlugins) == 0 {
+					j.addDeclaredDep(ctx, &deps, "libs", module, dep.HeaderJars)
+				}
 			case java9LibTag:
 				deps.java9Classpath = append(deps.java9Classpath, dep.HeaderJars...)
 			case staticLibTag:
@@ -1926,7 +2055,18 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.staticHeaderJars = append(deps.staticHeaderJars, dep.HeaderJars...)
 				deps.staticResourceJars = append(deps.staticResourceJars, dep.ResourceJars...)
 				deps.aidlIncludeDirs = append(deps.aidlIncludeDirs, dep.AidlIncludeDirs...)
+				addClassLists(&deps, dep)
+				if ctx.OtherModuleHasProvider(module, MavenPublicationInfoProvider) {
+					deps.mavenPublishedStaticJars = append(deps.mavenPublishedStaticJars, dep.ImplementationJars...)
+					deps.mavenPublishedStaticJars = append(deps.mavenPublishedStaticJars, dep.ResourceJars...)
+				}
+				if dep.BaselineProfiles != nil {
+					deps.baselineProfiles = append(deps.baselineProfiles, dep.BaselineProfiles)
+				}
 				addPlugins(&deps, dep.ExportedPlugins, dep.ExportedPluginClasses...)
+				if len(dep.ExportedPlugins) == 0 {
+					j.addDeclaredDep(ctx, &deps, "static_libs", module, dep.HeaderJars)
+				}
 				// Turbine doesn't run annotation processors, so any module that uses an
 				// annotation processor that generates API is incompatible with the turbine
 				// optimization.
@@ -1970,6 +2110,20 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 				deps.kotlinAnnotations = dep.HeaderJars
 			case kotlinPluginTag:
 				deps.kotlinPlugins = append(deps.kotlinPlugins, dep.ImplementationAndResourcesJars...)
+				if plugin, ok := module.(*KotlinPlugin); ok {
+					if id := String(plugin.kotlinPluginProperties.Plugin_id); id != "" {
+						deps.kotlinPluginIds = append(deps.kotlinPluginIds, id)
+					}
+					deps.kotlinPluginOptions = append(deps.kotlinPluginOptions, plugin.pluginOptions()...)
+				} else if android.InList(otherName, j.properties.Kotlin_plugins) {
+					ctx.PropertyErrorf("kotlin_plugins", "%q is not a kotlin_plugin module", otherName)
+				}
+			case kspPluginTag:
+				if _, ok := module.(*Plugin); ok {
+					deps.kspProcessorPath = append(deps.kspProcessorPath, dep.ImplementationAndResourcesJars...)
+				} else {
+					ctx.PropertyErrorf("ksp_plugins", "%q is not a java_plugin module", otherName)
+				}
 			case syspropPublicStubDepTag:
 				// This is a sysprop implementation library, forward the JavaInfoProvider from
 				// the corresponding sysprop public stub library as SyspropPublicStubInfoProvider.
@@ -2016,6 +2170,22 @@ func (j *Module) collectDeps(ctx android.ModuleContext) deps {
 	return deps
 }
 
+func (j *Module) strictDepsClassListsNeeded() bool {
+	return Bool(j.properties.Strict_deps) || j.properties.Strict_deps_class_list
+}
+
+func (j *Module) setStrictDepsClassList() {
+	j.properties.Strict_deps_class_list = true
+}
+
+// addClassLists adds the strict deps class lists of a direct libs or static_libs dependency.
+func addClassLists(deps *deps, dep JavaInfo) {
+	deps.directClassLists = append(deps.directClassLists, android.PathsIfNonNil(dep.ClassList)...)
+	if dep.TransitiveClassLists != nil {
+		deps.transitiveClassLists = append(deps.transitiveClassLists, dep.TransitiveClassLists)
+	}
+}
+
 func addPlugins(deps *deps, pluginJars android.Paths, pluginClasses ...string) {
 	deps.processorPath = append(deps.processorPath, pluginJars...)
 	deps.processorClasses = append(deps.processorClasses, pluginClasses...)
@@ -0,0 +1,86 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// The baseline profiles of an app and of its transitive static_libs are merged, compiled by
+// profman against the dex files of the app, and embedded in the APK as assets/dexopt/baseline.prof,
+// where ProfileInstaller finds them, and in the app bundle as bundle metadata.  The merged profile
+// also guides dexpreopt when there is no other profile for the app.
+
+var mergeBaselineProfilesRule = pctx.AndroidStaticRule("mergeBaselineProfiles",
+	blueprint.RuleParams{
+		// awk adds the missing newlines at the end of the files.
+		Command: `awk 1 $in > $out`,
+	})
+
+// mergeBaselineProfiles merges the baseline profiles of the app and of its transitive
+// static_libs, and returns an invalid path if there are none.
+func (a *AndroidApp) mergeBaselineProfiles(ctx android.ModuleContext) android.OptionalPath {
+	var transitive []*android.DepSet
+	ctx.VisitDirectDepsWithTag(staticLibTag, func(module android.Module) {
+		if ctx.OtherModuleHasProvider(module, JavaInfoProvider) {
+			dep := ctx.OtherModuleProvider(module, JavaInfoProvider).(JavaInfo)
+			if dep.BaselineProfiles != nil {
+				transitive = append(transitive, dep.BaselineProfiles)
+			}
+		}
+	})
+	profiles := android.NewDepSet(android.POSTORDER,
+		android.PathsForModuleSrc(ctx, a.properties.Baseline_profiles), transitive).ToList()
+	if len(profiles) == 0 {
+		return android.OptionalPath{}
+	}
+
+	merged := android.PathForModuleOut(ctx, "baseline_profile", "baseline-prof.txt")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        mergeBaselineProfilesRule,
+		Description: "merge baseline profiles",
+		Inputs:      profiles,
+		Output:      merged,
+	})
+	return android.OptionalPathForPath(merged)
+}
+
+// compileBaselineProfile compiles a baseline profile against the dex files of an app, and returns
+// the binary profile and a zip containing it at the path where it is embedded in the APK.
+func compileBaselineProfile(ctx android.ModuleContext, profile, dexJarFile android.Path) (android.Path, android.Path) {
+	binaryProfile := android.PathForModuleOut(ctx, "baseline_profile", "baseline.prof")
+	profileZip := android.PathForModuleOut(ctx, "baseline_profile", "baseline_profile.zip")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().
+		Text(`ANDROID_LOG_TAGS="*:e"`).
+		BuiltTool("profman").
+		FlagWithInput("--create-profile-from=", profile).
+		Flag("--output-profile-type=app").
+		FlagWithInput("--apk=", dexJarFile).
+		Flag("--dex-location=base.apk").
+		FlagWithOutput("--reference-profile-file=", binaryProfile)
+	rule.Command().
+		BuiltTool("soong_zip").
+		FlagWithOutput("-o ", profileZip).
+		FlagWithArg("-P ", "assets/dexopt").
+		Flag("-j").
+		FlagWithInput("-f ", binaryProfile)
+	rule.Build("baseline_profile", "baseline profile")
+
+	return binaryProfile, profileZip
+}
@@ -219,6 +219,24 @@ var (
 		},
 		"packages")
 
+	strictDepsClassList = pctx.AndroidStaticRule("strictDepsClassList",
+		blueprint.RuleParams{
+			Command:     "${config.JavaStrictDepsCmd} classes -module $module -o $out $in",
+			CommandDeps: []string{"${config.JavaStrictDepsCmd}"},
+		},
+		"module")
+
+	strictDepsCheck = pctx.AndroidStaticRule("strictDepsCheck",
+		blueprint.RuleParams{
+			Command: "rm -f $out && " +
+				"${config.JavaStrictDepsCmd} check -module $module -o $out $directClassLists " +
+				"-transitive @$out.rsp $in",
+			CommandDeps:    []string{"${config.JavaStrictDepsCmd}"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$transitiveClassLists",
+		},
+		"module", "directClassLists", "transitiveClassLists")
+
 	jetifier = pctx.AndroidStaticRule("jetifier",
 		blueprint.RuleParams{
 			Command:     "${config.JavaCmd}  ${config.JavaVmFlags} -jar ${config.JetifierJar} -l error -o $out -i $in",
@@ -617,6 +635,46 @@ func CheckJarPackages(ctx android.ModuleContext, outputFile android.WritablePath
 	})
 }
 
+// TransformJarsToClassList writes the list of classes in the jars that were compiled from the
+// sources of a module, for use by the strict deps checks of the modules that depend on it.
+func TransformJarsToClassList(ctx android.ModuleContext, outputFile android.WritablePath,
+	jars android.Paths) {
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        strictDepsClassList,
+		Description: "strict deps class list",
+		Output:      outputFile,
+		Inputs:      jars,
+		Args: map[string]string{
+			"module": ctx.ModuleName(),
+		},
+	})
+}
+
+// CheckStrictDeps creates a rule that touches outputFile if the classes in jars only use classes
+// from the module itself and from the modules whose class lists are in directClassLists.  It
+// fails with the modules to add to libs or static_libs if the classes use classes that are only
+// in transitiveClassLists.
+func CheckStrictDeps(ctx android.ModuleContext, outputFile android.WritablePath, jars android.Paths,
+	directClassLists, transitiveClassLists android.Paths) {
+
+	var implicits android.Paths
+	implicits = append(implicits, directClassLists...)
+	implicits = append(implicits, transitiveClassLists...)
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        strictDepsCheck,
+		Description: "strict deps check",
+		Output:      outputFile,
+		Inputs:      jars,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"module":               ctx.ModuleName(),
+			"directClassLists":     android.JoinWithPrefix(directClassLists.Strings(), "-direct "),
+			"transitiveClassLists": strings.Join(transitiveClassLists.Strings(), " "),
+		},
+	})
+}
+
 func TransformJetifier(ctx android.ModuleContext, outputFile android.WritablePath,
 	inputFile android.Path) {
 	ctx.Build(pctx, android.BuildParams{
@@ -153,6 +153,11 @@ func init() {
 	pctx.SourcePathVariable("JarArgsCmd", "build/soong/scripts/jar-args.sh")
 	pctx.SourcePathVariable("PackageCheckCmd", "build/soong/scripts/package-check.sh")
 	pctx.HostBinToolVariable("ExtractJarPackagesCmd", "extract_jar_packages")
+	pctx.HostBinToolVariable("JavaStrictDepsCmd", "java_strict_deps")
+	pctx.HostBinToolVariable("JavaUnusedDepsCmd", "java_unused_deps")
+	pctx.HostBinToolVariable("LintBaselineCmd", "lint_baseline")
+	pctx.HostBinToolVariable("JacocoReportCmd", "jacoco_report")
+	pctx.HostBinToolVariable("IdeaProjectCmd", "idea_project")
 	pctx.HostBinToolVariable("SoongZipCmd", "soong_zip")
 	pctx.HostBinToolVariable("MergeZipsCmd", "merge_zips")
 	pctx.HostBinToolVariable("Zip2ZipCmd", "zip2zip")
@@ -194,6 +199,7 @@ func init() {
 	pctx.StaticVariableWithEnvOverride("REZipExecStrategy", "RBE_ZIP_EXEC_STRATEGY", remoteexec.LocalExecStrategy)
 
 	pctx.HostJavaToolVariable("JacocoCLIJar", "jacoco-cli.jar")
+	pctx.HostJavaToolVariable("BundletoolJar", "bundletool.jar")
 
 	pctx.HostBinToolVariable("ManifestCheckCmd", "manifest_check")
 	pctx.HostBinToolVariable("ManifestFixerCmd", "manifest_fixer")
@@ -92,6 +92,10 @@ type dexpreopter struct {
 	enforceUsesLibs     bool
 	classLoaderContexts dexpreopt.ClassLoaderContextMap
 
+	// The merged baseline profiles of an app, used to guide optimization when there is no other
+	// profile for the app.
+	baselineProfile android.OptionalPath
+
 	// See the `dexpreopt` function for details.
 	builtInstalled        string
 	builtInstalledForApex []dexpreopterInstall
@@ -114,8 +118,9 @@ type DexpreoptProperties struct {
 		App_image *bool
 
 		// If true, use a checked-in profile to guide optimization.  Defaults to false unless
-		// a matching profile is set or a profile is found in PRODUCT_DEX_PREOPT_PROFILE_DIR
-		// that matches the name of this module, in which case it is defaulted to true.
+		// a matching profile is set, a profile is found in PRODUCT_DEX_PREOPT_PROFILE_DIR
+		// that matches the name of this module, or the app has baseline profiles, in which case
+		// it is defaulted to true.
 		Profile_guided *bool
 
 		// If set, provides the path to profile relative to the Android.bp file.  If not set,
@@ -309,6 +314,10 @@ func (d *dexpreopter) dexpreopt(ctx android.ModuleContext, dexJarFile android.Wr
 			profileClassListing = android.ExistentPathForSource(ctx,
 				global.ProfileDir, moduleName(ctx)+".prof")
 		}
+		if !profileClassListing.Valid() && d.baselineProfile.Valid() {
+			profileClassListing = d.baselineProfile
+			profileIsTextListing = true
+		}
 	}
 
 	// Full dexpreopt config, used to create dexpreopt build rules.
@@ -0,0 +1,310 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This singleton writes an IntelliJ IDEA or Android Studio project for the modules and the
+// directories of modules listed in SOONG_IDEA_MODULES.  Each module of the project has its sources,
+// test sources, generated sources, srcjars extracted by the idea_project tool, Android manifest and
+// resources, SDK version, and its dependencies, which are the modules of the project it depends on
+// and the header jars of the others.  The project is zipped in $OUT/soong/idea/project.zip by the
+// idea-project goal, to be extracted at the top of the source tree:
+//
+//	SOONG_IDEA_MODULES=Foo,FooTests,packages/apps/Bar m idea-project
+//	unzip -o out/soong/idea/project.zip
+
+func init() {
+	registerIdeaProjectBuildComponents(android.InitRegistrationContext)
+}
+
+func registerIdeaProjectBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("idea_project", ideaProjectSingletonFactory)
+}
+
+var ideaProject = pctx.AndroidStaticRule("ideaProject",
+	blueprint.RuleParams{
+		Command:     "${config.IdeaProjectCmd} -project $in -o $out",
+		CommandDeps: []string{"${config.IdeaProjectCmd}"},
+	})
+
+func ideaProjectEnabled(config android.Config) bool {
+	return config.Getenv("SOONG_IDEA_MODULES") != ""
+}
+
+// ideaProjectSelected returns true if the module is listed in SOONG_IDEA_MODULES, or is in a
+// directory listed in it.
+func ideaProjectSelected(config android.Config, name, dir string) bool {
+	for _, entry := range strings.Split(config.Getenv("SOONG_IDEA_MODULES"), ",") {
+		entry = strings.TrimSuffix(strings.TrimSpace(entry), "/")
+		if entry == "" {
+			continue
+		}
+		if entry == name || entry == dir || strings.HasPrefix(dir, entry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ideaProjectModuleInfo is what a module knows of itself for the idea-project goal.
+type ideaProjectModuleInfo struct {
+	srcs    android.Paths
+	genSrcs android.Paths
+	srcJars android.Paths
+	// sdkVersion is the API level of the Android SDK that the module is compiled against, or empty
+	// if it isn't compiled against an Android SDK.
+	sdkVersion string
+	deps       []ideaProjectDep
+}
+
+// ideaProjectDep is a dependency on the classpath of the sources of a module.
+type ideaProjectDep struct {
+	name       string
+	headerJars android.Paths
+	// exported is set for static_libs, whose classes are part of the module.
+	exported bool
+}
+
+// setIdeaProjectInfo records the Java and Kotlin sources, srcjars, SDK version and dependencies of
+// the module.
+func (j *Module) setIdeaProjectInfo(ctx android.ModuleContext, srcFiles, kotlinCommonSrcFiles, srcJars android.Paths) {
+	if !ideaProjectEnabled(ctx.Config()) {
+		return
+	}
+
+	info := &ideaProjectModuleInfo{
+		srcJars: append(android.Paths(nil), srcJars...),
+	}
+	for _, src := range append(append(android.Paths(nil), srcFiles...), kotlinCommonSrcFiles...) {
+		if ext := src.Ext(); ext != ".java" && ext != ".kt" {
+			continue
+		}
+		if _, generated := src.(android.WritablePath); generated {
+			info.genSrcs = append(info.genSrcs, src)
+		} else {
+			info.srcs = append(info.srcs, src)
+		}
+	}
+	info.srcs = android.FirstUniquePaths(info.srcs)
+	info.genSrcs = android.FirstUniquePaths(info.genSrcs)
+
+	switch sdkVersion := j.SdkVersion(ctx); sdkVersion.Kind {
+	case android.SdkPublic, android.SdkSystem, android.SdkTest:
+		if sdkVersion.ApiLevel.IsCurrent() || sdkVersion.ApiLevel.IsPreview() {
+			info.sdkVersion = ctx.Config().PlatformSdkVersion().String()
+		} else {
+			info.sdkVersion = sdkVersion.ApiLevel.String()
+		}
+	}
+
+	seen := make(map[string]bool)
+	ctx.VisitDirectDeps(func(dep android.Module) {
+		tag := ctx.OtherModuleDependencyTag(dep)
+		name := ctx.OtherModuleName(dep)
+		if !isIdeaProjectDepTag(tag) || seen[name] {
+			return
+		}
+		seen[name] = true
+		d := ideaProjectDep{name: name, exported: tag == staticLibTag}
+		if ctx.OtherModuleHasProvider(dep, JavaInfoProvider) {
+			d.headerJars = ctx.OtherModuleProvider(dep, JavaInfoProvider).(JavaInfo).HeaderJars
+		}
+		info.deps = append(info.deps, d)
+	})
+
+	j.ideaProjectInfo = info
+}
+
+// ideaProjectModule is implemented by the modules that can be modules of the project.
+type ideaProjectModule interface {
+	android.Module
+	ideaProject() *ideaProjectModuleInfo
+}
+
+func (j *Module) ideaProject() *ideaProjectModuleInfo {
+	return j.ideaProjectInfo
+}
+
+// ideaProjectAndroidModule is implemented by the modules with an Android manifest and resources.
+type ideaProjectAndroidModule interface {
+	ideaProjectAndroidFacet() *ideaAndroidFacet
+}
+
+func (a *aapt) ideaProjectAndroidFacet() *ideaAndroidFacet {
+	if a.manifestSrcPath == nil {
+		return nil
+	}
+	return &ideaAndroidFacet{
+		Manifest:   a.manifestSrcPath.String(),
+		Res_dirs:   a.resourceDirs.Strings(),
+		Asset_dirs: a.assetDirs.Strings(),
+		Library:    a.isLibrary,
+	}
+}
+
+// isIdeaProjectTestModule returns true if the sources of the module are test sources.
+func isIdeaProjectTestModule(module android.Module) bool {
+	switch module.(type) {
+	case *Test, *TestHost, *TestHelperLibrary, *AndroidTest, *robolectricTest:
+		return true
+	}
+	return false
+}
+
+// isIdeaProjectDepTag returns true if the dependency is on the classpath of the sources.
+func isIdeaProjectDepTag(tag blueprint.DependencyTag) bool {
+	switch tag {
+	case libTag, staticLibTag, java9LibTag, bootClasspathTag, kotlinStdlibTag, kotlinAnnotationsTag,
+		instrumentationForTag:
+		return true
+	}
+	return false
+}
+
+// The project description read by the idea_project tool.
+type ideaProjectDescription struct {
+	Modules []ideaModule `json:"modules"`
+}
+
+type ideaModule struct {
+	Name        string            `json:"name"`
+	Dir         string            `json:"dir"`
+	Test        bool              `json:"test"`
+	Sdk_version string            `json:"sdk_version"`
+	Srcs        []string          `json:"srcs"`
+	Gen_srcs    []string          `json:"gen_srcs"`
+	Srcjars     []string          `json:"srcjars"`
+	Deps        []ideaDependency  `json:"deps"`
+	Android     *ideaAndroidFacet `json:"android"`
+}
+
+type ideaDependency struct {
+	Module   string   `json:"module,omitempty"`
+	Jars     []string `json:"jars,omitempty"`
+	Exported bool     `json:"exported"`
+}
+
+type ideaAndroidFacet struct {
+	Manifest   string   `json:"manifest"`
+	Res_dirs   []string `json:"res_dirs"`
+	Asset_dirs []string `json:"asset_dirs"`
+	Library    bool     `json:"library"`
+}
+
+func ideaProjectSingletonFactory() android.Singleton {
+	return &ideaProjectSingleton{}
+}
+
+type ideaProjectSingleton struct {
+	project android.Path
+}
+
+func (i *ideaProjectSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !ideaProjectEnabled(ctx.Config()) {
+		return
+	}
+
+	// Only the first variant of each module is a module of the project.
+	modules := make(map[string]ideaProjectModule)
+	var names []string
+	ctx.VisitAllModules(func(module android.Module) {
+		m, ok := module.(ideaProjectModule)
+		if !ok || !module.Enabled() || m.ideaProject() == nil {
+			return
+		}
+		name := ctx.ModuleName(module)
+		if _, exists := modules[name]; exists || !ideaProjectSelected(ctx.Config(), name, ctx.ModuleDir(module)) {
+			return
+		}
+		modules[name] = m
+		names = append(names, name)
+	})
+	if len(names) == 0 {
+		ctx.Errorf("idea project: no modules found in SOONG_IDEA_MODULES=%s", ctx.Config().Getenv("SOONG_IDEA_MODULES"))
+		return
+	}
+	sort.Strings(names)
+
+	var description ideaProjectDescription
+	var inputs android.Paths
+	for _, name := range names {
+		module := modules[name]
+		info := module.ideaProject()
+		m := ideaModule{
+			Name:        name,
+			Dir:         ctx.ModuleDir(module),
+			Test:        isIdeaProjectTestModule(module),
+			Sdk_version: info.sdkVersion,
+			Srcs:        info.srcs.Strings(),
+			Gen_srcs:    info.genSrcs.Strings(),
+			Srcjars:     info.srcJars.Strings(),
+		}
+		if a, ok := module.(ideaProjectAndroidModule); ok {
+			m.Android = a.ideaProjectAndroidFacet()
+		}
+
+		for _, dep := range info.deps {
+			if _, ok := modules[dep.name]; ok {
+				m.Deps = append(m.Deps, ideaDependency{Module: dep.name, Exported: dep.exported})
+			} else if len(dep.headerJars) > 0 {
+				m.Deps = append(m.Deps, ideaDependency{Jars: dep.headerJars.Strings(), Exported: dep.exported})
+				// Build the header jars so that the IDE finds them.
+				inputs = append(inputs, dep.headerJars...)
+			}
+		}
+
+		description.Modules = append(description.Modules, m)
+		inputs = append(inputs, info.srcs...)
+		inputs = append(inputs, info.genSrcs...)
+		inputs = append(inputs, info.srcJars...)
+	}
+
+	buf, err := json.MarshalIndent(description, "", "  ")
+	if err != nil {
+		ctx.Errorf("idea project: %s", err)
+		return
+	}
+	descriptionFile := android.PathForOutput(ctx, "idea", "project.json")
+	android.WriteFileRule(ctx, descriptionFile, string(buf))
+
+	project := android.PathForOutput(ctx, "idea", "project.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        ideaProject,
+		Description: "idea project",
+		Output:      project,
+		Input:       descriptionFile,
+		Implicits:   android.FirstUniquePaths(inputs),
+	})
+	i.project = project
+
+	ctx.Phony("idea-project", project)
+}
+
+func (i *ideaProjectSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if i.project != nil {
+		ctx.DistForGoal("idea-project", i.project)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*ideaProjectSingleton)(nil)
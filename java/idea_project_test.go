@@ -0,0 +1,153 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"encoding/json"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestIdeaProject(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_IDEA_MODULES": "a/,bar",
+		}),
+		android.FixtureAddTextFile("a/Android.bp", `
+			android_library {
+				name: "foo",
+				srcs: [
+					"src/Foo.java",
+					"IFoo.aidl",
+				],
+				static_libs: ["bar"],
+				libs: ["baz"],
+				sdk_version: "current",
+			}
+		`),
+		android.FixtureAddTextFile("a/tests/Android.bp", `
+			android_test {
+				name: "FooTests",
+				srcs: ["FooTest.java"],
+				static_libs: ["foo"],
+				sdk_version: "current",
+			}
+		`),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "bar",
+			srcs: ["bar.java"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["baz.java"],
+		}
+	`)
+
+	singleton := result.SingletonForTests("idea_project")
+	descriptionFile := singleton.Output("idea/project.json")
+	var description ideaProjectDescription
+	if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, descriptionFile)), &description); err != nil {
+		t.Fatal(err)
+	}
+
+	modules := make(map[string]ideaModule)
+	var names []string
+	for _, m := range description.Modules {
+		modules[m.Name] = m
+		names = append(names, m.Name)
+	}
+	android.AssertDeepEquals(t, "modules", []string{"FooTests", "bar", "foo"}, names)
+
+	// Test that the sources, resources, SDK version and dependencies of the modules are described.
+	foo := modules["foo"]
+	android.AssertStringEquals(t, "foo dir", "a", foo.Dir)
+	android.AssertBoolEquals(t, "foo test", false, foo.Test)
+	android.AssertStringEquals(t, "foo sdk version", result.Config.PlatformSdkVersion().String(), foo.Sdk_version)
+	android.AssertDeepEquals(t, "foo srcs", []string{"a/src/Foo.java"}, foo.Srcs)
+	fooModule := result.ModuleForTests("foo", "android_common").Module().(*AndroidLibrary)
+	android.AssertDeepEquals(t, "foo srcjars", fooModule.compiledSrcJars.Strings(), foo.Srcjars)
+	android.AssertStringListContains(t, "foo srcjars", android.StringsRelativeToTop(result.Config, foo.Srcjars),
+		"out/soong/.intermediates/a/foo/android_common/gen/aidl/aidl0.srcjar")
+	android.AssertStringEquals(t, "foo manifest", "a/AndroidManifest.xml", foo.Android.Manifest)
+	android.AssertBoolEquals(t, "foo library", true, foo.Android.Library)
+
+	// Dependencies in the project are modules, the others are their header jars.
+	bazHeaderJars := result.ModuleForTests("baz", "android_common").Module().(*Library).HeaderJars()
+	var bazDep *ideaDependency
+	for i, dep := range foo.Deps {
+		if dep.Module == "" && android.InList(bazHeaderJars[0].String(), dep.Jars) {
+			bazDep = &foo.Deps[i]
+		}
+	}
+	android.AssertDeepEquals(t, "foo module deps", []ideaDependency{{Module: "bar", Exported: true}},
+		filterIdeaModuleDeps(foo.Deps))
+	android.AssertDeepEquals(t, "foo baz dep", &ideaDependency{Jars: bazHeaderJars.Strings()}, bazDep)
+
+	tests := modules["FooTests"]
+	android.AssertStringEquals(t, "FooTests dir", "a/tests", tests.Dir)
+	android.AssertBoolEquals(t, "FooTests test", true, tests.Test)
+	android.AssertDeepEquals(t, "FooTests srcs", []string{"a/tests/FooTest.java"}, tests.Srcs)
+	android.AssertStringEquals(t, "FooTests manifest", "a/tests/AndroidManifest.xml", tests.Android.Manifest)
+	android.AssertBoolEquals(t, "FooTests library", false, tests.Android.Library)
+	android.AssertDeepEquals(t, "FooTests module deps", []ideaDependency{{Module: "foo", Exported: true}},
+		filterIdeaModuleDeps(tests.Deps))
+
+	bar := modules["bar"]
+	android.AssertStringEquals(t, "bar sdk version", "", bar.Sdk_version)
+	if bar.Android != nil {
+		t.Errorf("expected no android facet for bar, got %+v", bar.Android)
+	}
+
+	// Test that the project is written from the description, and that it depends on the sources
+	// and the header jars of the dependencies outside of the project.
+	project := singleton.Output("idea/project.zip")
+	android.AssertPathRelativeToTopEquals(t, "project input", "out/soong/idea/project.json", project.Input)
+	implicits := android.PathsRelativeToTop(project.Implicits)
+	android.AssertStringListContains(t, "project implicits", implicits, "a/src/Foo.java")
+	android.AssertStringListContains(t, "project implicits", implicits, "a/tests/FooTest.java")
+	android.AssertStringListContains(t, "project implicits", implicits, android.PathRelativeToTop(bazHeaderJars[0]))
+}
+
+func TestIdeaProjectNoModules(t *testing.T) {
+	android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_IDEA_MODULES": "nonexistent",
+		}),
+	).ExtendWithErrorHandler(
+		android.FixtureExpectsAtLeastOneErrorMatchingPattern(
+			`idea project: no modules found in SOONG_IDEA_MODULES=nonexistent`)).
+		RunTestWithBp(t, `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+			}
+		`)
+}
+
+// filterIdeaModuleDeps returns the dependencies on modules of the project.
+func filterIdeaModuleDeps(deps []ideaDependency) []ideaDependency {
+	var ret []ideaDependency
+	for _, dep := range deps {
+		if dep.Module != "" {
+			ret = append(ret, dep)
+		}
+	}
+	return ret
+}
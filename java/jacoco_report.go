@@ -0,0 +1,245 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This singleton writes JaCoCo coverage reports from the coverage data files (.ec) written by test
+// runs.  SOONG_JACOCO_COVERAGE_DATA is a directory in the source tree that contains the coverage
+// data files, which are merged and reported against the uninstrumented classes and the sources of
+// each module instrumented with EMMA_INSTRUMENT, and against those of all the instrumented modules
+// in each directory.  The XML and zipped HTML reports are generated in
+// $OUT/soong/jacoco/{modules/<module>/<variant>,dirs/<dir>}, and their counters are summarized in
+// $OUT/soong/jacoco/summary.json by the jacoco-report goal:
+//
+//	EMMA_INSTRUMENT=true SOONG_JACOCO_COVERAGE_DATA=coverage/foo m jacoco-report
+
+func init() {
+	registerJacocoReportBuildComponents(android.InitRegistrationContext)
+}
+
+func registerJacocoReportBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("jacoco_report", jacocoReportSingletonFactory)
+}
+
+var (
+	jacocoReportSources = pctx.AndroidStaticRule("jacocoReportSources",
+		blueprint.RuleParams{
+			Command:        "${config.JacocoReportCmd} sources -o $out @$out.rsp",
+			CommandDeps:    []string{"${config.JacocoReportCmd}"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+
+	jacocoMergeCoverage = pctx.AndroidStaticRule("jacocoMergeCoverage",
+		blueprint.RuleParams{
+			Command:     "${config.JavaCmd} -jar ${config.JacocoCLIJar} merge --quiet --destfile $out $in",
+			CommandDeps: []string{"${config.JavaCmd}", "${config.JacocoCLIJar}"},
+		})
+
+	// jacocoMergeClasses merges the classes jars of the modules in a directory, taking each class
+	// from the first jar it is in, as JaCoCo can't analyze different classes with the same name
+	// together and tests often statically include the libraries they test.
+	jacocoMergeClasses = pctx.AndroidStaticRule("jacocoMergeClasses",
+		blueprint.RuleParams{
+			Command:     "${config.MergeZipsCmd} --ignore-duplicates $out $in",
+			CommandDeps: []string{"${config.MergeZipsCmd}"},
+		})
+
+	// jacocoCoverageReport writes the XML report and the zipped HTML report of classes jars from
+	// the merged coverage data, with the sources from the zips written by jacocoReportSources.
+	jacocoCoverageReport = pctx.AndroidStaticRule("jacocoCoverageReport",
+		blueprint.RuleParams{
+			Command: "rm -rf $tmpDir && mkdir -p $tmpDir/sources && " +
+				"for f in $sources; do unzip -qo $$f -d $tmpDir/sources; done && " +
+				"${config.JavaCmd} -jar ${config.JacocoCLIJar} report $in --quiet --name $name " +
+				"$classFlags --sourcefiles $tmpDir/sources --xml $out --html $tmpDir/html && " +
+				"${config.SoongZipCmd} -o $html -C $tmpDir/html -D $tmpDir/html && " +
+				"rm -rf $tmpDir",
+			CommandDeps: []string{"${config.JavaCmd}", "${config.JacocoCLIJar}", "${config.SoongZipCmd}"},
+		},
+		"name", "classFlags", "sources", "html", "tmpDir")
+
+	jacocoReportSummary = pctx.AndroidStaticRule("jacocoReportSummary",
+		blueprint.RuleParams{
+			Command:     "${config.JacocoReportCmd} summary -o $out $flags",
+			CommandDeps: []string{"${config.JacocoReportCmd}"},
+		},
+		"flags")
+)
+
+func jacocoReportEnabled(config android.Config) bool {
+	return config.Getenv("SOONG_JACOCO_COVERAGE_DATA") != ""
+}
+
+// buildJacocoReportSources writes the zip of the sources of the module for its coverage reports.
+func (j *Module) buildJacocoReportSources(ctx android.ModuleContext) {
+	if !jacocoReportEnabled(ctx.Config()) {
+		return
+	}
+	sources := android.PathForModuleOut(ctx, "jacoco-report-sources", "sources.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        jacocoReportSources,
+		Description: "jacoco report sources",
+		Output:      sources,
+		Inputs:      j.srcJarDeps,
+	})
+	j.jacocoReportSources = sources
+}
+
+// jacocoReportModule is implemented by the modules that can be part of the coverage reports.
+type jacocoReportModule interface {
+	android.Module
+	JacocoReportClassesFile() android.Path
+	jacocoReportSourcesFile() android.Path
+}
+
+func (j *Module) jacocoReportSourcesFile() android.Path {
+	return j.jacocoReportSources
+}
+
+func jacocoReportSingletonFactory() android.Singleton {
+	return &jacocoReportSingleton{}
+}
+
+type jacocoReportSingleton struct{}
+
+// buildJacocoCoverageReport writes the XML and HTML reports of classes jars in dir from the merged
+// coverage data, and returns the XML report.
+func buildJacocoCoverageReport(ctx android.SingletonContext, dir android.OutputPath, name string,
+	coverage android.Path, classes, sources android.Paths) android.Path {
+
+	var classFlags []string
+	for _, jar := range classes {
+		classFlags = append(classFlags, "--classfiles "+jar.String())
+	}
+
+	xml := dir.Join(ctx, "report.xml")
+	html := dir.Join(ctx, "report.html.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           jacocoCoverageReport,
+		Description:    "jacoco report " + name,
+		Output:         xml,
+		ImplicitOutput: html,
+		Input:          coverage,
+		Implicits:      append(append(android.Paths(nil), classes...), sources...),
+		Args: map[string]string{
+			"name":       name,
+			"classFlags": strings.Join(classFlags, " "),
+			"sources":    strings.Join(sources.Strings(), " "),
+			"html":       html.String(),
+			"tmpDir":     dir.Join(ctx, "tmp").String(),
+		},
+	})
+	return xml
+}
+
+func (j *jacocoReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !jacocoReportEnabled(ctx.Config()) {
+		return
+	}
+
+	dataDir := ctx.Config().Getenv("SOONG_JACOCO_COVERAGE_DATA")
+	dataFiles, err := ctx.GlobWithDeps(filepath.Join(dataDir, "**/*.ec"), nil)
+	if err != nil {
+		ctx.Errorf("jacoco report: %s", err)
+		return
+	}
+	if len(dataFiles) == 0 {
+		ctx.Errorf("jacoco report: no coverage data files (.ec) found in SOONG_JACOCO_COVERAGE_DATA=%s", dataDir)
+		return
+	}
+
+	coverage := android.PathForOutput(ctx, "jacoco", "coverage.ec")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        jacocoMergeCoverage,
+		Description: "jacoco merge coverage data",
+		Output:      coverage,
+		Inputs:      android.PathsForSource(ctx, dataFiles),
+	})
+
+	var summaryFlags []string
+	var reports android.Paths
+	dirClasses := make(map[string]android.Paths)
+	dirSources := make(map[string]android.Paths)
+	dirModules := make(map[string]map[string]bool)
+	ctx.VisitAllModules(func(module android.Module) {
+		m, ok := module.(jacocoReportModule)
+		if !ok || !module.Enabled() || m.JacocoReportClassesFile() == nil || m.jacocoReportSourcesFile() == nil {
+			return
+		}
+
+		name := ctx.ModuleName(module)
+		variant := ctx.ModuleSubDir(module)
+		classes := android.Paths{m.JacocoReportClassesFile()}
+		sources := android.Paths{m.jacocoReportSourcesFile()}
+		dir := android.PathForOutput(ctx, "jacoco", "modules", name, variant)
+		xml := buildJacocoCoverageReport(ctx, dir, name, coverage, classes, sources)
+		reports = append(reports, xml)
+		summaryFlags = append(summaryFlags, "-module "+name+":"+variant+":"+xml.String())
+
+		// Only report the classes of one variant of each module in the directory reports, as
+		// JaCoCo can't analyze different classes with the same name together.
+		moduleDir := ctx.ModuleDir(module)
+		if dirModules[moduleDir] == nil {
+			dirModules[moduleDir] = make(map[string]bool)
+		}
+		if !dirModules[moduleDir][name] {
+			dirModules[moduleDir][name] = true
+			dirClasses[moduleDir] = append(dirClasses[moduleDir], classes...)
+			dirSources[moduleDir] = append(dirSources[moduleDir], sources...)
+		}
+	})
+
+	var dirs []string
+	for dir := range dirClasses {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, moduleDir := range dirs {
+		dir := android.PathForOutput(ctx, "jacoco", "dirs", moduleDir)
+		classes := dir.Join(ctx, "classes.jar")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        jacocoMergeClasses,
+			Description: "jacoco merge classes " + moduleDir,
+			Output:      classes,
+			Inputs:      dirClasses[moduleDir],
+		})
+		xml := buildJacocoCoverageReport(ctx, dir, moduleDir, coverage, android.Paths{classes}, dirSources[moduleDir])
+		reports = append(reports, xml)
+		summaryFlags = append(summaryFlags, "-dir "+moduleDir+":"+xml.String())
+	}
+
+	summary := android.PathForOutput(ctx, "jacoco", "summary.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        jacocoReportSummary,
+		Description: "jacoco report summary",
+		Output:      summary,
+		Inputs:      reports,
+		Args: map[string]string{
+			"flags": strings.Join(summaryFlags, " "),
+		},
+	})
+
+	ctx.Phony("jacoco-report", summary)
+}
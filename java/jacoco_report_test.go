@@ -0,0 +1,89 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestJacocoReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeEnv(map[string]string{
+			"EMMA_INSTRUMENT":            "true",
+			"SOONG_JACOCO_COVERAGE_DATA": "coverage",
+		}),
+		android.FixtureAddFile("coverage/foo.ec", nil),
+		android.FixtureAddFile("coverage/run2/bar.ec", nil),
+		android.FixtureAddTextFile("a/Android.bp", `
+			android_app {
+				name: "foo",
+				srcs: ["a.java"],
+				sdk_version: "current",
+			}
+
+			android_app {
+				name: "bar",
+				srcs: ["b.java"],
+				sdk_version: "current",
+			}
+		`),
+	).RunTestWithBp(t, `
+		java_library {
+			name: "jacocoagent",
+			srcs: ["agent.java"],
+			sdk_version: "current",
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	bar := result.ModuleForTests("bar", "android_common")
+	fooClasses := foo.Module().(*AndroidApp).JacocoReportClassesFile()
+	barClasses := bar.Module().(*AndroidApp).JacocoReportClassesFile()
+
+	// Test that the sources of instrumented modules are laid out for the reports.
+	fooSources := foo.Rule("jacocoReportSources")
+	android.AssertPathsRelativeToTopEquals(t, "foo sources", []string{"a/a.java"}, fooSources.Inputs)
+
+	// Test that all the coverage data files are merged.
+	singleton := result.SingletonForTests("jacoco_report")
+	merge := singleton.Rule("jacocoMergeCoverage")
+	android.AssertPathsRelativeToTopEquals(t, "coverage data files",
+		[]string{"coverage/foo.ec", "coverage/run2/bar.ec"}, merge.Inputs)
+
+	// Test that each module is reported with its classes and sources.
+	fooReport := singleton.Output("jacoco/modules/foo/android_common/report.xml")
+	android.AssertPathRelativeToTopEquals(t, "foo report coverage", "out/soong/jacoco/coverage.ec", fooReport.Input)
+	android.AssertStringEquals(t, "foo report classes", "--classfiles "+fooClasses.String(), fooReport.Args["classFlags"])
+	android.AssertStringEquals(t, "foo report sources", fooSources.Output.String(), fooReport.Args["sources"])
+
+	// Test that the modules in a directory are reported together, from their classes merged
+	// without duplicates.
+	dirClasses := singleton.Output("jacoco/dirs/a/classes.jar")
+	android.AssertDeepEquals(t, "dir classes",
+		[]string{barClasses.String(), fooClasses.String()}, android.SortedUniquePaths(dirClasses.Inputs).Strings())
+	dirReport := singleton.Output("jacoco/dirs/a/report.xml")
+	android.AssertStringEquals(t, "dir report classes", "--classfiles "+dirClasses.Output.String(), dirReport.Args["classFlags"])
+
+	// Test that the reports are summarized.
+	summary := singleton.Rule("jacocoReportSummary")
+	android.AssertPathRelativeToTopEquals(t, "summary", "out/soong/jacoco/summary.json", summary.Output)
+	android.AssertStringDoesContain(t, "summary flags", summary.Args["flags"],
+		"-module foo:android_common:"+fooReport.Output.String())
+	android.AssertStringDoesContain(t, "summary flags", summary.Args["flags"],
+		"-dir a:"+dirReport.Output.String())
+}
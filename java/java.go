@@ -67,6 +67,10 @@ func registerJavaBuildComponents(ctx android.RegistrationContext) {
 		ctx.BottomUp("dexpreopt_tool_deps", dexpreoptToolDepsMutator).Parallel()
 	})
 
+	ctx.PostDepsMutators(func(ctx android.RegisterMutatorsContext) {
+		ctx.TopDown("strict_deps_class_list", strictDepsClassListMutator).Parallel()
+	})
+
 	ctx.RegisterSingletonType("logtags", LogtagsSingleton)
 	ctx.RegisterSingletonType("kythe_java_extract", kytheExtractJavaFactory)
 }
@@ -253,6 +257,18 @@ type JavaInfo struct {
 	// JacocoReportClassesFile is the path to a jar containing uninstrumented classes that will be
 	// instrumented by jacoco.
 	JacocoReportClassesFile android.Path
+
+	// ClassList is the path to the list of classes compiled from the sources of the module, used
+	// by the strict deps checks of the modules that depend on it.
+	ClassList android.Path
+
+	// TransitiveClassLists contains the class lists of the module and all of its transitive libs
+	// and static_libs.
+	TransitiveClassLists *android.DepSet
+
+	// BaselineProfiles contains the ART baseline profiles of the module and all of its transitive
+	// static_libs.
+	BaselineProfiles *android.DepSet
 }
 
 var JavaInfoProvider = blueprint.NewProvider(JavaInfo{})
@@ -267,6 +283,31 @@ type SyspropPublicStubInfo struct {
 
 var SyspropPublicStubInfoProvider = blueprint.NewProvider(SyspropPublicStubInfo{})
 
+// strictDepsClassListModule is implemented by the modules that write the list of their classes
+// for the strict deps checks of the modules that depend on them.
+type strictDepsClassListModule interface {
+	// strictDepsClassListsNeeded returns true if the module needs the class lists of its libs and
+	// static_libs, either because it has strict_deps set or because it writes its own class list.
+	strictDepsClassListsNeeded() bool
+
+	setStrictDepsClassList()
+}
+
+// strictDepsClassListMutator marks the transitive libs and static_libs of the modules that have
+// strict_deps set, so that only they write the class lists used by the strict deps checks.
+func strictDepsClassListMutator(ctx android.TopDownMutatorContext) {
+	if m, ok := ctx.Module().(strictDepsClassListModule); ok && m.strictDepsClassListsNeeded() {
+		ctx.VisitDirectDeps(func(dep android.Module) {
+			switch ctx.OtherModuleDependencyTag(dep) {
+			case libTag, staticLibTag, instrumentationForTag, syspropPublicStubDepTag:
+				if d, ok := dep.(strictDepsClassListModule); ok {
+					d.setStrictDepsClassList()
+				}
+			}
+		})
+	}
+}
+
 // Methods that need to be implemented for a module that is added to apex java_libs property.
 type ApexDependency interface {
 	HeaderJars() android.Paths
@@ -364,8 +405,10 @@ var (
 	kotlinStdlibTag         = dependencyTag{name: "kotlin-stdlib", runtimeLinked: true}
 	kotlinAnnotationsTag    = dependencyTag{name: "kotlin-annotations", runtimeLinked: true}
 	kotlinPluginTag         = dependencyTag{name: "kotlin-plugin", toolchain: true}
+	kspPluginTag            = dependencyTag{name: "ksp-plugin", toolchain: true}
 	proguardRaiseTag        = dependencyTag{name: "proguard-raise"}
 	certificateTag          = dependencyTag{name: "certificate"}
+	bundleFeatureTag        = dependencyTag{name: "bundle-feature"}
 	instrumentationForTag   = dependencyTag{name: "instrumentation_for"}
 	extraLintCheckTag       = dependencyTag{name: "extra-lint-check", toolchain: true}
 	jniLibTag               = dependencyTag{name: "jnilib", runtimeLinked: true}
@@ -475,6 +518,17 @@ type deps struct {
 	kotlinStdlib            android.Paths
 	kotlinAnnotations       android.Paths
 	kotlinPlugins           android.Paths
+	kotlinPluginIds         []string
+	kotlinPluginOptions     []string
+	kspProcessorPath        classpath
+	directClassLists        android.Paths
+	transitiveClassLists    []*android.DepSet
+	baselineProfiles        []*android.DepSet
+	declaredDeps            []declaredDep
+
+	// the implementation and resource jars of the static_libs that are published as Maven
+	// artifacts.
+	mavenPublishedStaticJars android.Paths
 
 	disableTurbine bool
 }
@@ -573,6 +627,8 @@ func normalizeJavaVersion(ctx android.BaseModuleContext, javaVersion string) jav
 type Library struct {
 	Module
 
+	mavenPublicationProperties MavenPublicationProperties
+
 	InstallMixin func(ctx android.ModuleContext, installPath android.Path) (extraInstallDeps android.Paths)
 }
 
@@ -666,6 +722,12 @@ func (j *Library) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		}
 		j.installFile = ctx.InstallFile(installDir, j.Stem()+".jar", j.outputFile, extraInstallDeps...)
 	}
+
+	mavenJar := j.implementationAndResourcesJar
+	if classesJar := j.mavenClassesJar(ctx); classesJar != nil {
+		mavenJar = classesJar
+	}
+	j.mavenPublicationBuildActions(ctx, mavenJar, "jar")
 }
 
 func (j *Library) DepsMutator(ctx android.BottomUpMutatorContext) {
@@ -803,6 +865,7 @@ func LibraryFactory() android.Module {
 	module := &Library{}
 
 	module.addHostAndDeviceProperties()
+	module.AddProperties(&module.mavenPublicationProperties)
 
 	module.initModuleAndImport(module)
 
@@ -826,6 +889,7 @@ func LibraryHostFactory() android.Module {
 	module := &Library{}
 
 	module.addHostProperties()
+	module.AddProperties(&module.mavenPublicationProperties)
 
 	module.Module.properties.Installable = proptools.BoolPtr(true)
 
@@ -1493,6 +1557,10 @@ type ImportProperties struct {
 		// that depend on this module, as well as to aidl for this module.
 		Export_include_dirs []string
 	}
+
+	// If true, a module that depends on this module through libs or static_libs, directly or
+	// transitively, has strict_deps set, and this module writes the list of its classes.
+	Strict_deps_class_list bool `blueprint:"mutated"`
 }
 
 type Import struct {
@@ -1520,6 +1588,11 @@ type Import struct {
 	classLoaderContexts   dexpreopt.ClassLoaderContextMap
 	exportAidlIncludeDirs android.Paths
 
+	// list of the classes in the jars of this module, and the class lists of this module and all
+	// of its transitive libs, used for strict deps checks.
+	classList            android.Path
+	transitiveClassLists *android.DepSet
+
 	hideApexVariantFromMake bool
 
 	sdkVersion    android.SdkSpec
@@ -1532,6 +1605,14 @@ func (j *Import) PermittedPackagesForUpdatableBootJars() []string {
 	return j.properties.Permitted_packages
 }
 
+func (j *Import) strictDepsClassListsNeeded() bool {
+	return j.properties.Strict_deps_class_list
+}
+
+func (j *Import) setStrictDepsClassList() {
+	j.properties.Strict_deps_class_list = true
+}
+
 func (j *Import) SdkVersion(ctx android.EarlyModuleContext) android.SdkSpec {
 	return android.SdkSpecFrom(ctx, String(j.properties.Sdk_version))
 }
@@ -1616,6 +1697,13 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 	j.combinedClasspathFile = outputFile
 	j.classLoaderContexts = make(dexpreopt.ClassLoaderContextMap)
 
+	if j.properties.Strict_deps_class_list {
+		classList := android.PathForModuleOut(ctx, "strict_deps", "classes.list")
+		TransformJarsToClassList(ctx, classList, android.Paths{outputFile})
+		j.classList = classList
+	}
+	var transitiveClassLists []*android.DepSet
+
 	var flags javaBuilderFlags
 
 	ctx.VisitDirectDeps(func(module android.Module) {
@@ -1627,6 +1715,9 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 			case libTag:
 				flags.classpath = append(flags.classpath, dep.HeaderJars...)
 				flags.dexClasspath = append(flags.dexClasspath, dep.HeaderJars...)
+				if dep.TransitiveClassLists != nil {
+					transitiveClassLists = append(transitiveClassLists, dep.TransitiveClassLists)
+				}
 			case staticLibTag:
 				flags.classpath = append(flags.classpath, dep.HeaderJars...)
 			case bootClasspathTag:
@@ -1642,6 +1733,9 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		addCLCFromDep(ctx, module, j.classLoaderContexts)
 	})
 
+	j.transitiveClassLists = android.NewDepSet(android.POSTORDER,
+		android.PathsIfNonNil(j.classList), transitiveClassLists)
+
 	if Bool(j.properties.Installable) {
 		var installDir android.InstallPath
 		if ctx.InstallInTestcases() {
@@ -1725,6 +1819,8 @@ func (j *Import) GenerateAndroidBuildActions(ctx android.ModuleContext) {
 		ImplementationAndResourcesJars: android.PathsIfNonNil(j.combinedClasspathFile),
 		ImplementationJars:             android.PathsIfNonNil(j.combinedClasspathFile),
 		AidlIncludeDirs:                j.exportAidlIncludeDirs,
+		ClassList:                      j.classList,
+		TransitiveClassLists:           j.transitiveClassLists,
 	})
 }
 
@@ -1667,3 +1667,80 @@ func TestDataDeviceBinsBuildsDeviceBinary(t *testing.T) {
 		})
 	}
 }
+
+func TestStrictDeps(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar", "qux"],
+			strict_deps: true,
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+
+		java_import {
+			name: "qux",
+			jars: ["a.jar"],
+		}
+
+		java_library {
+			name: "quux",
+			srcs: ["d.java"],
+		}
+	`)
+
+	classList := func(name string) string {
+		return "out/soong/.intermediates/" + name + "/android_common/strict_deps/classes.list"
+	}
+
+	foo := result.ModuleForTests("foo", "android_common")
+	check := foo.Rule("strictDepsCheck")
+	javac := foo.Rule("javac")
+
+	// Test that the check runs on the classes compiled from the sources of foo.
+	android.AssertPathsRelativeToTopEquals(t, "check inputs", []string{javac.Output.String()}, check.Inputs)
+
+	// Test that only the direct dependencies are allowed, and that the class lists of the
+	// transitive dependencies are used to suggest fixes.
+	direct := strings.Fields(strings.ReplaceAll(check.Args["directClassLists"], "-direct ", ""))
+	android.AssertStringListContains(t, "direct class lists", direct, classList("bar"))
+	android.AssertStringListContains(t, "direct class lists", direct, classList("qux"))
+	android.AssertStringListDoesNotContain(t, "direct class lists", direct, classList("baz"))
+	transitive := strings.Fields(check.Args["transitiveClassLists"])
+	android.AssertStringListContains(t, "transitive class lists", transitive, classList("baz"))
+	android.AssertStringListContains(t, "transitive class lists", transitive, classList("bar"))
+
+	// Test that the class list of bar only contains the classes compiled from its sources.
+	bar := result.ModuleForTests("bar", "android_common")
+	barClassList := bar.Output("strict_deps/classes.list")
+	android.AssertPathsRelativeToTopEquals(t, "bar class list inputs",
+		[]string{bar.Rule("javac").Output.String()}, barClassList.Inputs)
+
+	// Test that the output jar of foo depends on the check.
+	copied := foo.Output("strict_deps/foo.jar")
+	android.AssertPathRelativeToTopEquals(t, "validation", check.Output.String(), copied.Validation)
+	android.AssertPathRelativeToTopEquals(t, "output file", copied.Output.String(),
+		foo.Module().(*Library).outputFile)
+
+	// Test that modules without strict_deps aren't checked.
+	if bar.MaybeRule("strictDepsCheck").Rule != nil {
+		t.Errorf("expected no strict deps check for bar")
+	}
+
+	// Test that only the libs and static_libs of modules with strict_deps write class lists.
+	for _, name := range []string{"foo", "quux"} {
+		if result.ModuleForTests(name, "android_common").MaybeRule("strictDepsClassList").Rule != nil {
+			t.Errorf("expected no strict deps class list for %s", name)
+		}
+	}
+}
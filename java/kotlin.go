@@ -28,11 +28,12 @@ import (
 
 var kotlinc = pctx.AndroidRemoteStaticRule("kotlinc", android.RemoteRuleSupports{Goma: true},
 	blueprint.RuleParams{
-		Command: `rm -rf "$classesDir" "$headerClassesDir" "$srcJarDir" "$kotlinBuildFile" "$emptyDir" && ` +
-			`mkdir -p "$classesDir" "$headerClassesDir" "$srcJarDir" "$emptyDir" && ` +
+		Command: `rm -rf "$classesDir" "$headerClassesDir" "$srcJarDir" "$kotlinSrcJarDir" "$kotlinBuildFile" "$emptyDir" && ` +
+			`mkdir -p "$classesDir" "$headerClassesDir" "$srcJarDir" "$kotlinSrcJarDir" "$emptyDir" && ` +
 			`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
+			`${config.ZipSyncCmd} -d $kotlinSrcJarDir -l $kotlinSrcJarDir/list -f "*.java" -f "*.kt" $kotlinSrcJars && ` +
 			`${config.GenKotlinBuildFileCmd} --classpath "$classpath" --name "$name"` +
-			` --out_dir "$classesDir" --srcs "$out.rsp" --srcs "$srcJarDir/list"` +
+			` --out_dir "$classesDir" --srcs "$out.rsp" --srcs "$srcJarDir/list" --srcs "$kotlinSrcJarDir/list"` +
 			` $commonSrcFilesArg --out "$kotlinBuildFile" && ` +
 			`${config.KotlincCmd} ${config.KotlincGlobalFlags} ` +
 			` ${config.KotlincSuppressJDK9Warnings} ${config.JavacHeapFlags} ` +
@@ -42,7 +43,7 @@ var kotlinc = pctx.AndroidRemoteStaticRule("kotlinc", android.RemoteRuleSupports
 			` -P plugin:org.jetbrains.kotlin.jvm.abi:outputDir=$headerClassesDir && ` +
 			`${config.SoongZipCmd} -jar -o $out -C $classesDir -D $classesDir -write_if_changed && ` +
 			`${config.SoongZipCmd} -jar -o $headerJar -C $headerClassesDir -D $headerClassesDir -write_if_changed && ` +
-			`rm -rf "$srcJarDir"`,
+			`rm -rf "$srcJarDir" "$kotlinSrcJarDir"`,
 		CommandDeps: []string{
 			"${config.KotlincCmd}",
 			"${config.KotlinCompilerJar}",
@@ -61,8 +62,8 @@ var kotlinc = pctx.AndroidRemoteStaticRule("kotlinc", android.RemoteRuleSupports
 		RspfileContent: `$in`,
 		Restat:         true,
 	},
-	"kotlincFlags", "classpath", "srcJars", "commonSrcFilesArg", "srcJarDir", "classesDir",
-	"headerClassesDir", "headerJar", "kotlinJvmTarget", "kotlinBuildFile", "emptyDir", "name")
+	"kotlincFlags", "classpath", "srcJars", "kotlinSrcJars", "commonSrcFilesArg", "srcJarDir", "kotlinSrcJarDir",
+	"classesDir", "headerClassesDir", "headerJar", "kotlinJvmTarget", "kotlinBuildFile", "emptyDir", "name")
 
 func kotlinCommonSrcsList(ctx android.ModuleContext, commonSrcFiles android.Paths) android.OptionalPath {
 	if len(commonSrcFiles) > 0 {
@@ -80,15 +81,39 @@ func kotlinCommonSrcsList(ctx android.ModuleContext, commonSrcFiles android.Path
 	return android.OptionalPath{}
 }
 
+// kotlinPluginOptionFlags returns the kotlinc flags that pass the options of the kotlin_plugins of a module and
+// the options set by the module in kotlin_plugin_options, which must be for one of its kotlin_plugins.
+func kotlinPluginOptionFlags(ctx android.ModuleContext, deps deps, moduleOptions []string) []string {
+	var flags []string
+	for _, option := range deps.kotlinPluginOptions {
+		flags = append(flags, "-P plugin:"+option)
+	}
+	for _, option := range moduleOptions {
+		id := strings.SplitN(option, ":", 2)[0]
+		if !strings.Contains(option, ":") || !strings.Contains(option, "=") ||
+			!android.InList(id, deps.kotlinPluginIds) {
+			ctx.PropertyErrorf("kotlin_plugin_options",
+				"%q is not in the form <plugin id>:<option>=<value> for a plugin in kotlin_plugins", option)
+			continue
+		}
+		flags = append(flags, "-P plugin:"+option)
+	}
+	return flags
+}
+
 // kotlinCompile takes .java and .kt sources and srcJars, and compiles the .kt sources into a classes jar in outputFile.
+// Only the .java sources of srcJars are passed to kotlinc, the .kt sources are only compiled from kotlinSrcJars, the
+// srcjars generated by KSP.  The header jar in headerOutputFile is written by the jvm-abi-gen plugin in the same
+// compilation, so the compiler plugins in flags.kotlincFlags apply to it as well.
 func kotlinCompile(ctx android.ModuleContext, outputFile, headerOutputFile android.WritablePath,
-	srcFiles, commonSrcFiles, srcJars android.Paths,
+	srcFiles, commonSrcFiles, srcJars, kotlinSrcJars android.Paths,
 	flags javaBuilderFlags) {
 
 	var deps android.Paths
 	deps = append(deps, flags.kotlincClasspath...)
 	deps = append(deps, flags.kotlincDeps...)
 	deps = append(deps, srcJars...)
+	deps = append(deps, kotlinSrcJars...)
 	deps = append(deps, commonSrcFiles...)
 
 	kotlinName := filepath.Join(ctx.ModuleDir(), ctx.ModuleSubDir(), ctx.ModuleName())
@@ -113,10 +138,12 @@ func kotlinCompile(ctx android.ModuleContext, outputFile, headerOutputFile andro
 			"kotlincFlags":      flags.kotlincFlags,
 			"commonSrcFilesArg": commonSrcFilesArg,
 			"srcJars":           strings.Join(srcJars.Strings(), " "),
+			"kotlinSrcJars":     strings.Join(kotlinSrcJars.Strings(), " "),
 			"classesDir":        android.PathForModuleOut(ctx, "kotlinc", "classes").String(),
 			"headerClassesDir":  android.PathForModuleOut(ctx, "kotlinc", "header_classes").String(),
 			"headerJar":         headerOutputFile.String(),
 			"srcJarDir":         android.PathForModuleOut(ctx, "kotlinc", "srcJars").String(),
+			"kotlinSrcJarDir":   android.PathForModuleOut(ctx, "kotlinc", "kotlinSrcJars").String(),
 			"kotlinBuildFile":   android.PathForModuleOut(ctx, "kotlinc-build.xml").String(),
 			"emptyDir":          android.PathForModuleOut(ctx, "kotlinc", "empty").String(),
 			"kotlinJvmTarget":   flags.javaVersion.StringForKotlinc(),
@@ -234,6 +261,93 @@ func kotlinKapt(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile an
 	TurbineApt(ctx, srcJarOutputFile, resJarOutputFile, javaSrcFiles, turbineSrcJars, flags)
 }
 
+var kspGen = pctx.AndroidRemoteStaticRule("kspGen", android.RemoteRuleSupports{Goma: true},
+	blueprint.RuleParams{
+		Command: `rm -rf "$srcJarDir" "$kotlinBuildFile" "$kspDir" && ` +
+			`mkdir -p "$srcJarDir" "$kspDir/sources" "$kspDir/classes" "$kspDir/caches" && ` +
+			`${config.ZipSyncCmd} -d $srcJarDir -l $srcJarDir/list -f "*.java" $srcJars && ` +
+			`${config.GenKotlinBuildFileCmd} --classpath "$classpath" --name "$name"` +
+			` --srcs "$out.rsp" --srcs "$srcJarDir/list"` +
+			` $commonSrcFilesArg --out "$kotlinBuildFile" && ` +
+			`${config.KotlincCmd} ${config.KotlincGlobalFlags} ` +
+			`${config.KotlincSuppressJDK9Warnings} ${config.JavacHeapFlags} $kotlincFlags ` +
+			`-Xplugin=${config.KotlinKspApiJar} -Xplugin=${config.KotlinKspPluginJar} ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:projectBaseDir=$kspDir ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:kspOutputDir=$kspDir ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:kotlinOutputDir=$kspDir/sources ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:javaOutputDir=$kspDir/sources ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:classOutputDir=$kspDir/classes ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:resourceOutputDir=$kspDir/classes ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:cachesDir=$kspDir/caches ` +
+			`-P plugin:com.google.devtools.ksp.symbol-processing:incremental=false ` +
+			`$kspProcessorPath ` +
+			`-jvm-target $kotlinJvmTarget -Xbuild-file=$kotlinBuildFile && ` +
+			`${config.SoongZipCmd} -jar -o $out -C $kspDir/sources -D $kspDir/sources && ` +
+			`${config.SoongZipCmd} -jar -o $classesJarOut -C $kspDir/classes -D $kspDir/classes && ` +
+			`rm -rf "$srcJarDir"`,
+		CommandDeps: []string{
+			"${config.KotlincCmd}",
+			"${config.KotlinCompilerJar}",
+			"${config.KotlinKspApiJar}",
+			"${config.KotlinKspPluginJar}",
+			"${config.GenKotlinBuildFileCmd}",
+			"${config.SoongZipCmd}",
+			"${config.ZipSyncCmd}",
+		},
+		Rspfile:        "$out.rsp",
+		RspfileContent: `$in`,
+	},
+	"kotlincFlags", "kspProcessorPath", "classpath", "srcJars", "commonSrcFilesArg", "srcJarDir",
+	"kspDir", "kotlinJvmTarget", "kotlinBuildFile", "name", "classesJarOut")
+
+// kotlinKsp runs Kotlin Symbol Processing over .kt and .java sources and srcjars using the processors in
+// kspProcessorPath.  It produces a srcjar of generated .kt and .java sources in srcJarOutputFile and a jar of
+// generated resources and classes in resJarOutputFile.  The srcjar should be added as an additional input to the
+// kotlinc, javac and turbine rules.
+func kotlinKsp(ctx android.ModuleContext, srcJarOutputFile, resJarOutputFile android.WritablePath,
+	srcFiles, commonSrcFiles, srcJars android.Paths, kspProcessorPath classpath,
+	flags javaBuilderFlags) {
+
+	var deps android.Paths
+	deps = append(deps, flags.kotlincClasspath...)
+	deps = append(deps, flags.kotlincDeps...)
+	deps = append(deps, srcJars...)
+	deps = append(deps, kspProcessorPath...)
+	deps = append(deps, commonSrcFiles...)
+
+	commonSrcsList := kotlinCommonSrcsList(ctx, commonSrcFiles)
+	commonSrcFilesArg := ""
+	if commonSrcsList.Valid() {
+		deps = append(deps, commonSrcsList.Path())
+		commonSrcFilesArg = "--common_srcs " + commonSrcsList.String()
+	}
+
+	kotlinName := filepath.Join(ctx.ModuleDir(), ctx.ModuleSubDir(), ctx.ModuleName())
+	kotlinName = strings.ReplaceAll(kotlinName, "/", "__")
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           kspGen,
+		Description:    "ksp",
+		Output:         srcJarOutputFile,
+		ImplicitOutput: resJarOutputFile,
+		Inputs:         srcFiles,
+		Implicits:      deps,
+		Args: map[string]string{
+			"classpath":         flags.kotlincClasspath.FormJavaClassPath(""),
+			"kotlincFlags":      flags.kotlincFlags,
+			"commonSrcFilesArg": commonSrcFilesArg,
+			"srcJars":           strings.Join(srcJars.Strings(), " "),
+			"srcJarDir":         android.PathForModuleOut(ctx, "ksp", "srcJars").String(),
+			"kotlinBuildFile":   android.PathForModuleOut(ctx, "ksp", "build.xml").String(),
+			"kspProcessorPath":  strings.Join(kspProcessorPath.FormRepeatedClassPath("-P plugin:com.google.devtools.ksp.symbol-processing:apclasspath="), " "),
+			"kspDir":            android.PathForModuleOut(ctx, "ksp", "gen").String(),
+			"kotlinJvmTarget":   flags.javaVersion.StringForKotlinc(),
+			"name":              kotlinName,
+			"classesJarOut":     resJarOutputFile.String(),
+		},
+	})
+}
+
 // kapt converts a list of key, value pairs into a base64 encoded Java serialization, which is what kapt expects.
 func kaptEncodeFlags(options [][2]string) string {
 	buf := &bytes.Buffer{}
@@ -266,6 +266,77 @@ func TestKapt(t *testing.T) {
 	})
 }
 
+func TestKsp(t *testing.T) {
+	ctx, _ := testJava(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java", "b.kt"],
+			ksp_plugins: ["bar"],
+		}
+
+		java_plugin {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`)
+
+	buildOS := ctx.Config().BuildOS.String()
+
+	foo := ctx.ModuleForTests("foo", "android_common")
+	ksp := foo.Rule("kspGen")
+	kotlinc := foo.Rule("kotlinc")
+	javac := foo.Rule("javac")
+	turbine := foo.Rule("turbine")
+	combineJar := foo.Description("for javac")
+
+	bar := ctx.ModuleForTests("bar", buildOS+"_common").Rule("javac").Output.String()
+
+	// Test that the kotlin and java sources are passed to ksp
+	android.AssertPathsRelativeToTopEquals(t, "ksp inputs", []string{"a.java", "b.kt"}, ksp.Inputs)
+
+	// Test that the processors are passed to ksp and not to javac
+	expectedProcessorPath := "-P plugin:com.google.devtools.ksp.symbol-processing:apclasspath=" + bar
+	android.AssertStringEquals(t, "kspProcessorPath", expectedProcessorPath, ksp.Args["kspProcessorPath"])
+	android.AssertStringEquals(t, "javac processorpath", "", javac.Args["processorpath"])
+
+	// Test that the ksp srcjar is compiled by kotlinc, javac and turbine
+	kspSrcJar := ksp.Output.String()
+	for _, rule := range []android.TestingBuildParams{kotlinc, javac, turbine} {
+		android.AssertStringListContains(t, rule.Rule.String()+" implicits", rule.Implicits.Strings(), kspSrcJar)
+	}
+	android.AssertStringDoesContain(t, "javac srcJars", javac.Args["srcJars"], kspSrcJar)
+	android.AssertStringDoesContain(t, "turbine srcJars", turbine.Args["srcJars"], kspSrcJar)
+
+	// Test that only the .kt sources of the ksp srcjar are extracted for kotlinc
+	android.AssertStringEquals(t, "kotlinc kotlinSrcJars", kspSrcJar, kotlinc.Args["kotlinSrcJars"])
+	android.AssertStringDoesNotContain(t, "kotlinc srcJars", kotlinc.Args["srcJars"], kspSrcJar)
+
+	// Test that the ksp resources are combined into the output jar
+	kspResJar := ksp.ImplicitOutput.String()
+	android.AssertStringListContains(t, "combined jar inputs", combineJar.Inputs.Strings(), kspResJar)
+
+	// Test that the ksp srcjar is reported to the IDE
+	fooModule := foo.Module().(*Library)
+	dpInfo := &android.IdeInfo{}
+	fooModule.IDEInfo(dpInfo)
+	android.AssertStringListContains(t, "IDE srcjars", dpInfo.SrcJars, kspSrcJar)
+}
+
+func TestKspRequiresKotlin(t *testing.T) {
+	testJavaError(t, `requires .kt sources`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			ksp_plugins: ["bar"],
+		}
+
+		java_plugin {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+	`)
+}
+
 func TestKaptEncodeFlags(t *testing.T) {
 	// Compares the kaptEncodeFlags against the results of the example implementation at
 	// https://kotlinlang.org/docs/reference/kapt.html#apjavac-options-encoding
@@ -375,3 +446,98 @@ func TestKotlinCompose(t *testing.T) {
 	android.AssertStringDoesNotContain(t, "unexpected compose compiler plugin",
 		noCompose.VariablesForTestsRelativeToTop()["kotlincFlags"], "-Xplugin="+composeCompiler.String())
 }
+
+func TestKotlinPlugins(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+	).RunTestWithBp(t, `
+		kotlin_plugin {
+			name: "serialization-plugin",
+			plugin_id: "org.jetbrains.kotlinx.serialization",
+			options: ["enabled=true"],
+		}
+
+		java_library {
+			name: "foo",
+			srcs: ["a.kt"],
+			kotlin_plugins: ["serialization-plugin"],
+			kotlin_plugin_options: ["org.jetbrains.kotlinx.serialization:disableIntrinsic=false"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["a.kt"],
+		}
+	`)
+
+	buildOS := result.Config.BuildOS.String()
+
+	plugin := result.ModuleForTests("serialization-plugin", buildOS+"_common").Rule("combineJar").Output
+	foo := result.ModuleForTests("foo", "android_common")
+	bar := result.ModuleForTests("bar", "android_common")
+
+	kotlinc := foo.Rule("kotlinc")
+	android.AssertStringListContains(t, "missing kotlin plugin dependency",
+		kotlinc.Implicits.Strings(), plugin.String())
+
+	kotlincFlags := foo.VariablesForTestsRelativeToTop()["kotlincFlags"]
+	android.AssertStringDoesContain(t, "missing kotlin plugin", kotlincFlags, "-Xplugin="+plugin.String())
+	android.AssertStringDoesContain(t, "missing kotlin plugin option", kotlincFlags,
+		"-P plugin:org.jetbrains.kotlinx.serialization:enabled=true")
+	android.AssertStringDoesContain(t, "missing module kotlin plugin option", kotlincFlags,
+		"-P plugin:org.jetbrains.kotlinx.serialization:disableIntrinsic=false")
+
+	// The header jar is written by the same kotlinc compilation, with the plugin.
+	android.AssertPathRelativeToTopEquals(t, "kotlin header jar",
+		"out/soong/.intermediates/foo/android_common/kotlin_headers/foo.jar", kotlinc.ImplicitOutput)
+
+	android.AssertStringListDoesNotContain(t, "unexpected kotlin plugin dependency",
+		bar.Rule("kotlinc").Implicits.Strings(), plugin.String())
+}
+
+func TestKotlinPluginsErrors(t *testing.T) {
+	testJavaError(t, `"bar" is not a kotlin_plugin module`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.kt"],
+			kotlin_plugins: ["bar"],
+		}
+
+		java_library_host {
+			name: "bar",
+		}
+	`)
+
+	testJavaError(t, `kotlin_plugins: requires .kt sources`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			kotlin_plugins: ["bar"],
+		}
+
+		kotlin_plugin {
+			name: "bar",
+		}
+	`)
+
+	testJavaError(t, `"other:a=b" is not in the form <plugin id>:<option>=<value> for a plugin in kotlin_plugins`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.kt"],
+			kotlin_plugins: ["bar"],
+			kotlin_plugin_options: ["other:a=b"],
+		}
+
+		kotlin_plugin {
+			name: "bar",
+			plugin_id: "com.example.bar",
+		}
+	`)
+
+	testJavaError(t, `options: requires plugin_id`, `
+		kotlin_plugin {
+			name: "bar",
+			options: ["a=b"],
+		}
+	`)
+}
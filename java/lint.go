@@ -95,6 +95,9 @@ type lintOutputs struct {
 	text android.Path
 	xml  android.Path
 
+	// baseline is the updated lint baseline for the lint-baselines goal.
+	baseline *lintBaselineOutputs
+
 	depSets LintDepSets
 }
 
@@ -431,11 +434,16 @@ func (l *linter) lint(ctx android.ModuleContext) {
 		FlagWithArg("--java-language-level ", l.javaLanguageLevel).
 		FlagWithArg("--kotlin-language-level ", l.kotlinLanguageLevel).
 		FlagWithArg("--url ", fmt.Sprintf(".=.,%s=out", android.PathForOutput(ctx).String())).
-		Flag("--exitcode").
 		Flags(l.properties.Lint.Flags).
 		Implicit(annotationsZipPath).
 		Implicit(apiVersionsXMLPath)
 
+	// Don't fail on the issues that will be added to a regenerated baseline.
+	regenerateBaseline := regenerateLintBaseline(ctx)
+	if !regenerateBaseline {
+		cmd.Flag("--exitcode")
+	}
+
 	rule.Temporary(lintPaths.projectXML)
 	rule.Temporary(lintPaths.configXML)
 
@@ -464,6 +472,8 @@ func (l *linter) lint(ctx android.ModuleContext) {
 		text: text,
 		xml:  xml,
 
+		baseline: l.updateLintBaseline(ctx, lintBaseline, baseline, regenerateBaseline),
+
 		depSets: depSetsBuilder.Build(),
 	}
 
@@ -0,0 +1,198 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// When SOONG_LINT_BASELINES is set, the lint baseline of every linted module that has one is
+// updated from the reference baseline written by lint, which lists all the issues that lint
+// currently reports.  Issues that have been fixed are pruned from the existing baselines, and the
+// baselines of the modules listed in SOONG_LINT_BASELINE_REGENERATE, or of the modules in the
+// directories listed in it, are replaced with all the current issues.  Lint does not fail for the
+// regenerated modules.  This singleton collects the updated baselines into
+// $OUT/soong/lint_baselines/baselines.zip, whose entries are the paths of the baselines in the
+// source tree, and writes $OUT/soong/lint_baselines/report.json, which lists the modules whose
+// baselines grew, for the lint-baselines goal:
+//
+//	SOONG_LINT_BASELINES=true SOONG_LINT_BASELINE_REGENERATE=packages/apps/Foo m lint-baselines
+//	unzip -o out/soong/lint_baselines/baselines.zip
+
+func init() {
+	registerLintBaselineBuildComponents(android.InitRegistrationContext)
+}
+
+func registerLintBaselineBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("lint_baselines", lintBaselinesSingletonFactory)
+}
+
+var (
+	lintBaselineUpdate = pctx.AndroidStaticRule("lintBaselineUpdate",
+		blueprint.RuleParams{
+			Command: "${config.LintBaselineCmd} update -module $module -path $path $flags " +
+				"-reference $in -o $out -report $report",
+			CommandDeps: []string{"${config.LintBaselineCmd}"},
+		},
+		"module", "path", "flags", "report")
+
+	lintBaselineMerge = pctx.AndroidStaticRule("lintBaselineMerge",
+		blueprint.RuleParams{
+			Command:        "${config.LintBaselineCmd} merge -o $out @$out.rsp",
+			CommandDeps:    []string{"${config.LintBaselineCmd}"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+)
+
+func lintBaselinesEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_LINT_BASELINES")
+}
+
+// lintBaselineOutputs are the updated lint baseline of a module and the report of the update.
+type lintBaselineOutputs struct {
+	baseline android.Path
+	report   android.Path
+	// path is the path of the baseline in the source tree.
+	path string
+}
+
+// regenerateLintBaseline returns true if the lint baseline of the module is to be replaced with
+// all the issues that lint currently reports.
+func regenerateLintBaseline(ctx android.ModuleContext) bool {
+	if !lintBaselinesEnabled(ctx.Config()) {
+		return false
+	}
+	for _, entry := range strings.Split(ctx.Config().Getenv("SOONG_LINT_BASELINE_REGENERATE"), ",") {
+		entry = strings.TrimSuffix(strings.TrimSpace(entry), "/")
+		if entry == "" {
+			continue
+		}
+		if entry == ctx.ModuleName() || entry == ctx.ModuleDir() ||
+			strings.HasPrefix(ctx.ModuleDir(), entry+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// updateLintBaseline updates the lint baseline of the module from the reference baseline written
+// by lint, if the module has a baseline or its baseline is regenerated.
+func (l *linter) updateLintBaseline(ctx android.ModuleContext, lintBaseline android.OptionalPath,
+	reference android.Path, regenerate bool) *lintBaselineOutputs {
+
+	if !lintBaselinesEnabled(ctx.Config()) {
+		return nil
+	}
+
+	var path string
+	var flags []string
+	var implicits android.Paths
+	if lintBaseline.Valid() {
+		path = lintBaseline.Path().String()
+		flags = append(flags, "-baseline "+lintBaseline.Path().String())
+		implicits = append(implicits, lintBaseline.Path())
+	} else if lintFilename := proptools.StringDefault(l.properties.Lint.Baseline_filename, "lint-baseline.xml"); regenerate && lintFilename != "" {
+		path = filepath.Join(ctx.ModuleDir(), lintFilename)
+	} else {
+		return nil
+	}
+	if regenerate {
+		flags = append(flags, "-regenerate")
+	}
+
+	baseline := android.PathForModuleOut(ctx, "lint_baseline", filepath.Base(path))
+	report := android.PathForModuleOut(ctx, "lint_baseline", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:           lintBaselineUpdate,
+		Description:    "update lint baseline " + ctx.ModuleName(),
+		Input:          reference,
+		Implicits:      implicits,
+		Output:         baseline,
+		ImplicitOutput: report,
+		Args: map[string]string{
+			"module": ctx.ModuleName(),
+			"path":   path,
+			"flags":  strings.Join(flags, " "),
+			"report": report.String(),
+		},
+	})
+
+	return &lintBaselineOutputs{
+		baseline: baseline,
+		report:   report,
+		path:     path,
+	}
+}
+
+func lintBaselinesSingletonFactory() android.Singleton {
+	return &lintBaselinesSingleton{}
+}
+
+type lintBaselinesSingleton struct{}
+
+func (l *lintBaselinesSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !lintBaselinesEnabled(ctx.Config()) {
+		return
+	}
+
+	var reports android.Paths
+	var baselines []*lintBaselineOutputs
+	paths := make(map[string]bool)
+	ctx.VisitAllModules(func(m android.Module) {
+		l, ok := m.(lintOutputsIntf)
+		if !ok || !m.Enabled() {
+			return
+		}
+		if apex, ok := m.(android.ApexModule); ok && apex.NotAvailableForPlatform() {
+			apexInfo := ctx.ModuleProvider(m, android.ApexInfoProvider).(android.ApexInfo)
+			if apexInfo.IsForPlatform() {
+				// Stray platform variants of modules in apexes can't always be built.
+				return
+			}
+		}
+		// Variants of a module share its baseline, keep the first one.
+		if b := l.lintOutputs().baseline; b != nil && !paths[b.path] {
+			paths[b.path] = true
+			baselines = append(baselines, b)
+			reports = append(reports, b.report)
+		}
+	})
+
+	report := android.PathForOutput(ctx, "lint_baselines", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        lintBaselineMerge,
+		Description: "lint baselines report",
+		Output:      report,
+		Inputs:      reports,
+	})
+
+	zip := android.PathForOutput(ctx, "lint_baselines", "baselines.zip")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	cmd := rule.Command().BuiltTool("soong_zip").
+		FlagWithOutput("-o ", zip)
+	for _, b := range baselines {
+		cmd.FlagWithArg("-e ", b.path).FlagWithInput("-f ", b.baseline)
+	}
+	rule.Build("lint_baselines_zip", "lint baselines zip")
+
+	ctx.Phony("lint-baselines", report, zip)
+}
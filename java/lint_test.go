@@ -294,3 +294,98 @@ func TestJavaLintDatabaseSelectionPublicFiltered(t *testing.T) {
 		}
 	}
 }
+
+func TestJavaLintBaselines(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_LINT_BASELINES":           "true",
+			"SOONG_LINT_BASELINE_REGENERATE": "b/",
+		}),
+		android.FixtureAddTextFile("a/Android.bp", `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+			}
+		`),
+		android.FixtureAddFile("a/lint-baseline.xml", nil),
+		android.FixtureAddTextFile("b/c/Android.bp", `
+			java_library {
+				name: "bar",
+				srcs: ["b.java"],
+				lint: {
+					baseline_filename: "bar-baseline.xml",
+				},
+			}
+		`),
+		android.FixtureAddFile("b/c/bar-baseline.xml", nil),
+		android.FixtureAddTextFile("d/Android.bp", `
+			java_library {
+				name: "baz",
+				srcs: ["c.java"],
+			}
+
+			java_library {
+				name: "qux",
+				srcs: ["d.java"],
+			}
+		`),
+	).RunTest(t)
+
+	// Test that the existing baseline of foo is pruned.
+	foo := result.ModuleForTests("foo", "android_common")
+	fooUpdate := foo.Rule("lintBaselineUpdate")
+	android.AssertPathRelativeToTopEquals(t, "foo reference baseline",
+		"out/soong/.intermediates/a/foo/android_common/lint/lint-baseline.xml", fooUpdate.Input)
+	android.AssertStringEquals(t, "foo baseline path", "a/lint-baseline.xml", fooUpdate.Args["path"])
+	android.AssertStringEquals(t, "foo flags", "-baseline a/lint-baseline.xml", fooUpdate.Args["flags"])
+	fooLint := android.RuleBuilderSboxProtoForTests(t, foo.Output("lint.sbox.textproto"))
+	android.AssertStringDoesContain(t, "foo lint", *fooLint.Commands[0].Command, "--exitcode")
+
+	// Test that the baseline of bar is regenerated, and that lint doesn't fail for it.
+	bar := result.ModuleForTests("bar", "android_common")
+	barUpdate := bar.Rule("lintBaselineUpdate")
+	android.AssertStringEquals(t, "bar baseline path", "b/c/bar-baseline.xml", barUpdate.Args["path"])
+	android.AssertStringEquals(t, "bar flags", "-baseline b/c/bar-baseline.xml -regenerate", barUpdate.Args["flags"])
+	barLint := android.RuleBuilderSboxProtoForTests(t, bar.Output("lint.sbox.textproto"))
+	android.AssertStringDoesNotContain(t, "bar lint", *barLint.Commands[0].Command, "--exitcode")
+
+	// Test that modules without baselines aren't updated.
+	if result.ModuleForTests("baz", "android_common").MaybeRule("lintBaselineUpdate").Rule != nil {
+		t.Errorf("expected no baseline update for baz")
+	}
+
+	// Test that the baselines are collected for the lint-baselines goal.
+	singleton := result.SingletonForTests("lint_baselines")
+	merge := singleton.Rule("lintBaselineMerge")
+	android.AssertPathRelativeToTopEquals(t, "report", "out/soong/lint_baselines/report.json", merge.Output)
+	android.AssertStringListContains(t, "merged reports", merge.Inputs.Strings(), fooUpdate.ImplicitOutput.String())
+	android.AssertStringListContains(t, "merged reports", merge.Inputs.Strings(), barUpdate.ImplicitOutput.String())
+	android.AssertIntEquals(t, "merged reports", 2, len(merge.Inputs))
+	zip := singleton.Output("lint_baselines/baselines.zip")
+	android.AssertStringDoesContain(t, "baselines zip", zip.RuleParams.Command,
+		"-e a/lint-baseline.xml -f "+fooUpdate.Output.String())
+	android.AssertStringDoesContain(t, "baselines zip", zip.RuleParams.Command,
+		"-e b/c/bar-baseline.xml -f "+barUpdate.Output.String())
+}
+
+func TestJavaLintRegenerateBaseline(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		PrepareForTestWithJavaDefaultModules,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_LINT_BASELINES":           "true",
+			"SOONG_LINT_BASELINE_REGENERATE": "foo",
+		}),
+		android.FixtureAddTextFile("a/Android.bp", `
+			java_library {
+				name: "foo",
+				srcs: ["a.java"],
+			}
+		`),
+	).RunTest(t)
+
+	// Test that a baseline is created for a module that doesn't have one.
+	update := result.ModuleForTests("foo", "android_common").Rule("lintBaselineUpdate")
+	android.AssertStringEquals(t, "baseline path", "a/lint-baseline.xml", update.Args["path"])
+	android.AssertStringEquals(t, "flags", "-regenerate", update.Args["flags"])
+}
@@ -0,0 +1,248 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+)
+
+// java_library and android_library modules that set maven.group_id and maven.version are published
+// as Maven artifacts: the jar or aar, a sources jar and a POM, with their MD5 and SHA-1
+// checksums, laid out as in a Maven repository.  This is the reverse of cmd/pom2bp.  This
+// singleton merges the artifacts of all the libraries into
+// $OUT/soong/maven/repository.zip, which is built and dist'ed by the maven-publications goal.  The
+// zip is extracted at the root of the local repository, or uploaded to a remote one.
+
+func init() {
+	registerMavenPublicationBuildComponents(android.InitRegistrationContext)
+}
+
+func registerMavenPublicationBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("maven_publications", mavenPublicationsSingletonFactory)
+}
+
+type MavenPublicationProperties struct {
+	// Properties for publishing the library as a Maven artifact.
+	Maven struct {
+		// The group ID of the Maven artifact.  The library is published when it is set.
+		Group_id *string
+
+		// The artifact ID of the Maven artifact.  Defaults to the name of the module.
+		Artifact_id *string
+
+		// The version of the Maven artifact.  Required when group_id is set.
+		Version *string
+
+		// A description of the library for the POM.
+		Description *string
+	}
+}
+
+// MavenPublicationInfo contains the coordinates of a library published as a Maven artifact.
+type MavenPublicationInfo struct {
+	GroupId    string
+	ArtifactId string
+	Version    string
+	// Packaging is the type of the artifact, "jar" or "aar".
+	Packaging string
+
+	// Repository is a zip of the files of the artifact laid out as in a Maven repository.
+	Repository android.Path
+}
+
+var MavenPublicationInfoProvider = blueprint.NewProvider(MavenPublicationInfo{})
+
+type mavenDependency struct {
+	MavenPublicationInfo
+	Scope string
+}
+
+var pomTemplate = template.Must(template.New("pom").Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 http://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>{{html .GroupId}}</groupId>
+  <artifactId>{{html .ArtifactId}}</artifactId>
+  <version>{{html .Version}}</version>
+  <packaging>{{.Packaging}}</packaging>
+{{- if .Description}}
+  <description>{{html .Description}}</description>
+{{- end}}
+{{- if .Dependencies}}
+  <dependencies>
+{{- range .Dependencies}}
+    <dependency>
+      <groupId>{{html .GroupId}}</groupId>
+      <artifactId>{{html .ArtifactId}}</artifactId>
+      <version>{{html .Version}}</version>
+{{- if ne .Packaging "jar"}}
+      <type>{{.Packaging}}</type>
+{{- end}}
+      <scope>{{.Scope}}</scope>
+    </dependency>
+{{- end}}
+  </dependencies>
+{{- end}}
+</project>
+`))
+
+// mavenClassesJar returns the jar to publish in place of the classes jar of the library when some
+// of its static_libs are published as Maven artifacts themselves.  Their classes and resources are
+// left out, as the POM lists them as dependencies instead.  It returns nil if the classes jar of
+// the library can be published as is.
+func (j *Library) mavenClassesJar(ctx android.ModuleContext) android.Path {
+	if j.mavenPublicationProperties.Maven.Group_id == nil || j.mavenJars == nil {
+		return nil
+	}
+	jarName := j.Stem() + ".jar"
+	classesJar := android.PathForModuleOut(ctx, "maven", "combined", jarName)
+	TransformJarsToJar(ctx, classesJar, "for maven", j.mavenJars, j.mavenManifest, false, nil, nil)
+	if j.expandJarjarRules != nil {
+		jarjarFile := android.PathForModuleOut(ctx, "maven", "jarjar", jarName)
+		TransformJarJar(ctx, jarjarFile, classesJar, j.expandJarjarRules)
+		return jarjarFile
+	}
+	return classesJar
+}
+
+// mavenPublicationBuildActions publishes the library as a Maven artifact if maven.group_id is set.
+// The libs and static_libs that are published become compile dependencies in the POM, and the
+// published static_libs are left out of the artifact; see mavenClassesJar.  The other static_libs
+// are included in the artifact, and libs that are not published, like the libraries of the SDK,
+// are expected to be provided by the environment, so neither are listed.
+func (j *Library) mavenPublicationBuildActions(ctx android.ModuleContext, artifact android.Path, packaging string) {
+	props := j.mavenPublicationProperties.Maven
+	if props.Group_id == nil {
+		return
+	}
+	if props.Version == nil {
+		ctx.PropertyErrorf("maven.version", "must be set when maven.group_id is set")
+		return
+	}
+	if artifact == nil {
+		return
+	}
+	// Publish only the platform variant of the library, and only its device variant if it has one.
+	apexInfo := ctx.Provider(android.ApexInfoProvider).(android.ApexInfo)
+	if !apexInfo.IsForPlatform() || (ctx.Host() && j.DeviceSupported()) {
+		return
+	}
+
+	info := MavenPublicationInfo{
+		GroupId:    String(props.Group_id),
+		ArtifactId: proptools.StringDefault(props.Artifact_id, ctx.ModuleName()),
+		Version:    String(props.Version),
+		Packaging:  packaging,
+	}
+
+	var dependencies []mavenDependency
+	ctx.VisitDirectDeps(func(module android.Module) {
+		if tag := ctx.OtherModuleDependencyTag(module); tag != libTag && tag != staticLibTag {
+			return
+		}
+		if ctx.OtherModuleHasProvider(module, MavenPublicationInfoProvider) {
+			dep := ctx.OtherModuleProvider(module, MavenPublicationInfoProvider).(MavenPublicationInfo)
+			dependencies = append(dependencies, mavenDependency{dep, "compile"})
+		}
+	})
+
+	pom := android.PathForModuleOut(ctx, "maven", info.ArtifactId+".pom")
+	buf := &strings.Builder{}
+	err := pomTemplate.Execute(buf, struct {
+		MavenPublicationInfo
+		Description  string
+		Dependencies []mavenDependency
+	}{info, String(props.Description), dependencies})
+	if err != nil {
+		ctx.ModuleErrorf("failed to generate the POM: %s", err)
+		return
+	}
+	android.WriteFileRule(ctx, pom, buf.String())
+
+	sourcesJar := android.PathForModuleOut(ctx, "maven", info.ArtifactId+"-sources.jar")
+	TransformResourcesToJar(ctx, sourcesJar, j.srcJarArgs, j.srcJarDeps)
+
+	repoDir := android.PathForModuleOut(ctx, "maven", "repository")
+	versionDir := filepath.Join(append([]string{repoDir.String()},
+		append(strings.Split(info.GroupId, "."), info.ArtifactId, info.Version)...)...)
+	base := filepath.Join(versionDir, info.ArtifactId+"-"+info.Version)
+	repository := android.PathForModuleOut(ctx, "maven", "repository.zip")
+
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().Text("rm -rf").Text(repoDir.String())
+	rule.Command().Text("mkdir -p").Text(versionDir)
+	rule.Command().Text("cp").Input(artifact).Text(base + "." + packaging)
+	rule.Command().Text("cp").Input(sourcesJar).Text(base + "-sources.jar")
+	rule.Command().Text("cp").Input(pom).Text(base + ".pom")
+	rule.Command().
+		Textf(`for f in %s/*; do`, versionDir).
+		Text(`md5sum "$f" | cut -d' ' -f1 > "$f.md5" &&`).
+		Text(`sha1sum "$f" | cut -d' ' -f1 > "$f.sha1";`).
+		Text("done")
+	rule.Command().BuiltTool("soong_zip").
+		FlagWithOutput("-o ", repository).
+		FlagWithArg("-C ", repoDir.String()).
+		FlagWithArg("-D ", repoDir.String())
+	rule.Command().Text("rm -rf").Text(repoDir.String())
+	rule.Build("maven_publication", "maven publication "+info.GroupId+":"+info.ArtifactId)
+
+	info.Repository = repository
+	ctx.SetProvider(MavenPublicationInfoProvider, info)
+}
+
+func mavenPublicationsSingletonFactory() android.Singleton {
+	return &mavenPublicationsSingleton{}
+}
+
+type mavenPublicationsSingleton struct {
+	repository android.Path
+}
+
+func (m *mavenPublicationsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	var repositories android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if module.Enabled() && ctx.ModuleHasProvider(module, MavenPublicationInfoProvider) {
+			info := ctx.ModuleProvider(module, MavenPublicationInfoProvider).(MavenPublicationInfo)
+			repositories = append(repositories, info.Repository)
+		}
+	})
+	if len(repositories) == 0 {
+		return
+	}
+
+	repository := android.PathForOutput(ctx, "maven", "repository.zip")
+	rule := android.NewRuleBuilder(pctx, ctx)
+	rule.Command().BuiltTool("merge_zips").
+		Output(repository).
+		Inputs(repositories)
+	rule.Build("maven_repository", "maven repository")
+
+	m.repository = repository
+	ctx.Phony("maven-publications", repository)
+}
+
+func (m *mavenPublicationsSingleton) MakeVars(ctx android.MakeVarsContext) {
+	if m.repository != nil {
+		ctx.DistForGoal("maven-publications", m.repository)
+	}
+}
+
+var _ android.SingletonMakeVarsProvider = (*mavenPublicationsSingleton)(nil)
@@ -0,0 +1,140 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestMavenPublication(t *testing.T) {
+	result := prepareForJavaTest.RunTestWithBp(t, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar", "unpublished"],
+			static_libs: ["baz", "unpublished_static"],
+			maven: {
+				group_id: "com.example",
+				artifact_id: "foo-lib",
+				version: "1.0.0",
+				description: "Foo & more",
+			},
+		}
+
+		android_library {
+			name: "bar",
+			srcs: ["b.java"],
+			maven: {
+				group_id: "com.example.android",
+				version: "2.0",
+			},
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+			maven: {
+				group_id: "com.example",
+				version: "3.0",
+			},
+		}
+
+		java_library {
+			name: "unpublished",
+			srcs: ["d.java"],
+		}
+
+		java_library {
+			name: "unpublished_static",
+			srcs: ["e.java"],
+		}
+	`)
+
+	foo := result.ModuleForTests("foo", "android_common")
+
+	// Test that the POM lists the published libs and static_libs only, with the packaging of their
+	// artifacts.
+	pom := android.ContentFromFileRuleForTests(t, foo.Output("maven/foo-lib.pom"))
+	android.AssertStringEquals(t, "pom", `<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://maven.apache.org/POM/4.0.0 http://maven.apache.org/xsd/maven-4.0.0.xsd">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>foo-lib</artifactId>
+  <version>1.0.0</version>
+  <packaging>jar</packaging>
+  <description>Foo &amp; more</description>
+  <dependencies>
+    <dependency>
+      <groupId>com.example.android</groupId>
+      <artifactId>bar</artifactId>
+      <version>2.0</version>
+      <type>aar</type>
+      <scope>compile</scope>
+    </dependency>
+    <dependency>
+      <groupId>com.example</groupId>
+      <artifactId>baz</artifactId>
+      <version>3.0</version>
+      <scope>compile</scope>
+    </dependency>
+  </dependencies>
+</project>
+`, pom)
+
+	// Test that the artifacts are laid out as in a Maven repository.
+	publication := foo.Output("maven/repository.zip")
+	cmd := android.StringRelativeToTop(result.Config, publication.RuleParams.Command)
+	android.AssertStringDoesContain(t, "jar", cmd,
+		"cp out/soong/.intermediates/foo/android_common/maven/combined/foo.jar out/soong/.intermediates/foo/android_common/maven/repository/com/example/foo-lib/1.0.0/foo-lib-1.0.0.jar")
+
+	// Test that the published static_libs are left out of the jar, and the others are included.
+	mavenJar := foo.Output("maven/combined/foo.jar")
+	android.AssertPathsRelativeToTopEquals(t, "jar inputs", []string{
+		"out/soong/.intermediates/foo/android_common/javac/foo.jar",
+		"out/soong/.intermediates/unpublished_static/android_common/javac/unpublished_static.jar",
+	}, mavenJar.Inputs)
+	android.AssertStringDoesContain(t, "sources jar", cmd,
+		"com/example/foo-lib/1.0.0/foo-lib-1.0.0-sources.jar")
+	android.AssertStringDoesContain(t, "pom", cmd, "com/example/foo-lib/1.0.0/foo-lib-1.0.0.pom")
+	android.AssertStringDoesContain(t, "checksums", cmd, `sha1sum "$f"`)
+
+	bar := result.ModuleForTests("bar", "android_common")
+	android.AssertStringDoesContain(t, "aar", bar.Output("maven/repository.zip").RuleParams.Command,
+		"com/example/android/bar/2.0/bar-2.0.aar")
+
+	// Test that the publications are merged into the repository.
+	repository := result.SingletonForTests("maven_publications").Output("maven/repository.zip")
+	android.AssertPathRelativeToTopEquals(t, "repository", "out/soong/maven/repository.zip", repository.Output)
+	android.AssertStringListContains(t, "repository inputs", repository.Implicits.Strings(), publication.Output.String())
+	android.AssertIntEquals(t, "repository inputs", 3, len(repository.Implicits))
+
+	if result.ModuleForTests("unpublished", "android_common").MaybeOutput("maven/repository.zip").Rule != nil {
+		t.Errorf("expected no publication for unpublished")
+	}
+}
+
+func TestMavenPublicationRequiresVersion(t *testing.T) {
+	testJavaError(t, `maven.version: must be set when maven.group_id is set`, `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			maven: {
+				group_id: "com.example",
+			},
+		}
+	`)
+}
@@ -15,6 +15,8 @@
 package java
 
 import (
+	"strings"
+
 	"android/soong/android"
 	"android/soong/bazel"
 )
@@ -25,6 +27,7 @@ func init() {
 
 func registerJavaPluginBuildComponents(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("java_plugin", PluginFactory)
+	ctx.RegisterModuleType("kotlin_plugin", KotlinPluginFactory)
 }
 
 func PluginFactory() android.Module {
@@ -57,6 +60,57 @@ type PluginProperties struct {
 	Generates_api *bool
 }
 
+func KotlinPluginFactory() android.Module {
+	module := &KotlinPlugin{}
+
+	module.addHostProperties()
+	module.AddProperties(&module.kotlinPluginProperties)
+
+	InitJavaModule(module, android.HostSupported)
+
+	return module
+}
+
+// KotlinPlugin describes a kotlin_plugin module, a host java library that will be used by kotlinc as a compiler
+// plugin, for example the Jetpack Compose compiler or the kotlinx.serialization plugin.  A prebuilt plugin jar can be
+// used through static_libs.
+type KotlinPlugin struct {
+	Library
+
+	kotlinPluginProperties KotlinPluginProperties
+}
+
+type KotlinPluginProperties struct {
+	// The id of the compiler plugin, which its options are passed for, e.g. "org.jetbrains.kotlinx.serialization".
+	Plugin_id *string
+
+	// Options passed to the compiler plugin in every module that uses it, in the form <option>=<value>.  Modules can
+	// pass more options with kotlin_plugin_options.
+	Options []string
+}
+
+func (p *KotlinPlugin) GenerateAndroidBuildActions(ctx android.ModuleContext) {
+	if len(p.kotlinPluginProperties.Options) > 0 && p.kotlinPluginProperties.Plugin_id == nil {
+		ctx.PropertyErrorf("options", "requires plugin_id")
+	}
+	for _, option := range p.kotlinPluginProperties.Options {
+		if !strings.Contains(option, "=") {
+			ctx.PropertyErrorf("options", "%q is not in the form <option>=<value>", option)
+		}
+	}
+
+	p.Library.GenerateAndroidBuildActions(ctx)
+}
+
+// pluginOptions returns the options of the plugin in the form <plugin id>:<option>=<value>.
+func (p *KotlinPlugin) pluginOptions() []string {
+	var options []string
+	for _, option := range p.kotlinPluginProperties.Options {
+		options = append(options, String(p.kotlinPluginProperties.Plugin_id)+":"+option)
+	}
+	return options
+}
+
 type pluginAttributes struct {
 	*javaCommonAttributes
 	Deps            bazel.LabelListAttribute
@@ -315,6 +315,11 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	RegisterSystemModulesBuildComponents(ctx)
 	registerSystemserverClasspathBuildComponents(ctx)
 	registerLintBuildComponents(ctx)
+	registerLintBaselineBuildComponents(ctx)
+	registerMavenPublicationBuildComponents(ctx)
+	registerJacocoReportBuildComponents(ctx)
+	registerIdeaProjectBuildComponents(ctx)
+	registerUnusedDepsBuildComponents(ctx)
 }
 
 // gatherRequiredDepsForTest gathers the module definitions used by
@@ -0,0 +1,155 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"strings"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// Every Java module with sources gets an unused dependency report listing the libs and
+// static_libs that contributed no classes to the classes compiled from its sources when
+// SOONG_JAVA_UNUSED_DEPS is set. This singleton aggregates the reports into
+// $OUT/soong/java_unused_deps/report.json for the java-unused-deps goal:
+//
+//	SOONG_JAVA_UNUSED_DEPS=true m java-unused-deps
+//
+// The java_unused_deps tool can then remove the unused libs from the Android.bp files. Unused
+// static_libs are only reported, as they may still be used at runtime.
+
+func init() {
+	registerUnusedDepsBuildComponents(android.InitRegistrationContext)
+}
+
+func registerUnusedDepsBuildComponents(ctx android.RegistrationContext) {
+	ctx.RegisterSingletonType("java_unused_deps", javaUnusedDepsSingletonFactory)
+}
+
+var (
+	javaUnusedDeps = pctx.AndroidStaticRule("javaUnusedDeps",
+		blueprint.RuleParams{
+			Command: "${config.JavaUnusedDepsCmd} analyze -module $module -variant $variant " +
+				"-blueprint $blueprint -o $out $depFlags $in",
+			CommandDeps: []string{"${config.JavaUnusedDepsCmd}"},
+		},
+		"module", "variant", "blueprint", "depFlags")
+
+	javaUnusedDepsMerge = pctx.AndroidStaticRule("javaUnusedDepsMerge",
+		blueprint.RuleParams{
+			Command:        "${config.JavaUnusedDepsCmd} merge -o $out @$out.rsp",
+			CommandDeps:    []string{"${config.JavaUnusedDepsCmd}"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+)
+
+func unusedDepsEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_JAVA_UNUSED_DEPS")
+}
+
+// declaredDep is a library dependency of a module that is listed in its Android.bp file.
+type declaredDep struct {
+	name string
+	// kind is the name of the property that lists the dependency.
+	kind       string
+	headerJars android.Paths
+}
+
+// addDeclaredDep records a direct libs or static_libs dependency of the module for the unused
+// dependency report if it is listed in the Android.bp file of the module. Dependencies added
+// implicitly, like the SDK, are ignored as they can't be removed from the Android.bp file, as are
+// Android libraries in static_libs, which also contribute resources.
+func (j *Module) addDeclaredDep(ctx android.ModuleContext, deps *deps, kind string, module android.Module,
+	headerJars android.Paths) {
+
+	name := android.RemoveOptionalPrebuiltPrefix(ctx.OtherModuleName(module))
+	switch kind {
+	case "libs":
+		if !android.InList(name, j.properties.Libs) {
+			return
+		}
+	case "static_libs":
+		if _, ok := module.(AndroidLibraryDependency); ok || !android.InList(name, j.properties.Static_libs) {
+			return
+		}
+	}
+	deps.declaredDeps = append(deps.declaredDeps, declaredDep{name: name, kind: kind, headerJars: headerJars})
+}
+
+// TransformJarsToUnusedDepsReport writes the report of the declared dependencies of a module that
+// contributed no classes to the jars compiled from its sources.
+func TransformJarsToUnusedDepsReport(ctx android.ModuleContext, outputFile android.WritablePath,
+	jars android.Paths, declaredDeps []declaredDep) {
+
+	var implicits android.Paths
+	var depFlags []string
+	for _, dep := range declaredDeps {
+		for _, jar := range dep.headerJars {
+			implicits = append(implicits, jar)
+			depFlags = append(depFlags, "-dep "+dep.kind+":"+dep.name+":"+jar.String())
+		}
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        javaUnusedDeps,
+		Description: "unused deps " + ctx.ModuleName(),
+		Output:      outputFile,
+		Inputs:      jars,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"module":    ctx.ModuleName(),
+			"variant":   ctx.ModuleSubDir(),
+			"blueprint": ctx.BlueprintsFile(),
+			"depFlags":  strings.Join(depFlags, " "),
+		},
+	})
+}
+
+func (j *Module) unusedDepsReportFile() android.OptionalPath {
+	return j.unusedDepsReport
+}
+
+func javaUnusedDepsSingletonFactory() android.Singleton {
+	return &javaUnusedDepsSingleton{}
+}
+
+type javaUnusedDepsSingleton struct{}
+
+func (j *javaUnusedDepsSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !unusedDepsEnabled(ctx.Config()) {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if m, ok := module.(interface {
+			unusedDepsReportFile() android.OptionalPath
+		}); ok && module.Enabled() && m.unusedDepsReportFile().Valid() {
+			reports = append(reports, m.unusedDepsReportFile().Path())
+		}
+	})
+
+	report := android.PathForOutput(ctx, "java_unused_deps", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        javaUnusedDepsMerge,
+		Description: "java unused deps report",
+		Output:      report,
+		Inputs:      reports,
+	})
+	ctx.Phony("java-unused-deps", report)
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestUnusedDepsReport(t *testing.T) {
+	bp := `
+		java_library {
+			name: "foo",
+			srcs: ["a.java"],
+			libs: ["bar"],
+			static_libs: ["baz"],
+		}
+
+		java_library {
+			name: "bar",
+			srcs: ["b.java"],
+		}
+
+		java_library {
+			name: "baz",
+			srcs: ["c.java"],
+		}
+	`
+
+	result := android.GroupFixturePreparers(
+		prepareForJavaTest,
+		android.FixtureMergeEnv(map[string]string{
+			"SOONG_JAVA_UNUSED_DEPS": "true",
+		}),
+	).RunTestWithBp(t, bp)
+
+	foo := result.ModuleForTests("foo", "android_common")
+	analyze := foo.Rule("javaUnusedDeps")
+
+	// Test that the classes compiled from the sources of foo are analyzed against the header jars
+	// of the declared dependencies only.
+	android.AssertPathsRelativeToTopEquals(t, "analyzed jars",
+		[]string{foo.Rule("javac").Output.String()}, analyze.Inputs)
+	barHeaderJar := result.ModuleForTests("bar", "android_common").Output("turbine-combined/bar.jar").Output
+	bazHeaderJar := result.ModuleForTests("baz", "android_common").Output("turbine-combined/baz.jar").Output
+	android.AssertStringEquals(t, "dep flags",
+		"-dep libs:bar:"+barHeaderJar.String()+" -dep static_libs:baz:"+bazHeaderJar.String(),
+		analyze.Args["depFlags"])
+	android.AssertStringEquals(t, "blueprint", "Android.bp", analyze.Args["blueprint"])
+
+	// Test that modules without declared dependencies aren't analyzed.
+	if result.ModuleForTests("bar", "android_common").MaybeRule("javaUnusedDeps").Rule != nil {
+		t.Errorf("expected no unused deps report for bar")
+	}
+
+	// Test that the reports are merged into the tree-wide report.
+	merge := result.SingletonForTests("java_unused_deps").Rule("javaUnusedDepsMerge")
+	android.AssertPathRelativeToTopEquals(t, "report", "out/soong/java_unused_deps/report.json", merge.Output)
+	android.AssertStringListContains(t, "merged reports", merge.Inputs.Strings(), analyze.Output.String())
+
+	// Test that nothing is analyzed without SOONG_JAVA_UNUSED_DEPS.
+	result = prepareForJavaTest.RunTestWithBp(t, bp)
+	if result.ModuleForTests("foo", "android_common").MaybeRule("javaUnusedDeps").Rule != nil {
+		t.Errorf("expected no unused deps report without SOONG_JAVA_UNUSED_DEPS")
+	}
+	if outputs := result.SingletonForTests("java_unused_deps").AllOutputs(); len(outputs) != 0 {
+		t.Errorf("expected no java unused deps report without SOONG_JAVA_UNUSED_DEPS, got %q", outputs)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package javasrc reads Java and Kotlin source files for the tools that need to know how they are
+// laid out.
+package javasrc
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var packageRegexp = regexp.MustCompile(`^\s*package\s+([\w.]+)`)
+
+// PackagePath returns the directory of a Java or Kotlin source file relative to its source root,
+// according to its package declaration.  Files without a package declaration are in the root.
+func PackagePath(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	inComment := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if inComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				continue
+			}
+			line = strings.TrimSpace(line[end+2:])
+			inComment = false
+		}
+		if strings.HasPrefix(line, "/*") {
+			if end := strings.Index(line, "*/"); end >= 0 {
+				line = strings.TrimSpace(line[end+2:])
+			} else {
+				inComment = true
+				continue
+			}
+		}
+		if line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "@") {
+			continue
+		}
+		if m := packageRegexp.FindStringSubmatch(line); m != nil {
+			return strings.ReplaceAll(m[1], ".", "/"), nil
+		}
+		// The package declaration is the first statement of a source file.
+		return "", nil
+	}
+	return "", scanner.Err()
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package javasrc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPackagePath(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   string
+		expected string
+	}{
+		{
+			name:     "java",
+			source:   "package com.android.foo;\n\nclass Foo {}\n",
+			expected: "com/android/foo",
+		},
+		{
+			name: "license header",
+			source: `/*
+ * Copyright (C) 2022 The Android Open Source Project
+ */
+
+// package not.this;
+package com.android.foo;
+`,
+			expected: "com/android/foo",
+		},
+		{
+			name:     "kotlin file annotation",
+			source:   "@file:JvmName(\"Foo\")\npackage com.android.foo\n\nfun foo() {}\n",
+			expected: "com/android/foo",
+		},
+		{
+			name:     "default package",
+			source:   "/* Foo */ import java.util.List;\n\nclass Foo {}\n",
+			expected: "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := PackagePath(strings.NewReader(tc.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected package path %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package makedeps
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseNinjaDeps parses the output of `ninja -t deps`, which lists the dependencies stored in the
+// ninja deps log for each output whose rule used a depfile:
+//
+//	out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o: #deps 2, deps mtime 1 (VALID)
+//	    foo/foo.cpp
+//	    foo/include/foo.h
+func ParseNinjaDeps(r io.Reader) ([]*Deps, error) {
+	var ret []*Deps
+	var cur *Deps
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			cur = nil
+		case line[0] == ' ' || line[0] == '\t':
+			if cur == nil {
+				return nil, fmt.Errorf("line %d: dependency %q without an output", lineNumber,
+					strings.TrimSpace(line))
+			}
+			cur.Inputs = append(cur.Inputs, strings.TrimSpace(line))
+		default:
+			i := strings.Index(line, ": #deps")
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected \"<output>: #deps\", got %q", lineNumber, line)
+			}
+			cur = &Deps{Output: line[:i]}
+			ret = append(ret, cur)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
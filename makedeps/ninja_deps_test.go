@@ -0,0 +1,83 @@
+// Copyright 2022 Google Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package makedeps
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseNinjaDeps(t *testing.T) {
+	input := `out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o: #deps 3, deps mtime 1652 (VALID)
+    foo/foo.cpp
+    foo/include/foo.h
+    bionic/libc/include/stdio.h
+
+out/soong/.intermediates/foo/foo-aidl/gen/IFoo.cpp: #deps 1, deps mtime 1652 (VALID)
+    foo/IFoo.aidl
+
+out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o: #deps 0, deps mtime 1652 (STALE)
+`
+
+	got, err := ParseNinjaDeps(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	want := []*Deps{
+		{
+			Output: "out/soong/.intermediates/foo/libfoo/android_arm64_armv8-a_shared/obj/foo/foo.o",
+			Inputs: []string{"foo/foo.cpp", "foo/include/foo.h", "bionic/libc/include/stdio.h"},
+		},
+		{
+			Output: "out/soong/.intermediates/foo/foo-aidl/gen/IFoo.cpp",
+			Inputs: []string{"foo/IFoo.aidl"},
+		},
+		{
+			Output: "out/soong/.intermediates/bar/bar/android_arm64_armv8-a/obj/bar/bar.o",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("incorrect deps\nwant: %#v\n got: %#v", want, got)
+	}
+}
+
+func TestParseNinjaDepsErrors(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "dependency without output",
+			input: "    foo/foo.cpp\n",
+			err:   `line 1: dependency "foo/foo.cpp" without an output`,
+		},
+		{
+			name:  "malformed output",
+			input: "foo.o: foo.cpp\n",
+			err:   `line 1: expected "<output>: #deps", got "foo.o: foo.cpp"`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseNinjaDeps(strings.NewReader(tc.input))
+			if err == nil || err.Error() != tc.err {
+				t.Errorf("expected error %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
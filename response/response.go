@@ -17,6 +17,7 @@ package response
 import (
 	"io"
 	"io/ioutil"
+	"os"
 	"strings"
 	"unicode"
 )
@@ -70,6 +71,28 @@ func ReadRspFile(r io.Reader) ([]string, error) {
 	return files, nil
 }
 
+// ExpandRspFiles replaces arguments of the form @file with the contents of the response file.
+func ExpandRspFiles(args []string) ([]string, error) {
+	var ret []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "@") {
+			f, err := os.Open(strings.TrimPrefix(arg, "@"))
+			if err != nil {
+				return nil, err
+			}
+			rspArgs, err := ReadRspFile(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, rspArgs...)
+		} else {
+			ret = append(ret, arg)
+		}
+	}
+	return ret, nil
+}
+
 func rspUnsafeChar(r rune) bool {
 	switch {
 	case 'A' <= r && r <= 'Z',
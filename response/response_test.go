@@ -16,6 +16,8 @@ package response
 
 import (
 	"bytes"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -121,3 +123,23 @@ func TestWriteRspFile(t *testing.T) {
 		})
 	}
 }
+
+func TestExpandRspFiles(t *testing.T) {
+	rspFile := filepath.Join(t.TempDir(), "files.rsp")
+	if err := ioutil.WriteFile(rspFile, []byte("b 'c d'\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ExpandRspFiles([]string{"a", "@" + rspFile, "e"})
+	if err != nil {
+		t.Fatalf("unexpected error: %q", err)
+	}
+	expected := []string{"a", "b", "c d", "e"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %q got %q", expected, got)
+	}
+
+	if _, err := ExpandRspFiles([]string{"@" + rspFile + ".missing"}); err == nil {
+		t.Errorf("expected an error for a missing response file")
+	}
+}
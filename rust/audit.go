@@ -0,0 +1,214 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// This singleton writes an audit report for every Rust binary and FFI library, listing the crates
+// linked into it with their edition, features and dependencies, whether they are third-party
+// crates, and how much unsafe code they contain. The unsafe code of each crate is counted through
+// the unsafe_code lint of rustc, and the rust_audit tool merges the counts into the report.
+// The reports are generated in $OUT/soong/rust_audit/<module>/<variant>.json by the rust-audit
+// goal when SOONG_RUST_AUDIT is set.
+
+func init() {
+	android.RegisterSingletonType("rust_audit", rustAuditSingletonFactory)
+	pctx.HostBinToolVariable("rustAuditCmd", "rust_audit")
+}
+
+var rustAudit = pctx.AndroidStaticRule("rustAudit",
+	blueprint.RuleParams{
+		Command:     "$rustAuditCmd -o $out $in",
+		CommandDeps: []string{"$rustAuditCmd"},
+	})
+
+func auditEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_RUST_AUDIT")
+}
+
+func (compiler *baseCompiler) crateFeatures() []string {
+	return compiler.Properties.Features
+}
+
+// auditCrate is a crate in the audit manifest read by the rust_audit tool.
+type auditCrate struct {
+	Module      string   `json:"module"`
+	Crate_name  string   `json:"crate_name"`
+	Kind        string   `json:"kind"`
+	Edition     string   `json:"edition,omitempty"`
+	Version     string   `json:"version,omitempty"`
+	Features    []string `json:"features,omitempty"`
+	Third_party bool     `json:"third_party"`
+	Prebuilt    bool     `json:"prebuilt,omitempty"`
+	Blueprint   string   `json:"blueprint"`
+	Deps        []string `json:"deps,omitempty"`
+	// The unsafe_code diagnostics of rustc for the crate, missing for prebuilt crates.
+	Unsafe_diagnostics string `json:"unsafe_diagnostics,omitempty"`
+}
+
+// auditManifest is the audit manifest of a module, the first crate is the crate of the module.
+type auditManifest struct {
+	Name      string       `json:"name"`
+	Variant   string       `json:"variant"`
+	Blueprint string       `json:"blueprint"`
+	Output    string       `json:"output"`
+	Crates    []auditCrate `json:"crates"`
+}
+
+// auditCrateKind returns the crate type of a module, as passed to rustc.
+func auditCrateKind(mod *Module) string {
+	switch compiler := mod.compiler.(type) {
+	case *procMacroDecorator:
+		return "proc-macro"
+	case libraryInterface:
+		switch {
+		case compiler.rlib():
+			return "rlib"
+		case compiler.dylib():
+			return "dylib"
+		case compiler.shared():
+			return "cdylib"
+		case compiler.static():
+			return "staticlib"
+		}
+	}
+	return "bin"
+}
+
+// audited returns true for the modules that get an audit report, binaries and FFI libraries.
+func audited(mod *Module) bool {
+	if !mod.Enabled() || mod.UnstrippedOutputFile() == nil {
+		return false
+	}
+	switch compiler := mod.compiler.(type) {
+	case *buildScriptDecorator:
+		return false
+	case binaryInterface:
+		return true
+	case libraryInterface:
+		return compiler.shared() || compiler.static()
+	}
+	return false
+}
+
+func rustAuditSingletonFactory() android.Singleton {
+	return &rustAuditSingleton{}
+}
+
+type rustAuditSingleton struct{}
+
+func (r *rustAuditSingleton) auditCrate(ctx android.SingletonContext, mod *Module) auditCrate {
+	crate := auditCrate{
+		Module:      ctx.ModuleName(mod),
+		Crate_name:  mod.CrateName(),
+		Kind:        auditCrateKind(mod),
+		Third_party: android.IsThirdPartyPath(ctx.ModuleDir(mod)),
+		Prebuilt:    mod.IsPrebuilt(),
+		Blueprint:   ctx.BlueprintFile(mod),
+	}
+	if compiler, ok := mod.compiler.(interface {
+		edition() string
+		crateFeatures() []string
+	}); ok && !crate.Prebuilt {
+		crate.Edition = compiler.edition()
+		crate.Features = android.SortedUniqueStrings(compiler.crateFeatures())
+	}
+	if mod.compiler != nil {
+		crate.Version = mod.compiler.CargoPkgVersion()
+	}
+	for _, dep := range mod.auditDeps {
+		crate.Deps = append(crate.Deps, ctx.ModuleName(dep))
+	}
+	crate.Deps = android.SortedUniqueStrings(crate.Deps)
+	if mod.unsafeAuditFile.Valid() {
+		crate.Unsafe_diagnostics = mod.unsafeAuditFile.Path().String()
+	}
+	return crate
+}
+
+func (r *rustAuditSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !auditEnabled(ctx.Config()) {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		mod, ok := module.(*Module)
+		if !ok || !audited(mod) {
+			return
+		}
+
+		// Collect the crates linked into the module, the module itself first and its transitive
+		// dependencies sorted by name.
+		seen := map[*Module]bool{mod: true}
+		var deps []*Module
+		var visit func(*Module)
+		visit = func(m *Module) {
+			for _, dep := range m.auditDeps {
+				if !seen[dep] {
+					seen[dep] = true
+					deps = append(deps, dep)
+					visit(dep)
+				}
+			}
+		}
+		visit(mod)
+		sort.SliceStable(deps, func(i, j int) bool {
+			return ctx.ModuleName(deps[i]) < ctx.ModuleName(deps[j])
+		})
+
+		manifest := auditManifest{
+			Name:      ctx.ModuleName(mod),
+			Variant:   ctx.ModuleSubDir(mod),
+			Blueprint: ctx.BlueprintFile(mod),
+			Output:    mod.UnstrippedOutputFile().String(),
+		}
+		var implicits android.Paths
+		for _, m := range append([]*Module{mod}, deps...) {
+			manifest.Crates = append(manifest.Crates, r.auditCrate(ctx, m))
+			if m.unsafeAuditFile.Valid() {
+				implicits = append(implicits, m.unsafeAuditFile.Path())
+			}
+		}
+
+		buf, err := json.MarshalIndent(manifest, "", "\t")
+		if err != nil {
+			ctx.Errorf("JSON marshal of the rust audit manifest of %s failed: %s", manifest.Name, err)
+			return
+		}
+
+		manifestFile := android.PathForOutput(ctx, "rust_audit", manifest.Name, manifest.Variant+".manifest.json")
+		android.WriteFileRule(ctx, manifestFile, string(buf))
+
+		report := android.PathForOutput(ctx, "rust_audit", manifest.Name, manifest.Variant+".json")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        rustAudit,
+			Description: "rust audit " + manifest.Name,
+			Output:      report,
+			Input:       manifestFile,
+			Implicits:   implicits,
+		})
+		reports = append(reports, report)
+	})
+
+	ctx.Phony("rust-audit", reports...)
+}
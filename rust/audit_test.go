@@ -0,0 +1,98 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestRustAudit(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureMergeEnv(map[string]string{"SOONG_RUST_AUDIT": "true"}),
+		android.FixtureAddTextFile("external/rust/crates/bar/Android.bp", `
+			rust_library {
+				name: "libbar",
+				crate_name: "bar",
+				srcs: ["lib.rs"],
+				edition: "2018",
+				features: ["std", "alloc"],
+				cargo_pkg_version: "1.2.3",
+				proc_macros: ["libbar_derive"],
+				host_supported: true,
+			}
+			rust_proc_macro {
+				name: "libbar_derive",
+				crate_name: "bar_derive",
+				srcs: ["lib.rs"],
+			}
+		`),
+		android.FixtureAddFile("external/rust/crates/bar/lib.rs", nil),
+	).RunTestWithBp(t, `
+		rust_binary_host {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rustlibs: ["libbar"],
+			lints: "android",
+		}
+	`)
+
+	fizz := result.ModuleForTests("fizz", "linux_glibc_x86_64")
+	audit := fizz.Output("fizz.unsafe.json")
+	for _, expected := range []string{"--crate-name=fizz", "--crate-type=bin"} {
+		android.AssertStringDoesContain(t, "unsafe audit flags", audit.Args["rustcFlags"], expected)
+	}
+	android.AssertStringDoesNotContain(t, "unsafe audit flags", audit.Args["rustcFlags"], "${config.RustDefaultLints}")
+	android.AssertStringDoesContain(t, "rustc flags", fizz.Rule("rustc").Args["rustcFlags"], "${config.RustDefaultLints}")
+
+	singleton := result.SingletonForTests("rust_audit")
+	manifestFile := singleton.Output("rust_audit/fizz/linux_glibc_x86_64.manifest.json")
+	var manifest auditManifest
+	if err := json.Unmarshal([]byte(android.ContentFromFileRuleForTests(t, manifestFile)), &manifest); err != nil {
+		t.Fatalf("invalid audit manifest: %s", err)
+	}
+
+	var crates []string
+	for _, crate := range manifest.Crates {
+		crates = append(crates, crate.Module+":"+crate.Kind)
+	}
+	android.AssertDeepEquals(t, "audited crates",
+		[]string{"fizz:bin", "libbar:rlib", "libbar_derive:proc-macro", "libstd:rlib"}, crates)
+
+	fizzCrate, bar, barDerive, std := manifest.Crates[0], manifest.Crates[1], manifest.Crates[2], manifest.Crates[3]
+	android.AssertDeepEquals(t, "fizz deps", []string{"libbar", "libstd"}, fizzCrate.Deps)
+	android.AssertBoolEquals(t, "fizz is third-party", false, fizzCrate.Third_party)
+
+	android.AssertBoolEquals(t, "libbar is third-party", true, bar.Third_party)
+	android.AssertStringEquals(t, "libbar edition", "2018", bar.Edition)
+	android.AssertStringEquals(t, "libbar version", "1.2.3", bar.Version)
+	android.AssertDeepEquals(t, "libbar features", []string{"alloc", "std"}, bar.Features)
+	android.AssertDeepEquals(t, "libbar deps", []string{"libbar_derive", "libstd"}, bar.Deps)
+	if !strings.HasSuffix(barDerive.Unsafe_diagnostics, "libbar_derive.so.unsafe.json") {
+		t.Errorf("expected unsafe diagnostics for libbar_derive, got %q", barDerive.Unsafe_diagnostics)
+	}
+
+	android.AssertBoolEquals(t, "libstd is prebuilt", true, std.Prebuilt)
+	android.AssertStringEquals(t, "libstd unsafe diagnostics", "", std.Unsafe_diagnostics)
+
+	report := singleton.Output("rust_audit/fizz/linux_glibc_x86_64.json")
+	android.AssertPathRelativeToTopEquals(t, "audit manifest", "out/soong/rust_audit/fizz/linux_glibc_x86_64.manifest.json", report.Input)
+	android.AssertStringListContains(t, "audit implicits", report.Implicits.Strings(), fizz.Output("fizz.unsafe.json").Output.String())
+}
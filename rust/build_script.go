@@ -0,0 +1,235 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	cc_config "android/soong/cc/config"
+	"android/soong/rust/config"
+)
+
+func init() {
+	android.RegisterModuleType("rust_build_script", RustBuildScriptFactory)
+}
+
+const buildScriptSubDir = "build_script"
+
+type BuildScriptProperties struct {
+	// list of files the build script writes to OUT_DIR that are used when compiling the crates
+	// it is run for, relative to OUT_DIR.
+	Out []string
+
+	// list of files read by the build script, other than its sources. The build script is run
+	// in a sandbox from the directory of this module, which is also its CARGO_MANIFEST_DIR, and
+	// can only read the files listed here.
+	Data []string `android:"path"`
+}
+
+type buildScriptDecorator struct {
+	*binaryDecorator
+
+	Properties BuildScriptProperties
+
+	// manifestDir is the directory the build script is run from.
+	manifestDir android.Path
+	data        android.Paths
+}
+
+var _ compiler = (*buildScriptDecorator)(nil)
+
+// rust_build_script compiles the build script (build.rs) of a crate for the host. The crates that
+// reference it with their build_script property run it before they are compiled, the same way
+// Cargo does: the files it writes to OUT_DIR are available in their OUT_DIR, and the cfgs and
+// environment variables it sets with cargo:rustc-cfg and cargo:rustc-env are passed to rustc.
+func RustBuildScriptFactory() android.Module {
+	module, _ := NewRustBuildScript(android.HostSupportedNoCross)
+	return module.Init()
+}
+
+func NewRustBuildScript(hod android.HostOrDeviceSupported) (*Module, *buildScriptDecorator) {
+	module, binary := NewRustBinary(hod)
+
+	buildScript := &buildScriptDecorator{
+		binaryDecorator: binary,
+	}
+
+	// Don't sanitize build scripts, they only run during the build.
+	module.sanitize = nil
+	module.compiler = buildScript
+
+	return module, buildScript
+}
+
+func (buildScript *buildScriptDecorator) compilerProps() []interface{} {
+	return append(buildScript.binaryDecorator.compilerProps(),
+		&buildScript.Properties)
+}
+
+func (buildScript *buildScriptDecorator) compile(ctx ModuleContext, flags Flags, deps PathDeps) android.Path {
+	buildScript.manifestDir = android.PathForModuleSrc(ctx)
+	buildScript.data = android.PathsForModuleSrc(ctx, buildScript.Properties.Data)
+
+	return buildScript.binaryDecorator.compile(ctx, flags, deps)
+}
+
+func (buildScript *buildScriptDecorator) everInstallable() bool {
+	// Build scripts are only run during the build.
+	return false
+}
+
+// buildScriptDeps holds the outputs of a build script run for a crate.
+type buildScriptDeps struct {
+	// outputs are the files written to OUT_DIR.
+	outputs android.Paths
+	// cfgs is a rustc argument file with the cfgs set by the build script.
+	cfgs android.Path
+	// env is a shell script that exports the environment variables set by the build script.
+	env android.Path
+}
+
+// cargoTargetEnv returns the CARGO_CFG_TARGET_* environment variables Cargo sets for build
+// scripts, which describe the target the crate is compiled for.
+func cargoTargetEnv(toolchain config.Toolchain) map[string]string {
+	triple := toolchain.RustTriple()
+	parts := strings.Split(triple, "-")
+
+	arch := parts[0]
+	switch arch {
+	case "armv7":
+		arch = "arm"
+	case "i686":
+		arch = "x86"
+	}
+
+	var targetOs, env, vendor string
+	switch {
+	case strings.Contains(triple, "android"):
+		targetOs, vendor = "android", "unknown"
+	case strings.Contains(triple, "linux"):
+		targetOs, vendor = "linux", "unknown"
+	case strings.Contains(triple, "darwin"):
+		targetOs, vendor = "macos", "apple"
+	case strings.Contains(triple, "windows"):
+		targetOs, vendor = "windows", "pc"
+	}
+	switch last := parts[len(parts)-1]; last {
+	case "gnu", "musl":
+		env = last
+	}
+
+	family := "unix"
+	if targetOs == "windows" {
+		family = "windows"
+	}
+
+	pointerWidth := "32"
+	if toolchain.Is64Bit() {
+		pointerWidth = "64"
+	}
+
+	return map[string]string{
+		"TARGET":                               triple,
+		"CARGO_CFG_TARGET_ARCH":                arch,
+		"CARGO_CFG_TARGET_OS":                  targetOs,
+		"CARGO_CFG_TARGET_ENV":                 env,
+		"CARGO_CFG_TARGET_FAMILY":              family,
+		"CARGO_CFG_TARGET_VENDOR":              vendor,
+		"CARGO_CFG_TARGET_ENDIAN":              "little",
+		"CARGO_CFG_TARGET_POINTER_WIDTH":       pointerWidth,
+		"CARGO_CFG_" + strings.ToUpper(family): "",
+	}
+}
+
+// runBuildScript runs the build script of the crate in a sandbox, and returns the files it
+// writes to OUT_DIR and the files that hold the cfgs and environment variables it sets.
+func (compiler *baseCompiler) runBuildScript(ctx ModuleContext, buildScript *Module) buildScriptDeps {
+	script := buildScript.compiler.(*buildScriptDecorator)
+
+	sandboxDir := android.PathForModuleOut(ctx, buildScriptSubDir)
+	outDir := android.PathForModuleOut(ctx, buildScriptSubDir, genSubDir)
+	cfgsFile := android.PathForModuleOut(ctx, buildScriptSubDir, "cfgs.rsp")
+	envFile := android.PathForModuleOut(ctx, buildScriptSubDir, "env.sh")
+
+	var outputs android.WritablePaths
+	for _, out := range script.Properties.Out {
+		outputs = append(outputs, outDir.Join(ctx, out))
+	}
+
+	env := cargoTargetEnv(ctx.toolchain())
+	env["HOST"] = config.FindToolchain(ctx.Config().BuildOS, ctx.Config().BuildOSTarget.Arch).RustTriple()
+	env["PROFILE"] = "release"
+	env["OPT_LEVEL"] = "2"
+	env["DEBUG"] = "false"
+	env["NUM_JOBS"] = "1"
+	env["CARGO_PKG_NAME"] = compiler.crateName()
+	env["CARGO_PKG_VERSION"] = compiler.CargoPkgVersion()
+	env["CARGO_ENCODED_RUSTFLAGS"] = ""
+	for _, feature := range compiler.Properties.Features {
+		env["CARGO_FEATURE_"+strings.ToUpper(strings.ReplaceAll(feature, "-", "_"))] = "1"
+	}
+
+	rule := android.NewRuleBuilder(pctx, ctx).
+		Sbox(sandboxDir, android.PathForModuleOut(ctx, buildScriptSubDir+".sbox.textproto")).
+		SandboxInputs()
+	cmd := rule.Command().Text("env")
+
+	// The tools Cargo passes to build scripts are copied into the sandbox, along with the
+	// libraries of the Rust toolchain that rustc and rustdoc are linked against.
+	tools := map[string]android.Path{
+		"RUSTC":        config.RustPath(ctx, "bin/rustc"),
+		"RUSTDOC":      config.RustPath(ctx, "bin/rustdoc"),
+		"CARGO":        config.RustPath(ctx, "bin/cargo"),
+		"RUSTC_LINKER": cc_config.ClangPath(ctx, "bin/clang++"),
+	}
+	for _, k := range android.SortedStringKeys(tools) {
+		env[k] = cmd.PathForTool(tools[k])
+		cmd.ImplicitTool(tools[k])
+	}
+	cmd.ImplicitTools(ctx.GlobFiles(filepath.Join(config.RustPath(ctx, "lib").String(), "*.so"), nil))
+	env["CARGO_MANIFEST_DIR"] = cmd.PathForInput(script.manifestDir)
+
+	var envVars []string
+	for k, v := range env {
+		envVars = append(envVars, k+"="+proptools.ShellEscapeIncludingSpaces(v))
+	}
+	sort.Strings(envVars)
+
+	scriptPath := buildScript.UnstrippedOutputFile()
+	cmd.Flags(envVars).
+		BuiltTool("cargo_build_script")
+	cmd.FlagWithArg("-script ", cmd.PathForTool(scriptPath)).
+		ImplicitTool(scriptPath).
+		FlagWithArg("-manifest_dir ", cmd.PathForInput(script.manifestDir)).
+		Implicits(script.data).
+		FlagWithArg("-out_dir ", cmd.PathForOutput(outDir)).
+		FlagWithArg("-final_out_dir ", outDir.String()).
+		FlagWithOutput("-cfgs ", cfgsFile).
+		FlagWithOutput("-env ", envFile).
+		ImplicitOutputs(outputs)
+
+	rule.Build("build_script", "build script "+ctx.ModuleName())
+
+	return buildScriptDeps{
+		outputs: outputs.Paths(),
+		cfgs:    cfgsFile,
+		env:     envFile,
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestBuildScript(t *testing.T) {
+	ctx := testRust(t, `
+		rust_build_script {
+			name: "libfoo_build_script",
+			srcs: ["build.rs"],
+			out: ["generated.rs"],
+			data: ["data.txt"],
+		}
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			features: ["std", "some-feature"],
+			cargo_pkg_version: "1.2.3",
+			build_script: "libfoo_build_script",
+			host_supported: true,
+		}`)
+
+	foo := ctx.ModuleForTests("libfoo", "android_arm64_armv8-a_rlib_dylib-std")
+	outDir := "out/soong/.intermediates/libfoo/android_arm64_armv8-a_rlib_dylib-std/build_script"
+
+	manifest := android.RuleBuilderSboxProtoForTests(t, foo.Output("build_script.sbox.textproto"))
+	cmd := *manifest.Commands[0].Command
+	for _, expected := range []string{
+		"CARGO_CFG_TARGET_ARCH=aarch64",
+		"CARGO_CFG_TARGET_OS=android",
+		"CARGO_CFG_TARGET_POINTER_WIDTH=64",
+		"CARGO_FEATURE_SOME_FEATURE=1",
+		"CARGO_FEATURE_STD=1",
+		"CARGO_PKG_VERSION=1.2.3",
+		"TARGET=aarch64-linux-android",
+		"HOST=x86_64-unknown-linux-gnu",
+		"RUSTC=__SBOX_SANDBOX_DIR__/tools/src/prebuilts/rust/linux-x86/",
+		"RUSTDOC=__SBOX_SANDBOX_DIR__/tools/src/prebuilts/rust/linux-x86/",
+		"CARGO=__SBOX_SANDBOX_DIR__/tools/src/prebuilts/rust/linux-x86/",
+		"RUSTC_LINKER=__SBOX_SANDBOX_DIR__/tools/src/prebuilts/clang/host/linux-x86/",
+		"CARGO_MANIFEST_DIR=.",
+		"-script __SBOX_SANDBOX_DIR__/tools/",
+		"-out_dir __SBOX_SANDBOX_DIR__/out/out",
+		"-final_out_dir " + outDir + "/out",
+		"-cfgs __SBOX_SANDBOX_DIR__/out/cfgs.rsp",
+		"-env __SBOX_SANDBOX_DIR__/out/env.sh",
+	} {
+		if !strings.Contains(cmd, expected) {
+			t.Errorf("expected %q in the build script command, got: %s", expected, cmd)
+		}
+	}
+
+	buildScript := foo.Output(outDir + "/cfgs.rsp")
+	android.AssertPathsRelativeToTopEquals(t, "build script outputs",
+		[]string{outDir + "/env.sh", outDir + "/out/generated.rs"}, buildScript.ImplicitOutputs.Paths())
+
+	rustc := foo.Rule("rustc")
+	if !strings.Contains(rustc.Args["rustcFlags"], "@"+outDir+"/cfgs.rsp") {
+		t.Errorf("expected the build script cfgs to be passed to rustc, got: %s", rustc.Args["rustcFlags"])
+	}
+	if !strings.HasPrefix(rustc.Args["envVars"], ". "+outDir+"/env.sh &&") {
+		t.Errorf("expected the build script env to be sourced, got: %s", rustc.Args["envVars"])
+	}
+	if !strings.Contains(rustc.Args["envVars"], "OUT_DIR=$$PWD/"+outDir+"/out") {
+		t.Errorf("expected OUT_DIR to be the build script OUT_DIR, got: %s", rustc.Args["envVars"])
+	}
+	android.AssertStringListContains(t, "rustc implicits", rustc.Implicits.Strings(), outDir+"/out/generated.rs")
+
+	// The build script runs again for each variant of the crate, with the target of the variant.
+	hostManifest := android.RuleBuilderSboxProtoForTests(t,
+		ctx.ModuleForTests("libfoo", "linux_glibc_x86_64_rlib_rlib-std").Output("build_script.sbox.textproto"))
+	if !strings.Contains(*hostManifest.Commands[0].Command, "TARGET=x86_64-unknown-linux-gnu") {
+		t.Errorf("expected the host target, got: %s", *hostManifest.Commands[0].Command)
+	}
+}
+
+func TestBuildScriptErrors(t *testing.T) {
+	testRustError(t, `"libbar" is not a rust_build_script module`, `
+		rust_binary_host {
+			name: "libbar",
+			srcs: ["build.rs"],
+		}
+		rust_library_host {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			build_script: "libbar",
+		}`)
+}
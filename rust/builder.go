@@ -21,6 +21,7 @@ import (
 	"github.com/google/blueprint"
 
 	"android/soong/android"
+	"android/soong/bloaty"
 	"android/soong/rust/config"
 )
 
@@ -53,6 +54,35 @@ var (
 		},
 		"rustdocFlags", "outDir", "envVars")
 
+	// rustdocTest compiles the documentation tests of a crate, and zips the binaries of the tests
+	// that rustdoc would run, which are collected by the rustdocCollectDoctestCmd runtool, so that
+	// they are run when the test is run. rustdoc fails when a documentation test doesn't compile,
+	// or when it is marked should_panic, as the collected binaries are expected to succeed.
+	_           = pctx.SourcePathVariable("rustdocCollectDoctestCmd", "build/soong/scripts/rustdoc_collect_doctest.sh")
+	rustdocTest = pctx.AndroidStaticRule("rustdocTest",
+		blueprint.RuleParams{
+			Command: "rm -rf $outDir $persistDir && mkdir -p $outDir $persistDir && " +
+				"$envVars DOCTEST_OUT_DIR=$$(cd $outDir && pwd) $rustdocCmd --test $rustdocFlags $in " +
+				"-Z unstable-options --persist-doctests $persistDir --runtool $rustdocCollectDoctestCmd " +
+				"-C linker=${config.RustLinker} " +
+				"-C link-args=\"${config.RustLinkerArgs} ${linkFlags}\" " +
+				"${libFlags} && " +
+				"${SoongZipCmd} -o $out -C $outDir -D $outDir",
+			CommandDeps: []string{"$rustdocCmd", "$rustdocCollectDoctestCmd", "${SoongZipCmd}"},
+		},
+		"rustdocFlags", "linkFlags", "libFlags", "envVars", "outDir", "persistDir")
+
+	// unsafeAudit reports the unsafe code of a crate through the unsafe_code lint of rustc for the
+	// rust audit report. The lint is forced to warn regardless of the lints of the module or the
+	// allow attributes in the crate.
+	unsafeAudit = pctx.AndroidStaticRule("unsafeAudit",
+		blueprint.RuleParams{
+			Command: "$envVars $rustcCmd --emit metadata -o $out.rmeta $in ${libFlags} $rustcFlags " +
+				"--cap-lints warn --force-warn unsafe_code --error-format=json 2> $out.tmp && mv $out.tmp $out",
+			CommandDeps: []string{"$rustcCmd"},
+		},
+		"rustcFlags", "libFlags", "envVars")
+
 	_            = pctx.SourcePathVariable("clippyCmd", "${config.RustBin}/clippy-driver")
 	clippyDriver = pctx.AndroidStaticRule("clippy",
 		blueprint.RuleParams{
@@ -68,6 +98,24 @@ var (
 		},
 		"rustcFlags", "libFlags", "clippyFlags", "envVars")
 
+	// rustcLints and clippyLints report the warnings of a crate as JSON diagnostics for its lint
+	// report. Lints that deny the build are reported as warnings.
+	rustcLints = pctx.AndroidStaticRule("rustcLints",
+		blueprint.RuleParams{
+			Command: "$envVars $rustcCmd --emit metadata -o $out.rmeta $in ${libFlags} $rustcFlags " +
+				"--cap-lints warn --error-format=json 2> $out.tmp && mv $out.tmp $out",
+			CommandDeps: []string{"$rustcCmd"},
+		},
+		"rustcFlags", "libFlags", "envVars")
+
+	clippyLints = pctx.AndroidStaticRule("clippyLints",
+		blueprint.RuleParams{
+			Command: "$envVars $clippyCmd --emit metadata -o $out.rmeta $in ${libFlags} $rustcFlags $clippyFlags " +
+				"--cap-lints warn --error-format=json 2> $out.tmp && mv $out.tmp $out",
+			CommandDeps: []string{"$clippyCmd"},
+		},
+		"rustcFlags", "libFlags", "clippyFlags", "envVars")
+
 	zip = pctx.AndroidStaticRule("zip",
 		blueprint.RuleParams{
 			Command:        "cat $out.rsp | tr ' ' '\\n' | tr -d \\' | sort -u > ${out}.tmp && ${SoongZipCmd} -o ${out} -C $$OUT_DIR -l ${out}.tmp",
@@ -127,6 +175,30 @@ func TransformSrctoProcMacro(ctx ModuleContext, mainSrc android.Path, deps PathD
 	return transformSrctoCrate(ctx, mainSrc, deps, flags, outputFile, "proc-macro")
 }
 
+// pipelinedCompilationEnabled returns true when the metadata of rlibs is emitted along with them, so
+// that dependent rlibs are compiled against the metadata of their dependencies. Only the crates that
+// are linked then depend on the rlibs of their transitive dependencies.
+func pipelinedCompilationEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_RUST_PIPELINED_COMPILATION")
+}
+
+// metadataDeps returns the dependencies of a crate that isn't linked, with the rlibs that have
+// metadata and the directories of their transitive dependencies replaced by their metadata.
+func metadataDeps(deps PathDeps) PathDeps {
+	rlibs := make(RustLibraries, 0, len(deps.RLibs))
+	for _, lib := range deps.RLibs {
+		if lib.Metadata.Valid() {
+			lib.Path = lib.Metadata.Path()
+		}
+		rlibs = append(rlibs, lib)
+	}
+	deps.RLibs = rlibs
+	// The rlib directories must not be searched, rustc rejects a crate whose rlib and metadata
+	// found in the search paths don't match, which happens while the rlib is being rebuilt.
+	deps.linkDirs = append(android.RemoveListFromList(deps.linkDirs, deps.rlibLinkDirs), deps.metadataLinkDirs...)
+	return deps
+}
+
 func rustLibsToPaths(libs RustLibraries) android.Paths {
 	var paths android.Paths
 	for _, lib := range libs {
@@ -166,7 +238,7 @@ func rustEnvVars(ctx ModuleContext, deps PathDeps) []string {
 		envVars = append(envVars, "STD_ENV_ARCH="+config.StdEnvArch[ctx.RustModule().Arch().ArchType])
 	}
 
-	if len(deps.SrcDeps) > 0 {
+	if len(deps.SrcDeps) > 0 || deps.buildScript != nil {
 		moduleGenDir := ctx.RustModule().compiler.CargoOutDir()
 		// We must calculate an absolute path for OUT_DIR since Rust's include! macro (which normally consumes this)
 		// assumes that paths are relative to the source file.
@@ -181,9 +253,25 @@ func rustEnvVars(ctx ModuleContext, deps PathDeps) []string {
 		envVars = append(envVars, "OUT_DIR="+filepath.Join(outDirPrefix, moduleGenDir.String()))
 	}
 
+	if deps.buildScript != nil {
+		// Export the environment variables set by the build script before running the compiler.
+		envVars = append([]string{". " + deps.buildScriptOutputs.env.String() + " &&"}, envVars...)
+	}
+
 	return envVars
 }
 
+// buildScriptFlags returns the rustc flags and the implicit inputs for the outputs of the build
+// script of the crate.
+func buildScriptFlags(deps PathDeps) ([]string, android.Paths) {
+	if deps.buildScript == nil {
+		return nil, nil
+	}
+	outputs := deps.buildScriptOutputs
+	implicits := append(android.Paths{outputs.cfgs, outputs.env}, outputs.outputs...)
+	return []string{"@" + outputs.cfgs.String()}, implicits
+}
+
 func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, flags Flags,
 	outputFile android.WritablePath, crateType string) buildOutput {
 
@@ -204,6 +292,9 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 	// Collect rustc flags
 	rustcFlags = append(rustcFlags, flags.GlobalRustFlags...)
 	rustcFlags = append(rustcFlags, flags.RustFlags...)
+	rustcFlags = append(rustcFlags, flags.LintFlags...)
+	buildScriptRustcFlags, buildScriptImplicits := buildScriptFlags(deps)
+	rustcFlags = append(rustcFlags, buildScriptRustcFlags...)
 	rustcFlags = append(rustcFlags, "--crate-type="+crateType)
 	if crateName != "" {
 		rustcFlags = append(rustcFlags, "--crate-name="+crateName)
@@ -223,6 +314,25 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		rustcFlags = append(rustcFlags, "-C incremental="+incrementalPath)
 	}
 
+	pipelined := crateType == "rlib" && pipelinedCompilationEnabled(ctx.Config())
+	if pipelined {
+		// rlibs aren't linked, so they only need the metadata of their dependencies. The MIR of all
+		// items is encoded so that dependents can generate the code of the generic and inline
+		// functions of the crate from its metadata.
+		deps = metadataDeps(deps)
+		rustcFlags = append(rustcFlags, "-Z always-encode-mir")
+	}
+
+	// With a lint baseline, the warnings of the crate are checked by its lint report instead, so
+	// the lints are only applied to the steps that report them.
+	lintReport := (lintReportEnabled(ctx.Config()) || flags.LintBaseline.Valid()) &&
+		!config.AllowsAllLints(flags.LintFlags)
+	baselined := lintReport && flags.LintBaseline.Valid()
+	lintedRustcFlags := rustcFlags
+	if baselined {
+		rustcFlags = append(android.RemoveListFromList(rustcFlags, flags.LintFlags), "${config.RustAllowAllLints}")
+	}
+
 	// Collect linker flags
 	linkFlags = append(linkFlags, flags.GlobalLinkFlags...)
 	linkFlags = append(linkFlags, flags.LinkFlags...)
@@ -236,6 +346,15 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		linkFlags = append(linkFlags, dynamicLinker)
 	}
 
+	// Write a linker map for linked crates when binary size attribution is enabled, so that their
+	// size can be attributed to the crates and static libraries linked into them.
+	linked := crateType == "bin" || crateType == "dylib" || crateType == "cdylib"
+	if linked && bloaty.SizeAttributionEnabled(ctx.Config()) && !ctx.Darwin() && !ctx.Windows() {
+		linkerMap := bloaty.LinkerMapForSizeAttribution(ctx, outputFile)
+		linkFlags = append(linkFlags, "-Wl,-Map="+linkerMap.String())
+		implicitOutputs = append(implicitOutputs, linkerMap)
+	}
+
 	libFlags := makeLibFlags(deps)
 
 	// Collect dependencies
@@ -246,11 +365,22 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 	implicits = append(implicits, deps.SharedLibDeps...)
 	implicits = append(implicits, deps.srcProviderFiles...)
 	implicits = append(implicits, deps.AfdoProfiles...)
+	implicits = append(implicits, buildScriptImplicits...)
 
 	implicits = append(implicits, deps.CrtBegin...)
 	implicits = append(implicits, deps.CrtEnd...)
 
+	if crateType != "rlib" {
+		// The rlibs of transitive dependencies are linked into the crate, and may have been
+		// compiled after its direct dependencies when they were compiled against their metadata.
+		implicits = append(implicits, deps.pipelinedRlibs...)
+	}
+
 	if len(deps.SrcDeps) > 0 {
+		if deps.buildScript != nil {
+			ctx.PropertyErrorf("build_script", "cannot be used with generated sources in srcs, "+
+				"the build script owns OUT_DIR")
+		}
 		moduleGenDir := ctx.RustModule().compiler.CargoOutDir()
 		var outputs android.WritablePaths
 
@@ -287,7 +417,25 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		}
 	}
 
-	if flags.Clippy {
+	if auditEnabled(ctx.Config()) {
+		auditFile := android.PathForModuleOut(ctx, outputFile.Base()+".unsafe.json")
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        unsafeAudit,
+			Description: "unsafe audit " + main.Rel(),
+			Output:      auditFile,
+			Inputs:      inputs,
+			Implicits:   implicits,
+			Args: map[string]string{
+				// The lints of the module would conflict with the lint flags of the audit.
+				"rustcFlags": strings.Join(android.RemoveListFromList(lintedRustcFlags, flags.LintFlags), " "),
+				"libFlags":   strings.Join(libFlags, " "),
+				"envVars":    strings.Join(envVars, " "),
+			},
+		})
+		ctx.RustModule().unsafeAuditFile = android.OptionalPathForPath(auditFile)
+	}
+
+	if flags.Clippy && !baselined {
 		clippyFile := android.PathForModuleOut(ctx, outputFile.Base()+".clippy")
 		ctx.Build(pctx, android.BuildParams{
 			Rule:            clippyDriver,
@@ -307,6 +455,47 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 		implicits = append(implicits, clippyFile)
 	}
 
+	if lintReport {
+		diagnostics := android.PathForModuleOut(ctx, outputFile.Base()+".lints.json")
+		args := map[string]string{
+			"rustcFlags": strings.Join(lintedRustcFlags, " "),
+			"libFlags":   strings.Join(libFlags, " "),
+			"envVars":    strings.Join(envVars, " "),
+		}
+		rule := rustcLints
+		if flags.Clippy {
+			rule = clippyLints
+			args["clippyFlags"] = strings.Join(flags.ClippyFlags, " ")
+		}
+		ctx.Build(pctx, android.BuildParams{
+			Rule:        rule,
+			Description: "lints " + main.Rel(),
+			Output:      diagnostics,
+			Inputs:      inputs,
+			Implicits:   implicits,
+			Args:        args,
+		})
+
+		report := android.PathForModuleOut(ctx, outputFile.Base()+".lint_report.json")
+		TransformLintsToReport(ctx, diagnostics, flags.LintBaseline, report)
+		ctx.RustModule().lintReportFile = android.OptionalPathForPath(report)
+		if baselined {
+			// Fail the build of the crate on warnings that aren't in the baseline.
+			implicits = append(implicits, report)
+		}
+	}
+
+	if pipelined {
+		// The metadata is emitted by the same invocation of rustc as the rlib, so that the hash of
+		// the crate that dependents are compiled against matches the one of the rlib they are linked
+		// with.
+		metadataFile := android.PathForModuleOut(ctx, "meta",
+			strings.TrimSuffix(outputFile.Base(), outputFile.Ext())+".rmeta")
+		rustcFlags = append(rustcFlags, "--emit metadata="+metadataFile.String())
+		implicitOutputs = append(implicitOutputs, metadataFile)
+		ctx.RustModule().metadataFile = android.OptionalPathForPath(metadataFile)
+	}
+
 	ctx.Build(pctx, android.BuildParams{
 		Rule:            rustc,
 		Description:     "rustc " + main.Rel(),
@@ -327,6 +516,64 @@ func transformSrctoCrate(ctx ModuleContext, main android.Path, deps PathDeps, fl
 	return output
 }
 
+// RustdocTest compiles the documentation tests of the crate rooted at main, and writes an
+// executable to outputFile that runs them when the test is run. The binaries of the documentation
+// tests are zipped into the returned file, which must be installed next to the executable.
+func RustdocTest(ctx ModuleContext, main android.Path, deps PathDeps, flags Flags,
+	outputFile android.WritablePath) android.Path {
+
+	rustdocFlags := append([]string{}, flags.RustdocFlags...)
+	rustdocFlags = append(rustdocFlags, "--sysroot=/dev/null")
+	if targetTriple := ctx.toolchain().RustTriple(); targetTriple != "" {
+		rustdocFlags = append(rustdocFlags, "--target="+targetTriple)
+	}
+	if crateName := ctx.RustModule().CrateName(); crateName != "" {
+		rustdocFlags = append(rustdocFlags, "--crate-name "+crateName)
+	}
+	buildScriptRustdocFlags, buildScriptImplicits := buildScriptFlags(deps)
+	rustdocFlags = append(rustdocFlags, buildScriptRustdocFlags...)
+
+	var linkFlags []string
+	linkFlags = append(linkFlags, flags.GlobalLinkFlags...)
+	linkFlags = append(linkFlags, flags.LinkFlags...)
+
+	var implicits android.Paths
+	implicits = append(implicits, rustLibsToPaths(deps.RLibs)...)
+	implicits = append(implicits, rustLibsToPaths(deps.DyLibs)...)
+	implicits = append(implicits, rustLibsToPaths(deps.ProcMacros)...)
+	implicits = append(implicits, deps.StaticLibs...)
+	implicits = append(implicits, deps.SharedLibDeps...)
+	implicits = append(implicits, deps.pipelinedRlibs...)
+	implicits = append(implicits, buildScriptImplicits...)
+
+	doctests := android.PathForModuleOut(ctx, outputFile.Base()+".doctests.zip")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        rustdocTest,
+		Description: "rustdoc --test " + main.Rel(),
+		Output:      doctests,
+		Input:       main,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"rustdocFlags": strings.Join(rustdocFlags, " "),
+			"linkFlags":    strings.Join(linkFlags, " "),
+			"libFlags":     strings.Join(makeLibFlags(deps), " "),
+			"envVars":      strings.Join(rustEnvVars(ctx, deps), " "),
+			"outDir":       android.PathForModuleOut(ctx, "doctests", "out").String(),
+			"persistDir":   android.PathForModuleOut(ctx, "doctests", "persist").String(),
+		},
+	})
+
+	// The runner finds the binaries of the documentation tests in the zip file next to it.
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cp,
+		Description: "rust doctest runner",
+		Output:      outputFile,
+		Input:       android.PathForSource(ctx, "build/soong/scripts/rust_doctest_runner.sh"),
+	})
+
+	return doctests
+}
+
 func Rustdoc(ctx ModuleContext, main android.Path, deps PathDeps,
 	flags Flags) android.ModuleOutPath {
 
@@ -347,7 +594,17 @@ func Rustdoc(ctx ModuleContext, main android.Path, deps PathDeps,
 	crateName := ctx.RustModule().CrateName()
 	rustdocFlags = append(rustdocFlags, "--crate-name "+crateName)
 
+	// rustdoc only reads the metadata of the dependencies, and the crate may have been compiled
+	// before the rlibs of its dependencies.
+	var implicits android.Paths
+	if pipelinedCompilationEnabled(ctx.Config()) {
+		deps = metadataDeps(deps)
+		implicits = append(implicits, rustLibsToPaths(deps.RLibs)...)
+	}
+
 	rustdocFlags = append(rustdocFlags, makeLibFlags(deps)...)
+	buildScriptRustdocFlags, buildScriptImplicits := buildScriptFlags(deps)
+	rustdocFlags = append(rustdocFlags, buildScriptRustdocFlags...)
 	docTimestampFile := android.PathForModuleOut(ctx, "rustdoc.timestamp")
 
 	// Silence warnings about renamed lints for third-party crates
@@ -369,6 +626,7 @@ func Rustdoc(ctx ModuleContext, main android.Path, deps PathDeps,
 		Output:      docTimestampFile,
 		Input:       main,
 		Implicit:    ctx.RustModule().UnstrippedOutputFile(),
+		Implicits:   append(implicits, buildScriptImplicits...),
 		Args: map[string]string{
 			"rustdocFlags": strings.Join(rustdocFlags, " "),
 			"outDir":       docDir.String(),
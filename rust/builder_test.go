@@ -14,7 +14,11 @@
 
 package rust
 
-import "testing"
+import (
+	"testing"
+
+	"android/soong/android"
+)
 
 func TestSourceProviderCollision(t *testing.T) {
 	testRustError(t, "multiple source providers generate the same filename output: bindings.rs", `
@@ -40,3 +44,57 @@ func TestSourceProviderCollision(t *testing.T) {
 		}
 	`)
 }
+
+func TestPipelinedCompilation(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{"SOONG_RUST_PIPELINED_COMPILATION": "true"}),
+	).RunTestWithBp(t, `
+		rust_binary_host {
+			name: "fizz",
+			srcs: ["foo.rs"],
+			rustlibs: ["libfoo"],
+		}
+		rust_library_host_rlib {
+			name: "libfoo",
+			crate_name: "foo",
+			srcs: ["foo.rs"],
+			rustlibs: ["libbar"],
+		}
+		rust_library_host_rlib {
+			name: "libbar",
+			crate_name: "bar",
+			srcs: ["foo.rs"],
+		}
+	`)
+
+	variant := "linux_glibc_x86_64_rlib_rlib-std"
+	bar := result.ModuleForTests("libbar", variant)
+	barRlib := bar.Output("libbar.rlib").Output
+	barRustc := bar.Rule("rustc")
+	barMetadataFile := bar.Module().(*Module).metadataFile.Path()
+
+	// The metadata of libbar is emitted by the same invocation of rustc as its rlib.
+	android.AssertPathRelativeToTopEquals(t, "libbar metadata output", barRlib, bar.Output("meta/libbar.rmeta").Output)
+	android.AssertStringDoesContain(t, "libbar rustc flags", barRustc.Args["rustcFlags"], "-Z always-encode-mir")
+	android.AssertStringDoesContain(t, "libbar rustc flags", barRustc.Args["rustcFlags"],
+		"--emit metadata="+barMetadataFile.String())
+
+	// libfoo is compiled against the metadata of libbar.
+	foo := result.ModuleForTests("libfoo", variant)
+	fooRustc := foo.Rule("rustc")
+	fooRlib := fooRustc.Output
+	android.AssertStringDoesContain(t, "libfoo lib flags", fooRustc.Args["libFlags"], "--extern bar="+barMetadataFile.String())
+	android.AssertStringListContains(t, "libfoo implicits", fooRustc.Implicits.Strings(), barMetadataFile.String())
+	android.AssertStringListDoesNotContain(t, "libfoo implicits", fooRustc.Implicits.Strings(), barRlib.String())
+
+	// fizz is linked, so it is compiled against the rlib of libfoo and depends on the rlib of its
+	// transitive dependency libbar.
+	fizz := result.ModuleForTests("fizz", "linux_glibc_x86_64").Rule("rustc")
+	android.AssertStringDoesContain(t, "fizz lib flags", fizz.Args["libFlags"], "--extern foo="+fooRlib.String())
+	android.AssertStringDoesContain(t, "fizz lib flags", fizz.Args["libFlags"], "-L "+linkPathFromFilePath(barRlib))
+	android.AssertStringDoesNotContain(t, "fizz lib flags", fizz.Args["libFlags"], "-L "+linkPathFromFilePath(barMetadataFile))
+	android.AssertStringListContains(t, "fizz implicits", fizz.Implicits.Strings(), fooRlib.String())
+	android.AssertStringListContains(t, "fizz implicits", fizz.Implicits.Strings(), barRlib.String())
+	android.AssertStringDoesNotContain(t, "fizz rustc flags", fizz.Args["rustcFlags"], "-Z always-encode-mir")
+}
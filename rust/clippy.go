@@ -15,6 +15,7 @@
 package rust
 
 import (
+	"android/soong/android"
 	"android/soong/rust/config"
 )
 
@@ -27,6 +28,13 @@ type ClippyProperties struct {
 	// relaxed set) and "none" (to disable the execution of clippy).  The
 	// default value is "default". See also the `lints` property.
 	Clippy_lints *string
+
+	// JSON file of the known rustc and clippy warnings of this module. When set, the warnings of
+	// the module don't fail the build as long as they are listed in the baseline, and any other
+	// warning does. The lint report of the module, in
+	// $OUT/soong/.intermediates/<path>/<module>/<variant>/<output>.lint_report.json, can be copied
+	// to update the baseline once warnings have been fixed.
+	Lint_baseline *string `android:"path,arch_variant"`
 }
 
 type clippy struct {
@@ -44,5 +52,6 @@ func (c *clippy) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags, Pa
 	}
 	flags.Clippy = enabled
 	flags.ClippyFlags = append(flags.ClippyFlags, lints)
+	flags.LintBaseline = android.OptionalPathForModuleSrc(ctx, c.Properties.Lint_baseline)
 	return flags, deps
 }
@@ -172,6 +172,11 @@ type BaseCompilerProperties struct {
 
 	// If cargo_env_compat is true, sets the CARGO_PKG_VERSION env var to this value.
 	Cargo_pkg_version *string
+
+	// rust_build_script module to run before compiling this crate, like Cargo runs build.rs. The
+	// files it writes to OUT_DIR are available in the OUT_DIR of this crate, and the cfgs and
+	// environment variables it sets with cargo:rustc-cfg and cargo:rustc-env are passed to rustc.
+	Build_script *string
 }
 
 type baseCompiler struct {
@@ -296,7 +301,7 @@ func (compiler *baseCompiler) compilerFlags(ctx ModuleContext, flags Flags) Flag
 		}
 	}
 
-	flags.RustFlags = append(flags.RustFlags, lintFlags)
+	flags.LintFlags = append(flags.LintFlags, lintFlags)
 	flags.RustFlags = append(flags.RustFlags, compiler.Properties.Flags...)
 	flags.RustFlags = append(flags.RustFlags, "--edition="+compiler.edition())
 	flags.RustdocFlags = append(flags.RustdocFlags, "--edition="+compiler.edition())
@@ -335,7 +340,12 @@ func (compiler *baseCompiler) rustdoc(ctx ModuleContext, flags Flags,
 }
 
 func (compiler *baseCompiler) initialize(ctx ModuleContext) {
-	compiler.cargoOutDir = android.PathForModuleOut(ctx, genSubDir)
+	if compiler.Properties.Build_script != nil {
+		// The build script writes OUT_DIR in a sandbox that owns the whole directory.
+		compiler.cargoOutDir = android.PathForModuleOut(ctx, buildScriptSubDir, genSubDir)
+	} else {
+		compiler.cargoOutDir = android.PathForModuleOut(ctx, genSubDir)
+	}
 }
 
 func (compiler *baseCompiler) CargoOutDir() android.OptionalPath {
@@ -367,6 +377,7 @@ func (compiler *baseCompiler) compilerDeps(ctx DepsContext, deps Deps) Deps {
 	deps.WholeStaticLibs = append(deps.WholeStaticLibs, compiler.Properties.Whole_static_libs...)
 	deps.SharedLibs = append(deps.SharedLibs, compiler.Properties.Shared_libs...)
 	deps.Stdlibs = append(deps.Stdlibs, compiler.Properties.Stdlibs...)
+	deps.BuildScript = String(compiler.Properties.Build_script)
 
 	if !Bool(compiler.Properties.No_stdlibs) {
 		for _, stdlib := range config.Stdlibs {
@@ -110,3 +110,12 @@ func GetRustVersion(ctx android.PathContext) string {
 	}
 	return RustDefaultVersion
 }
+
+// RustPath returns the path of a file in the Rust prebuilts for the build host, like bin/rustc.
+func RustPath(ctx android.PathContext, file string) android.SourcePath {
+	base := RustDefaultBase
+	if override := ctx.Config().Getenv("RUST_PREBUILTS_BASE"); override != "" {
+		base = override
+	}
+	return android.PathForSource(ctx, base, ctx.Config().PrebuiltOS(), GetRustVersion(ctx), file)
+}
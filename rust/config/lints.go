@@ -116,6 +116,12 @@ const rustcAllowAll = "${config.RustAllowAllLints}"
 const clippyDefault = "${config.ClippyDefaultLints}"
 const clippyVendor = "${config.ClippyVendorLints}"
 
+// AllowsAllLints returns true if the rustc lint flags of a module allow all lints, in which case
+// it has no warnings to report.
+func AllowsAllLints(lintFlags []string) bool {
+	return android.InList(rustcAllowAll, lintFlags)
+}
+
 // lintConfig defines a set of lints and clippy configuration.
 type lintConfig struct {
 	rustcConfig   string // for the lints to apply to rustc.
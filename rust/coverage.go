@@ -16,7 +16,9 @@ package rust
 
 import (
 	"github.com/google/blueprint"
+	"github.com/google/blueprint/proptools"
 
+	"android/soong/android"
 	"android/soong/cc"
 )
 
@@ -25,6 +27,9 @@ var CovLibraryName = "libprofile-clang-extras"
 // Add '%c' to default specifier after we resolve http://b/210012154
 const profileInstrFlag = "-fprofile-instr-generate=/data/misc/trace/clang-%p-%m.profraw"
 
+// Host tests write their profiles to the path in LLVM_PROFILE_FILE, see cc/coverage_report.go.
+const hostProfileInstrFlag = "-fprofile-instr-generate"
+
 type coverage struct {
 	Properties cc.CoverageProperties
 
@@ -37,7 +42,7 @@ func (cov *coverage) props() []interface{} {
 }
 
 func (cov *coverage) deps(ctx DepsContext, deps Deps) Deps {
-	if cov.Properties.NeedCoverageVariant {
+	if cov.Properties.NeedCoverageVariant && ctx.Device() {
 		ctx.AddVariationDependencies([]blueprint.Variation{
 			{Mutator: "link", Variation: "static"},
 		}, cc.CoverageDepTag, CovLibraryName)
@@ -54,9 +59,14 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 
 	if cov.Properties.CoverageEnabled {
 		flags.Coverage = true
-		coverage := ctx.GetDirectDepWithTag(CovLibraryName, cc.CoverageDepTag).(cc.LinkableInterface)
 		flags.RustFlags = append(flags.RustFlags,
 			"-Z instrument-coverage", "-g")
+		if ctx.Host() {
+			flags.LinkFlags = append(flags.LinkFlags, hostProfileInstrFlag, "-g",
+				"${cc_config.ClangAsanLibDir}/"+cc.HostProfileRuntimeLibrary(ctx.RustModule().ccToolchain(ctx)))
+			return flags, deps
+		}
+		coverage := ctx.GetDirectDepWithTag(CovLibraryName, cc.CoverageDepTag).(cc.LinkableInterface)
 		flags.LinkFlags = append(flags.LinkFlags,
 			profileInstrFlag, "-g", coverage.OutputFile().Path().String(), "-Wl,--wrap,open")
 		deps.StaticLibs = append(deps.StaticLibs, coverage.OutputFile().Path())
@@ -71,9 +81,24 @@ func (cov *coverage) flags(ctx ModuleContext, flags Flags, deps PathDeps) (Flags
 
 func (cov *coverage) begin(ctx BaseModuleContext) {
 	if ctx.Host() {
-		// Host coverage not yet supported.
+		if cc.HostCoverageEnabled(ctx) {
+			cov.Properties = cc.SetCoverageProperties(ctx, cov.Properties, ctx.RustModule().nativeCoverage(), false, "")
+		}
 	} else {
 		// Update useSdk and sdkVersion args if Rust modules become SDK aware.
 		cov.Properties = cc.SetCoverageProperties(ctx, cov.Properties, ctx.RustModule().nativeCoverage(), false, "")
 	}
 }
+
+var _ cc.CoverageReportTest = (*Module)(nil)
+
+// CoverageReportTestBinary returns the test binary of host tests built with coverage for the native
+// coverage report.
+func (mod *Module) CoverageReportTestBinary() android.OptionalPath {
+	test, ok := mod.compiler.(*testDecorator)
+	if !ok || !mod.Host() || mod.coverage == nil || !mod.coverage.Properties.CoverageEnabled ||
+		proptools.Bool(test.Properties.Doctests) || mod.UnstrippedOutputFile() == nil {
+		return android.OptionalPath{}
+	}
+	return android.OptionalPathForPath(mod.UnstrippedOutputFile())
+}
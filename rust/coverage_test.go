@@ -18,6 +18,8 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 )
 
@@ -108,3 +110,34 @@ func TestCoverageDeps(t *testing.T) {
 		t.Fatalf("missing expected coverage 'libprofile-clang-extras' dependency in linkFlags: %#v", fizz.Args["linkFlags"])
 	}
 }
+
+// Test that host tests are built with coverage for the native coverage report.
+func TestHostCoverage(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		rustMockedFiles.AddToFixture(),
+		android.FixtureMergeEnv(map[string]string{"SOONG_HOST_NATIVE_COVERAGE": "true"}),
+		android.FixtureModifyProductVariables(
+			func(variables android.FixtureProductVariables) {
+				variables.ClangCoverage = proptools.BoolPtr(true)
+				variables.Native_coverage = proptools.BoolPtr(true)
+				variables.NativeCoveragePaths = []string{"*"}
+			},
+		),
+	).RunTestWithBp(t, `
+		rust_test_host {
+			name: "foo_test",
+			srcs: ["foo.rs"],
+		}`)
+
+	fooTest := result.ModuleForTests("foo_test", "linux_glibc_x86_64_cov")
+	rustc := fooTest.Rule("rustc")
+	android.AssertStringDoesContain(t, "rustc flags", rustc.Args["rustcFlags"], "-Z instrument-coverage")
+	android.AssertStringDoesContain(t, "link flags", rustc.Args["linkFlags"],
+		"-fprofile-instr-generate -g ${cc_config.ClangAsanLibDir}/libclang_rt.profile-x86_64.a")
+	android.AssertStringDoesNotContain(t, "link flags", rustc.Args["linkFlags"], "libprofile-clang-extras")
+
+	binary := fooTest.Module().(*Module).CoverageReportTestBinary()
+	android.AssertBoolEquals(t, "foo_test has a coverage report", true, binary.Valid())
+	android.AssertPathRelativeToTopEquals(t, "test binary", android.PathRelativeToTop(rustc.Output), binary.Path())
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"fmt"
+
+	"github.com/google/blueprint/proptools"
+
+	"android/soong/android"
+	"android/soong/cc"
+)
+
+var (
+	// The host tool that generates the C++ side of a cxx bridge.
+	cxxBridgeTool = "cxxbridge"
+
+	// The cxx crate, which provides the #[cxx::bridge] macro and links the C++ runtime of the
+	// bridge into Rust modules.
+	cxxBridgeRustlib = "libcxx"
+)
+
+func init() {
+	android.RegisterModuleType("rust_cxx_bridge", RustCxxBridgeFactory)
+	android.RegisterModuleType("rust_cxx_bridge_host", RustCxxBridgeHostFactory)
+}
+
+var _ SourceProvider = (*cxxBridgeDecorator)(nil)
+
+type CxxBridgeProperties struct {
+	// The Rust source file that contains the #[cxx::bridge] module. This field is required. It
+	// is not arch variant, as it is also the source of the <name>_cxx module that is created
+	// once defaults are applied, before arch variants are resolved.
+	Bridge_src *string `android:"path"`
+}
+
+type cxxBridgeDecorator struct {
+	*BaseSourceProvider
+
+	Properties CxxBridgeProperties
+}
+
+func (bridge *cxxBridgeDecorator) GenerateSource(ctx ModuleContext, deps PathDeps) android.Path {
+	bridgeSrc := android.OptionalPathForModuleSrc(ctx, bridge.Properties.Bridge_src)
+	if !bridgeSrc.Valid() {
+		ctx.PropertyErrorf("bridge_src", "invalid path to the cxx bridge source")
+		return nil
+	}
+
+	// The Rust side of the bridge is expanded by the cxx::bridge procedural macro, so the bridge
+	// source is the crate root of the generated library.
+	outputFile := android.PathForModuleOut(ctx, bridge.BaseSourceProvider.getStem(ctx)+".rs")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        android.Cp,
+		Description: "cxx bridge " + bridgeSrc.Path().Rel(),
+		Output:      outputFile,
+		Input:       bridgeSrc.Path(),
+	})
+
+	bridge.BaseSourceProvider.OutputFiles = android.Paths{outputFile}
+	return outputFile
+}
+
+func (bridge *cxxBridgeDecorator) SourceProviderProps() []interface{} {
+	return append(bridge.BaseSourceProvider.SourceProviderProps(), &bridge.Properties)
+}
+
+func (bridge *cxxBridgeDecorator) SourceProviderDeps(ctx DepsContext, deps Deps) Deps {
+	deps = bridge.BaseSourceProvider.SourceProviderDeps(ctx, deps)
+	deps.Rustlibs = append(deps.Rustlibs, cxxBridgeRustlib)
+	return deps
+}
+
+// createCxxBridgeGenrule creates the <name>_cxx module that runs cxxbridge to generate the C++
+// side of the bridge, along with the rust/cxx.h header that the generated code includes. It runs
+// after defaults are applied, so that the module is created from the final properties.
+func (bridge *cxxBridgeDecorator) createCxxBridgeGenrule(ctx android.DefaultableHookContext) {
+	if bridge.Properties.Bridge_src == nil {
+		ctx.PropertyErrorf("bridge_src", "bridge_src property is undefined but required for rust_cxx_bridge modules")
+		return
+	}
+	stem := String(bridge.BaseSourceProvider.Properties.Source_stem)
+	if stem == "" {
+		// GenerateSource reports the missing source_stem.
+		return
+	}
+
+	header := stem + ".rs.h"
+	source := stem + ".rs.cc"
+	runtimeHeader := "rust/cxx.h"
+
+	module := ctx.Module().(*Module)
+	props := struct {
+		Name               *string
+		Srcs               []string
+		Out                []string
+		Tools              []string
+		Cmd                *string
+		Host_supported     *bool
+		Device_supported   *bool
+		Vendor_available   *bool
+		Product_available  *bool
+		Recovery_available *bool
+	}{
+		Name:  proptools.StringPtr(ctx.ModuleName() + "_cxx"),
+		Srcs:  []string{*bridge.Properties.Bridge_src},
+		Out:   []string{header, source, runtimeHeader},
+		Tools: []string{cxxBridgeTool},
+		Cmd: proptools.StringPtr(fmt.Sprintf(
+			"$(location %[1]s) $(in) -o $(location %[2]s) -o $(location %[3]s) && "+
+				"$(location %[1]s) --header > $(location %[4]s)",
+			cxxBridgeTool, header, source, runtimeHeader)),
+		Host_supported:     proptools.BoolPtr(module.HostSupported()),
+		Device_supported:   proptools.BoolPtr(module.DeviceSupported()),
+		Vendor_available:   module.VendorProperties.Vendor_available,
+		Product_available:  module.VendorProperties.Product_available,
+		Recovery_available: module.Properties.Recovery_available,
+	}
+
+	ctx.CreateModule(cc.GenRuleFactory, &props)
+}
+
+// rust_cxx_bridge generates Rust/C++ interop code with cxx from a source file containing a
+// #[cxx::bridge] module. The Rust side is exposed as a crate that can be added to the rlibs, dylibs
+// and rustlibs properties of other modules, and links the cxx runtime. The C++ side is generated
+// by a <name>_cxx module that can be added to the generated_headers and generated_sources
+// properties of cc modules, which include the generated header as "<source_stem>.rs.h".
+func RustCxxBridgeFactory() android.Module {
+	module, _ := NewRustCxxBridge(android.HostAndDeviceSupported)
+	return module.Init()
+}
+
+// A host-only variant of rust_cxx_bridge. Refer to rust_cxx_bridge for more details.
+func RustCxxBridgeHostFactory() android.Module {
+	module, _ := NewRustCxxBridge(android.HostSupported)
+	return module.Init()
+}
+
+func NewRustCxxBridge(hod android.HostOrDeviceSupported) (*Module, *cxxBridgeDecorator) {
+	bridge := &cxxBridgeDecorator{
+		BaseSourceProvider: NewSourceProvider(),
+		Properties:         CxxBridgeProperties{},
+	}
+
+	module := NewSourceProviderModule(hod, bridge, false)
+	module.SetDefaultableHook(bridge.createCxxBridgeGenrule)
+
+	return module, bridge
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"strings"
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestRustCxxBridge(t *testing.T) {
+	ctx := testRust(t, `
+		rust_cxx_bridge {
+			name: "libbridge",
+			crate_name: "bridge",
+			source_stem: "bridge",
+			bridge_src: "src/bar.rs",
+		}
+		rust_binary_host {
+			name: "cxxbridge",
+			srcs: ["foo.rs"],
+		}
+		cc_library_static {
+			name: "libcpp",
+			srcs: ["foo.c"],
+			generated_headers: ["libbridge_cxx"],
+			generated_sources: ["libbridge_cxx"],
+		}
+	`)
+
+	// Check that the bridge source is the crate root and that libcxx is added as a dependency.
+	source := ctx.ModuleForTests("libbridge", "android_arm64_armv8-a_source").Output("bridge.rs")
+	android.AssertStringEquals(t, "bridge crate root", "src/bar.rs", source.Input.String())
+	libbridge := ctx.ModuleForTests("libbridge", "android_arm64_armv8-a_dylib").Module().(*Module)
+	if !android.InList("libcxx", libbridge.Properties.AndroidMkDylibs) {
+		t.Errorf("libcxx dependency missing for rust_cxx_bridge (dependency missing from AndroidMkDylibs)")
+	}
+
+	// Check that cxxbridge generates the C++ side of the bridge.
+	gen := ctx.ModuleForTests("libbridge_cxx", "android_arm64_armv8-a")
+	manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	cmd := *manifest.Commands[0].Command
+	for _, expected := range []string{
+		"src/bar.rs -o __SBOX_SANDBOX_DIR__/out/bridge.rs.h -o __SBOX_SANDBOX_DIR__/out/bridge.rs.cc",
+		"--header > __SBOX_SANDBOX_DIR__/out/rust/cxx.h",
+	} {
+		if !strings.Contains(cmd, expected) {
+			t.Errorf("expected %q in the cxxbridge command, got: %s", expected, cmd)
+		}
+	}
+
+	// Check that cc modules compile the generated source and see the generated headers.
+	genDir := "out/soong/.intermediates/libbridge_cxx/android_arm64_armv8-a/gen"
+	libcpp := ctx.ModuleForTests("libcpp", "android_arm64_armv8-a_static")
+	var cc android.TestingBuildParams
+	for _, output := range libcpp.AllOutputs() {
+		if strings.HasSuffix(output, "/bridge.rs.o") {
+			cc = libcpp.Output(output)
+		}
+	}
+	if cc.Rule == nil {
+		t.Fatalf("expected libcpp to compile the generated C++ source, outputs: %q", libcpp.AllOutputs())
+	}
+	android.AssertPathRelativeToTopEquals(t, "generated C++ source", genDir+"/bridge.rs.cc", cc.Input)
+	if !strings.Contains(cc.Args["cFlags"], "-I"+genDir) {
+		t.Errorf("expected %q in cflags, got: %s", "-I"+genDir, cc.Args["cFlags"])
+	}
+}
+
+func TestRustCxxBridgeDefaults(t *testing.T) {
+	ctx := testRust(t, `
+		rust_defaults {
+			name: "bridge_defaults",
+			source_stem: "bridge",
+			bridge_src: "src/bar.rs",
+			host_supported: true,
+			vendor_available: true,
+		}
+		rust_cxx_bridge {
+			name: "libbridge",
+			crate_name: "bridge",
+			defaults: ["bridge_defaults"],
+		}
+		rust_binary_host {
+			name: "cxxbridge",
+			srcs: ["foo.rs"],
+		}
+	`)
+
+	// Check that the <name>_cxx module is created from the properties set in the defaults.
+	gen := ctx.ModuleForTests("libbridge_cxx", "android_arm64_armv8-a")
+	manifest := android.RuleBuilderSboxProtoForTests(t, gen.Output("genrule.sbox.textproto"))
+	android.AssertStringDoesContain(t, "cxxbridge command", *manifest.Commands[0].Command,
+		"src/bar.rs -o __SBOX_SANDBOX_DIR__/out/bridge.rs.h")
+	ctx.ModuleForTests("libbridge_cxx", "linux_glibc_x86_64")
+	ctx.ModuleForTests("libbridge_cxx", "android_vendor.29_arm64_armv8-a")
+}
+
+func TestRustCxxBridgeErrors(t *testing.T) {
+	testRustError(t, "bridge_src: bridge_src property is undefined but required for rust_cxx_bridge modules", `
+		rust_cxx_bridge {
+			name: "libbridge",
+			crate_name: "bridge",
+			source_stem: "bridge",
+		}
+	`)
+}
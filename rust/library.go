@@ -15,10 +15,13 @@
 package rust
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
 	"strings"
 
+	"github.com/google/blueprint/proptools"
+
 	"android/soong/android"
 	"android/soong/cc"
 	"android/soong/snapshot"
@@ -60,6 +63,14 @@ type LibraryCompilerProperties struct {
 
 	// Whether this library is part of the Rust toolchain sysroot.
 	Sysroot *bool
+	// if set, creates a <name>_doctests rust_test_host module that runs the documentation tests
+	// of this library with rustdoc --test, using the same srcs, features, cfgs, rustlibs and
+	// proc_macros. Only rust libraries with host_supported: true can run documentation tests.
+	Doctests *bool
+
+	// list of compatibility suites the <name>_doctests module should be installed into.
+	// Defaults to general-tests.
+	Doctest_suites []string
 }
 
 type LibraryMutatedProperties struct {
@@ -89,6 +100,10 @@ type LibraryMutatedProperties struct {
 
 	// Whether this library variant should be link libstd via rlibs
 	VariantIsStaticStd bool `blueprint:"mutated"`
+
+	// The properties copied to the <name>_doctests module, encoded as JSON, used to check that
+	// the variants of the library don't override them per arch or target.
+	DoctestProperties string `blueprint:"mutated"`
 }
 
 type libraryDecorator struct {
@@ -412,10 +427,99 @@ func NewRustLibrary(hod android.HostOrDeviceSupported) (*Module, *libraryDecorat
 	}
 
 	module.compiler = library
+	module.SetDefaultableHook(library.createDoctests)
 
 	return module, library
 }
 
+// doctestProperties are the properties of a library that its <name>_doctests module is created
+// with.
+type doctestProperties struct {
+	Srcs         []string
+	Crate_name   string
+	Edition      *string
+	Features     []string
+	Cfgs         []string
+	Rustlibs     []string
+	Proc_macros  []string
+	Build_script *string
+}
+
+func newDoctestProperties(props BaseCompilerProperties) doctestProperties {
+	return doctestProperties{
+		Srcs:         android.CopyOf(props.Srcs),
+		Crate_name:   props.Crate_name,
+		Edition:      props.Edition,
+		Features:     android.CopyOf(props.Features),
+		Cfgs:         android.CopyOf(props.Cfgs),
+		Rustlibs:     android.CopyOf(props.Rustlibs),
+		Proc_macros:  android.CopyOf(props.Proc_macros),
+		Build_script: props.Build_script,
+	}
+}
+
+func (props doctestProperties) encode() string {
+	buf, err := json.Marshal(props)
+	if err != nil {
+		panic(err)
+	}
+	return string(buf)
+}
+
+// createDoctests creates a rust_test_host module that runs the documentation tests of the library
+// when doctests is set. It runs after defaults are applied, but before the properties of the arch
+// and target of the variants are, so the library can't override the properties of the doctests
+// per arch or target, which is checked by checkDoctestProperties.
+func (library *libraryDecorator) createDoctests(ctx android.DefaultableHookContext) {
+	if !Bool(library.Properties.Doctests) {
+		return
+	}
+
+	if !library.buildRlib() && !library.buildDylib() {
+		ctx.PropertyErrorf("doctests", "documentation tests are only supported for rust libraries")
+		return
+	}
+	if !ctx.Module().(*Module).HostSupported() {
+		ctx.PropertyErrorf("doctests", "documentation tests require host_supported: true")
+		return
+	}
+
+	testSuites := library.Properties.Doctest_suites
+	if len(testSuites) == 0 {
+		testSuites = []string{"general-tests"}
+	}
+
+	doctestProps := newDoctestProperties(library.baseCompiler.Properties)
+	library.MutatedProperties.DoctestProperties = doctestProps.encode()
+	doctestProps.Rustlibs = append(doctestProps.Rustlibs, ctx.ModuleName())
+
+	props := struct {
+		Name            *string
+		Doctests        *bool
+		Test_suites     []string
+		Auto_gen_config *bool
+	}{
+		Name:            proptools.StringPtr(ctx.ModuleName() + "_doctests"),
+		Doctests:        proptools.BoolPtr(true),
+		Test_suites:     testSuites,
+		Auto_gen_config: proptools.BoolPtr(true),
+	}
+
+	ctx.CreateModule(RustTestHostFactory, &props, &doctestProps)
+}
+
+// checkDoctestProperties reports an error if the properties the <name>_doctests module was
+// created with are overridden for the arch or target of a host variant of the library.
+func (library *libraryDecorator) checkDoctestProperties(ctx DepsContext) {
+	if library.MutatedProperties.DoctestProperties == "" || !ctx.Host() {
+		return
+	}
+	if newDoctestProperties(library.baseCompiler.Properties).encode() != library.MutatedProperties.DoctestProperties {
+		ctx.PropertyErrorf("doctests", "srcs, crate_name, edition, features, cfgs, rustlibs, proc_macros "+
+			"and build_script can't be set per arch or target in libraries with documentation tests")
+	}
+}
+
 func (library *libraryDecorator) compilerProps() []interface{} {
 	return append(library.baseCompiler.compilerProps(),
 		&library.Properties,
@@ -425,6 +529,7 @@ func (library *libraryDecorator) compilerProps() []interface{} {
 
 func (library *libraryDecorator) compilerDeps(ctx DepsContext, deps Deps) Deps {
 	deps = library.baseCompiler.compilerDeps(ctx, deps)
+	library.checkDoctestProperties(ctx)
 
 	if library.dylib() || library.shared() {
 		if ctx.toolchain().Bionic() {
@@ -536,6 +641,7 @@ func (library *libraryDecorator) compile(ctx ModuleContext, flags Flags, deps Pa
 	if library.rlib() || library.dylib() {
 		library.flagExporter.exportLinkDirs(deps.linkDirs...)
 		library.flagExporter.exportLinkObjects(deps.linkObjects...)
+		library.flagExporter.exportMetadata(deps.rlibLinkDirs, deps.metadataLinkDirs, deps.pipelinedRlibs)
 	}
 
 	if library.static() || library.shared() {
@@ -0,0 +1,107 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"github.com/google/blueprint"
+
+	"android/soong/android"
+)
+
+// Every Rust module gets a lint report listing the rustc and clippy warnings of its crate when
+// SOONG_RUST_LINT_REPORT is set, or when it has a lint_baseline. This singleton aggregates the
+// reports into $OUT/soong/rust_lints/report.json for the rust-lint-report goal:
+//
+//	SOONG_RUST_LINT_REPORT=true m rust-lint-report
+
+func init() {
+	android.RegisterSingletonType("rust_lint_report", rustLintReportSingletonFactory)
+	pctx.HostBinToolVariable("rustLintReportCmd", "rust_lint_report")
+}
+
+var (
+	rustLintReport = pctx.AndroidStaticRule("rustLintReport",
+		blueprint.RuleParams{
+			Command:     "$rustLintReportCmd check -module $module -variant $variant $baselineFlags -o $out $in",
+			CommandDeps: []string{"$rustLintReportCmd"},
+		},
+		"module", "variant", "baselineFlags")
+
+	rustLintReportMerge = pctx.AndroidStaticRule("rustLintReportMerge",
+		blueprint.RuleParams{
+			Command:        "$rustLintReportCmd merge -o $out @$out.rsp",
+			CommandDeps:    []string{"$rustLintReportCmd"},
+			Rspfile:        "$out.rsp",
+			RspfileContent: "$in",
+		})
+)
+
+func lintReportEnabled(config android.Config) bool {
+	return config.IsEnvTrue("SOONG_RUST_LINT_REPORT")
+}
+
+// TransformLintsToReport writes the lint report of a module from the JSON diagnostics of rustc or
+// clippy. With a baseline, the report fails to build if there are warnings that aren't in it.
+func TransformLintsToReport(ctx ModuleContext, diagnostics android.Path, baseline android.OptionalPath,
+	report android.WritablePath) {
+
+	var implicits android.Paths
+	var baselineFlags string
+	if baseline.Valid() {
+		implicits = append(implicits, baseline.Path())
+		baselineFlags = "-baseline " + baseline.Path().String()
+	}
+
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        rustLintReport,
+		Description: "lint report " + ctx.ModuleName(),
+		Output:      report,
+		Input:       diagnostics,
+		Implicits:   implicits,
+		Args: map[string]string{
+			"module":        ctx.ModuleName(),
+			"variant":       ctx.ModuleSubDir(),
+			"baselineFlags": baselineFlags,
+		},
+	})
+}
+
+func rustLintReportSingletonFactory() android.Singleton {
+	return &rustLintReportSingleton{}
+}
+
+type rustLintReportSingleton struct{}
+
+func (r *rustLintReportSingleton) GenerateBuildActions(ctx android.SingletonContext) {
+	if !lintReportEnabled(ctx.Config()) {
+		return
+	}
+
+	var reports android.Paths
+	ctx.VisitAllModules(func(module android.Module) {
+		if mod, ok := module.(*Module); ok && mod.Enabled() && mod.lintReportFile.Valid() {
+			reports = append(reports, mod.lintReportFile.Path())
+		}
+	})
+
+	report := android.PathForOutput(ctx, "rust_lints", "report.json")
+	ctx.Build(pctx, android.BuildParams{
+		Rule:        rustLintReportMerge,
+		Description: "rust lint report",
+		Output:      report,
+		Inputs:      reports,
+	})
+	ctx.Phony("rust-lint-report", report)
+}
@@ -0,0 +1,111 @@
+// Copyright 2022 The Android Open Source Project
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rust
+
+import (
+	"testing"
+
+	"android/soong/android"
+)
+
+func TestLintReport(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureMergeEnv(map[string]string{"SOONG_RUST_LINT_REPORT": "true"}),
+	).RunTestWithBp(t, `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+		}
+		rust_library {
+			name: "libbar",
+			srcs: ["foo.rs"],
+			crate_name: "bar",
+			clippy_lints: "none",
+		}
+		rust_library {
+			name: "libfoobar",
+			srcs: ["foo.rs"],
+			crate_name: "foobar",
+			lints: "none",
+		}
+	`)
+
+	variant := "android_arm64_armv8-a_dylib"
+	foo := result.ModuleForTests("libfoo", variant)
+	lints := foo.Rule("clippyLints")
+	android.AssertStringDoesContain(t, "libfoo lint flags", lints.Args["rustcFlags"], "${config.RustDefaultLints}")
+	android.AssertStringEquals(t, "libfoo clippy flags", "${config.ClippyDefaultLints}", lints.Args["clippyFlags"])
+	android.AssertStringDoesContain(t, "libfoo rustc flags", foo.Rule("rustc").Args["rustcFlags"], "${config.RustDefaultLints}")
+
+	fooReport := foo.Rule("rustLintReport")
+	android.AssertPathRelativeToTopEquals(t, "libfoo diagnostics", lints.Output.String(), fooReport.Input)
+	android.AssertStringEquals(t, "libfoo report module", "libfoo", fooReport.Args["module"])
+	android.AssertStringEquals(t, "libfoo report variant", variant, fooReport.Args["variant"])
+	android.AssertStringEquals(t, "libfoo report baseline", "", fooReport.Args["baselineFlags"])
+
+	// libbar doesn't run clippy, its rustc warnings are still reported.
+	bar := result.ModuleForTests("libbar", variant)
+	android.AssertStringDoesContain(t, "libbar lint flags", bar.Rule("rustcLints").Args["rustcFlags"], "${config.RustDefaultLints}")
+
+	// libfoobar allows all lints, so it has no warnings to report.
+	foobar := result.ModuleForTests("libfoobar", variant)
+	if r := foobar.MaybeRule("rustLintReport"); r.Rule != nil {
+		t.Errorf("libfoobar has a lint report when all lints are allowed")
+	}
+
+	report := result.SingletonForTests("rust_lint_report").Output("rust_lints/report.json")
+	reports := report.Inputs.Strings()
+	android.AssertStringListContains(t, "lint reports", reports, fooReport.Output.String())
+	android.AssertStringListContains(t, "lint reports", reports, bar.Rule("rustLintReport").Output.String())
+}
+
+func TestLintBaseline(t *testing.T) {
+	result := android.GroupFixturePreparers(
+		prepareForRustTest,
+		android.FixtureAddFile("foo/lints_baseline.json", nil),
+		android.FixtureAddTextFile("foo/Android.bp", `
+			rust_library {
+				name: "libfoo",
+				srcs: ["foo.rs"],
+				crate_name: "foo",
+				lint_baseline: "lints_baseline.json",
+			}
+		`),
+	).RunTest(t)
+
+	foo := result.ModuleForTests("libfoo", "android_arm64_armv8-a_dylib")
+
+	// The warnings of libfoo are checked against its baseline instead of failing its build.
+	lints := foo.Rule("clippyLints")
+	android.AssertStringDoesContain(t, "libfoo lint flags", lints.Args["rustcFlags"], "${config.RustDefaultLints}")
+	report := foo.Rule("rustLintReport")
+	android.AssertStringEquals(t, "libfoo report baseline", "-baseline foo/lints_baseline.json", report.Args["baselineFlags"])
+	android.AssertStringListContains(t, "libfoo report implicits", report.Implicits.Strings(), "foo/lints_baseline.json")
+
+	rustc := foo.Rule("rustc")
+	android.AssertStringDoesNotContain(t, "libfoo rustc flags", rustc.Args["rustcFlags"], "${config.RustDefaultLints}")
+	android.AssertStringDoesContain(t, "libfoo rustc flags", rustc.Args["rustcFlags"], "${config.RustAllowAllLints}")
+	android.AssertStringListContains(t, "libfoo rustc implicits", rustc.Implicits.Strings(), report.Output.String())
+	if r := foo.MaybeRule("clippy"); r.Rule != nil {
+		t.Errorf("libfoo runs clippy separately from its lint report")
+	}
+
+	// Without SOONG_RUST_LINT_REPORT, the reports aren't aggregated.
+	if r := result.SingletonForTests("rust_lint_report").MaybeOutput("rust_lints/report.json"); r.Rule != nil {
+		t.Errorf("the rust lint report is generated without SOONG_RUST_LINT_REPORT")
+	}
+}
@@ -61,9 +61,11 @@ type Flags struct {
 	LinkFlags       []string // Flags that apply to linker
 	ClippyFlags     []string // Flags that apply to clippy-driver, during the linting
 	RustdocFlags    []string // Flags that apply to rustdoc
+	LintFlags       []string // Flags that configure the rustc lints
 	Toolchain       config.Toolchain
 	Coverage        bool
 	Clippy          bool
+	LintBaseline    android.OptionalPath // Known warnings that don't fail the build
 }
 
 type BaseProperties struct {
@@ -163,6 +165,19 @@ type Module struct {
 
 	docTimestampFile android.OptionalPath
 
+	// The crates this module depends on directly and the unsafe_code diagnostics of its crate, only
+	// collected for the rust audit report.
+	auditDeps       []*Module
+	unsafeAuditFile android.OptionalPath
+
+	// The report of the rustc and clippy warnings of the crate, set when the lint report is enabled
+	// or the module has a lint baseline.
+	lintReportFile android.OptionalPath
+
+	// The metadata of the rlib of the crate, which dependent crates that aren't linked are compiled
+	// against when pipelined compilation is enabled.
+	metadataFile android.OptionalPath
+
 	hideApexVariantFromMake bool
 
 	// For apex variants, this is set as apex.min_sdk_version
@@ -410,6 +425,9 @@ type Deps struct {
 	DataBins []string
 
 	CrtBegin, CrtEnd []string
+
+	// rust_build_script module run before compiling the crate.
+	BuildScript string
 }
 
 type PathDeps struct {
@@ -430,6 +448,13 @@ type PathDeps struct {
 	linkDirs    []string
 	linkObjects []string
 
+	// rlibLinkDirs and metadataLinkDirs are the directories of the transitive rlib dependencies that
+	// have metadata and of their metadata, which replace them in linkDirs for crates that aren't
+	// linked. pipelinedRlibs are those rlibs, which crates that are linked depend on.
+	rlibLinkDirs     []string
+	metadataLinkDirs []string
+	pipelinedRlibs   android.Paths
+
 	// Used by bindgen modules which call clang
 	depClangFlags         []string
 	depIncludePaths       android.Paths
@@ -442,6 +467,10 @@ type PathDeps struct {
 	// Paths to generated source files
 	SrcDeps          android.Paths
 	srcProviderFiles android.Paths
+
+	// The build script of the crate, and the outputs of running it for the crate.
+	buildScript        *Module
+	buildScriptOutputs buildScriptDeps
 }
 
 type RustLibraries []RustLibrary
@@ -449,6 +478,8 @@ type RustLibraries []RustLibrary
 type RustLibrary struct {
 	Path      android.Path
 	CrateName string
+	// The metadata of an rlib, set when pipelined compilation is enabled.
+	Metadata android.OptionalPath
 }
 
 type compiler interface {
@@ -459,6 +490,7 @@ type compiler interface {
 	compilerProps() []interface{}
 	compile(ctx ModuleContext, flags Flags, deps PathDeps) android.Path
 	compilerDeps(ctx DepsContext, deps Deps) Deps
+	runBuildScript(ctx ModuleContext, buildScript *Module) buildScriptDeps
 	crateName() string
 	rustdoc(ctx ModuleContext, flags Flags, deps PathDeps) android.OptionalPath
 
@@ -491,11 +523,16 @@ type compiler interface {
 type exportedFlagsProducer interface {
 	exportLinkDirs(...string)
 	exportLinkObjects(...string)
+	exportMetadata(rlibDirs, metadataDirs []string, rlibs android.Paths)
 }
 
 type flagExporter struct {
 	linkDirs    []string
 	linkObjects []string
+
+	rlibLinkDirs     []string
+	metadataLinkDirs []string
+	pipelinedRlibs   android.Paths
 }
 
 func (flagExporter *flagExporter) exportLinkDirs(dirs ...string) {
@@ -506,10 +543,21 @@ func (flagExporter *flagExporter) exportLinkObjects(flags ...string) {
 	flagExporter.linkObjects = android.FirstUniqueStrings(append(flagExporter.linkObjects, flags...))
 }
 
+// exportMetadata exports rlibs that have metadata with their directories and the directories of
+// their metadata, for pipelined compilation.
+func (flagExporter *flagExporter) exportMetadata(rlibDirs, metadataDirs []string, rlibs android.Paths) {
+	flagExporter.rlibLinkDirs = android.FirstUniqueStrings(append(flagExporter.rlibLinkDirs, rlibDirs...))
+	flagExporter.metadataLinkDirs = android.FirstUniqueStrings(append(flagExporter.metadataLinkDirs, metadataDirs...))
+	flagExporter.pipelinedRlibs = android.FirstUniquePaths(append(flagExporter.pipelinedRlibs, rlibs...))
+}
+
 func (flagExporter *flagExporter) setProvider(ctx ModuleContext) {
 	ctx.SetProvider(FlagExporterInfoProvider, FlagExporterInfo{
-		LinkDirs:    flagExporter.linkDirs,
-		LinkObjects: flagExporter.linkObjects,
+		LinkDirs:         flagExporter.linkDirs,
+		LinkObjects:      flagExporter.linkObjects,
+		RlibLinkDirs:     flagExporter.rlibLinkDirs,
+		MetadataLinkDirs: flagExporter.metadataLinkDirs,
+		PipelinedRlibs:   flagExporter.pipelinedRlibs,
 	})
 }
 
@@ -523,6 +571,12 @@ type FlagExporterInfo struct {
 	Flags       []string
 	LinkDirs    []string // TODO: this should be android.Paths
 	LinkObjects []string // TODO: this should be android.Paths
+
+	// The transitive rlib dependencies that have metadata, their directories and the directories
+	// of their metadata, for pipelined compilation.
+	RlibLinkDirs     []string
+	MetadataLinkDirs []string
+	PipelinedRlibs   android.Paths
 }
 
 var FlagExporterInfoProvider = blueprint.NewProvider(FlagExporterInfo{})
@@ -572,6 +626,7 @@ func DefaultsFactory(props ...interface{}) android.Module {
 		&cc.VendorProperties{},
 		&BenchmarkProperties{},
 		&BindgenProperties{},
+		&CxxBridgeProperties{},
 		&BaseCompilerProperties{},
 		&BinaryCompilerProperties{},
 		&LibraryCompilerProperties{},
@@ -904,6 +959,9 @@ func (mod *Module) GenerateAndroidBuildActions(actx android.ModuleContext) {
 
 	if mod.compiler != nil && !mod.compiler.Disabled() {
 		mod.compiler.initialize(ctx)
+		if deps.buildScript != nil {
+			deps.buildScriptOutputs = mod.compiler.runBuildScript(ctx, deps.buildScript)
+		}
 		outputFile := mod.compiler.compile(ctx, flags, deps)
 		if ctx.Failed() {
 			return
@@ -1007,6 +1065,7 @@ var _ android.LicenseAnnotationsDependencyTag = dependencyTag{}
 
 var (
 	customBindgenDepTag = dependencyTag{name: "customBindgenTag"}
+	buildScriptDepTag   = dependencyTag{name: "buildScript"}
 	rlibDepTag          = dependencyTag{name: "rlibTag", library: true}
 	dylibDepTag         = dependencyTag{name: "dylib", library: true, dynamic: true}
 	procMacroDepTag     = dependencyTag{name: "procMacro", procMacro: true}
@@ -1112,6 +1171,12 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 			case procMacroDepTag:
 				directProcMacroDeps = append(directProcMacroDeps, rustDep)
 				mod.Properties.AndroidMkProcMacroLibs = append(mod.Properties.AndroidMkProcMacroLibs, makeLibName)
+			case buildScriptDepTag:
+				if _, ok := rustDep.compiler.(*buildScriptDecorator); !ok {
+					ctx.PropertyErrorf("build_script", "%q is not a rust_build_script module", depName)
+					return
+				}
+				depPaths.buildScript = rustDep
 			}
 
 			if android.IsSourceDepTagWithOutputTag(depTag, "") {
@@ -1140,6 +1205,9 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				depPaths.linkDirs = append(depPaths.linkDirs, exportedInfo.LinkDirs...)
 				depPaths.depFlags = append(depPaths.depFlags, exportedInfo.Flags...)
 				depPaths.linkObjects = append(depPaths.linkObjects, exportedInfo.LinkObjects...)
+				depPaths.rlibLinkDirs = append(depPaths.rlibLinkDirs, exportedInfo.RlibLinkDirs...)
+				depPaths.metadataLinkDirs = append(depPaths.metadataLinkDirs, exportedInfo.MetadataLinkDirs...)
+				depPaths.pipelinedRlibs = append(depPaths.pipelinedRlibs, exportedInfo.PipelinedRlibs...)
 			}
 
 			if depTag == dylibDepTag || depTag == rlibDepTag || depTag == procMacroDepTag {
@@ -1147,6 +1215,10 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 				linkDir := linkPathFromFilePath(linkFile)
 				if lib, ok := mod.compiler.(exportedFlagsProducer); ok {
 					lib.exportLinkDirs(linkDir)
+					if rustDep.metadataFile.Valid() {
+						lib.exportMetadata([]string{linkDir},
+							[]string{linkPathFromFilePath(rustDep.metadataFile.Path())}, android.Paths{linkFile})
+					}
 				}
 			}
 
@@ -1261,9 +1333,16 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 		}
 	})
 
+	if auditEnabled(ctx.Config()) {
+		mod.auditDeps = append(mod.auditDeps, directRlibDeps...)
+		mod.auditDeps = append(mod.auditDeps, directDylibDeps...)
+		mod.auditDeps = append(mod.auditDeps, directProcMacroDeps...)
+	}
+
 	var rlibDepFiles RustLibraries
 	for _, dep := range directRlibDeps {
-		rlibDepFiles = append(rlibDepFiles, RustLibrary{Path: dep.UnstrippedOutputFile(), CrateName: dep.CrateName()})
+		rlibDepFiles = append(rlibDepFiles, RustLibrary{Path: dep.UnstrippedOutputFile(), CrateName: dep.CrateName(),
+			Metadata: dep.metadataFile})
 	}
 	var dylibDepFiles RustLibraries
 	for _, dep := range directDylibDeps {
@@ -1311,6 +1390,9 @@ func (mod *Module) depsToPaths(ctx android.ModuleContext) PathDeps {
 	// Dedup exported flags from dependencies
 	depPaths.linkDirs = android.FirstUniqueStrings(depPaths.linkDirs)
 	depPaths.linkObjects = android.FirstUniqueStrings(depPaths.linkObjects)
+	depPaths.rlibLinkDirs = android.FirstUniqueStrings(depPaths.rlibLinkDirs)
+	depPaths.metadataLinkDirs = android.FirstUniqueStrings(depPaths.metadataLinkDirs)
+	depPaths.pipelinedRlibs = android.FirstUniquePaths(depPaths.pipelinedRlibs)
 	depPaths.depFlags = android.FirstUniqueStrings(depPaths.depFlags)
 	depPaths.depClangFlags = android.FirstUniqueStrings(depPaths.depClangFlags)
 	depPaths.depIncludePaths = android.FirstUniquePaths(depPaths.depIncludePaths)
@@ -1475,6 +1557,12 @@ func (mod *Module) DepsMutator(actx android.BottomUpMutatorContext) {
 
 	actx.AddVariationDependencies(nil, dataBinDepTag, deps.DataBins...)
 
+	// build scripts run on the host before the crate is compiled.
+	if deps.BuildScript != "" {
+		actx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), buildScriptDepTag,
+			deps.BuildScript)
+	}
+
 	// proc_macros are compiler plugins, and so we need the host arch variant as a dependendcy.
 	actx.AddFarVariationDependencies(ctx.Config().BuildOSTarget.Variations(), procMacroDepTag, deps.ProcMacros...)
 }
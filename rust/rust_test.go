@@ -47,6 +47,7 @@ var prepareForRustTest = android.GroupFixturePreparers(
 
 var rustMockedFiles = android.MockFS{
 	"foo.rs":                       nil,
+	"build.rs":                     nil,
 	"foo.c":                        nil,
 	"src/bar.rs":                   nil,
 	"src/any.h":                    nil,
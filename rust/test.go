@@ -70,6 +70,12 @@ type TestProperties struct {
 	// Add RootTargetPreparer to auto generated test config. This guarantees the test to run
 	// with root permission.
 	Require_root *bool
+
+	// if set, runs the documentation tests of the crate rooted at srcs instead of building its
+	// #[test] functions. The crate itself must be listed in rustlibs. Documentation tests are
+	// compiled with rustdoc --test when the test is built, and run when the test is run.
+	// Documentation tests marked should_panic are not supported. Only supported for host tests.
+	Doctests *bool
 }
 
 // A test module is a binary module with extra --test compiler flag
@@ -187,6 +193,29 @@ func (test *testDecorator) install(ctx ModuleContext) {
 	test.binaryDecorator.install(ctx)
 }
 
+func (test *testDecorator) compile(ctx ModuleContext, flags Flags, deps PathDeps) android.Path {
+	if !Bool(test.Properties.Doctests) {
+		return test.binaryDecorator.compile(ctx, flags, deps)
+	}
+
+	if !ctx.Host() {
+		ctx.PropertyErrorf("doctests", "documentation tests are only supported for host tests")
+		return nil
+	}
+
+	flags.RustdocFlags = append(flags.RustdocFlags, deps.depFlags...)
+	flags.LinkFlags = append(flags.LinkFlags, deps.depLinkFlags...)
+	flags.LinkFlags = append(flags.LinkFlags, deps.linkObjects...)
+
+	srcPath, _ := srcPathFromModuleSrcs(ctx, test.baseCompiler.Properties.Srcs)
+	outputFile := android.PathForModuleOut(ctx, test.getStem(ctx))
+	doctests := RustdocTest(ctx, srcPath, deps, flags, outputFile)
+	test.data = append(test.data, android.DataPath{SrcPath: doctests})
+	test.baseCompiler.unstrippedOutputFile = outputFile
+
+	return outputFile
+}
+
 func (test *testDecorator) compilerFlags(ctx ModuleContext, flags Flags) Flags {
 	flags = test.binaryDecorator.compilerFlags(ctx, flags)
 	if test.testHarness() {
@@ -200,3 +200,118 @@ func TestDataLibsRelativeInstallPath(t *testing.T) {
 			" but was '%s'", entries.EntryMap["LOCAL_TEST_DATA"][2])
 	}
 }
+
+func TestRustDoctests(t *testing.T) {
+	ctx := testRust(t, `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			features: ["some-feature"],
+			rustlibs: ["libbar"],
+			doctests: true,
+			host_supported: true,
+		}
+		rust_library {
+			name: "libbar",
+			srcs: ["foo.rs"],
+			crate_name: "bar",
+			host_supported: true,
+		}`)
+
+	doctests := ctx.ModuleForTests("libfoo_doctests", "linux_glibc_x86_64")
+	rustdoc := doctests.Rule("rustdocTest")
+	android.AssertPathRelativeToTopEquals(t, "doctest binaries",
+		"out/soong/.intermediates/libfoo_doctests/linux_glibc_x86_64/libfoo_doctests.doctests.zip", rustdoc.Output)
+
+	// The documentation tests are run when the test is run, by a runner that is installed with the
+	// zip file of their binaries.
+	runner := doctests.Output("libfoo_doctests")
+	android.AssertPathRelativeToTopEquals(t, "doctest runner", "build/soong/scripts/rust_doctest_runner.sh", runner.Input)
+	test := doctests.Module().(*Module).compiler.(*testDecorator)
+	android.AssertPathsRelativeToTopEquals(t, "doctest data",
+		[]string{"out/soong/.intermediates/libfoo_doctests/linux_glibc_x86_64/libfoo_doctests.doctests.zip"},
+		android.Paths{test.dataPaths()[0].SrcPath})
+	for _, expected := range []string{"--crate-name foo", "--cfg 'feature=\"some-feature\"'"} {
+		if !strings.Contains(rustdoc.Args["rustdocFlags"], expected) {
+			t.Errorf("expected %q in rustdoc flags, got: %s", expected, rustdoc.Args["rustdocFlags"])
+		}
+	}
+	for _, expected := range []string{"--extern foo=", "--extern bar="} {
+		if !strings.Contains(rustdoc.Args["libFlags"], expected) {
+			t.Errorf("expected %q in rustdoc lib flags, got: %s", expected, rustdoc.Args["libFlags"])
+		}
+	}
+
+	android.AssertDeepEquals(t, "doctest suites", []string{"general-tests"}, test.Properties.Test_suites)
+}
+
+func TestRustDoctestsDefaults(t *testing.T) {
+	ctx := testRust(t, `
+		rust_defaults {
+			name: "foo_defaults",
+			srcs: ["foo.rs"],
+			features: ["some-feature"],
+			rustlibs: ["libbar"],
+			host_supported: true,
+		}
+		rust_library {
+			name: "libfoo",
+			crate_name: "foo",
+			defaults: ["foo_defaults"],
+			doctests: true,
+		}
+		rust_library {
+			name: "libbar",
+			srcs: ["foo.rs"],
+			crate_name: "bar",
+			host_supported: true,
+		}`)
+
+	// The doctests are created from the properties of the library after its defaults are applied.
+	rustdoc := ctx.ModuleForTests("libfoo_doctests", "linux_glibc_x86_64").Rule("rustdocTest")
+	android.AssertPathRelativeToTopEquals(t, "doctest source", "foo.rs", rustdoc.Input)
+	android.AssertStringDoesContain(t, "rustdoc flags", rustdoc.Args["rustdocFlags"], "--cfg 'feature=\"some-feature\"'")
+	android.AssertStringDoesContain(t, "rustdoc lib flags", rustdoc.Args["libFlags"], "--extern bar=")
+}
+
+func TestRustDoctestsErrors(t *testing.T) {
+	testRustError(t, "doctests: documentation tests require host_supported: true", `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			doctests: true,
+		}`)
+
+	testRustError(t, "doctests: documentation tests are only supported for rust libraries", `
+		rust_ffi_static {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			doctests: true,
+			host_supported: true,
+		}`)
+
+	testRustError(t, "doctests: srcs, crate_name, edition, features, cfgs, rustlibs, proc_macros and build_script "+
+		"can't be set per arch or target in libraries with documentation tests", `
+		rust_library {
+			name: "libfoo",
+			srcs: ["foo.rs"],
+			crate_name: "foo",
+			doctests: true,
+			host_supported: true,
+			target: {
+				host: {
+					features: ["host-feature"],
+				},
+			},
+		}`)
+
+	testRustError(t, "doctests: documentation tests are only supported for host tests", `
+		rust_test {
+			name: "foo_doctests",
+			srcs: ["foo.rs"],
+			doctests: true,
+		}`)
+}
@@ -131,6 +131,12 @@ func GatherRequiredDepsForTest() string {
 			srcs: ["foo.rs"],
 			host_supported: true,
 		}
+		rust_library {
+			name: "libcxx",
+			crate_name: "cxx",
+			srcs: ["foo.rs"],
+			host_supported: true,
+		}
 		rust_library {
 			name: "libgrpcio",
 			crate_name: "grpcio",
@@ -166,6 +172,9 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 	ctx.RegisterModuleType("rust_binary_host", RustBinaryHostFactory)
 	ctx.RegisterModuleType("rust_bindgen", RustBindgenFactory)
 	ctx.RegisterModuleType("rust_bindgen_host", RustBindgenHostFactory)
+	ctx.RegisterModuleType("rust_build_script", RustBuildScriptFactory)
+	ctx.RegisterModuleType("rust_cxx_bridge", RustCxxBridgeFactory)
+	ctx.RegisterModuleType("rust_cxx_bridge_host", RustCxxBridgeHostFactory)
 	ctx.RegisterModuleType("rust_test", RustTestFactory)
 	ctx.RegisterModuleType("rust_test_host", RustTestHostFactory)
 	ctx.RegisterModuleType("rust_library", RustLibraryFactory)
@@ -194,6 +203,8 @@ func registerRequiredBuildComponentsForTest(ctx android.RegistrationContext) {
 		ctx.BottomUp("rust_begin", BeginMutator).Parallel()
 	})
 	ctx.RegisterSingletonType("rust_project_generator", rustProjectGeneratorSingleton)
+	ctx.RegisterSingletonType("rust_audit", rustAuditSingletonFactory)
+	ctx.RegisterSingletonType("rust_lint_report", rustLintReportSingletonFactory)
 	ctx.PostDepsMutators(func(ctx android.RegisterMutatorsContext) {
 		ctx.BottomUp("rust_sanitizers", rustSanitizerRuntimeMutator).Parallel()
 	})
@@ -0,0 +1,78 @@
+#!/bin/bash
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Runs the binaries of the Rust documentation tests in the <test>.doctests.zip file next to this
+# script, which are collected by rustdoc_collect_doctest.sh when the test is built, and reports
+# their results in the format of the Rust test harness. --list lists the tests without running them.
+
+zip="$0.doctests.zip"
+if [ ! -f "${zip}" ]; then
+  echo "error: ${zip} not found" >&2
+  exit 1
+fi
+
+dir=$(mktemp -d) || exit 1
+trap 'rm -rf "${dir}"' EXIT
+# unzip exits with 1 on warnings, like an empty zip file when the crate has no documentation tests.
+unzip -qo "${zip}" -d "${dir}"
+if [ $? -gt 1 ]; then
+  exit 1
+fi
+tests=$(cd "${dir}" && ls | sort)
+
+for arg in "$@"; do
+  if [ "${arg}" == "--list" ]; then
+    for test in ${tests}; do
+      echo "${test}: test"
+    done
+    exit 0
+  fi
+done
+
+echo
+echo "running $(echo ${tests} | wc -w) tests"
+passed=0
+failed=0
+failures=""
+for test in ${tests}; do
+  if output=$("${dir}/${test}" 2>&1); then
+    echo "test ${test} ... ok"
+    passed=$((passed + 1))
+  else
+    echo "test ${test} ... FAILED"
+    failed=$((failed + 1))
+    failures="${failures}${test} "
+    printf -- "---- %s stdout ----\n%s\n\n" "${test}" "${output}" >> "${dir}/.failures"
+  fi
+done
+
+result="ok"
+if [ ${failed} -gt 0 ]; then
+  result="FAILED"
+  echo
+  echo "failures:"
+  echo
+  cat "${dir}/.failures"
+  echo "failures:"
+  for test in ${failures}; do
+    echo "    ${test}"
+  done
+fi
+
+echo
+echo "test result: ${result}. ${passed} passed; ${failed} failed; 0 ignored; 0 measured; 0 filtered out"
+echo
+[ ${failed} -eq 0 ]
@@ -0,0 +1,28 @@
+#!/bin/bash -e
+
+# Copyright 2022 Google Inc. All rights reserved.
+#
+# Licensed under the Apache License, Version 2.0 (the "License");
+# you may not use this file except in compliance with the License.
+# You may obtain a copy of the License at
+#
+#     http://www.apache.org/licenses/LICENSE-2.0
+#
+# Unless required by applicable law or agreed to in writing, software
+# distributed under the License is distributed on an "AS IS" BASIS,
+# WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+# See the License for the specific language governing permissions and
+# limitations under the License.
+
+# Used as the --runtool of rustdoc --test to collect the binaries of the documentation tests that
+# rustdoc would run into DOCTEST_OUT_DIR instead of running them, so that they are run by
+# rust_doctest_runner.sh when the test is run. rustdoc compiles each documentation test in its own
+# directory of --persist-doctests, named after the source file and line of the test, and passes the
+# path of the test binary as the only argument.
+
+if [ -z "${DOCTEST_OUT_DIR}" ] || [ $# -ne 1 ]; then
+  echo "usage: DOCTEST_OUT_DIR=<dir> $0 <doctest binary>" >&2
+  exit 1
+fi
+
+cp "$1" "${DOCTEST_OUT_DIR}/$(basename "$(dirname "$1")")"